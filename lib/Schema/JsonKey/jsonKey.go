@@ -29,7 +29,10 @@ const (
 	AdditionalProperties = "additionalProperties"
 	ArchivedSchemaIdDiv  = "__"
 	Array                = "array"
+	Attribute            = "attribute"
+	Boolean              = "boolean"
 	ContentMediaType     = "contentMediaType"
+	Default              = "default"
 	Definitions          = "definitions"
 	DefinitionPrefix     = "#/definitions/"
 	DocRoot              = "#"
@@ -39,12 +42,16 @@ const (
 	Key                  = "key"
 	Name                 = "name"
 	Map                  = "map"
+	Number               = "number"
 	Object               = "object"
 	Properties           = "properties"
 	Ref                  = "$ref"
 	Required             = "required"
+	Retention            = "retention"
 	Schema               = "schema"
+	SoftDelete           = "softDelete"
 	String               = "string"
+	Ttl                  = "ttl"
 	Integer              = "integer"
 	Type                 = "type"
 	Version              = "version"
@@ -26,6 +26,8 @@ This copyright notice and license applies to all files in this directory or sub-
 package Record
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -42,6 +44,9 @@ const (
 	KeyRecord = "record"
 	NotRecord = "No-Record-Framework"
 	Version   = "__ver"
+	Revision  = "__rev"
+	Deleted   = "__deleted"
+	Expire    = "__expire"
 	Schema    = `{
 		"__id": "record",
 		"__type": "schema",
@@ -60,6 +65,18 @@ const (
 				"__ver": {
 					"type": "string"         
 				},
+				"__rev": {
+					"type": "integer",
+					"required": false
+				},
+				"__deleted": {
+					"type": "string",
+					"required": false
+				},
+				"__expire": {
+					"type": "string",
+					"required": false
+				},
 				"data": {
 					"type": "object"
 				}
@@ -68,11 +85,17 @@ const (
 	}`
 )
 
+// Revision increase on every change of the record, 0 means record is saved before revision introduced.
+// Deleted is the RFC3339 time the record is soft deleted, record is a tombstone when it is not empty.
+// Expire is the RFC3339 time the record is deleted automatically, empty means never
 type Record struct {
-	Id      string                 `json:"__id"`
-	Type    string                 `json:"__type"`
-	Version string                 `json:"__ver"`
-	Data    map[string]interface{} `json:"data"`
+	Id       string                 `json:"__id"`
+	Type     string                 `json:"__type"`
+	Version  string                 `json:"__ver"`
+	Revision int64                  `json:"__rev,omitempty"`
+	Deleted  string                 `json:"__deleted,omitempty"`
+	Expire   string                 `json:"__expire,omitempty"`
+	Data     map[string]interface{} `json:"data"`
 }
 
 func IsRecord(data map[string]interface{}) bool {
@@ -194,3 +217,10 @@ func (rec *Record) Map() map[string]interface{} {
 	json.Unmarshal([]byte(*rec.Raw()), &data)
 	return data
 }
+
+// ETag of record from its canonical JSON content, quoted as in HTTP header
+func (rec *Record) ETag() string {
+	recordBytes, _ := json.Marshal(rec)
+	sum := sha256.Sum256(recordBytes)
+	return fmt.Sprintf("\"%s\"", hex.EncodeToString(sum[:16]))
+}
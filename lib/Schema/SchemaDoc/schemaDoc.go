@@ -32,6 +32,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
 	"github.com/salesforce/UniTAO/lib/Util"
@@ -220,6 +221,69 @@ func (d *SchemaDoc) Properties() map[string]interface{} {
 	return d.Data[JsonKey.Properties].(map[string]interface{})
 }
 
+// SoftDelete return whether records are kept as tombstone on delete, and how long tombstones are kept before purged.
+// retention 0 means tombstones are kept forever
+func (d *SchemaDoc) SoftDelete() (bool, time.Duration, error) {
+	config, ok := d.Data[JsonKey.SoftDelete]
+	if !ok {
+		return false, 0, nil
+	}
+	configMap, ok := config.(map[string]interface{})
+	if !ok {
+		return false, 0, fmt.Errorf("invalid [%s] on schema [%s], expect object", JsonKey.SoftDelete, d.Id)
+	}
+	retention, ok := configMap[JsonKey.Retention]
+	if !ok {
+		return true, 0, nil
+	}
+	retentionStr, ok := retention.(string)
+	if !ok {
+		return false, 0, fmt.Errorf("invalid [%s/%s] on schema [%s], expect duration string", JsonKey.SoftDelete, JsonKey.Retention, d.Id)
+	}
+	duration, err := time.ParseDuration(retentionStr)
+	if err != nil || duration < 0 {
+		return false, 0, fmt.Errorf("invalid [%s/%s]=[%s] on schema [%s], expect non-negative duration, ex: 720h", JsonKey.SoftDelete, JsonKey.Retention, retentionStr, d.Id)
+	}
+	return true, duration, nil
+}
+
+// Ttl return the string attribute holding RFC3339 expiry time of record, and default time to live of record
+// when the attribute is not set. both are empty when records never expire
+func (d *SchemaDoc) Ttl() (string, time.Duration, error) {
+	config, ok := d.Data[JsonKey.Ttl]
+	if !ok {
+		return "", 0, nil
+	}
+	configMap, ok := config.(map[string]interface{})
+	if !ok {
+		return "", 0, fmt.Errorf("invalid [%s] on schema [%s], expect object", JsonKey.Ttl, d.Id)
+	}
+	attrName := ""
+	if attr, ok := configMap[JsonKey.Attribute]; ok {
+		attrName, ok = attr.(string)
+		if !ok {
+			return "", 0, fmt.Errorf("invalid [%s/%s] on schema [%s], expect attribute name", JsonKey.Ttl, JsonKey.Attribute, d.Id)
+		}
+		attrDef, ok := d.Properties()[attrName].(map[string]interface{})
+		if !ok || attrDef[JsonKey.Type] != JsonKey.String {
+			return "", 0, fmt.Errorf("invalid [%s/%s]=[%s] on schema [%s], expect string attribute of schema", JsonKey.Ttl, JsonKey.Attribute, attrName, d.Id)
+		}
+	}
+	var duration time.Duration
+	if ttl, ok := configMap[JsonKey.Default]; ok {
+		ttlStr, ok := ttl.(string)
+		if !ok {
+			return "", 0, fmt.Errorf("invalid [%s/%s] on schema [%s], expect duration string", JsonKey.Ttl, JsonKey.Default, d.Id)
+		}
+		var err error
+		duration, err = time.ParseDuration(ttlStr)
+		if err != nil || duration <= 0 {
+			return "", 0, fmt.Errorf("invalid [%s/%s]=[%s] on schema [%s], expect positive duration, ex: 24h", JsonKey.Ttl, JsonKey.Default, ttlStr, d.Id)
+		}
+	}
+	return attrName, duration, nil
+}
+
 func (d *SchemaDoc) preprocess() error {
 	err := d.processRequired()
 	if err != nil {
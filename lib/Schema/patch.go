@@ -0,0 +1,484 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package Schema
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/SchemaDoc"
+	"github.com/salesforce/UniTAO/lib/Util"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+	"github.com/salesforce/UniTAO/lib/Util/Json"
+)
+
+// operations of RFC 6902 JSON Patch
+const (
+	PatchOp    = "op"
+	PatchPath  = "path"
+	PatchValue = "value"
+	PatchFrom  = "from"
+
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+	OpMove    = "move"
+	OpCopy    = "copy"
+	OpTest    = "test"
+)
+
+// PointerToPath translate JSON pointer to path of SetDataOnPath,
+// segment after an array or map attribute is the key of item, ex: /attr1/key1/attr2 => attr1[key1]/attr2
+// "-" after an array attribute address the end of array
+func PointerToPath(schema *SchemaDoc.SchemaDoc, pointer string) (string, *Http.HttpError) {
+	if pointer == "" || !strings.HasPrefix(pointer, "/") {
+		return "", Http.NewHttpError(fmt.Sprintf("invalid pointer [%s], expect path to attribute, ex: /attr", pointer), http.StatusBadRequest)
+	}
+	segments := strings.Split(pointer[1:], "/")
+	for idx, segment := range segments {
+		segments[idx] = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+	}
+	pathList := []string{}
+	doc := schema
+	idx := 0
+	for idx < len(segments) {
+		if doc == nil {
+			return "", Http.NewHttpError(fmt.Sprintf("invalid pointer [%s], cannot walk in @[%s]", pointer, strings.Join(pathList, "/")), http.StatusBadRequest)
+		}
+		attrName := segments[idx]
+		idx++
+		attrDef, ok := doc.Data[JsonKey.Properties].(map[string]interface{})[attrName].(map[string]interface{})
+		if !ok {
+			return "", Http.NewHttpError(fmt.Sprintf("invalid pointer [%s], attr=[%s] not defined", pointer, attrName), http.StatusBadRequest)
+		}
+		attrPath := attrName
+		attrType, _ := attrDef[JsonKey.Type].(string)
+		isMap := attrType == JsonKey.Object && SchemaDoc.IsMap(attrDef)
+		switch {
+		case attrType == JsonKey.Array || isMap:
+			nextDoc := doc.SubDocs[attrName]
+			doc = nil
+			if idx == len(segments) {
+				break
+			}
+			key := segments[idx]
+			idx++
+			if key == "-" && attrType == JsonKey.Array {
+				if idx < len(segments) {
+					return "", Http.NewHttpError(fmt.Sprintf("invalid pointer [%s], [-] should be the last", pointer), http.StatusBadRequest)
+				}
+				break
+			}
+			attrPath = fmt.Sprintf("%s[%s]", attrName, key)
+			doc = nextDoc
+		case attrType == JsonKey.Object:
+			doc = doc.SubDocs[attrName]
+		default:
+			doc = nil
+		}
+		pathList = append(pathList, attrPath)
+	}
+	return strings.Join(pathList, "/"), nil
+}
+
+// JsonPatchPaths return paths of SetDataOnPath changed or read by each operation of JSON Patch
+func JsonPatchPaths(schema *SchemaDoc.SchemaDoc, patch interface{}) ([]string, *Http.HttpError) {
+	opList, ok := patch.([]interface{})
+	if !ok {
+		return nil, Http.NewHttpError("invalid JSON patch, expect array of operations", http.StatusBadRequest)
+	}
+	pathList := []string{}
+	for idx, opData := range opList {
+		op, ok := opData.(map[string]interface{})
+		if !ok {
+			return nil, Http.NewHttpError(fmt.Sprintf("invalid JSON patch operation @[%d], expect object", idx), http.StatusBadRequest)
+		}
+		for _, key := range []string{PatchPath, PatchFrom} {
+			pointer, ok := op[key].(string)
+			if !ok {
+				continue
+			}
+			dataPath, err := PointerToPath(schema, pointer)
+			if err != nil {
+				return nil, err
+			}
+			pathList = append(pathList, dataPath)
+		}
+	}
+	return pathList, nil
+}
+
+// MergePatchPaths return paths of SetDataOnPath of each value in JSON Merge Patch,
+// nested object is walked into its attributes, or items by key of array and map
+func MergePatchPaths(schema *SchemaDoc.SchemaDoc, patch interface{}) ([]string, *Http.HttpError) {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return nil, Http.NewHttpError("invalid merge patch, expect object", http.StatusBadRequest)
+	}
+	pathList := []string{}
+	err := mergePaths(schema, "", patchMap, &pathList)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(pathList)
+	return pathList, nil
+}
+
+func mergePaths(doc *SchemaDoc.SchemaDoc, pathPrefix string, patch map[string]interface{}, pathList *[]string) *Http.HttpError {
+	for attrName, value := range patch {
+		attrDef, ok := doc.Data[JsonKey.Properties].(map[string]interface{})[attrName].(map[string]interface{})
+		if !ok {
+			return Http.NewHttpError(fmt.Sprintf("attr=[%s] not defined at path=[%s]", attrName, pathPrefix), http.StatusBadRequest)
+		}
+		attrPath := pathPrefix + attrName
+		valueMap, isObject := value.(map[string]interface{})
+		if !isObject || len(valueMap) == 0 {
+			*pathList = append(*pathList, attrPath)
+			continue
+		}
+		attrType, _ := attrDef[JsonKey.Type].(string)
+		subDoc := doc.SubDocs[attrName]
+		switch {
+		case attrType == JsonKey.Array || SchemaDoc.IsMap(attrDef):
+			for key, item := range valueMap {
+				itemPath := fmt.Sprintf("%s[%s]", attrPath, key)
+				itemMap, isObject := item.(map[string]interface{})
+				if !isObject || len(itemMap) == 0 || subDoc == nil {
+					*pathList = append(*pathList, itemPath)
+					continue
+				}
+				err := mergePaths(subDoc, itemPath+"/", itemMap, pathList)
+				if err != nil {
+					return err
+				}
+			}
+		case attrType == JsonKey.Object && subDoc != nil:
+			err := mergePaths(subDoc, attrPath+"/", valueMap, pathList)
+			if err != nil {
+				return err
+			}
+		default:
+			*pathList = append(*pathList, attrPath)
+		}
+	}
+	return nil
+}
+
+// GetDataOnPath return value on path of SetDataOnPath, StatusNotFound when path does not exists
+func GetDataOnPath(schema *SchemaDoc.SchemaDoc, data map[string]interface{}, dataPath string, prevPath string) (interface{}, *Http.HttpError) {
+	attrPath, nextPath := Util.ParsePath(dataPath)
+	attrName, key, err := Util.ParseArrayPath(attrPath)
+	if err != nil {
+		return nil, Http.NewHttpError(fmt.Sprintf("failed to parse attrPath=[%s] @path=[%s]", attrPath, prevPath), http.StatusBadRequest)
+	}
+	attrDef, ok := schema.Data[JsonKey.Properties].(map[string]interface{})[attrName].(map[string]interface{})
+	if !ok {
+		return nil, Http.NewHttpError(fmt.Sprintf("attr=[%s] not defined at path=[%s]", attrName, prevPath), http.StatusBadRequest)
+	}
+	value, ok := data[attrName]
+	if !ok {
+		return nil, Http.NewHttpError(fmt.Sprintf("path=[%s/%s] does not exists", prevPath, attrName), http.StatusNotFound)
+	}
+	subDoc := schema.SubDocs[attrName]
+	if key != "" {
+		value, err = getItem(subDoc, attrDef, value, key)
+		if err != nil {
+			return nil, Http.WrapError(err, fmt.Sprintf("failed to get item @path=[%s/%s]", prevPath, attrPath), err.(*Http.HttpError).Status)
+		}
+	}
+	if nextPath == "" {
+		return value, nil
+	}
+	mapData, ok := value.(map[string]interface{})
+	if !ok || subDoc == nil {
+		return nil, Http.NewHttpError(fmt.Sprintf("invalid path, cannot walk in. @path=[%s/%s]", prevPath, attrPath), http.StatusBadRequest)
+	}
+	return GetDataOnPath(subDoc, mapData, nextPath, fmt.Sprintf("%s/%s", prevPath, attrPath))
+}
+
+// item of array or map by key, same as SetDataOnPath, item of simple array is addressed by index or value
+func getItem(subDoc *SchemaDoc.SchemaDoc, attrDef map[string]interface{}, value interface{}, key string) (interface{}, error) {
+	switch attrDef[JsonKey.Type] {
+	case JsonKey.Array:
+		itemList, _ := value.([]interface{})
+		itemType, _ := attrDef[JsonKey.Items].(map[string]interface{})[JsonKey.Type].(string)
+		if itemType != JsonKey.Object {
+			if idx, ex := strconv.Atoi(key); ex == nil {
+				if idx >= 0 && idx < len(itemList) {
+					return itemList[idx], nil
+				}
+				return nil, Http.NewHttpError(fmt.Sprintf("idx=[%d] out of range", idx), http.StatusNotFound)
+			}
+			for _, item := range itemList {
+				if item == key {
+					return item, nil
+				}
+			}
+			return nil, Http.NewHttpError(fmt.Sprintf("key=[%s] not found", key), http.StatusNotFound)
+		}
+		for idx, item := range itemList {
+			itemKey, ex := subDoc.BuildKey(item.(map[string]interface{}))
+			if ex != nil {
+				return nil, Http.WrapError(ex, fmt.Sprintf("failed to get key @[%d]", idx), http.StatusInternalServerError)
+			}
+			if itemKey == key {
+				return item, nil
+			}
+		}
+		return nil, Http.NewHttpError(fmt.Sprintf("key=[%s] not found", key), http.StatusNotFound)
+	case JsonKey.Object:
+		if !SchemaDoc.IsMap(attrDef) {
+			return nil, Http.NewHttpError(fmt.Sprintf("data is not map to drill in with key=[%s]", key), http.StatusBadRequest)
+		}
+		item, ok := value.(map[string]interface{})[key]
+		if !ok {
+			return nil, Http.NewHttpError(fmt.Sprintf("key=[%s] not found", key), http.StatusNotFound)
+		}
+		return item, nil
+	default:
+		return nil, Http.NewHttpError(fmt.Sprintf("type=[%s] has no key", attrDef[JsonKey.Type]), http.StatusBadRequest)
+	}
+}
+
+// ApplyJsonPatch apply RFC 6902 operations on data in order through SetDataOnPath,
+// add and replace set the value the same way as SetDataOnPath. StatusConflict when an operation cannot apply
+func ApplyJsonPatch(schema *SchemaDoc.SchemaDoc, data map[string]interface{}, patch interface{}, prevPath string) *Http.HttpError {
+	opList, ok := patch.([]interface{})
+	if !ok {
+		return Http.NewHttpError("invalid JSON patch, expect array of operations", http.StatusBadRequest)
+	}
+	for idx, opData := range opList {
+		op, ok := opData.(map[string]interface{})
+		if !ok {
+			return Http.NewHttpError(fmt.Sprintf("invalid JSON patch operation @[%d], expect object", idx), http.StatusBadRequest)
+		}
+		err := applyPatchOp(schema, data, op, prevPath)
+		if err != nil {
+			err.Context = append(err.Context, fmt.Sprintf("JSON patch operation @[%d]", idx))
+			return err
+		}
+	}
+	return nil
+}
+
+func applyPatchOp(schema *SchemaDoc.SchemaDoc, data map[string]interface{}, op map[string]interface{}, prevPath string) *Http.HttpError {
+	opName, _ := op[PatchOp].(string)
+	pointer, _ := op[PatchPath].(string)
+	dataPath, err := PointerToPath(schema, pointer)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(pointer, "/-") && opName != OpAdd && opName != OpCopy && opName != OpMove {
+		return Http.NewHttpError(fmt.Sprintf("[%s] on [%s], [-] only address new item", opName, pointer), http.StatusBadRequest)
+	}
+	value, hasValue := op[PatchValue]
+	switch opName {
+	case OpAdd, OpReplace, OpTest:
+		if !hasValue || value == nil {
+			return Http.NewHttpError(fmt.Sprintf("[%s] on [%s] expect not null [%s]", opName, pointer, PatchValue), http.StatusBadRequest)
+		}
+	case OpMove, OpCopy:
+		from, _ := op[PatchFrom].(string)
+		fromPath, err := PointerToPath(schema, from)
+		if err != nil {
+			return err
+		}
+		value, err = getPatchTarget(schema, data, fromPath, prevPath)
+		if err != nil {
+			return err
+		}
+		value, _ = Json.Copy(value)
+		if opName == OpMove {
+			err = setPatchValue(schema, data, fromPath, prevPath, nil)
+			if err != nil {
+				return err
+			}
+		}
+	case OpRemove:
+	default:
+		return Http.NewHttpError(fmt.Sprintf("invalid JSON patch op=[%s]", opName), http.StatusBadRequest)
+	}
+	switch opName {
+	case OpTest:
+		current, err := getPatchTarget(schema, data, dataPath, prevPath)
+		if err != nil {
+			return err
+		}
+		current, _ = Json.Copy(current)
+		value, _ = Json.Copy(value)
+		if !reflect.DeepEqual(current, value) {
+			return Http.NewHttpError(fmt.Sprintf("test failed, value of [%s] does not match", pointer), http.StatusConflict)
+		}
+		return nil
+	case OpRemove:
+		_, err := getPatchTarget(schema, data, dataPath, prevPath)
+		if err != nil {
+			return err
+		}
+		return setPatchValue(schema, data, dataPath, prevPath, nil)
+	case OpReplace:
+		_, err := getPatchTarget(schema, data, dataPath, prevPath)
+		if err != nil {
+			return err
+		}
+	}
+	return setPatchValue(schema, data, dataPath, prevPath, value)
+}
+
+// target of operation must exists
+func getPatchTarget(schema *SchemaDoc.SchemaDoc, data map[string]interface{}, dataPath string, prevPath string) (interface{}, *Http.HttpError) {
+	value, err := GetDataOnPath(schema, data, dataPath, prevPath)
+	if err != nil && err.Status == http.StatusNotFound {
+		return nil, Http.WrapError(err, fmt.Sprintf("path [%s] does not exists", dataPath), http.StatusConflict)
+	}
+	return value, err
+}
+
+// SetDataOnPath, value already set is not an error
+func setPatchValue(schema *SchemaDoc.SchemaDoc, data map[string]interface{}, dataPath string, prevPath string, value interface{}) *Http.HttpError {
+	err := SetDataOnPath(schema, data, dataPath, prevPath, value)
+	if err != nil && err.Status != http.StatusNotModified {
+		return err
+	}
+	return nil
+}
+
+// ApplyMergePatch apply RFC 7396 merge patch on data through SetDataOnPath, null remove the attribute.
+// items of map, and of keyed array when patch value is an object, are merged by key. array value replace whole array
+func ApplyMergePatch(schema *SchemaDoc.SchemaDoc, data map[string]interface{}, patch interface{}, prevPath string) *Http.HttpError {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return Http.NewHttpError("invalid merge patch, expect object", http.StatusBadRequest)
+	}
+	return mergeOnPath(schema, schema, data, "", patchMap, prevPath)
+}
+
+// merge patch into data @pathPrefix of root data, doc is schema of data @pathPrefix
+func mergeOnPath(root *SchemaDoc.SchemaDoc, doc *SchemaDoc.SchemaDoc, data map[string]interface{}, pathPrefix string, patch map[string]interface{}, prevPath string) *Http.HttpError {
+	attrList := make([]string, 0, len(patch))
+	for attrName := range patch {
+		attrList = append(attrList, attrName)
+	}
+	sort.Strings(attrList)
+	for _, attrName := range attrList {
+		value := patch[attrName]
+		attrDef, ok := doc.Data[JsonKey.Properties].(map[string]interface{})[attrName].(map[string]interface{})
+		if !ok {
+			return Http.NewHttpError(fmt.Sprintf("attr=[%s] not defined at path=[%s/%s]", attrName, prevPath, pathPrefix), http.StatusBadRequest)
+		}
+		attrPath := pathPrefix + attrName
+		valueMap, isObject := value.(map[string]interface{})
+		if !isObject {
+			err := mergeValue(root, data, attrPath, prevPath, value)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		current, err := GetDataOnPath(root, data, attrPath, prevPath)
+		if err != nil && err.Status != http.StatusNotFound {
+			return err
+		}
+		attrType, _ := attrDef[JsonKey.Type].(string)
+		subDoc := doc.SubDocs[attrName]
+		switch {
+		case current == nil:
+			err = mergeValue(root, data, attrPath, prevPath, removeNulls(value))
+		case attrType == JsonKey.Array || SchemaDoc.IsMap(attrDef):
+			err = mergeItems(root, subDoc, data, attrPath, valueMap, prevPath)
+		case attrType == JsonKey.Object && subDoc != nil:
+			err = mergeOnPath(root, subDoc, data, attrPath+"/", valueMap, prevPath)
+		default:
+			err = mergeValue(root, data, attrPath, prevPath, removeNulls(value))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// merge items of map or keyed array @attrPath by key
+func mergeItems(root *SchemaDoc.SchemaDoc, itemDoc *SchemaDoc.SchemaDoc, data map[string]interface{}, attrPath string, patch map[string]interface{}, prevPath string) *Http.HttpError {
+	keyList := make([]string, 0, len(patch))
+	for key := range patch {
+		keyList = append(keyList, key)
+	}
+	sort.Strings(keyList)
+	for _, key := range keyList {
+		itemPath := fmt.Sprintf("%s[%s]", attrPath, key)
+		itemPatch, isObject := patch[key].(map[string]interface{})
+		if isObject && itemDoc != nil {
+			current, err := GetDataOnPath(root, data, itemPath, prevPath)
+			if err != nil && err.Status != http.StatusNotFound {
+				return err
+			}
+			if current != nil {
+				err = mergeOnPath(root, itemDoc, data, itemPath+"/", itemPatch, prevPath)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		err := mergeValue(root, data, itemPath, prevPath, removeNulls(patch[key]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mergeValue(root *SchemaDoc.SchemaDoc, data map[string]interface{}, dataPath string, prevPath string, value interface{}) *Http.HttpError {
+	if value == nil {
+		_, err := GetDataOnPath(root, data, dataPath, prevPath)
+		if err != nil && err.Status == http.StatusNotFound {
+			return nil
+		}
+	}
+	return setPatchValue(root, data, dataPath, prevPath, value)
+}
+
+// null in new object of merge patch means nothing
+func removeNulls(value interface{}) interface{} {
+	valueMap, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	result := map[string]interface{}{}
+	for key, item := range valueMap {
+		if item == nil {
+			continue
+		}
+		result[key] = removeNulls(item)
+	}
+	return result
+}
@@ -452,7 +452,11 @@ func setAttrData(schema *SchemaDoc.SchemaDoc, data map[string]interface{}, attrP
 			data[attrName] = newData
 		} else {
 			itemType := attrDef[JsonKey.Items].(map[string]interface{})[JsonKey.Type].(string)
-			attrData := data[attrName].(map[string]interface{})
+			attrData, ok := data[attrName].(map[string]interface{})
+			if !ok {
+				attrData = map[string]interface{}{}
+				data[attrName] = attrData
+			}
 			if key == "" {
 				newKey, ex := getHashKey(schema, itemType, newData, attrName)
 				if ex != nil {
@@ -0,0 +1,133 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package SchemaPath
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/salesforce/UniTAO/lib/Schema"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Schema/SchemaDoc"
+	"github.com/salesforce/UniTAO/lib/SchemaPath/Data"
+	"github.com/salesforce/UniTAO/lib/SchemaPath/Node"
+	"github.com/salesforce/UniTAO/lib/Util"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+// Target is a record reached by SchemaPath, Path is attribute path in data of the record, empty for the whole record
+type Target struct {
+	DataType string
+	DataId   string
+	Path     string
+	Schema   *SchemaDoc.SchemaDoc
+}
+
+// Targets resolve SchemaPath {dataType}/{dataId}/{path} to targets it covers as a prefix:
+// records at the end of path as a whole, and attribute of each reference walked through,
+// ex: dc/DC1/racks[*] cover attribute racks[{rack}] of DC1 and every rack record in it
+func Targets(conn *Data.Connection, schemaPath string) ([]*Target, *Http.HttpError) {
+	dataType, idPath := Util.ParsePath(schemaPath)
+	dataId, dataPath := Util.ParsePath(idPath)
+	node, err := BuildNodePath(conn, dataType, dataId, dataPath)
+	if err != nil {
+		return nil, err
+	}
+	targets := []*Target{}
+	collectTargets(node, node, "", &targets)
+	return targets, nil
+}
+
+func collectTargets(record *Node.PathNode, node *Node.PathNode, path string, targets *[]*Target) {
+	if len(node.Next) == 0 {
+		*targets = append(*targets, newTarget(record, path))
+		return
+	}
+	for _, next := range node.Next {
+		if next.IsRecord() {
+			*targets = append(*targets, newTarget(record, path))
+			collectTargets(next, next, "", targets)
+			continue
+		}
+		nextPath := path + next.Id
+		if next.AttrName != "" && path != "" {
+			nextPath = fmt.Sprintf("%s/%s", path, next.AttrName)
+		}
+		collectTargets(record, next, nextPath, targets)
+	}
+}
+
+func newTarget(record *Node.PathNode, path string) *Target {
+	return &Target{
+		DataType: record.DataType,
+		DataId:   record.DataId,
+		Path:     path,
+		Schema:   record.Schema,
+	}
+}
+
+func (t *Target) String() string {
+	if t.Path == "" {
+		return fmt.Sprintf("%s/%s", t.DataType, t.DataId)
+	}
+	return fmt.Sprintf("%s/%s/%s", t.DataType, t.DataId, t.Path)
+}
+
+// Changed return true when change from before to after record is on record and path of target
+func (t *Target) Changed(dataType string, dataId string, before map[string]interface{}, after map[string]interface{}) bool {
+	if dataType != t.DataType || dataId != t.DataId {
+		return false
+	}
+	if t.Path == "" {
+		return true
+	}
+	// compare in JSON, numbers could be of different types in before and after
+	beforeValue, _ := json.Marshal(t.valueOnPath(before))
+	afterValue, _ := json.Marshal(t.valueOnPath(after))
+	return string(beforeValue) != string(afterValue)
+}
+
+func (t *Target) valueOnPath(record map[string]interface{}) interface{} {
+	data, ok := record[Record.Data].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	value, err := Schema.GetDataOnPath(t.Schema, data, t.Path, "")
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+// MatchTargets return true when change is on any of targets
+func MatchTargets(targets []*Target, dataType string, dataId string, before map[string]interface{}, after map[string]interface{}) bool {
+	for _, target := range targets {
+		if target.Changed(dataType, dataId, before, after) {
+			return true
+		}
+	}
+	return false
+}
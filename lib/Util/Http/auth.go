@@ -0,0 +1,531 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package Http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderAuthorization   = "Authorization"
+	HeaderWwwAuthenticate = "WWW-Authenticate"
+	SchemeBearer          = "Bearer"
+	// authentication methods, also value of AuthType in referral of data service
+	AuthToken = "token"
+	AuthHmac  = "hmac"
+	AuthMtls  = "mtls"
+	// divider of keyId, claims and signature in HMAC signed token
+	HmacTokenDiv = "."
+)
+
+type identityKey struct{}
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	Name   string   `json:"name"`
+	Method string   `json:"method"`
+	Roles  []string `json:"roles"`
+}
+
+// Authenticator returns nil identity and nil error when request does not carry its kind of credential,
+// StatusUnauthorized when it carries an invalid one
+type Authenticator interface {
+	Name() string
+	Authenticate(r *http.Request) (*Identity, *HttpError)
+}
+
+// AuthConfig under "auth" of http config. no authenticator configured means authentication is off
+type AuthConfig struct {
+	Tokens []TokenConfig `json:"tokens"`
+	Hmac   *HmacConfig   `json:"hmac"`
+	Mtls   *MtlsConfig   `json:"mtls"`
+	// methods allowed without credential, ex: ["GET"]
+	Anonymous []string `json:"anonymous"`
+	// credential of this service when calling peers
+	Peer *Credential `json:"peer"`
+}
+
+type TokenConfig struct {
+	Name  string   `json:"name"`
+	Token string   `json:"token"`
+	Roles []string `json:"roles"`
+}
+
+// HmacConfig hold secrets of signed tokens by keyId
+type HmacConfig struct {
+	Keys map[string]string `json:"keys"`
+}
+
+// MtlsConfig map common name of verified client certificate to roles, any verified client is accepted if empty
+type MtlsConfig struct {
+	Clients map[string][]string `json:"clients"`
+}
+
+// TlsConfig under "tls" of http config, server listen on https when CertFile is set.
+// client certificates signed by ClientCaFile are verified for mtls
+type TlsConfig struct {
+	CertFile     string `json:"certFile"`
+	KeyFile      string `json:"keyFile"`
+	ClientCaFile string `json:"clientCaFile"`
+}
+
+type AuthHandler struct {
+	authenticators []Authenticator
+	anonymous      map[string]bool
+}
+
+func NewAuthHandler(cfg AuthConfig) (*AuthHandler, *HttpError) {
+	a := AuthHandler{
+		authenticators: []Authenticator{},
+		anonymous:      map[string]bool{},
+	}
+	for _, method := range cfg.Anonymous {
+		a.anonymous[strings.ToUpper(method)] = true
+	}
+	if len(cfg.Tokens) > 0 {
+		auth, err := NewTokenAuth(cfg.Tokens)
+		if err != nil {
+			return nil, err
+		}
+		a.Register(auth)
+	}
+	if cfg.Hmac != nil {
+		auth, err := NewHmacAuth(*cfg.Hmac)
+		if err != nil {
+			return nil, err
+		}
+		a.Register(auth)
+	}
+	if cfg.Mtls != nil {
+		a.Register(NewMtlsAuth(*cfg.Mtls))
+	}
+	return &a, nil
+}
+
+// Register add custom Authenticator, tried in order of registration
+func (a *AuthHandler) Register(auth Authenticator) {
+	a.authenticators = append(a.authenticators, auth)
+}
+
+func (a *AuthHandler) Enabled() bool {
+	return len(a.authenticators) > 0
+}
+
+// Authenticate return identity of request from first authenticator recognize the credential
+func (a *AuthHandler) Authenticate(r *http.Request) (*Identity, *HttpError) {
+	for _, auth := range a.authenticators {
+		identity, err := auth.Authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+		if identity != nil {
+			return identity, nil
+		}
+	}
+	if BearerToken(r) != "" {
+		return nil, NewHttpError("invalid bearer token", http.StatusUnauthorized)
+	}
+	if a.anonymous[r.Method] {
+		return nil, nil
+	}
+	return nil, NewHttpError(fmt.Sprintf("authentication required for [%s] on [%s]", r.Method, r.URL.Path), http.StatusUnauthorized)
+}
+
+// Middleware reject unauthenticated requests with StatusUnauthorized, identity of the caller is in the request context
+func (a *AuthHandler) Middleware(next http.HandlerFunc, httpCfg Config) http.HandlerFunc {
+	if !a.Enabled() {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set(HeaderWwwAuthenticate, SchemeBearer)
+			ResponseJson(w, err, err.Status, httpCfg)
+			return
+		}
+		if identity != nil {
+			r = WithIdentity(r, identity)
+		}
+		next(w, r)
+	}
+}
+
+func WithIdentity(r *http.Request, identity *Identity) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), identityKey{}, identity))
+}
+
+// GetIdentity return nil on anonymous request or when authentication is off
+func GetIdentity(r *http.Request) *Identity {
+	identity, _ := r.Context().Value(identityKey{}).(*Identity)
+	return identity
+}
+
+func BearerToken(r *http.Request) string {
+	auth := r.Header.Get(HeaderAuthorization)
+	prefix := SchemeBearer + " "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(auth[len(prefix):])
+}
+
+// TokenAuth check static bearer tokens, tokens are kept as sha256 hash
+type TokenAuth struct {
+	tokens map[string]*Identity
+}
+
+func NewTokenAuth(tokens []TokenConfig) (*TokenAuth, *HttpError) {
+	auth := TokenAuth{
+		tokens: map[string]*Identity{},
+	}
+	for _, token := range tokens {
+		if token.Token == "" || token.Name == "" {
+			return nil, NewHttpError("invalid auth token config, [name] and [token] are required", http.StatusBadRequest)
+		}
+		auth.tokens[hashToken(token.Token)] = &Identity{
+			Name:   token.Name,
+			Method: AuthToken,
+			Roles:  token.Roles,
+		}
+	}
+	return &auth, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *TokenAuth) Name() string {
+	return AuthToken
+}
+
+func (a *TokenAuth) Authenticate(r *http.Request) (*Identity, *HttpError) {
+	token := BearerToken(r)
+	if token == "" {
+		return nil, nil
+	}
+	identity, ok := a.tokens[hashToken(token)]
+	if !ok {
+		// could be a signed token for next authenticator
+		return nil, nil
+	}
+	return identity, nil
+}
+
+type HmacClaims struct {
+	Subject string   `json:"sub"`
+	Roles   []string `json:"roles"`
+	Expire  int64    `json:"exp"`
+}
+
+// HmacAuth check bearer tokens of {keyId}.{base64 claims}.{base64 HMAC-SHA256 of keyId and claims}
+type HmacAuth struct {
+	keys map[string]string
+	Now  func() time.Time
+}
+
+func NewHmacAuth(cfg HmacConfig) (*HmacAuth, *HttpError) {
+	if len(cfg.Keys) == 0 {
+		return nil, NewHttpError("invalid hmac auth config, [keys] is empty", http.StatusBadRequest)
+	}
+	return &HmacAuth{
+		keys: cfg.Keys,
+		Now:  time.Now,
+	}, nil
+}
+
+func (a *HmacAuth) Name() string {
+	return AuthHmac
+}
+
+func (a *HmacAuth) Authenticate(r *http.Request) (*Identity, *HttpError) {
+	token := BearerToken(r)
+	parts := strings.Split(token, HmacTokenDiv)
+	if len(parts) != 3 {
+		return nil, nil
+	}
+	secret, ok := a.keys[parts[0]]
+	if !ok {
+		return nil, NewHttpError(fmt.Sprintf("unknown key [%s] of signed token", parts[0]), http.StatusUnauthorized)
+	}
+	expect := signHmac(secret, parts[0], parts[1])
+	if subtle.ConstantTimeCompare([]byte(expect), []byte(parts[2])) != 1 {
+		return nil, NewHttpError("invalid signature of signed token", http.StatusUnauthorized)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, WrapError(err, "invalid claims of signed token", http.StatusUnauthorized)
+	}
+	claims := HmacClaims{}
+	err = json.Unmarshal(raw, &claims)
+	if err != nil || claims.Subject == "" {
+		return nil, NewHttpError("invalid claims of signed token", http.StatusUnauthorized)
+	}
+	if claims.Expire > 0 && a.Now().Unix() >= claims.Expire {
+		return nil, NewHttpError(fmt.Sprintf("signed token of [%s] expired", claims.Subject), http.StatusUnauthorized)
+	}
+	return &Identity{
+		Name:   claims.Subject,
+		Method: AuthHmac,
+		Roles:  claims.Roles,
+	}, nil
+}
+
+func signHmac(secret string, keyId string, claims string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(keyId + HmacTokenDiv + claims))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewHmacToken issue signed token of subject, never expire when ttl is 0
+func NewHmacToken(keyId string, secret string, subject string, roles []string, ttl time.Duration) string {
+	claims := HmacClaims{
+		Subject: subject,
+		Roles:   roles,
+	}
+	if ttl != 0 {
+		claims.Expire = time.Now().Add(ttl).Unix()
+	}
+	raw, _ := json.Marshal(claims)
+	encoded := base64.RawURLEncoding.EncodeToString(raw)
+	return strings.Join([]string{keyId, encoded, signHmac(secret, keyId, encoded)}, HmacTokenDiv)
+}
+
+// MtlsAuth take common name of client certificate verified by tls config of server as identity
+type MtlsAuth struct {
+	clients map[string][]string
+}
+
+func NewMtlsAuth(cfg MtlsConfig) *MtlsAuth {
+	return &MtlsAuth{
+		clients: cfg.Clients,
+	}
+}
+
+func (a *MtlsAuth) Name() string {
+	return AuthMtls
+}
+
+func (a *MtlsAuth) Authenticate(r *http.Request) (*Identity, *HttpError) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, nil
+	}
+	name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if len(a.clients) == 0 {
+		return &Identity{Name: name, Method: AuthMtls}, nil
+	}
+	roles, ok := a.clients[name]
+	if !ok {
+		return nil, NewHttpError(fmt.Sprintf("client certificate [%s] is not allowed", name), http.StatusUnauthorized)
+	}
+	return &Identity{Name: name, Method: AuthMtls, Roles: roles}, nil
+}
+
+// Credential of this service when calling peers, token for AuthToken, Hmac for AuthHmac and client certificate for AuthMtls
+type Credential struct {
+	Token    string      `json:"token"`
+	Hmac     *HmacIssuer `json:"hmac"`
+	CertFile string      `json:"certFile"`
+	KeyFile  string      `json:"keyFile"`
+	CaFile   string      `json:"caFile"`
+}
+
+// HmacIssuer sign a new token for each request
+type HmacIssuer struct {
+	KeyId   string   `json:"keyId"`
+	Secret  string   `json:"secret"`
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+	Ttl     string   `json:"ttl"`
+}
+
+// For return credential only of authType that peer accepts, whole credential when authType is empty
+func (c *Credential) For(authType string) *Credential {
+	if c == nil || authType == "" {
+		return c
+	}
+	cred := Credential{}
+	switch authType {
+	case AuthToken:
+		cred.Token = c.Token
+	case AuthHmac:
+		cred.Hmac = c.Hmac
+	case AuthMtls:
+		cred.CertFile = c.CertFile
+		cred.KeyFile = c.KeyFile
+		cred.CaFile = c.CaFile
+	}
+	return &cred
+}
+
+// Apply set Authorization header on request, signed token take precedence over static token
+func (c *Credential) Apply(r *http.Request) *HttpError {
+	if c == nil {
+		return nil
+	}
+	if c.Hmac != nil {
+		ttl := time.Duration(0)
+		if c.Hmac.Ttl != "" {
+			d, err := time.ParseDuration(c.Hmac.Ttl)
+			if err != nil {
+				return WrapError(err, fmt.Sprintf("invalid ttl [%s] of hmac credential", c.Hmac.Ttl), http.StatusInternalServerError)
+			}
+			ttl = d
+		}
+		token := NewHmacToken(c.Hmac.KeyId, c.Hmac.Secret, c.Hmac.Subject, c.Hmac.Roles, ttl)
+		r.Header.Set(HeaderAuthorization, fmt.Sprintf("%s %s", SchemeBearer, token))
+		return nil
+	}
+	if c.Token != "" {
+		r.Header.Set(HeaderAuthorization, fmt.Sprintf("%s %s", SchemeBearer, c.Token))
+	}
+	return nil
+}
+
+// client of credential without certificate, shared so connections to peers are reused
+var defaultClient = &http.Client{}
+
+// clients with client certificate by certificate files, client is built again when one of the files is modified
+var certClientLock sync.Mutex
+var certClients = map[string]*certClient{}
+
+type certClient struct {
+	modTimes []time.Time
+	client   *http.Client
+}
+
+// Client return http client with client certificate when CertFile is set, default client otherwise.
+// client is reused by credentials with same certificate files until the files are modified
+func (c *Credential) Client() (*http.Client, *HttpError) {
+	if c == nil || c.CertFile == "" {
+		return defaultClient, nil
+	}
+	fileList := []string{c.CertFile, c.KeyFile, c.CaFile}
+	modTimes := make([]time.Time, len(fileList))
+	for idx, file := range fileList {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[idx] = info.ModTime()
+		}
+	}
+	key := strings.Join(fileList, "|")
+	certClientLock.Lock()
+	defer certClientLock.Unlock()
+	current, ok := certClients[key]
+	if ok && sameTimes(current.modTimes, modTimes) {
+		return current.client, nil
+	}
+	client, ex := c.newClient()
+	if ex != nil {
+		return nil, ex
+	}
+	if ok {
+		current.client.CloseIdleConnections()
+	}
+	certClients[key] = &certClient{
+		modTimes: modTimes,
+		client:   client,
+	}
+	return client, nil
+}
+
+func sameTimes(a []time.Time, b []time.Time) bool {
+	for idx := range a {
+		if !a[idx].Equal(b[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Credential) newClient() (*http.Client, *HttpError) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, WrapError(err, fmt.Sprintf("failed to load client certificate [%s]", c.CertFile), http.StatusInternalServerError)
+	}
+	tlsCfg := tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+	if c.CaFile != "" {
+		pool, ex := loadCertPool(c.CaFile)
+		if ex != nil {
+			return nil, ex
+		}
+		tlsCfg.RootCAs = pool
+	}
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tlsCfg},
+	}, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, *HttpError) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, WrapError(err, fmt.Sprintf("failed to read CA file [%s]", caFile), http.StatusInternalServerError)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, NewHttpError(fmt.Sprintf("no certificate found in CA file [%s]", caFile), http.StatusInternalServerError)
+	}
+	return pool, nil
+}
+
+// ListenAndServe on https with optional client certificate verification when tls is configured, http otherwise
+func ListenAndServe(port string, handler http.Handler, httpCfg Config) error {
+	addr := fmt.Sprintf(":%s", port)
+	if httpCfg.Tls.CertFile == "" {
+		return http.ListenAndServe(addr, handler)
+	}
+	server := http.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: &tls.Config{},
+	}
+	if httpCfg.Tls.ClientCaFile != "" {
+		pool, err := loadCertPool(httpCfg.Tls.ClientCaFile)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig.ClientCAs = pool
+		server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return server.ListenAndServeTLS(httpCfg.Tls.CertFile, httpCfg.Tls.KeyFile)
+}
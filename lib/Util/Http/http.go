@@ -57,6 +57,10 @@ type Config struct {
 	Port      string                 `json:"port"`
 	Id        string                 `json:"id"`
 	HeaderCfg map[string]interface{} `json:"headers"`
+	Auth      AuthConfig             `json:"auth"`
+	Tls       TlsConfig              `json:"tls"`
+	// origins of web pages allowed to open websocket besides the server itself, ex: https://portal.example.com, * for all
+	AllowOrigins []string `json:"allowOrigins"`
 }
 
 func GetUrl(r *http.Request) (string, *HttpError) {
@@ -105,6 +109,19 @@ func ResponseText(w http.ResponseWriter, txt []byte, status int, httpCfg Config)
 }
 
 func Response(w http.ResponseWriter, txt []byte, status int, httpCfg Config) {
+	setHeaders(w, httpCfg)
+	w.WriteHeader(status)
+	w.Write(txt)
+}
+
+// ResponseStream write status and headers only, caller write body to w afterwards
+func ResponseStream(w http.ResponseWriter, contentType string, status int, httpCfg Config) {
+	w.Header().Set("Content-Type", contentType)
+	setHeaders(w, httpCfg)
+	w.WriteHeader(status)
+}
+
+func setHeaders(w http.ResponseWriter, httpCfg Config) {
 	for key, value := range httpCfg.HeaderCfg {
 		switch reflect.TypeOf(value).Kind() {
 		case reflect.Slice:
@@ -116,8 +133,6 @@ func Response(w http.ResponseWriter, txt []byte, status int, httpCfg Config) {
 		}
 
 	}
-	w.WriteHeader(status)
-	w.Write(txt)
 }
 
 func ResponseErr(w http.ResponseWriter, err error, code int, httpCfg Config) {
@@ -130,8 +145,24 @@ func ResponseErr(w http.ResponseWriter, err error, code int, httpCfg Config) {
 }
 
 func GetRestData(url string) (interface{}, int, error) {
-	client := &http.Client{}
-	response, err := client.Get(url)
+	return GetRestDataAuth(url, nil)
+}
+
+// GetRestDataAuth call peer with credential of this service
+func GetRestDataAuth(url string, cred *Credential) (interface{}, int, error) {
+	client, ex := cred.Client()
+	if ex != nil {
+		return nil, ex.Status, ex
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to create request, [url]=[%s], Err:%s", url, err)
+	}
+	ex = cred.Apply(req)
+	if ex != nil {
+		return nil, ex.Status, ex
+	}
+	response, err := client.Do(req)
 	if err != nil {
 		return nil, http.StatusInternalServerError, fmt.Errorf("failed to get response, [url]=[%s], Err:%s", url, err)
 	}
@@ -151,10 +182,18 @@ func GetRestData(url string) (interface{}, int, error) {
 }
 
 func SubmitPayload(dataUrl string, method string, headers map[string]interface{}, payload interface{}) (*http.Response, int, error) {
+	return SubmitPayloadAuth(dataUrl, method, headers, payload, nil)
+}
+
+// SubmitPayloadAuth call peer with credential of this service
+func SubmitPayloadAuth(dataUrl string, method string, headers map[string]interface{}, payload interface{}, cred *Credential) (*http.Response, int, error) {
 	if _, ok := UpdateMethods[method]; !ok {
 		return nil, http.StatusBadRequest, fmt.Errorf("invalid method=[%s], not a update method", method)
 	}
-	client := &http.Client{}
+	client, ex := cred.Client()
+	if ex != nil {
+		return nil, ex.Status, ex
+	}
 	var req *http.Request
 	if payload == nil {
 		if method != http.MethodGet && method != http.MethodDelete && method != http.MethodPatch {
@@ -199,6 +238,10 @@ func SubmitPayload(dataUrl string, method string, headers map[string]interface{}
 			return nil, code, err
 		}
 	}
+	ex = cred.Apply(req)
+	if ex != nil {
+		return nil, ex.Status, ex
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return resp, http.StatusInternalServerError, err
@@ -0,0 +1,381 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package Http
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// minimal websocket of RFC 6455, messages are not compressed and extensions are not supported
+const (
+	WebSocketGuid    = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	WebSocketVersion = "13"
+	// max size of message read from peer
+	WebSocketMaxMessage = 1 << 20
+
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	ClosePolicyViolation = 1008
+	CloseTryAgainLater   = 1013
+)
+
+type WebSocket struct {
+	conn net.Conn
+	buf  *bufio.ReadWriter
+	// client masks frames it sends, server does not
+	client bool
+	lock   sync.Mutex
+}
+
+// IsWebSocket check if request asks to upgrade to websocket
+func IsWebSocket(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") && headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+func headerHasToken(header http.Header, key string, token string) bool {
+	for _, value := range header.Values(key) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func webSocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + WebSocketGuid))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// CheckOrigin reject websocket request sent by browser from a page of other origin, which would carry credentials of user.
+// request without Origin header is not from a browser, and is allowed
+func CheckOrigin(r *http.Request, allowOrigins []string) *HttpError {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	originUrl, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(originUrl.Host, r.Host) {
+		return nil
+	}
+	for _, allowed := range allowOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return nil
+		}
+	}
+	return NewHttpError(fmt.Sprintf("websocket from origin=[%s] not allowed", origin), http.StatusForbidden)
+}
+
+// UpgradeWebSocket complete handshake of websocket request and take over connection of w.
+// Origin of request is checked against host of request and allowOrigins.
+// nothing is written to w when handshake fails, caller response with the error
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request, allowOrigins []string) (*WebSocket, *HttpError) {
+	if r.Method != http.MethodGet {
+		return nil, NewHttpError(fmt.Sprintf("websocket method=[%s] not supported, expect [%s]", r.Method, http.MethodGet), http.StatusMethodNotAllowed)
+	}
+	if !IsWebSocket(r) {
+		return nil, NewHttpError("request is not websocket upgrade", http.StatusBadRequest)
+	}
+	if version := r.Header.Get("Sec-WebSocket-Version"); version != WebSocketVersion {
+		return nil, NewHttpError(fmt.Sprintf("websocket version=[%s] not supported, expect [%s]", version, WebSocketVersion), http.StatusUpgradeRequired)
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, NewHttpError("missing header [Sec-WebSocket-Key]", http.StatusBadRequest)
+	}
+	if err := CheckOrigin(r, allowOrigins); err != nil {
+		return nil, err
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, NewHttpError("connection does not support websocket", http.StatusInternalServerError)
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return nil, WrapError(err, "failed to take over connection for websocket", http.StatusInternalServerError)
+	}
+	response := fmt.Sprintf("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", webSocketAccept(key))
+	_, err = buf.WriteString(response)
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, WrapError(err, "failed to response websocket handshake", http.StatusInternalServerError)
+	}
+	return &WebSocket{
+		conn: conn,
+		buf:  buf,
+	}, nil
+}
+
+// DialWebSocket connect to websocket of ws://, wss://, http:// or https:// url
+func DialWebSocket(wsUrl string, header http.Header) (*WebSocket, *HttpError) {
+	target, err := url.Parse(wsUrl)
+	if err != nil {
+		return nil, WrapError(err, fmt.Sprintf("invalid websocket url=[%s]", wsUrl), http.StatusBadRequest)
+	}
+	secure := false
+	switch target.Scheme {
+	case "ws":
+		target.Scheme = "http"
+	case "wss":
+		target.Scheme = "https"
+		secure = true
+	case "http":
+	case "https":
+		secure = true
+	default:
+		return nil, NewHttpError(fmt.Sprintf("invalid websocket url=[%s], unknown scheme", wsUrl), http.StatusBadRequest)
+	}
+	host := target.Host
+	if target.Port() == "" {
+		port := "80"
+		if secure {
+			port = "443"
+		}
+		host = net.JoinHostPort(target.Hostname(), port)
+	}
+	var conn net.Conn
+	if secure {
+		conn, err = tls.Dial("tcp", host, &tls.Config{ServerName: target.Hostname()})
+	} else {
+		conn, err = net.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, WrapError(err, fmt.Sprintf("failed to connect websocket url=[%s]", wsUrl), http.StatusServiceUnavailable)
+	}
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req, _ := http.NewRequest(http.MethodGet, target.String(), nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", WebSocketVersion)
+	req.Header.Set("Sec-WebSocket-Key", key)
+	err = req.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, WrapError(err, fmt.Sprintf("failed to send websocket handshake to url=[%s]", wsUrl), http.StatusServiceUnavailable)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, WrapError(err, fmt.Sprintf("failed to read websocket handshake from url=[%s]", wsUrl), http.StatusServiceUnavailable)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(resp.Body)
+		conn.Close()
+		return nil, NewHttpError(fmt.Sprintf("websocket handshake failed with status [%d]. url=[%s]\n%s", resp.StatusCode, wsUrl, body), resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		conn.Close()
+		return nil, NewHttpError(fmt.Sprintf("invalid websocket handshake from url=[%s], accept key does not match", wsUrl), http.StatusBadGateway)
+	}
+	return &WebSocket{
+		conn:   conn,
+		buf:    bufio.NewReadWriter(reader, bufio.NewWriter(conn)),
+		client: true,
+	}, nil
+}
+
+// WriteMessage send payload in one frame, it is safe to write from multiple goroutines
+func (ws *WebSocket) WriteMessage(opcode int, payload []byte) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	header := []byte{0x80 | byte(opcode)}
+	maskBit := byte(0)
+	if ws.client {
+		maskBit = 0x80
+	}
+	size := len(payload)
+	switch {
+	case size < 126:
+		header = append(header, maskBit|byte(size))
+	case size <= 0xFFFF:
+		sizeBytes := make([]byte, 2)
+		binary.BigEndian.PutUint16(sizeBytes, uint16(size))
+		header = append(append(header, maskBit|126), sizeBytes...)
+	default:
+		sizeBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(sizeBytes, uint64(size))
+		header = append(append(header, maskBit|127), sizeBytes...)
+	}
+	if ws.client {
+		mask := make([]byte, 4)
+		rand.Read(mask)
+		header = append(header, mask...)
+		masked := make([]byte, size)
+		for idx, b := range payload {
+			masked[idx] = b ^ mask[idx%4]
+		}
+		payload = masked
+	}
+	_, err := ws.buf.Write(header)
+	if err != nil {
+		return err
+	}
+	_, err = ws.buf.Write(payload)
+	if err != nil {
+		return err
+	}
+	return ws.buf.Flush()
+}
+
+func (ws *WebSocket) WriteText(data []byte) error {
+	return ws.WriteMessage(OpText, data)
+}
+
+func (ws *WebSocket) WriteClose(code int, reason string) error {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return ws.WriteMessage(OpClose, append(payload, []byte(reason)...))
+}
+
+// ReadMessage return next text or binary message. ping is answered with pong,
+// close is answered and io.EOF is returned
+func (ws *WebSocket) ReadMessage() (int, []byte, error) {
+	opcode := -1
+	message := []byte{}
+	for {
+		fin, op, payload, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case OpPing:
+			err = ws.WriteMessage(OpPong, payload)
+			if err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			ws.WriteMessage(OpClose, payload)
+			return OpClose, nil, io.EOF
+		case OpContinuation:
+			if opcode == -1 {
+				return 0, nil, fmt.Errorf("websocket continuation frame without first frame")
+			}
+		case OpText, OpBinary:
+			if opcode != -1 {
+				return 0, nil, fmt.Errorf("websocket new message before last one is finished")
+			}
+			opcode = op
+		default:
+			return 0, nil, fmt.Errorf("unknown websocket opcode=[%d]", op)
+		}
+		message = append(message, payload...)
+		if len(message) > WebSocketMaxMessage {
+			return 0, nil, fmt.Errorf("websocket message is larger than [%d] bytes", WebSocketMaxMessage)
+		}
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+func (ws *WebSocket) readFrame() (bool, int, []byte, error) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(ws.buf, header)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := int(header[0] & 0x0F)
+	masked := header[1]&0x80 != 0
+	size := uint64(header[1] & 0x7F)
+	switch size {
+	case 126:
+		sizeBytes := make([]byte, 2)
+		_, err = io.ReadFull(ws.buf, sizeBytes)
+		size = uint64(binary.BigEndian.Uint16(sizeBytes))
+	case 127:
+		sizeBytes := make([]byte, 8)
+		_, err = io.ReadFull(ws.buf, sizeBytes)
+		size = binary.BigEndian.Uint64(sizeBytes)
+	}
+	if err != nil {
+		return false, 0, nil, err
+	}
+	if masked == ws.client {
+		return false, 0, nil, fmt.Errorf("invalid websocket frame, masked=[%t]", masked)
+	}
+	if size > WebSocketMaxMessage {
+		return false, 0, nil, fmt.Errorf("websocket frame is larger than [%d] bytes", WebSocketMaxMessage)
+	}
+	mask := make([]byte, 4)
+	if masked {
+		_, err = io.ReadFull(ws.buf, mask)
+		if err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, size)
+	_, err = io.ReadFull(ws.buf, payload)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for idx := range payload {
+			payload[idx] ^= mask[idx%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+func (ws *WebSocket) Close() error {
+	return ws.conn.Close()
+}
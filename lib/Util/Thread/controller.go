@@ -75,11 +75,24 @@ func (c *ThreadCtrl) GetWorker(workerId string) *Worker {
 
 func (c *ThreadCtrl) RemoveWorker(workerId string) error {
 	c.lock.Lock()
-	defer c.lock.Lock()
+	defer c.lock.Unlock()
 	delete(c.workers, workerId)
 	return nil
 }
 
+// Wait for all workers to exit, call after Broadcast of exit signal
+func (c *ThreadCtrl) Wait() {
+	c.lock.Lock()
+	workerList := make([]*Worker, 0, len(c.workers))
+	for _, worker := range c.workers {
+		workerList = append(workerList, worker)
+	}
+	c.lock.Unlock()
+	for _, worker := range workerList {
+		worker.Wait()
+	}
+}
+
 func (c *ThreadCtrl) Broadcast(event interface{}) {
 	for _, worker := range c.workers {
 		worker.Notify(event)
@@ -73,7 +73,6 @@ func (w *Worker) Log(message string) {
 
 func (w *Worker) setup() {
 	w.stopped = false
-	w.wg.Add(1)
 }
 
 func (w *Worker) postRun() {
@@ -88,6 +87,7 @@ func (w *Worker) postRun() {
 }
 
 func (w *Worker) workerRoutine() {
+	defer w.wg.Done()
 	w.setup()
 	defer w.postRun()
 	err := w.run(w.event)
@@ -97,9 +97,15 @@ func (w *Worker) workerRoutine() {
 }
 
 func (w *Worker) Run() {
+	w.wg.Add(1)
 	go w.workerRoutine()
 }
 
+// Wait until run function returned and worker is removed by cleanup
+func (w *Worker) Wait() {
+	w.wg.Wait()
+}
+
 func (w *Worker) queueEvent(event interface{}) {
 	w.event <- event
 }
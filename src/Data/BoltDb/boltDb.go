@@ -0,0 +1,529 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package BoltDb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"Data/DbConfig"
+	"Data/DbIface"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	Name        = "boltdb"
+	DefaultFile = "unitao.db"
+	OpenTimeout = 5 * time.Second
+)
+
+// table is a top level bucket, record type is a nested bucket under table and record id is the key within type bucket
+type boltDb struct {
+	logger *log.Logger
+	config DbConfig.BoltDbConfig
+	db     *bolt.DB
+}
+
+func (db *boltDb) Name() string {
+	return Name
+}
+
+func (db *boltDb) Close() error {
+	return db.db.Close()
+}
+
+func (db *boltDb) ListTable() ([]interface{}, error) {
+	tableList := []interface{}{}
+	err := db.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			tableList = append(tableList, string(name))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list table. Error:%s", err)
+	}
+	return tableList, nil
+}
+
+func (db *boltDb) CreateTable(name string, data map[string]interface{}) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return fmt.Errorf("failed to create table [%s]. Error:%s", name, err)
+		}
+		return nil
+	})
+}
+
+func (db *boltDb) DeleteTable(name string) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(name))
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return fmt.Errorf("failed to delete table [%s]. Error:%s", name, err)
+		}
+		return nil
+	})
+}
+
+func getTable(tx *bolt.Tx, name string) (*bolt.Bucket, error) {
+	table := tx.Bucket([]byte(name))
+	if table == nil {
+		return nil, fmt.Errorf("table [%s] does not exists", name)
+	}
+	return table, nil
+}
+
+func loadRecord(value []byte) (map[string]interface{}, error) {
+	record := map[string]interface{}{}
+	err := json.Unmarshal(value, &record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal record. Error:%s", err)
+	}
+	return record, nil
+}
+
+func recordKeys(data interface{}) (map[string]interface{}, string, string, error) {
+	payload, ok := data.(map[string]interface{})
+	if !ok {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("failed to marshal data. Error:%s", err)
+		}
+		payload, err = loadRecord(raw)
+		if err != nil {
+			return nil, "", "", err
+		}
+	}
+	dataType, ok := payload[Record.DataType].(string)
+	if !ok || dataType == "" {
+		return nil, "", "", fmt.Errorf("missing key [%s] from data", Record.DataType)
+	}
+	dataId, ok := payload[Record.DataId].(string)
+	if !ok || dataId == "" {
+		return nil, "", "", fmt.Errorf("missing key [%s] from data", Record.DataId)
+	}
+	return payload, dataType, dataId, nil
+}
+
+// find the bucket of the record with keys, [__type] could be missing, then search all type buckets for [__id]
+func findRecord(table *bolt.Bucket, keys map[string]interface{}) (*bolt.Bucket, []byte, error) {
+	dataId, ok := keys[Record.DataId].(string)
+	if !ok {
+		return nil, nil, fmt.Errorf("missing key=[%s]", Record.DataId)
+	}
+	dataType, ok := keys[Record.DataType].(string)
+	if ok {
+		typeBucket := table.Bucket([]byte(dataType))
+		if typeBucket == nil {
+			return nil, nil, nil
+		}
+		return typeBucket, typeBucket.Get([]byte(dataId)), nil
+	}
+	var found *bolt.Bucket
+	var value []byte
+	table.ForEach(func(name []byte, v []byte) error {
+		if v != nil || found != nil {
+			return nil
+		}
+		typeBucket := table.Bucket(name)
+		if data := typeBucket.Get([]byte(dataId)); data != nil {
+			found = typeBucket
+			value = data
+		}
+		return nil
+	})
+	return found, value, nil
+}
+
+// check revision condition in keys against stored value, value is nil when record does not exists
+func checkRevision(value []byte, keys map[string]interface{}) error {
+	if _, ok := keys[Record.Revision]; !ok {
+		return nil
+	}
+	var current map[string]interface{}
+	if value != nil {
+		record, err := loadRecord(value)
+		if err != nil {
+			return err
+		}
+		current = record
+	}
+	return DbIface.CheckRevision(current, keys)
+}
+
+func (db *boltDb) Get(queryArgs map[string]interface{}) ([]map[string]interface{}, error) {
+	tableName, ok := queryArgs[DbIface.Table].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing parameter [%s] from queryArgs", DbIface.Table)
+	}
+	filter, err := DbIface.GetFilter(queryArgs)
+	if err != nil {
+		return nil, err
+	}
+	result := []map[string]interface{}{}
+	err = db.db.View(func(tx *bolt.Tx) error {
+		table, err := getTable(tx, tableName)
+		if err != nil {
+			return err
+		}
+		if _, ok := queryArgs[Record.DataId].(string); ok {
+			_, value, err := findRecord(table, queryArgs)
+			if err != nil || value == nil {
+				return err
+			}
+			record, err := loadRecord(value)
+			if err != nil {
+				return err
+			}
+			result = append(result, record)
+			return nil
+		}
+		typeList := [][]byte{}
+		if dataType, ok := queryArgs[Record.DataType].(string); ok {
+			typeList = append(typeList, []byte(dataType))
+		} else {
+			table.ForEach(func(name []byte, v []byte) error {
+				if v == nil {
+					typeList = append(typeList, name)
+				}
+				return nil
+			})
+		}
+		for _, dataType := range typeList {
+			typeBucket := table.Bucket(dataType)
+			if typeBucket == nil {
+				continue
+			}
+			err := typeBucket.ForEach(func(_ []byte, value []byte) error {
+				record, err := loadRecord(value)
+				if err != nil {
+					return err
+				}
+				result = append(result, record)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return DbIface.FilterRecords(result, filter), nil
+}
+
+func (db *boltDb) GetPage(queryArgs map[string]interface{}, pageSize int, pageToken string) ([]map[string]interface{}, string, error) {
+	if _, ok := queryArgs[Record.DataId].(string); ok {
+		recordList, err := db.Get(queryArgs)
+		if err != nil {
+			return nil, "", err
+		}
+		return DbIface.PageRecords(recordList, pageSize, pageToken)
+	}
+	tableName, ok := queryArgs[DbIface.Table].(string)
+	if !ok {
+		return nil, "", fmt.Errorf("missing parameter [%s] from queryArgs", DbIface.Table)
+	}
+	filter, err := DbIface.GetFilter(queryArgs)
+	if err != nil {
+		return nil, "", err
+	}
+	start := DbIface.PageKey{}
+	if pageToken != "" {
+		err = DbIface.DecodePageToken(pageToken, &start)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	result := []map[string]interface{}{}
+	nextToken := ""
+	err = db.db.View(func(tx *bolt.Tx) error {
+		table, err := getTable(tx, tableName)
+		if err != nil {
+			return err
+		}
+		typeList := [][]byte{}
+		if dataType, ok := queryArgs[Record.DataType].(string); ok {
+			typeList = append(typeList, []byte(dataType))
+		} else {
+			table.ForEach(func(name []byte, v []byte) error {
+				if v == nil {
+					typeList = append(typeList, name)
+				}
+				return nil
+			})
+		}
+		last := DbIface.PageKey{}
+		for _, dataType := range typeList {
+			if string(dataType) < start.Type {
+				continue
+			}
+			typeBucket := table.Bucket(dataType)
+			if typeBucket == nil {
+				continue
+			}
+			cursor := typeBucket.Cursor()
+			key, value := cursor.First()
+			if pageToken != "" && string(dataType) == start.Type {
+				key, value = cursor.Seek([]byte(start.Id))
+				if key != nil && string(key) == start.Id {
+					key, value = cursor.Next()
+				}
+			}
+			for ; key != nil; key, value = cursor.Next() {
+				if value == nil {
+					continue
+				}
+				record, err := loadRecord(value)
+				if err != nil {
+					return err
+				}
+				if filter != nil && !filter.Match(record) {
+					continue
+				}
+				if pageSize > 0 && len(result) == pageSize {
+					nextToken, err = DbIface.EncodePageToken(last)
+					return err
+				}
+				result = append(result, record)
+				last = DbIface.PageKey{Type: string(dataType), Id: string(key)}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return result, nextToken, nil
+}
+
+func putRecord(table *bolt.Bucket, dataType string, dataId string, payload map[string]interface{}) error {
+	typeBucket, err := table.CreateBucketIfNotExists([]byte(dataType))
+	if err != nil {
+		return fmt.Errorf("failed to create bucket for type [%s]. Error:%s", dataType, err)
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data [%s/%s]. Error:%s", dataType, dataId, err)
+	}
+	return typeBucket.Put([]byte(dataId), raw)
+}
+
+func createRecord(tx *bolt.Tx, tableName string, data interface{}) error {
+	payload, dataType, dataId, err := recordKeys(data)
+	if err != nil {
+		return err
+	}
+	table, err := getTable(tx, tableName)
+	if err != nil {
+		return err
+	}
+	_, value, err := findRecord(table, payload)
+	if err != nil {
+		return err
+	}
+	if value != nil {
+		return fmt.Errorf("data [%s/%s] already exists", dataType, dataId)
+	}
+	return putRecord(table, dataType, dataId, payload)
+}
+
+func (db *boltDb) Create(tableName string, data interface{}) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return createRecord(tx, tableName, data)
+	})
+}
+
+func (db *boltDb) Update(tableName string, keys map[string]interface{}, data interface{}) (map[string]interface{}, error) {
+	dataType, ok := keys[Record.DataType].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing key=[%s]", Record.DataType)
+	}
+	dataId, ok := keys[Record.DataId].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing key=[%s]", Record.DataId)
+	}
+	if dataType == "" || dataId == "" {
+		return nil, fmt.Errorf("missing dataType/dataId, expect format:[{dataType}/{dataId}/{dataPath}]")
+	}
+	queryPath, ok := keys[DbIface.PatchPath].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing patch key=[%s]", DbIface.PatchPath)
+	}
+	var patchData map[string]interface{}
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		table, err := getTable(tx, tableName)
+		if err != nil {
+			return err
+		}
+		_, value, err := findRecord(table, keys)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			return fmt.Errorf("data [%s/%s] does not exists", dataType, dataId)
+		}
+		patchData, err = loadRecord(value)
+		if err != nil {
+			return err
+		}
+		err = DbIface.CheckRevision(patchData, keys)
+		if err != nil {
+			return err
+		}
+		subData, attrPath, err := DbIface.GetDataOnPath(patchData, queryPath, fmt.Sprintf("%s/%s/%s", dataType, dataId, queryPath))
+		if err != nil {
+			return err
+		}
+		err = DbIface.SetPatchData(subData, attrPath, data)
+		if err != nil {
+			return err
+		}
+		DbIface.BumpRevision(patchData)
+		return putRecord(table, dataType, dataId, patchData)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return patchData, nil
+}
+
+func replaceRecord(tx *bolt.Tx, tableName string, keys map[string]interface{}, data interface{}) error {
+	payload, dataType, dataId, err := recordKeys(data)
+	if err != nil {
+		return err
+	}
+	table, err := getTable(tx, tableName)
+	if err != nil {
+		return err
+	}
+	// keys could point to a different record, ex: schema archive replace current schema with archived id
+	typeBucket, value, err := findRecord(table, keys)
+	if err != nil {
+		return err
+	}
+	err = checkRevision(value, keys)
+	if err != nil {
+		return err
+	}
+	if value != nil {
+		err = typeBucket.Delete([]byte(keys[Record.DataId].(string)))
+		if err != nil {
+			return fmt.Errorf("failed to remove current record. Error:%s", err)
+		}
+	}
+	return putRecord(table, dataType, dataId, payload)
+}
+
+func (db *boltDb) Replace(tableName string, keys map[string]interface{}, data interface{}) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return replaceRecord(tx, tableName, keys, data)
+	})
+}
+
+func deleteRecord(tx *bolt.Tx, tableName string, keys map[string]interface{}) error {
+	table, err := getTable(tx, tableName)
+	if err != nil {
+		return err
+	}
+	typeBucket, value, err := findRecord(table, keys)
+	if err != nil {
+		return err
+	}
+	err = checkRevision(value, keys)
+	if err != nil || value == nil {
+		return err
+	}
+	return typeBucket.Delete([]byte(keys[Record.DataId].(string)))
+}
+
+func (db *boltDb) Delete(tableName string, keys map[string]interface{}) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return deleteRecord(tx, tableName, keys)
+	})
+}
+
+// all operations are applied in one bolt transaction, which is rolled back on any error
+func (db *boltDb) Commit(batch *DbIface.Batch) error {
+	if batch.Empty() {
+		return nil
+	}
+	return db.db.Update(func(tx *bolt.Tx) error {
+		for idx, op := range batch.Operations {
+			var err error
+			switch op.Action {
+			case DbIface.OpCreate:
+				err = createRecord(tx, op.Table, op.Data)
+			case DbIface.OpReplace:
+				err = replaceRecord(tx, op.Table, op.Keys, op.Data)
+			case DbIface.OpDelete:
+				err = deleteRecord(tx, op.Table, op.Keys)
+			default:
+				err = fmt.Errorf("unknown action [%s]", op.Action)
+			}
+			if err != nil {
+				return fmt.Errorf("batch operation [%d] failed, rollback. Error:%w", idx, err)
+			}
+		}
+		return nil
+	})
+}
+
+func Connect(config DbConfig.BoltDbConfig, logger *log.Logger) (DbIface.Database, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if config.Path == "" {
+		return nil, fmt.Errorf("missing path from config for %s", Name)
+	}
+	dbPath, err := filepath.Abs(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse path to absolute path, path=[%s], Error:%s", config.Path, err)
+	}
+	pathInfo, err := os.Stat(dbPath)
+	if err == nil && pathInfo.IsDir() {
+		dbPath = filepath.Join(dbPath, DefaultFile)
+	}
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: OpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db file [%s], Error:%s", dbPath, err)
+	}
+	database := boltDb{
+		logger: logger,
+		config: config,
+		db:     db,
+	}
+	return &database, nil
+}
@@ -0,0 +1,324 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DbCache
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"Data/DbConfig"
+	"Data/DbIface"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Util/Json"
+)
+
+const Prefix = "unitao_db_cache"
+
+// Stats of cache, hit and miss counters are for tuning cache size
+type Stats struct {
+	Capacity  int    `json:"capacity"`
+	Size      int    `json:"size"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// WriteText write stats in Prometheus text exposition format
+func (s Stats) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "# TYPE %s_hits_total counter\n%s_hits_total %d\n", Prefix, Prefix, s.Hits)
+	fmt.Fprintf(w, "# TYPE %s_misses_total counter\n%s_misses_total %d\n", Prefix, Prefix, s.Misses)
+	fmt.Fprintf(w, "# TYPE %s_evictions_total counter\n%s_evictions_total %d\n", Prefix, Prefix, s.Evictions)
+	fmt.Fprintf(w, "# TYPE %s_size gauge\n%s_size %d\n", Prefix, Prefix, s.Size)
+	fmt.Fprintf(w, "# TYPE %s_capacity gauge\n%s_capacity %d\n", Prefix, Prefix, s.Capacity)
+}
+
+type entry struct {
+	key        string
+	table      string
+	dataId     string
+	resultList []map[string]interface{}
+}
+
+// Database is a read-through cache of single record lookup on [table/type/id] in front of another Database.
+// every write through it invalidates the records it touches, writes from other processes are not seen until evicted
+type Database struct {
+	db       DbIface.Database
+	capacity int
+	logger   *log.Logger
+	lock     sync.Mutex
+	entries  map[string]*list.Element
+	order    *list.List
+	// bumped on every invalidation, a read started before it is not cached
+	gen       uint64
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+func New(db DbIface.Database, config DbConfig.CacheConfig, logger *log.Logger) (*Database, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if db == nil {
+		return nil, fmt.Errorf("missing database to cache")
+	}
+	if config.Size <= 0 {
+		return nil, fmt.Errorf("invalid cache size [%d], expect positive integer", config.Size)
+	}
+	logger.Printf("cache [%d] records of %s", config.Size, db.Name())
+	return &Database{
+		db:       db,
+		capacity: config.Size,
+		logger:   logger,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}, nil
+}
+
+func (c *Database) Stats() Stats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return Stats{
+		Capacity:  c.capacity,
+		Size:      c.order.Len(),
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+func (c *Database) Name() string {
+	return c.db.Name()
+}
+
+func (c *Database) ListTable() ([]interface{}, error) {
+	return c.db.ListTable()
+}
+
+func (c *Database) CreateTable(name string, data map[string]interface{}) error {
+	defer c.invalidate(name, "", "")
+	return c.db.CreateTable(name, data)
+}
+
+func (c *Database) DeleteTable(name string) error {
+	defer c.invalidate(name, "", "")
+	return c.db.DeleteTable(name)
+}
+
+// only lookup of one record by table, type and id is cached
+func cacheKey(queryArgs map[string]interface{}) (string, string, string, bool) {
+	if len(queryArgs) != 3 {
+		return "", "", "", false
+	}
+	table, ok := queryArgs[DbIface.Table].(string)
+	if !ok {
+		return "", "", "", false
+	}
+	dataType, ok := queryArgs[Record.DataType].(string)
+	if !ok {
+		return "", "", "", false
+	}
+	dataId, ok := queryArgs[Record.DataId].(string)
+	if !ok {
+		return "", "", "", false
+	}
+	return table, dataType, dataId, true
+}
+
+func entryKey(table string, dataType string, dataId string) string {
+	return fmt.Sprintf("%s/%s/%s", table, dataType, dataId)
+}
+
+func (c *Database) Get(queryArgs map[string]interface{}) ([]map[string]interface{}, error) {
+	table, dataType, dataId, ok := cacheKey(queryArgs)
+	if !ok {
+		return c.db.Get(queryArgs)
+	}
+	key := entryKey(table, dataType, dataId)
+	c.lock.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		c.hits++
+		resultList := copyList(elem.Value.(*entry).resultList)
+		c.lock.Unlock()
+		return resultList, nil
+	}
+	c.misses++
+	gen := c.gen
+	c.lock.Unlock()
+	resultList, err := c.db.Get(queryArgs)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.gen == gen {
+		c.add(&entry{
+			key:        key,
+			table:      table,
+			dataId:     dataId,
+			resultList: copyList(resultList),
+		})
+	}
+	return resultList, nil
+}
+
+func (c *Database) GetPage(queryArgs map[string]interface{}, pageSize int, pageToken string) ([]map[string]interface{}, string, error) {
+	return c.db.GetPage(queryArgs, pageSize, pageToken)
+}
+
+func (c *Database) Create(table string, data interface{}) error {
+	defer c.invalidateData(table, data)
+	return c.db.Create(table, data)
+}
+
+func (c *Database) Update(table string, keys map[string]interface{}, data interface{}) (map[string]interface{}, error) {
+	defer c.invalidateKeys(table, keys)
+	return c.db.Update(table, keys, data)
+}
+
+func (c *Database) Replace(table string, keys map[string]interface{}, data interface{}) error {
+	defer c.invalidateOp(table, keys, data)
+	return c.db.Replace(table, keys, data)
+}
+
+func (c *Database) Delete(table string, keys map[string]interface{}) error {
+	defer c.invalidateKeys(table, keys)
+	return c.db.Delete(table, keys)
+}
+
+func (c *Database) Commit(batch *DbIface.Batch) error {
+	defer func() {
+		for _, op := range batch.Operations {
+			c.invalidateOp(op.Table, op.Keys, op.Data)
+		}
+	}()
+	return c.db.Commit(batch)
+}
+
+// caller holds the lock
+func (c *Database) add(item *entry) {
+	if elem, ok := c.entries[item.key]; ok {
+		c.order.Remove(elem)
+	}
+	c.entries[item.key] = c.order.PushFront(item)
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+// caller holds the lock
+func (c *Database) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).key)
+}
+
+// invalidate cache after write, empty dataType matches any type and empty dataId matches the whole table
+func (c *Database) invalidate(table string, dataType string, dataId string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.gen++
+	if dataType != "" && dataId != "" {
+		if elem, ok := c.entries[entryKey(table, dataType, dataId)]; ok {
+			c.remove(elem)
+		}
+		return
+	}
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		item := elem.Value.(*entry)
+		if item.table == table && (dataId == "" || item.dataId == dataId) {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+func (c *Database) invalidateKeys(table string, keys map[string]interface{}) {
+	dataType, _ := keys[Record.DataType].(string)
+	dataId, _ := keys[Record.DataId].(string)
+	c.invalidate(table, dataType, dataId)
+}
+
+func (c *Database) invalidateData(table string, data interface{}) {
+	if data == nil {
+		return
+	}
+	payload, ok := data.(map[string]interface{})
+	if !ok {
+		var err error
+		payload, err = Json.CopyToMap(data)
+		if err != nil {
+			c.invalidate(table, "", "")
+			return
+		}
+	}
+	c.invalidateKeys(table, payload)
+}
+
+// keys are optional on create and replace
+func (c *Database) invalidateOp(table string, keys map[string]interface{}, data interface{}) {
+	if keys != nil {
+		c.invalidateKeys(table, keys)
+	}
+	c.invalidateData(table, data)
+}
+
+// records handed out are copies, so callers changing them do not change the cache
+func copyList(resultList []map[string]interface{}) []map[string]interface{} {
+	if resultList == nil {
+		return nil
+	}
+	result := make([]map[string]interface{}, 0, len(resultList))
+	for _, record := range resultList {
+		result = append(result, copyValue(record).(map[string]interface{}))
+	}
+	return result
+}
+
+func copyValue(value interface{}) interface{} {
+	switch data := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(data))
+		for key, item := range data {
+			result[key] = copyValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, 0, len(data))
+		for _, item := range data {
+			result = append(result, copyValue(item))
+		}
+		return result
+	default:
+		return value
+	}
+}
@@ -25,11 +25,68 @@ This copyright notice and license applies to all files in this directory or sub-
 
 package DbConfig
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	DbType = "type"
+)
+
+// DatabaseConfig keeps the backend type and every backend section as raw JSON,
+// the section of the selected backend is decoded by the backend registered with the same name
 type DatabaseConfig struct {
-	DbType     string           `json:"type"`
-	Dynamodb   DynmoDbConfig    `json:"dynamodb"`
-	Mongodb    MongoDbConfig    `json:"mongodb"`
-	SysDirFile SysDirFileConfig `json:"sysdirfile"`
+	DbType   string
+	Sections map[string]json.RawMessage
+}
+
+func (c *DatabaseConfig) UnmarshalJSON(raw []byte) error {
+	sections := map[string]json.RawMessage{}
+	err := json.Unmarshal(raw, &sections)
+	if err != nil {
+		return err
+	}
+	c.DbType = ""
+	if typeRaw, ok := sections[DbType]; ok {
+		err = json.Unmarshal(typeRaw, &c.DbType)
+		if err != nil {
+			return fmt.Errorf("invalid database config [%s], Error:%s", DbType, err)
+		}
+		delete(sections, DbType)
+	}
+	c.Sections = sections
+	return nil
+}
+
+func (c DatabaseConfig) MarshalJSON() ([]byte, error) {
+	data := map[string]interface{}{
+		DbType: c.DbType,
+	}
+	for name, section := range c.Sections {
+		data[name] = section
+	}
+	return json.Marshal(data)
+}
+
+// Section return raw config section of backend [name], nil if not configured
+func (c DatabaseConfig) Section(name string) json.RawMessage {
+	if c.Sections == nil {
+		return nil
+	}
+	return c.Sections[name]
+}
+
+func (c *DatabaseConfig) SetSection(name string, section interface{}) error {
+	raw, err := json.Marshal(section)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config section [%s], Error:%s", name, err)
+	}
+	if c.Sections == nil {
+		c.Sections = map[string]json.RawMessage{}
+	}
+	c.Sections[name] = raw
+	return nil
 }
 
 type DynmoDbConfig struct {
@@ -50,3 +107,17 @@ type MongoDbConfig struct {
 type SysDirFileConfig struct {
 	Path string `json:"path"`
 }
+
+type BoltDbConfig struct {
+	Path string `json:"path"`
+}
+
+type InMemoryConfig struct {
+	Snapshot string `json:"snapshot"`
+	// number of writes appended to log of snapshot before they are compacted into snapshot, 0 for default
+	LogSize int `json:"logSize"`
+}
+
+type CacheConfig struct {
+	Size int `json:"size"`
+}
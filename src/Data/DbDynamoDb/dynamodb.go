@@ -29,6 +29,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 
 	"Data/DbConfig"
 	"Data/DbIface"
@@ -48,11 +49,13 @@ const (
 	Name      = "dynamodb"
 	UpdateOps = "updateops"
 	TableName = "TableName"
+	// max number of items allowed in one TransactWriteItems call
+	MaxTransactItems = 100
 )
 
 type dynamoDB struct {
 	logger   *log.Logger
-	config   DbConfig.DatabaseConfig
+	config   DbConfig.DynmoDbConfig
 	sess     *session.Session
 	database *dynamodb.DynamoDB
 }
@@ -75,7 +78,125 @@ func ParseQueryOutput(output *dynamodb.QueryOutput) ([]map[string]interface{}, e
 	return result, nil
 }
 
+// convert filter to FilterExpression condition, nested attribute path is joined by dot notation
+func filterCondition(filter *DbIface.FilterExpr) expression.ConditionBuilder {
+	if filter.Op == DbIface.FilterAnd {
+		condList := make([]expression.ConditionBuilder, 0, len(filter.Items))
+		for _, item := range filter.Items {
+			condList = append(condList, filterCondition(item))
+		}
+		if len(condList) == 1 {
+			return condList[0]
+		}
+		return expression.And(condList[0], condList[1], condList[2:]...)
+	}
+	name := expression.Name(strings.Join(filter.PathList(), "."))
+	value := expression.Value(filter.Value)
+	switch filter.Op {
+	case DbIface.FilterNe:
+		return name.NotEqual(value)
+	case DbIface.FilterGt:
+		return name.GreaterThan(value)
+	case DbIface.FilterGte:
+		return name.GreaterThanEqual(value)
+	case DbIface.FilterLt:
+		return name.LessThan(value)
+	case DbIface.FilterLte:
+		return name.LessThanEqual(value)
+	case DbIface.FilterIn:
+		others := make([]expression.OperandBuilder, 0, len(filter.Values)-1)
+		for _, item := range filter.Values[1:] {
+			others = append(others, expression.Value(item))
+		}
+		return name.In(expression.Value(filter.Values[0]), others...)
+	}
+	return name.Equal(value)
+}
+
 func (db *dynamoDB) Query(table string, index string, queryArgs map[string]interface{}) ([]map[string]interface{}, error) {
+	return db.QueryFilter(table, index, queryArgs, nil)
+}
+
+func (db *dynamoDB) QueryFilter(table string, index string, queryArgs map[string]interface{}, filter *DbIface.FilterExpr) ([]map[string]interface{}, error) {
+	queryInput, err := buildQuery(table, index, queryArgs, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []map[string]interface{}{}
+	for {
+		output, err := db.database.Query(queryInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query version for [table]=[%s]. Error: %s", table, err)
+		}
+		items, err := ParseQueryOutput(output)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+		// one query returns 1MB at most, follow LastEvaluatedKey to get the rest
+		if len(output.LastEvaluatedKey) == 0 {
+			return result, nil
+		}
+		queryInput.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// QueryPage return at most pageSize items after pageToken, pageToken is the encoded LastEvaluatedKey.
+// Limit counts items before FilterExpression, so keep reading until page is full or no more item
+func (db *dynamoDB) QueryPage(table string, index string, queryArgs map[string]interface{}, filter *DbIface.FilterExpr, pageSize int, pageToken string) ([]map[string]interface{}, string, error) {
+	if pageSize <= 0 && pageToken == "" {
+		result, err := db.QueryFilter(table, index, queryArgs, filter)
+		return result, "", err
+	}
+	queryInput, err := buildQuery(table, index, queryArgs, filter)
+	if err != nil {
+		return nil, "", err
+	}
+	if pageToken != "" {
+		startKey := map[string]interface{}{}
+		err = DbIface.DecodePageToken(pageToken, &startKey)
+		if err != nil {
+			return nil, "", err
+		}
+		queryInput.ExclusiveStartKey, err = dynamodbattribute.MarshalMap(startKey)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w [%s], Error: %s", DbIface.ErrInvalidPageToken, pageToken, err)
+		}
+	}
+	result := []map[string]interface{}{}
+	for {
+		if pageSize > 0 {
+			queryInput.Limit = aws.Int64(int64(pageSize - len(result)))
+		}
+		output, err := db.database.Query(queryInput)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to query page for [table]=[%s]. Error: %s", table, err)
+		}
+		items, err := ParseQueryOutput(output)
+		if err != nil {
+			return nil, "", err
+		}
+		result = append(result, items...)
+		if len(output.LastEvaluatedKey) == 0 {
+			return result, "", nil
+		}
+		if pageSize > 0 && len(result) >= pageSize {
+			lastKey := map[string]interface{}{}
+			err = dynamodbattribute.UnmarshalMap(output.LastEvaluatedKey, &lastKey)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to unmarshal LastEvaluatedKey. Error: %s", err)
+			}
+			nextToken, err := DbIface.EncodePageToken(lastKey)
+			if err != nil {
+				return nil, "", err
+			}
+			return result, nextToken, nil
+		}
+		queryInput.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+func buildQuery(table string, index string, queryArgs map[string]interface{}, filter *DbIface.FilterExpr) (*dynamodb.QueryInput, error) {
 	init := false
 	var cond expression.KeyConditionBuilder
 	for key, value := range queryArgs {
@@ -86,7 +207,11 @@ func (db *dynamoDB) Query(table string, index string, queryArgs map[string]inter
 		}
 		cond = cond.And(expression.Key(key).Equal(expression.Value(value)))
 	}
-	expr, err := expression.NewBuilder().WithKeyCondition(cond).Build()
+	builder := expression.NewBuilder().WithKeyCondition(cond)
+	if filter != nil {
+		builder = builder.WithFilter(filterCondition(filter))
+	}
+	expr, err := builder.Build()
 	if err != nil {
 		return nil, fmt.Errorf("failed to build index query. Error:%s", err)
 	}
@@ -94,27 +219,29 @@ func (db *dynamoDB) Query(table string, index string, queryArgs map[string]inter
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
 		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
 		ProjectionExpression:      expr.Projection(),
 		TableName:                 aws.String(table),
 	}
 	if index != "" {
 		queryInput.IndexName = aws.String(index)
 	}
-	output, err := db.database.Query(queryInput)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query version for [table]=[%s]. Error: %s", table, err)
-	}
-	return ParseQueryOutput(output)
+	return queryInput, nil
 }
 
 func (db *dynamoDB) Get(queryArgs map[string]interface{}) ([]map[string]interface{}, error) {
+	result, _, err := db.GetPage(queryArgs, 0, "")
+	return result, err
+}
+
+func (db *dynamoDB) GetPage(queryArgs map[string]interface{}, pageSize int, pageToken string) ([]map[string]interface{}, string, error) {
 	tableName, ok := queryArgs[DbIface.Table].(string)
 	if !ok {
-		return nil, fmt.Errorf("missing parameter [%s] from queryArgs", DbIface.Table)
+		return nil, "", fmt.Errorf("missing parameter [%s] from queryArgs", DbIface.Table)
 	}
 	dataType, ok := queryArgs[Record.DataType].(string)
 	if !ok {
-		return nil, fmt.Errorf("missing parameter [%s] from queryArgs", Record.DataType)
+		return nil, "", fmt.Errorf("missing parameter [%s] from queryArgs", Record.DataType)
 	}
 	args := make(map[string]interface{})
 	args[Record.DataType] = dataType
@@ -122,7 +249,11 @@ func (db *dynamoDB) Get(queryArgs map[string]interface{}) ([]map[string]interfac
 	if ok {
 		args[Record.DataId] = dataId
 	}
-	return db.Query(tableName, "", args)
+	filter, err := DbIface.GetFilter(queryArgs)
+	if err != nil {
+		return nil, "", err
+	}
+	return db.QueryPage(tableName, "", args, filter, pageSize, pageToken)
 }
 
 func (db *dynamoDB) ListTable() ([]interface{}, error) {
@@ -222,6 +353,10 @@ func (db *dynamoDB) createRecord(table string, data interface{}) error {
 }
 
 func (db *dynamoDB) Replace(table string, keys map[string]interface{}, data interface{}) error {
+	if _, ok := keys[Record.Revision]; ok {
+		// conditional delete and put need to be in one transaction
+		return db.Commit(DbIface.NewBatch().Replace(table, keys, data))
+	}
 	queryArgs := map[string]interface{}{
 		DbIface.Table: table,
 	}
@@ -246,7 +381,7 @@ func (db *dynamoDB) Delete(table string, keys map[string]interface{}) error {
 }
 
 func (db *dynamoDB) deleteRecord(table string, keys map[string]interface{}) error {
-	av, err := dynamodbattribute.MarshalMap(keys)
+	av, err := dynamodbattribute.MarshalMap(DbIface.RecordKeys(keys))
 	if err != nil {
 		log.Printf("Got error marshalling map: %s", err)
 		return err
@@ -255,14 +390,45 @@ func (db *dynamoDB) deleteRecord(table string, keys map[string]interface{}) erro
 		Key:       av,
 		TableName: aws.String(table),
 	}
+	cond, ok, err := revisionCondition(keys)
+	if err != nil {
+		return err
+	}
+	if ok {
+		input.ConditionExpression = cond.Condition()
+		input.ExpressionAttributeNames = cond.Names()
+		input.ExpressionAttributeValues = cond.Values()
+	}
 	_, err = db.database.DeleteItem(input)
 	if err != nil {
+		if aerr, isAws := err.(awserr.Error); isAws && ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return fmt.Errorf("%w, record [%v/%v] is not on revision [%v]", DbIface.ErrRevisionConflict, keys[Record.DataType], keys[Record.DataId], keys[Record.Revision])
+		}
 		log.Printf("Got error calling DeleteItem: %s", err)
 		return err
 	}
 	return nil
 }
 
+// condition of keys with [__rev], records saved without [__rev] match expected revision 0
+func revisionCondition(keys map[string]interface{}) (*expression.Expression, bool, error) {
+	expected, ok, err := DbIface.ExpectedRevision(keys)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	revName := expression.Name(Record.Revision)
+	cond := revName.Equal(expression.Value(expected))
+	if expected == 0 {
+		cond = expression.Or(cond, expression.AttributeNotExists(revName))
+	}
+	cond = expression.And(expression.AttributeExists(expression.Name(Record.DataId)), cond)
+	expr, err := expression.NewBuilder().WithCondition(cond).Build()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to build revision condition. Error:%s", err)
+	}
+	return &expr, true, nil
+}
+
 func (db *dynamoDB) Update(table string, keys map[string]interface{}, data interface{}) (map[string]interface{}, error) {
 	dataType, ok := keys[Record.DataType].(string)
 	if !ok {
@@ -290,6 +456,13 @@ func (db *dynamoDB) Update(table string, keys map[string]interface{}, data inter
 	if len(patchData) == 0 {
 		return nil, fmt.Errorf("data [%s/%s] does not exists", dataType, dataId)
 	}
+	err = DbIface.CheckRevision(patchData[0], keys)
+	if err != nil {
+		return nil, err
+	}
+	// write back only if record is not changed since read
+	writeKeys := DbIface.RecordKeys(keys)
+	writeKeys[Record.Revision] = DbIface.RecordRevision(patchData[0])
 	subData, attrPath, err := DbIface.GetDataOnPath(patchData[0], queryPath, fmt.Sprintf("%s/%s/%s", dataType, dataId, queryPath))
 	if err != nil {
 		return nil, err
@@ -298,42 +471,170 @@ func (db *dynamoDB) Update(table string, keys map[string]interface{}, data inter
 	if err != nil {
 		return nil, err
 	}
-	err = db.Create(table, patchData[0])
+	DbIface.BumpRevision(patchData[0])
+	err = db.Commit(DbIface.NewBatch().Replace(table, writeKeys, patchData[0]))
 	if err != nil {
 		return nil, err
 	}
 	return patchData[0], nil
 }
 
-func Connect(config DbConfig.DatabaseConfig, logger *log.Logger) (DbIface.Database, error) {
+// keys point to the same item as data, so replace could be a single put
+func sameItem(keys map[string]interface{}, data interface{}) bool {
+	payload, ok := data.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for key, value := range DbIface.RecordKeys(keys) {
+		if payload[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// keys carry the revision condition of a replace, nil for create or unconditional put
+func (db *dynamoDB) transactPut(table string, data interface{}, createOnly bool, keys map[string]interface{}) (*dynamodb.TransactWriteItem, error) {
+	av, err := MarshalMapWithCustomEncoder(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data. Error:%s", err)
+	}
+	put := &dynamodb.Put{
+		Item:      av,
+		TableName: aws.String(table),
+	}
+	if createOnly {
+		put.ConditionExpression = aws.String("attribute_not_exists(#id)")
+		put.ExpressionAttributeNames = map[string]*string{
+			"#id": aws.String(Record.DataId),
+		}
+	}
+	cond, ok, err := revisionCondition(keys)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		put.ConditionExpression = cond.Condition()
+		put.ExpressionAttributeNames = cond.Names()
+		put.ExpressionAttributeValues = cond.Values()
+	}
+	return &dynamodb.TransactWriteItem{Put: put}, nil
+}
+
+func (db *dynamoDB) transactDelete(table string, keys map[string]interface{}) (*dynamodb.TransactWriteItem, error) {
+	av, err := dynamodbattribute.MarshalMap(DbIface.RecordKeys(keys))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal keys. Error:%s", err)
+	}
+	del := &dynamodb.Delete{
+		Key:       av,
+		TableName: aws.String(table),
+	}
+	cond, ok, err := revisionCondition(keys)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		del.ConditionExpression = cond.Condition()
+		del.ExpressionAttributeNames = cond.Names()
+		del.ExpressionAttributeValues = cond.Values()
+	}
+	return &dynamodb.TransactWriteItem{Delete: del}, nil
+}
+
+func (db *dynamoDB) Commit(batch *DbIface.Batch) error {
+	if batch.Empty() {
+		return nil
+	}
+	items := []*dynamodb.TransactWriteItem{}
+	// index of items with revision condition, to tell revision conflict from other cancel reasons
+	revItems := map[int]bool{}
+	for idx, op := range batch.Operations {
+		if _, ok := op.Keys[Record.Revision]; ok && op.Action != DbIface.OpCreate {
+			revItems[len(items)] = true
+		}
+		switch op.Action {
+		case DbIface.OpCreate:
+			item, err := db.transactPut(op.Table, op.Data, true, nil)
+			if err != nil {
+				return fmt.Errorf("batch operation [%d] failed. Error:%s", idx, err)
+			}
+			items = append(items, item)
+		case DbIface.OpReplace:
+			putKeys := op.Keys
+			if !sameItem(op.Keys, op.Data) {
+				item, err := db.transactDelete(op.Table, op.Keys)
+				if err != nil {
+					return fmt.Errorf("batch operation [%d] failed. Error:%s", idx, err)
+				}
+				items = append(items, item)
+				// revision is checked on delete of the current item
+				putKeys = nil
+			}
+			item, err := db.transactPut(op.Table, op.Data, false, putKeys)
+			if err != nil {
+				return fmt.Errorf("batch operation [%d] failed. Error:%s", idx, err)
+			}
+			items = append(items, item)
+		case DbIface.OpDelete:
+			item, err := db.transactDelete(op.Table, op.Keys)
+			if err != nil {
+				return fmt.Errorf("batch operation [%d] failed. Error:%s", idx, err)
+			}
+			items = append(items, item)
+		default:
+			return fmt.Errorf("batch operation [%d] failed. unknown action [%s]", idx, op.Action)
+		}
+	}
+	if len(items) > MaxTransactItems {
+		return fmt.Errorf("too many items [%d] in one transaction, max=[%d]", len(items), MaxTransactItems)
+	}
+	_, err := db.database.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		if cancel, ok := err.(*dynamodb.TransactionCanceledException); ok {
+			for idx, reason := range cancel.CancellationReasons {
+				if revItems[idx] && aws.StringValue(reason.Code) == "ConditionalCheckFailed" {
+					return fmt.Errorf("%w, transaction item [%d] failed revision check", DbIface.ErrRevisionConflict, idx)
+				}
+			}
+		}
+		log.Printf("Got error calling TransactWriteItems: %s", err)
+		return err
+	}
+	return nil
+}
+
+func Connect(config DbConfig.DynmoDbConfig, logger *log.Logger) (DbIface.Database, error) {
 	if logger == nil {
 		logger = log.Default()
 	}
-	if config.Dynamodb.Region == "" {
+	if config.Region == "" {
 		err := fmt.Errorf("missing configuration region")
 		return nil, err
 	}
-	if config.Dynamodb.EndPoint == "" {
+	if config.EndPoint == "" {
 		err := fmt.Errorf("missing configuration endpoint")
 		return nil, err
 	}
-	if config.Dynamodb.AccessKey == "" {
-		config.Dynamodb.AccessKey = "dummyAccessKey"
+	if config.AccessKey == "" {
+		config.AccessKey = "dummyAccessKey"
 	}
-	if config.Dynamodb.SecretKey == "" {
-		config.Dynamodb.SecretKey = "dummySecret"
+	if config.SecretKey == "" {
+		config.SecretKey = "dummySecret"
 	}
-	if config.Dynamodb.AccessToken == "" {
-		config.Dynamodb.AccessToken = "dummyToken"
+	if config.AccessToken == "" {
+		config.AccessToken = "dummyToken"
 	}
 
 	dbSession, err := session.NewSession(&aws.Config{
-		Region:      aws.String(config.Dynamodb.Region),
-		Endpoint:    aws.String(config.Dynamodb.EndPoint),
-		Credentials: credentials.NewStaticCredentials(config.Dynamodb.AccessKey, config.Dynamodb.SecretKey, config.Dynamodb.AccessToken),
+		Region:      aws.String(config.Region),
+		Endpoint:    aws.String(config.EndPoint),
+		Credentials: credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, config.AccessToken),
 	})
 	if err != nil {
-		newErr := fmt.Errorf("failed to create AWS session, region:%s, endpoint:%s, error:%s", config.Dynamodb.Region, config.Dynamodb.EndPoint, err.Error())
+		newErr := fmt.Errorf("failed to create AWS session, region:%s, endpoint:%s, error:%s", config.Region, config.EndPoint, err.Error())
 		return nil, newErr
 	}
 	dbSvc := dynamodb.New(dbSession)
@@ -0,0 +1,84 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DbIface
+
+const (
+	OpCreate  = "create"
+	OpReplace = "replace"
+	OpDelete  = "delete"
+)
+
+// Operation is one write in a Batch, Keys is not used by OpCreate and Data is not used by OpDelete
+type Operation struct {
+	Action string
+	Table  string
+	Keys   map[string]interface{}
+	Data   interface{}
+}
+
+// Batch collects writes to be committed by Database.Commit, either all of them are applied or none of them
+type Batch struct {
+	Operations []*Operation
+}
+
+func NewBatch() *Batch {
+	batch := Batch{
+		Operations: []*Operation{},
+	}
+	return &batch
+}
+
+func (b *Batch) Create(table string, data interface{}) *Batch {
+	b.Operations = append(b.Operations, &Operation{
+		Action: OpCreate,
+		Table:  table,
+		Data:   data,
+	})
+	return b
+}
+
+func (b *Batch) Replace(table string, keys map[string]interface{}, data interface{}) *Batch {
+	b.Operations = append(b.Operations, &Operation{
+		Action: OpReplace,
+		Table:  table,
+		Keys:   keys,
+		Data:   data,
+	})
+	return b
+}
+
+func (b *Batch) Delete(table string, keys map[string]interface{}) *Batch {
+	b.Operations = append(b.Operations, &Operation{
+		Action: OpDelete,
+		Table:  table,
+		Keys:   keys,
+	})
+	return b
+}
+
+func (b *Batch) Empty() bool {
+	return b == nil || len(b.Operations) == 0
+}
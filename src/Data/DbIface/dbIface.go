@@ -47,10 +47,13 @@ type Database interface {
 	CreateTable(name string, data map[string]interface{}) error
 	DeleteTable(name string) error
 	Get(queryArgs map[string]interface{}) ([]map[string]interface{}, error)
+	// GetPage return at most pageSize records after pageToken and the token of next page, "" when no more records
+	GetPage(queryArgs map[string]interface{}, pageSize int, pageToken string) ([]map[string]interface{}, string, error)
 	Create(table string, data interface{}) error
 	Update(table string, keys map[string]interface{}, data interface{}) (map[string]interface{}, error)
 	Replace(table string, keys map[string]interface{}, data interface{}) error
 	Delete(table string, keys map[string]interface{}) error
+	Commit(batch *Batch) error
 }
 
 // walk into data with dataPath
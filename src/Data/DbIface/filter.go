@@ -0,0 +1,280 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DbIface
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+const (
+	// queryArgs key of *FilterExpr for Database.Get
+	Filter = "filter"
+
+	FilterAnd = "and"
+	FilterEq  = "eq"
+	FilterNe  = "ne"
+	FilterGt  = "gt"
+	FilterGte = "gte"
+	FilterLt  = "lt"
+	FilterLte = "lte"
+	FilterIn  = "in"
+
+	FilterPathDiv = "/"
+)
+
+// FilterExpr is a backend neutral filter on record data.
+// Path is the attribute path under record [data], nested attribute separated by "/", ex: rack/name
+type FilterExpr struct {
+	Op     string        `json:"op"`
+	Path   string        `json:"path,omitempty"`
+	Value  interface{}   `json:"value,omitempty"`
+	Values []interface{} `json:"values,omitempty"`
+	Items  []*FilterExpr `json:"items,omitempty"`
+}
+
+func Eq(path string, value interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterEq, Path: path, Value: value}
+}
+
+func Ne(path string, value interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterNe, Path: path, Value: value}
+}
+
+func Gt(path string, value interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterGt, Path: path, Value: value}
+}
+
+func Gte(path string, value interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterGte, Path: path, Value: value}
+}
+
+func Lt(path string, value interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterLt, Path: path, Value: value}
+}
+
+func Lte(path string, value interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterLte, Path: path, Value: value}
+}
+
+func In(path string, values ...interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterIn, Path: path, Values: values}
+}
+
+func And(items ...*FilterExpr) *FilterExpr {
+	return &FilterExpr{Op: FilterAnd, Items: items}
+}
+
+// GetFilter return filter from queryArgs, nil if there is no filter
+func GetFilter(queryArgs map[string]interface{}) (*FilterExpr, error) {
+	value, ok := queryArgs[Filter]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	filter, ok := value.(*FilterExpr)
+	if !ok {
+		return nil, fmt.Errorf("invalid queryArgs [%s], expect *FilterExpr, got [%s]", Filter, reflect.TypeOf(value))
+	}
+	if filter == nil {
+		return nil, nil
+	}
+	err := filter.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func (f *FilterExpr) Validate() error {
+	switch f.Op {
+	case FilterAnd:
+		if len(f.Items) == 0 {
+			return fmt.Errorf("filter op=[%s] has no items", f.Op)
+		}
+		for idx, item := range f.Items {
+			if item == nil {
+				return fmt.Errorf("filter op=[%s] item [%d] is nil", f.Op, idx)
+			}
+			err := item.Validate()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case FilterEq, FilterNe, FilterGt, FilterGte, FilterLt, FilterLte, FilterIn:
+		if len(f.PathList()) == 0 {
+			return fmt.Errorf("filter op=[%s] missing path", f.Op)
+		}
+		if f.Op == FilterIn && len(f.Values) == 0 {
+			return fmt.Errorf("filter op=[%s] @path=[%s] has no values", f.Op, f.Path)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown filter op=[%s]", f.Op)
+	}
+}
+
+// PathList return attribute names from record root, start with [data]
+func (f *FilterExpr) PathList() []string {
+	pathList := []string{}
+	for _, attr := range strings.Split(f.Path, FilterPathDiv) {
+		if attr != "" {
+			pathList = append(pathList, attr)
+		}
+	}
+	if len(pathList) == 0 {
+		return pathList
+	}
+	return append([]string{Record.Data}, pathList...)
+}
+
+func filterValue(record map[string]interface{}, pathList []string) (interface{}, bool) {
+	var current interface{} = record
+	for _, attr := range pathList {
+		currentMap, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = currentMap[attr]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// compare 2 values, ok is false when they are not comparable
+func compareValue(left interface{}, right interface{}) (int, bool) {
+	if leftNum, ok := toFloat(left); ok {
+		rightNum, ok := toFloat(right)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case leftNum < rightNum:
+			return -1, true
+		case leftNum > rightNum:
+			return 1, true
+		}
+		return 0, true
+	}
+	if leftStr, ok := left.(string); ok {
+		rightStr, ok := right.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(leftStr, rightStr), true
+	}
+	if leftBool, ok := left.(bool); ok {
+		rightBool, ok := right.(bool)
+		if !ok || leftBool != rightBool {
+			return 0, false
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func valueEqual(left interface{}, right interface{}) bool {
+	result, ok := compareValue(left, right)
+	return ok && result == 0
+}
+
+// Match evaluate filter on record in memory, for backends without native query support
+func (f *FilterExpr) Match(record map[string]interface{}) bool {
+	if f.Op == FilterAnd {
+		for _, item := range f.Items {
+			if !item.Match(record) {
+				return false
+			}
+		}
+		return true
+	}
+	value, ok := filterValue(record, f.PathList())
+	if !ok {
+		return f.Op == FilterNe
+	}
+	switch f.Op {
+	case FilterEq:
+		return valueEqual(value, f.Value)
+	case FilterNe:
+		return !valueEqual(value, f.Value)
+	case FilterIn:
+		for _, item := range f.Values {
+			if valueEqual(value, item) {
+				return true
+			}
+		}
+		return false
+	}
+	result, ok := compareValue(value, f.Value)
+	if !ok {
+		return false
+	}
+	switch f.Op {
+	case FilterGt:
+		return result > 0
+	case FilterGte:
+		return result >= 0
+	case FilterLt:
+		return result < 0
+	case FilterLte:
+		return result <= 0
+	}
+	return false
+}
+
+func FilterRecords(recordList []map[string]interface{}, filter *FilterExpr) []map[string]interface{} {
+	if filter == nil {
+		return recordList
+	}
+	result := make([]map[string]interface{}, 0, len(recordList))
+	for _, record := range recordList {
+		if filter.Match(record) {
+			result = append(result, record)
+		}
+	}
+	return result
+}
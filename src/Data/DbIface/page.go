@@ -0,0 +1,112 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DbIface
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// PageKey is the position of last record returned in a page,
+// used by backends that page on record keys
+type PageKey struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+// EncodePageToken turn backend specific position into opaque page token
+func EncodePageToken(position interface{}) (string, error) {
+	raw, err := json.Marshal(position)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode page token, Error: %s", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// DecodePageToken parse opaque page token into position, returned error wraps ErrInvalidPageToken
+func DecodePageToken(pageToken string, position interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return fmt.Errorf("%w [%s], Error: %s", ErrInvalidPageToken, pageToken, err)
+	}
+	err = json.Unmarshal(raw, position)
+	if err != nil {
+		return fmt.Errorf("%w [%s], Error: %s", ErrInvalidPageToken, pageToken, err)
+	}
+	return nil
+}
+
+func recordKey(record map[string]interface{}) PageKey {
+	key := PageKey{}
+	key.Type, _ = record[Record.DataType].(string)
+	key.Id, _ = record[Record.DataId].(string)
+	return key
+}
+
+func (k PageKey) Less(other PageKey) bool {
+	if k.Type != other.Type {
+		return k.Type < other.Type
+	}
+	return k.Id < other.Id
+}
+
+// PageRecords return one page from a full record list, ordered by [__type, __id].
+// for backends that load all records in memory anyway.
+// pageSize <= 0 returns all records after pageToken
+func PageRecords(recordList []map[string]interface{}, pageSize int, pageToken string) ([]map[string]interface{}, string, error) {
+	start := PageKey{}
+	if pageToken != "" {
+		err := DecodePageToken(pageToken, &start)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	sort.SliceStable(recordList, func(i, j int) bool {
+		return recordKey(recordList[i]).Less(recordKey(recordList[j]))
+	})
+	result := []map[string]interface{}{}
+	for _, record := range recordList {
+		if pageToken != "" && !start.Less(recordKey(record)) {
+			continue
+		}
+		if pageSize > 0 && len(result) == pageSize {
+			nextToken, err := EncodePageToken(recordKey(result[len(result)-1]))
+			if err != nil {
+				return nil, "", err
+			}
+			return result, nextToken, nil
+		}
+		result = append(result, record)
+	}
+	return result, "", nil
+}
@@ -0,0 +1,113 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DbIface
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+// ErrRevisionConflict is wrapped by errors of Replace/Update/Delete/Commit
+// when keys carry [__rev] and stored record is on a different revision
+var ErrRevisionConflict = errors.New("revision conflict")
+
+// RevisionValue convert revision value loaded from json/bson/dynamodb into int64
+func RevisionValue(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("invalid revision [%v], expect integer", v)
+		}
+		return int64(v), nil
+	case json.Number:
+		return v.Int64()
+	}
+	return 0, fmt.Errorf("invalid revision type [%s], expect integer", reflect.TypeOf(value))
+}
+
+// RecordRevision return revision of record, 0 when record is saved before revision is introduced
+func RecordRevision(record map[string]interface{}) int64 {
+	revision, _ := RevisionValue(record[Record.Revision])
+	return revision
+}
+
+// ExpectedRevision return the revision condition carried in keys, ok is false when there is no condition
+func ExpectedRevision(keys map[string]interface{}) (int64, bool, error) {
+	value, ok := keys[Record.Revision]
+	if !ok {
+		return 0, false, nil
+	}
+	revision, err := RevisionValue(value)
+	if err != nil {
+		return 0, false, err
+	}
+	return revision, true, nil
+}
+
+// CheckRevision validate the revision condition in keys against current record, current is nil when record does not exists.
+func CheckRevision(current map[string]interface{}, keys map[string]interface{}) error {
+	expected, ok, err := ExpectedRevision(keys)
+	if err != nil || !ok {
+		return err
+	}
+	if current == nil {
+		return fmt.Errorf("%w, record [%v/%v] does not exists, expect revision [%d]", ErrRevisionConflict, keys[Record.DataType], keys[Record.DataId], expected)
+	}
+	currentRev := RecordRevision(current)
+	if currentRev != expected {
+		return fmt.Errorf("%w, record [%v/%v] is on revision [%d], expect revision [%d]", ErrRevisionConflict, keys[Record.DataType], keys[Record.DataId], currentRev, expected)
+	}
+	return nil
+}
+
+// BumpRevision set next revision on record that is updated in place
+func BumpRevision(record map[string]interface{}) {
+	record[Record.Revision] = RecordRevision(record) + 1
+}
+
+// RecordKeys return [__type] and [__id] from keys, without conditions like [__rev] and [patchPath]
+func RecordKeys(keys map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, key := range []string{Record.DataType, Record.DataId} {
+		if value, ok := keys[key]; ok {
+			result[key] = value
+		}
+	}
+	return result
+}
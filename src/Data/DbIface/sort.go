@@ -0,0 +1,72 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DbIface
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+// SortKey order records by attribute on Path under record [data], same path format as FilterExpr
+type SortKey struct {
+	Path string `json:"path"`
+	Desc bool   `json:"desc,omitempty"`
+}
+
+func (k SortKey) PathList() []string {
+	return (&FilterExpr{Path: k.Path}).PathList()
+}
+
+// SortRecords order records in memory by sortKeys, record without value of a key is ordered last,
+// ties are ordered by [__id]
+func SortRecords(recordList []map[string]interface{}, sortKeys []SortKey) {
+	sort.SliceStable(recordList, func(i, j int) bool {
+		for _, key := range sortKeys {
+			pathList := key.PathList()
+			left, leftOk := filterValue(recordList[i], pathList)
+			right, rightOk := filterValue(recordList[j], pathList)
+			if !leftOk || !rightOk {
+				if leftOk != rightOk {
+					return leftOk
+				}
+				continue
+			}
+			result, ok := compareValue(left, right)
+			if !ok || result == 0 {
+				continue
+			}
+			if key.Desc {
+				return result > 0
+			}
+			return result < 0
+		}
+		leftId, _ := recordList[i][Record.DataId].(string)
+		rightId, _ := recordList[j][Record.DataId].(string)
+		return strings.Compare(leftId, rightId) < 0
+	})
+}
@@ -0,0 +1,311 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DbMetrics
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"Data/DbIface"
+)
+
+const (
+	Prefix = "unitao_db"
+
+	MethodListTable   = "ListTable"
+	MethodCreateTable = "CreateTable"
+	MethodDeleteTable = "DeleteTable"
+	MethodGet         = "Get"
+	MethodGetPage     = "GetPage"
+	MethodCreate      = "Create"
+	MethodUpdate      = "Update"
+	MethodReplace     = "Replace"
+	MethodDelete      = "Delete"
+	MethodCommit      = "Commit"
+
+	// table label of calls not on one table
+	AnyTable = "*"
+)
+
+// upper bound of latency buckets in seconds
+var LatencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// upper bound of result size buckets in records
+var SizeBuckets = []float64{0, 1, 10, 100, 1000, 10000}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *histogram) observe(value float64) {
+	for idx, bound := range h.buckets {
+		if value <= bound {
+			h.counts[idx]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+type seriesKey struct {
+	method string
+	table  string
+}
+
+type series struct {
+	latency *histogram
+	size    *histogram
+	errors  uint64
+}
+
+// Stats of calls of one method on one table
+type Stats struct {
+	Method     string  `json:"method"`
+	Table      string  `json:"table"`
+	Count      uint64  `json:"count"`
+	Errors     uint64  `json:"errors"`
+	LatencySum float64 `json:"latencySum"`
+	Records    uint64  `json:"records"`
+}
+
+// Database records latency, errors and result size of every call to the Database it wraps
+type Database struct {
+	db     DbIface.Database
+	logger *log.Logger
+	lock   sync.Mutex
+	series map[seriesKey]*series
+}
+
+func New(db DbIface.Database, logger *log.Logger) (*Database, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if db == nil {
+		return nil, fmt.Errorf("missing database to instrument")
+	}
+	return &Database{
+		db:     db,
+		logger: logger,
+		series: map[seriesKey]*series{},
+	}, nil
+}
+
+// size < 0 when call does not return records
+func (m *Database) observe(method string, table string, start time.Time, size int, err error) {
+	elapsed := time.Since(start).Seconds()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := seriesKey{method: method, table: table}
+	item, ok := m.series[key]
+	if !ok {
+		item = &series{
+			latency: newHistogram(LatencyBuckets),
+			size:    newHistogram(SizeBuckets),
+		}
+		m.series[key] = item
+	}
+	item.latency.observe(elapsed)
+	if err != nil {
+		item.errors++
+		return
+	}
+	if size >= 0 {
+		item.size.observe(float64(size))
+	}
+}
+
+func (m *Database) Name() string {
+	return m.db.Name()
+}
+
+func (m *Database) ListTable() ([]interface{}, error) {
+	start := time.Now()
+	result, err := m.db.ListTable()
+	m.observe(MethodListTable, AnyTable, start, len(result), err)
+	return result, err
+}
+
+func (m *Database) CreateTable(name string, data map[string]interface{}) error {
+	start := time.Now()
+	err := m.db.CreateTable(name, data)
+	m.observe(MethodCreateTable, name, start, -1, err)
+	return err
+}
+
+func (m *Database) DeleteTable(name string) error {
+	start := time.Now()
+	err := m.db.DeleteTable(name)
+	m.observe(MethodDeleteTable, name, start, -1, err)
+	return err
+}
+
+func (m *Database) Get(queryArgs map[string]interface{}) ([]map[string]interface{}, error) {
+	start := time.Now()
+	result, err := m.db.Get(queryArgs)
+	table, _ := queryArgs[DbIface.Table].(string)
+	m.observe(MethodGet, table, start, len(result), err)
+	return result, err
+}
+
+func (m *Database) GetPage(queryArgs map[string]interface{}, pageSize int, pageToken string) ([]map[string]interface{}, string, error) {
+	start := time.Now()
+	result, nextToken, err := m.db.GetPage(queryArgs, pageSize, pageToken)
+	table, _ := queryArgs[DbIface.Table].(string)
+	m.observe(MethodGetPage, table, start, len(result), err)
+	return result, nextToken, err
+}
+
+func (m *Database) Create(table string, data interface{}) error {
+	start := time.Now()
+	err := m.db.Create(table, data)
+	m.observe(MethodCreate, table, start, -1, err)
+	return err
+}
+
+func (m *Database) Update(table string, keys map[string]interface{}, data interface{}) (map[string]interface{}, error) {
+	start := time.Now()
+	result, err := m.db.Update(table, keys, data)
+	m.observe(MethodUpdate, table, start, -1, err)
+	return result, err
+}
+
+func (m *Database) Replace(table string, keys map[string]interface{}, data interface{}) error {
+	start := time.Now()
+	err := m.db.Replace(table, keys, data)
+	m.observe(MethodReplace, table, start, -1, err)
+	return err
+}
+
+func (m *Database) Delete(table string, keys map[string]interface{}) error {
+	start := time.Now()
+	err := m.db.Delete(table, keys)
+	m.observe(MethodDelete, table, start, -1, err)
+	return err
+}
+
+// batch on more than one table is recorded on AnyTable, size is number of operations
+func (m *Database) Commit(batch *DbIface.Batch) error {
+	table := ""
+	for _, op := range batch.Operations {
+		if table == "" {
+			table = op.Table
+		} else if table != op.Table {
+			table = AnyTable
+			break
+		}
+	}
+	start := time.Now()
+	err := m.db.Commit(batch)
+	m.observe(MethodCommit, table, start, len(batch.Operations), err)
+	return err
+}
+
+func (m *Database) sortedKeys() []seriesKey {
+	keyList := make([]seriesKey, 0, len(m.series))
+	for key := range m.series {
+		keyList = append(keyList, key)
+	}
+	sort.Slice(keyList, func(i, j int) bool {
+		if keyList[i].method != keyList[j].method {
+			return keyList[i].method < keyList[j].method
+		}
+		return keyList[i].table < keyList[j].table
+	})
+	return keyList
+}
+
+func (m *Database) Stats() []Stats {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	result := make([]Stats, 0, len(m.series))
+	for _, key := range m.sortedKeys() {
+		item := m.series[key]
+		result = append(result, Stats{
+			Method:     key.method,
+			Table:      key.table,
+			Count:      item.latency.count,
+			Errors:     item.errors,
+			LatencySum: item.latency.sum,
+			Records:    uint64(item.size.sum),
+		})
+	}
+	return result
+}
+
+// WriteText write metrics in Prometheus text exposition format
+func (m *Database) WriteText(w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	keyList := m.sortedKeys()
+	latencyName := fmt.Sprintf("%s_call_duration_seconds", Prefix)
+	fmt.Fprintf(w, "# HELP %s latency of database calls\n# TYPE %s histogram\n", latencyName, latencyName)
+	for _, key := range keyList {
+		writeHistogram(w, latencyName, key, m.series[key].latency)
+	}
+	errorName := fmt.Sprintf("%s_call_errors_total", Prefix)
+	fmt.Fprintf(w, "# HELP %s failed database calls\n# TYPE %s counter\n", errorName, errorName)
+	for _, key := range keyList {
+		fmt.Fprintf(w, "%s{%s} %d\n", errorName, labels(key), m.series[key].errors)
+	}
+	sizeName := fmt.Sprintf("%s_result_records", Prefix)
+	fmt.Fprintf(w, "# HELP %s records returned or written by successful database calls\n# TYPE %s histogram\n", sizeName, sizeName)
+	for _, key := range keyList {
+		if m.series[key].size.count > 0 {
+			writeHistogram(w, sizeName, key, m.series[key].size)
+		}
+	}
+}
+
+var labelEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labels(key seriesKey) string {
+	return fmt.Sprintf(`method="%s",table="%s"`, labelEscape.Replace(key.method), labelEscape.Replace(key.table))
+}
+
+func writeHistogram(w io.Writer, name string, key seriesKey, h *histogram) {
+	keyLabels := labels(key)
+	for idx, bound := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%g\"} %d\n", name, keyLabels, bound, h.counts[idx])
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, keyLabels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %g\n", name, keyLabels, h.sum)
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, keyLabels, h.count)
+}
@@ -0,0 +1,648 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package InMemory
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"Data/DbConfig"
+	"Data/DbIface"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+const (
+	Name           = "inmemory"
+	DefaultLogSize = 1000
+)
+
+// table -> type -> id -> record
+type tableData map[string]map[string]map[string]interface{}
+
+type inMemory struct {
+	logger *log.Logger
+	config DbConfig.InMemoryConfig
+	lock   sync.RWMutex
+	tables map[string]tableData
+	oplog  *opLog
+}
+
+func (db *inMemory) Name() string {
+	return Name
+}
+
+func copyData(data interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data. Error:%s", err)
+	}
+	result := map[string]interface{}{}
+	err = json.Unmarshal(raw, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data. Error:%s", err)
+	}
+	return result, nil
+}
+
+func recordKeys(data interface{}) (map[string]interface{}, string, string, error) {
+	payload, err := copyData(data)
+	if err != nil {
+		return nil, "", "", err
+	}
+	dataType, ok := payload[Record.DataType].(string)
+	if !ok || dataType == "" {
+		return nil, "", "", fmt.Errorf("missing key [%s] from data", Record.DataType)
+	}
+	dataId, ok := payload[Record.DataId].(string)
+	if !ok || dataId == "" {
+		return nil, "", "", fmt.Errorf("missing key [%s] from data", Record.DataId)
+	}
+	return payload, dataType, dataId, nil
+}
+
+func (db *inMemory) getTable(name string) (tableData, error) {
+	table, ok := db.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("table [%s] does not exists", name)
+	}
+	return table, nil
+}
+
+// find the type of the record with keys, [__type] could be missing, then search all types for [__id]
+func (t tableData) find(keys map[string]interface{}) (string, string, map[string]interface{}, error) {
+	dataId, ok := keys[Record.DataId].(string)
+	if !ok {
+		return "", "", nil, fmt.Errorf("missing key=[%s]", Record.DataId)
+	}
+	if dataType, ok := keys[Record.DataType].(string); ok {
+		record, ok := t[dataType][dataId]
+		if !ok {
+			return dataType, dataId, nil, nil
+		}
+		return dataType, dataId, record, nil
+	}
+	for dataType, typeData := range t {
+		if record, ok := typeData[dataId]; ok {
+			return dataType, dataId, record, nil
+		}
+	}
+	return "", dataId, nil, nil
+}
+
+func (t tableData) put(dataType string, dataId string, record map[string]interface{}) {
+	if _, ok := t[dataType]; !ok {
+		t[dataType] = map[string]map[string]interface{}{}
+	}
+	t[dataType][dataId] = record
+}
+
+func (t tableData) remove(dataType string, dataId string) {
+	delete(t[dataType], dataId)
+	if len(t[dataType]) == 0 {
+		delete(t, dataType)
+	}
+}
+
+func (db *inMemory) ListTable() ([]interface{}, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	tableList := make([]interface{}, 0, len(db.tables))
+	for name := range db.tables {
+		tableList = append(tableList, name)
+	}
+	return tableList, nil
+}
+
+func (db *inMemory) CreateTable(name string, data map[string]interface{}) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if _, ok := db.tables[name]; ok {
+		return nil
+	}
+	err := db.persist(&DbIface.Operation{
+		Action: opCreateTable,
+		Table:  name,
+	})
+	if err != nil {
+		return err
+	}
+	db.tables[name] = tableData{}
+	db.compact()
+	return nil
+}
+
+func (db *inMemory) DeleteTable(name string) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if _, ok := db.tables[name]; !ok {
+		return nil
+	}
+	err := db.persist(&DbIface.Operation{
+		Action: opDeleteTable,
+		Table:  name,
+	})
+	if err != nil {
+		return err
+	}
+	delete(db.tables, name)
+	db.compact()
+	return nil
+}
+
+func (db *inMemory) Get(queryArgs map[string]interface{}) ([]map[string]interface{}, error) {
+	tableName, ok := queryArgs[DbIface.Table].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing parameter [%s] from queryArgs", DbIface.Table)
+	}
+	filter, err := DbIface.GetFilter(queryArgs)
+	if err != nil {
+		return nil, err
+	}
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	table, err := db.getTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	result := []map[string]interface{}{}
+	if _, ok := queryArgs[Record.DataId].(string); ok {
+		_, _, record, err := table.find(queryArgs)
+		if err != nil || record == nil {
+			return result, err
+		}
+		if filter != nil && !filter.Match(record) {
+			return result, nil
+		}
+		record, err = copyData(record)
+		if err != nil {
+			return nil, err
+		}
+		return append(result, record), nil
+	}
+	for dataType, typeData := range table {
+		if queryType, ok := queryArgs[Record.DataType].(string); ok && queryType != dataType {
+			continue
+		}
+		for _, data := range typeData {
+			if filter != nil && !filter.Match(data) {
+				continue
+			}
+			record, err := copyData(data)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+// GetPage page on records returned by Get, they are copies sorted by [__type, __id]
+func (db *inMemory) GetPage(queryArgs map[string]interface{}, pageSize int, pageToken string) ([]map[string]interface{}, string, error) {
+	recordList, err := db.Get(queryArgs)
+	if err != nil {
+		return nil, "", err
+	}
+	return DbIface.PageRecords(recordList, pageSize, pageToken)
+}
+
+// check the operation against tables and return function to apply it, tables are not changed until it is called
+func createRecord(tables map[string]tableData, tableName string, data interface{}) (func(), error) {
+	payload, dataType, dataId, err := recordKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	table, ok := tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("table [%s] does not exists", tableName)
+	}
+	_, _, record, err := table.find(payload)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil {
+		return nil, fmt.Errorf("data [%s/%s] already exists", dataType, dataId)
+	}
+	return func() {
+		table.put(dataType, dataId, payload)
+	}, nil
+}
+
+func (db *inMemory) Create(tableName string, data interface{}) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	apply, err := createRecord(db.tables, tableName, data)
+	if err != nil {
+		return err
+	}
+	err = db.persist(&DbIface.Operation{
+		Action: DbIface.OpCreate,
+		Table:  tableName,
+		Data:   data,
+	})
+	if err != nil {
+		return err
+	}
+	apply()
+	db.compact()
+	return nil
+}
+
+func (db *inMemory) Update(tableName string, keys map[string]interface{}, data interface{}) (map[string]interface{}, error) {
+	dataType, ok := keys[Record.DataType].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing key=[%s]", Record.DataType)
+	}
+	dataId, ok := keys[Record.DataId].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing key=[%s]", Record.DataId)
+	}
+	if dataType == "" || dataId == "" {
+		return nil, fmt.Errorf("missing dataType/dataId, expect format:[{dataType}/{dataId}/{dataPath}]")
+	}
+	queryPath, ok := keys[DbIface.PatchPath].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing patch key=[%s]", DbIface.PatchPath)
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	table, err := db.getTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	_, _, record, err := table.find(keys)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, fmt.Errorf("data [%s/%s] does not exists", dataType, dataId)
+	}
+	err = DbIface.CheckRevision(record, keys)
+	if err != nil {
+		return nil, err
+	}
+	patchData, err := copyData(record)
+	if err != nil {
+		return nil, err
+	}
+	subData, attrPath, err := DbIface.GetDataOnPath(patchData, queryPath, fmt.Sprintf("%s/%s/%s", dataType, dataId, queryPath))
+	if err != nil {
+		return nil, err
+	}
+	err = DbIface.SetPatchData(subData, attrPath, data)
+	if err != nil {
+		return nil, err
+	}
+	DbIface.BumpRevision(patchData)
+	// log patched record as replace, so replay does not depend on patch logic
+	err = db.persist(&DbIface.Operation{
+		Action: DbIface.OpReplace,
+		Table:  tableName,
+		Keys: map[string]interface{}{
+			Record.DataType: dataType,
+			Record.DataId:   dataId,
+		},
+		Data: patchData,
+	})
+	if err != nil {
+		return nil, err
+	}
+	table.put(dataType, dataId, patchData)
+	db.compact()
+	return copyData(patchData)
+}
+
+func replaceRecord(tables map[string]tableData, tableName string, keys map[string]interface{}, data interface{}) (func(), error) {
+	payload, dataType, dataId, err := recordKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	table, ok := tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("table [%s] does not exists", tableName)
+	}
+	// keys could point to a different record, ex: schema archive replace current schema with archived id
+	currentType, currentId, record, err := table.find(keys)
+	if err != nil {
+		return nil, err
+	}
+	err = DbIface.CheckRevision(record, keys)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if record != nil {
+			table.remove(currentType, currentId)
+		}
+		table.put(dataType, dataId, payload)
+	}, nil
+}
+
+func (db *inMemory) Replace(tableName string, keys map[string]interface{}, data interface{}) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	apply, err := replaceRecord(db.tables, tableName, keys, data)
+	if err != nil {
+		return err
+	}
+	err = db.persist(&DbIface.Operation{
+		Action: DbIface.OpReplace,
+		Table:  tableName,
+		Keys:   keys,
+		Data:   data,
+	})
+	if err != nil {
+		return err
+	}
+	apply()
+	db.compact()
+	return nil
+}
+
+func deleteRecord(tables map[string]tableData, tableName string, keys map[string]interface{}) (func(), error) {
+	table, ok := tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("table [%s] does not exists", tableName)
+	}
+	dataType, dataId, record, err := table.find(keys)
+	if err != nil {
+		return nil, err
+	}
+	err = DbIface.CheckRevision(record, keys)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if record != nil {
+			table.remove(dataType, dataId)
+		}
+	}, nil
+}
+
+func (db *inMemory) Delete(tableName string, keys map[string]interface{}) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	apply, err := deleteRecord(db.tables, tableName, keys)
+	if err != nil {
+		return err
+	}
+	err = db.persist(&DbIface.Operation{
+		Action: DbIface.OpDelete,
+		Table:  tableName,
+		Keys:   keys,
+	})
+	if err != nil {
+		return err
+	}
+	apply()
+	db.compact()
+	return nil
+}
+
+func (t tableData) clone() tableData {
+	result := make(tableData, len(t))
+	for dataType, typeData := range t {
+		idMap := make(map[string]map[string]interface{}, len(typeData))
+		for dataId, record := range typeData {
+			idMap[dataId] = record
+		}
+		result[dataType] = idMap
+	}
+	return result
+}
+
+// operations are applied on a copy of the touched tables, which replace current ones only when all succeeded
+func (db *inMemory) Commit(batch *DbIface.Batch) error {
+	if batch.Empty() {
+		return nil
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	staged := make(map[string]tableData, len(db.tables))
+	for name, table := range db.tables {
+		staged[name] = table
+	}
+	cloned := map[string]bool{}
+	for idx, op := range batch.Operations {
+		if table, ok := staged[op.Table]; ok && !cloned[op.Table] {
+			staged[op.Table] = table.clone()
+			cloned[op.Table] = true
+		}
+		var apply func()
+		var err error
+		switch op.Action {
+		case DbIface.OpCreate:
+			apply, err = createRecord(staged, op.Table, op.Data)
+		case DbIface.OpReplace:
+			apply, err = replaceRecord(staged, op.Table, op.Keys, op.Data)
+		case DbIface.OpDelete:
+			apply, err = deleteRecord(staged, op.Table, op.Keys)
+		default:
+			err = fmt.Errorf("unknown action [%s]", op.Action)
+		}
+		if err != nil {
+			return fmt.Errorf("batch operation [%d] failed, rollback. Error:%w", idx, err)
+		}
+		apply()
+	}
+	err := db.persist(batch.Operations...)
+	if err != nil {
+		return err
+	}
+	db.tables = staged
+	db.compact()
+	return nil
+}
+
+// snapshot format: {tableName: [record, ...]}
+func (db *inMemory) snapshotData() map[string][]interface{} {
+	snapshot := map[string][]interface{}{}
+	for tableName, table := range db.tables {
+		recordList := []interface{}{}
+		for _, typeData := range table {
+			for _, record := range typeData {
+				recordList = append(recordList, record)
+			}
+		}
+		snapshot[tableName] = recordList
+	}
+	return snapshot
+}
+
+// append operations to log if snapshot is configured, must succeed before tables are changed. caller should hold the lock
+func (db *inMemory) persist(ops ...*DbIface.Operation) error {
+	if db.oplog == nil {
+		return nil
+	}
+	return db.oplog.append(ops)
+}
+
+// write tables to snapshot and reset log when log is full, caller should hold the lock.
+// writes are already durable in log, so failure is only logged and retried on next write
+func (db *inMemory) compact() {
+	if db.oplog == nil || db.oplog.count < db.config.LogSize {
+		return
+	}
+	err := db.saveSnapshot()
+	if err != nil {
+		db.logger.Printf("failed to compact log [%s], Error:%s", db.oplog.path, err)
+	}
+}
+
+func (db *inMemory) saveSnapshot() error {
+	err := db.writeSnapshot(db.config.Snapshot)
+	if err != nil {
+		return err
+	}
+	return db.oplog.reset()
+}
+
+func (db *inMemory) writeSnapshot(path string) error {
+	raw, err := json.MarshalIndent(db.snapshotData(), "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot. Error:%s", err)
+	}
+	tmpPath := fmt.Sprintf("%s.tmp", path)
+	err = os.WriteFile(tmpPath, raw, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot [%s]. Error:%s", tmpPath, err)
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot [%s]. Error:%s", path, err)
+	}
+	return nil
+}
+
+// write all tables to a JSON file
+func (db *inMemory) Snapshot(path string) error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	return db.writeSnapshot(path)
+}
+
+// replace all tables with the content of a snapshot JSON file
+func (db *inMemory) Load(path string) error {
+	tables, err := readSnapshot(path)
+	if err != nil {
+		return err
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	db.tables = tables
+	if db.oplog == nil {
+		return nil
+	}
+	// log is on top of configured snapshot, so save loaded tables there before next write
+	return db.saveSnapshot()
+}
+
+func readSnapshot(path string) (map[string]tableData, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot [%s]. Error:%s", path, err)
+	}
+	snapshot := map[string][]interface{}{}
+	err = json.Unmarshal(raw, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot [%s]. Error:%s", path, err)
+	}
+	tables := map[string]tableData{}
+	for tableName, recordList := range snapshot {
+		table := tableData{}
+		for idx, data := range recordList {
+			record, dataType, dataId, err := recordKeys(data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid record @[%s][%d] in snapshot [%s]. Error:%s", tableName, idx, path, err)
+			}
+			table.put(dataType, dataId, record)
+		}
+		tables[tableName] = table
+	}
+	return tables, nil
+}
+
+// snapshot plus log of writes after it, log is [{snapshot}.log]
+func Connect(config DbConfig.InMemoryConfig, logger *log.Logger) (DbIface.Database, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	db := inMemory{
+		logger: logger,
+		config: config,
+		tables: map[string]tableData{},
+	}
+	if config.Snapshot == "" {
+		return &db, nil
+	}
+	if db.config.LogSize <= 0 {
+		db.config.LogSize = DefaultLogSize
+	}
+	absPath, err := filepath.Abs(config.Snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse path to absolute path, path=[%s], Error:%s", config.Snapshot, err)
+	}
+	db.config.Snapshot = absPath
+	_, err = os.Stat(absPath)
+	if err == nil {
+		db.tables, err = readSnapshot(absPath)
+		if err != nil {
+			return nil, err
+		}
+		logger.Printf("loaded snapshot [%s]", absPath)
+	} else if os.IsNotExist(err) {
+		logger.Printf("snapshot [%s] does not exists, start with empty database", absPath)
+	} else {
+		return nil, fmt.Errorf("failed to stat snapshot [%s], Err:%s", absPath, err)
+	}
+	logPath := fmt.Sprintf("%s.log", absPath)
+	count, err := replayOpLog(logPath, db.tables)
+	if err != nil {
+		return nil, err
+	}
+	db.oplog, err = openOpLog(logPath)
+	if err != nil {
+		return nil, err
+	}
+	logInfo, err := db.oplog.file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat log [%s], Err:%s", logPath, err)
+	}
+	if logInfo.Size() > 0 {
+		logger.Printf("replayed [%d] writes from log [%s]", count, logPath)
+		// also drop incomplete line at the end of log, so later writes start on a new line
+		err = db.saveSnapshot()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &db, nil
+}
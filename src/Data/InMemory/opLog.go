@@ -0,0 +1,164 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package InMemory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"Data/DbIface"
+)
+
+const (
+	opCreateTable = "createTable"
+	opDeleteTable = "deleteTable"
+)
+
+// append only log of writes since last snapshot, one line of JSON list of operations per write
+type opLog struct {
+	path  string
+	file  *os.File
+	count int
+}
+
+func openOpLog(path string) (*opLog, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log [%s]. Error:%s", path, err)
+	}
+	return &opLog{
+		path: path,
+		file: file,
+	}, nil
+}
+
+// write is durable when append return, so operations of a batch are in one line to be replayed together
+func (l *opLog) append(ops []*DbIface.Operation) error {
+	raw, err := json.Marshal(ops)
+	if err != nil {
+		return fmt.Errorf("failed to marshal log entry. Error:%s", err)
+	}
+	_, err = l.file.Write(append(raw, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write log [%s]. Error:%s", l.path, err)
+	}
+	err = l.file.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync log [%s]. Error:%s", l.path, err)
+	}
+	l.count++
+	return nil
+}
+
+// drop all entries, called after they are written to snapshot
+func (l *opLog) reset() error {
+	err := l.file.Truncate(0)
+	if err != nil {
+		return fmt.Errorf("failed to truncate log [%s]. Error:%s", l.path, err)
+	}
+	l.count = 0
+	return l.file.Sync()
+}
+
+// apply log entries on tables, return number of entries replayed.
+// a line without line end is from a write interrupted before it returned, it is ignored.
+// entries could be already in snapshot when process stopped between snapshot and reset,
+// so operations are applied as overwrite without any check to replay them again safely.
+func replayOpLog(path string, tables map[string]tableData) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to open log [%s]. Error:%s", path, err)
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	count := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("failed to read log [%s]. Error:%s", path, err)
+		}
+		ops := []*DbIface.Operation{}
+		err = json.Unmarshal(line, &ops)
+		if err != nil {
+			return count, fmt.Errorf("invalid entry [%d] in log [%s]. Error:%s", count, path, err)
+		}
+		for _, op := range ops {
+			err = replayOperation(tables, op)
+			if err != nil {
+				return count, fmt.Errorf("failed to replay entry [%d] in log [%s]. Error:%s", count, path, err)
+			}
+		}
+		count++
+	}
+}
+
+func replayOperation(tables map[string]tableData, op *DbIface.Operation) error {
+	switch op.Action {
+	case opCreateTable:
+		if _, ok := tables[op.Table]; !ok {
+			tables[op.Table] = tableData{}
+		}
+		return nil
+	case opDeleteTable:
+		delete(tables, op.Table)
+		return nil
+	case DbIface.OpCreate, DbIface.OpReplace, DbIface.OpDelete:
+	default:
+		return fmt.Errorf("unknown action [%s]", op.Action)
+	}
+	table, ok := tables[op.Table]
+	if !ok {
+		table = tableData{}
+		tables[op.Table] = table
+	}
+	if op.Action != DbIface.OpCreate {
+		dataType, dataId, record, err := table.find(op.Keys)
+		if err != nil {
+			return err
+		}
+		if record != nil {
+			table.remove(dataType, dataId)
+		}
+	}
+	if op.Action == DbIface.OpDelete {
+		return nil
+	}
+	payload, dataType, dataId, err := recordKeys(op.Data)
+	if err != nil {
+		return err
+	}
+	table.put(dataType, dataId, payload)
+	return nil
+}
@@ -31,6 +31,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/salesforce/UniTAO/lib/Schema/Record"
 	"github.com/salesforce/UniTAO/lib/Util"
@@ -54,7 +55,7 @@ const (
 
 type mongoDb struct {
 	logger *log.Logger
-	config DbConfig.DatabaseConfig
+	config DbConfig.MongoDbConfig
 	client *mongo.Client
 }
 
@@ -68,8 +69,8 @@ func (db *mongoDb) Database() (*mongo.Database, error) {
 		return nil, fmt.Errorf("failed to list database. Error: %s", err)
 	}
 	for _, dbName := range dbList {
-		if dbName == db.config.Mongodb.Database {
-			database := db.client.Database(db.config.Mongodb.Database)
+		if dbName == db.config.Database {
+			database := db.client.Database(db.config.Database)
 			return database, nil
 		}
 	}
@@ -96,7 +97,7 @@ func (db *mongoDb) ListTable() ([]interface{}, error) {
 }
 
 func (db *mongoDb) CreateTable(tableName string, data map[string]interface{}) error {
-	database := db.client.Database(db.config.Mongodb.Database)
+	database := db.client.Database(db.config.Database)
 	tableList, err := db.ListTable()
 	if err != nil {
 		return fmt.Errorf("failed to list table. Error:%s", err)
@@ -154,7 +155,7 @@ func (db *mongoDb) CreateTable(tableName string, data map[string]interface{}) er
 }
 
 func (db *mongoDb) DeleteTable(name string) error {
-	database := db.client.Database(db.config.Mongodb.Database)
+	database := db.client.Database(db.config.Database)
 	tableList, err := db.ListTable()
 	if err != nil {
 		return err
@@ -170,18 +171,24 @@ func (db *mongoDb) DeleteTable(name string) error {
 }
 
 func (db *mongoDb) Get(queryArgs map[string]interface{}) ([]map[string]interface{}, error) {
-	database := db.client.Database(db.config.Mongodb.Database)
+	result, _, err := db.GetPage(queryArgs, 0, "")
+	return result, err
+}
+
+// GetPage sort records on [__id] and continue after the last [__id] of previous page
+func (db *mongoDb) GetPage(queryArgs map[string]interface{}, pageSize int, pageToken string) ([]map[string]interface{}, string, error) {
+	database := db.client.Database(db.config.Database)
 	tableName, ok := queryArgs[DbIface.Table].(string)
 	if !ok {
-		return nil, fmt.Errorf("missing parameter [%s] from queryArgs", DbIface.Table)
+		return nil, "", fmt.Errorf("missing parameter [%s] from queryArgs", DbIface.Table)
 	}
 	table := database.Collection(tableName)
 	if table == nil {
-		return nil, fmt.Errorf("table [%s] does not exists", tableName)
+		return nil, "", fmt.Errorf("table [%s] does not exists", tableName)
 	}
 	dataType, ok := queryArgs[Record.DataType].(string)
 	if !ok {
-		return nil, fmt.Errorf("missing parameter [%s] from queryArgs", Record.DataType)
+		return nil, "", fmt.Errorf("missing parameter [%s] from queryArgs", Record.DataType)
 	}
 
 	filter := bson.M{
@@ -200,20 +207,95 @@ func (db *mongoDb) Get(queryArgs map[string]interface{}) ([]map[string]interface
 			},
 		}
 	}
-	cursor, err := table.Find(context.TODO(), filter)
+	dataFilter, err := DbIface.GetFilter(queryArgs)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if dataFilter != nil {
+		filter = bson.M{
+			"$and": bson.A{
+				filter,
+				filterQuery(dataFilter),
+			},
+		}
+	}
+	if pageToken != "" {
+		start := DbIface.PageKey{}
+		err = DbIface.DecodePageToken(pageToken, &start)
+		if err != nil {
+			return nil, "", err
+		}
+		filter = bson.M{
+			"$and": bson.A{
+				filter,
+				bson.M{
+					Record.DataId: bson.M{
+						"$gt": start.Id,
+					},
+				},
+			},
+		}
+	}
+	findOptions := options.Find().SetSort(bson.D{{Key: Record.DataId, Value: 1}})
+	if pageSize > 0 {
+		// read one more record to know if there is a next page
+		findOptions.SetLimit(int64(pageSize + 1))
+	}
+	cursor, err := table.Find(context.TODO(), filter, findOptions)
+	if err != nil {
+		return nil, "", err
 	}
 	result := []map[string]interface{}{}
 	err = cursor.All(context.TODO(), &result)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if pageSize <= 0 || len(result) <= pageSize {
+		return result, "", nil
+	}
+	result = result[:pageSize]
+	nextToken, err := DbIface.EncodePageToken(DbIface.PageKey{
+		Type: dataType,
+		Id:   result[pageSize-1][Record.DataId].(string),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return result, nextToken, nil
+}
+
+var filterOps = map[string]string{
+	DbIface.FilterEq:  "$eq",
+	DbIface.FilterNe:  "$ne",
+	DbIface.FilterGt:  "$gt",
+	DbIface.FilterGte: "$gte",
+	DbIface.FilterLt:  "$lt",
+	DbIface.FilterLte: "$lte",
+	DbIface.FilterIn:  "$in",
+}
+
+// convert filter to mongo query, nested attribute path is joined by dot notation
+func filterQuery(filter *DbIface.FilterExpr) bson.M {
+	if filter.Op == DbIface.FilterAnd {
+		items := bson.A{}
+		for _, item := range filter.Items {
+			items = append(items, filterQuery(item))
+		}
+		return bson.M{"$and": items}
+	}
+	var value interface{} = filter.Value
+	if filter.Op == DbIface.FilterIn {
+		value = bson.A(filter.Values)
+	}
+	return bson.M{
+		strings.Join(filter.PathList(), "."): bson.M{
+			filterOps[filter.Op]: value,
+		},
 	}
-	return result, nil
 }
 
 func (db *mongoDb) Create(tableName string, data interface{}) error {
-	database := db.client.Database(db.config.Mongodb.Database)
+	database := db.client.Database(db.config.Database)
 	table := database.Collection(tableName)
 	if table == nil {
 		return fmt.Errorf("table [%s] does not exists", tableName)
@@ -224,6 +306,63 @@ func (db *mongoDb) Create(tableName string, data interface{}) error {
 	}
 	return nil
 }
+
+// filter on record keys, with revision condition when keys carry [__rev].
+// records saved without [__rev] match expected revision 0
+func keyFilter(keys map[string]interface{}) (bson.M, bool, error) {
+	filter := bson.M{}
+	for key, value := range DbIface.RecordKeys(keys) {
+		filter[key] = value
+	}
+	expected, ok, err := DbIface.ExpectedRevision(keys)
+	if err != nil || !ok {
+		return filter, false, err
+	}
+	if expected == 0 {
+		filter[Record.Revision] = bson.M{
+			"$in": bson.A{0, nil},
+		}
+	} else {
+		filter[Record.Revision] = expected
+	}
+	return filter, true, nil
+}
+
+func revisionConflict(keys map[string]interface{}) error {
+	return fmt.Errorf("%w, record [%v/%v] is not on revision [%v]", DbIface.ErrRevisionConflict, keys[Record.DataType], keys[Record.DataId], keys[Record.Revision])
+}
+
+func replaceOne(ctx context.Context, table *mongo.Collection, keys map[string]interface{}, data interface{}) error {
+	filter, conditional, err := keyFilter(keys)
+	if err != nil {
+		return err
+	}
+	opts := options.Replace().SetUpsert(!conditional)
+	result, err := table.ReplaceOne(ctx, filter, data, opts)
+	if err != nil {
+		return err
+	}
+	if conditional && result.MatchedCount == 0 {
+		return revisionConflict(keys)
+	}
+	return nil
+}
+
+func deleteOne(ctx context.Context, table *mongo.Collection, keys map[string]interface{}) error {
+	filter, conditional, err := keyFilter(keys)
+	if err != nil {
+		return err
+	}
+	result, err := table.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if conditional && result.DeletedCount == 0 {
+		return revisionConflict(keys)
+	}
+	return nil
+}
+
 func (db *mongoDb) Update(table string, keys map[string]interface{}, data interface{}) (map[string]interface{}, error) {
 	dataType, ok := keys[Record.DataType].(string)
 	if !ok {
@@ -251,6 +390,13 @@ func (db *mongoDb) Update(table string, keys map[string]interface{}, data interf
 	if len(patchData) == 0 {
 		return nil, fmt.Errorf("data [%s/%s] does not exists", dataType, dataId)
 	}
+	err = DbIface.CheckRevision(patchData[0], keys)
+	if err != nil {
+		return nil, err
+	}
+	// write back only if record is not changed since read
+	writeKeys := DbIface.RecordKeys(keys)
+	writeKeys[Record.Revision] = DbIface.RecordRevision(patchData[0])
 	subData, attrPath, err := DbIface.GetDataOnPath(patchData[0], queryPath, fmt.Sprintf("%s/%s/%s", dataType, dataId, queryPath))
 	if err != nil {
 		return nil, err
@@ -259,44 +405,80 @@ func (db *mongoDb) Update(table string, keys map[string]interface{}, data interf
 	if err != nil {
 		return nil, err
 	}
-	err = db.Create(table, patchData[0])
+	DbIface.BumpRevision(patchData[0])
+	database := db.client.Database(db.config.Database)
+	err = replaceOne(context.TODO(), database.Collection(table), writeKeys, patchData[0])
 	if err != nil {
 		return nil, err
 	}
-	return nil, nil
+	return patchData[0], nil
 }
 
 func (db *mongoDb) Replace(tableName string, keys map[string]interface{}, data interface{}) error {
-	database := db.client.Database(db.config.Mongodb.Database)
+	database := db.client.Database(db.config.Database)
 	table := database.Collection(tableName)
 	if table == nil {
 		return fmt.Errorf("table [%s] does not exists", tableName)
 	}
-	opts := options.Replace().SetUpsert(true)
-	table.ReplaceOne(context.TODO(), keys, data, opts)
-	return nil
+	return replaceOne(context.TODO(), table, keys, data)
 }
 
 func (db *mongoDb) Delete(tableName string, keys map[string]interface{}) error {
-	database := db.client.Database(db.config.Mongodb.Database)
+	database := db.client.Database(db.config.Database)
 	table := database.Collection(tableName)
 	if table == nil {
 		return fmt.Errorf("table [%s] does not exists", tableName)
 	}
-	table.DeleteOne(context.TODO(), keys)
+	return deleteOne(context.TODO(), table, keys)
+}
+
+// operations are applied in a multi-document transaction, which requires mongodb running as replica set
+func (db *mongoDb) Commit(batch *DbIface.Batch) error {
+	if batch.Empty() {
+		return nil
+	}
+	database := db.client.Database(db.config.Database)
+	session, err := db.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session. Error:%s", err)
+	}
+	defer session.EndSession(context.TODO())
+	_, err = session.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) (interface{}, error) {
+		for idx, op := range batch.Operations {
+			table := database.Collection(op.Table)
+			var err error
+			switch op.Action {
+			case DbIface.OpCreate:
+				_, err = table.InsertOne(sessCtx, op.Data)
+			case DbIface.OpReplace:
+				err = replaceOne(sessCtx, table, op.Keys, op.Data)
+			case DbIface.OpDelete:
+				err = deleteOne(sessCtx, table, op.Keys)
+			default:
+				err = fmt.Errorf("unknown action [%s]", op.Action)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("batch operation [%d] failed. Error:%w", idx, err)
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("transaction aborted. Error:%w", err)
+	}
 	return nil
 }
 
-func Connect(config DbConfig.DatabaseConfig, logger *log.Logger) (DbIface.Database, error) {
+func Connect(config DbConfig.MongoDbConfig, logger *log.Logger) (DbIface.Database, error) {
 	if logger == nil {
 		logger = log.Default()
 	}
 	credential := options.Credential{
 		AuthMechanism: "SCRAM-SHA-1",
-		Username:      config.Mongodb.UserName,
-		Password:      config.Mongodb.Password,
+		Username:      config.UserName,
+		Password:      config.Password,
 	}
-	clientOpts := options.Client().ApplyURI(config.Mongodb.EndPoint).SetAuth(credential)
+	clientOpts := options.Client().ApplyURI(config.EndPoint).SetAuth(credential)
 	client, err := mongo.Connect(context.TODO(), clientOpts)
 	if err != nil {
 		return nil, err
@@ -26,10 +26,13 @@ This copyright notice and license applies to all files in this directory or sub-
 package DirTable
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"Data/SysDirFile/FileRecord"
 )
@@ -38,6 +41,7 @@ type Table struct {
 	Name     string
 	FullPath string
 	records  map[string]*FileRecord.Record
+	lock     sync.Mutex
 }
 
 func (tbl *Table) List() ([]map[string]interface{}, error) {
@@ -51,46 +55,48 @@ func (tbl *Table) List() ([]map[string]interface{}, error) {
 		if err != nil {
 			return nil, err
 		}
+		// removed after listed
+		if record == nil {
+			continue
+		}
 		result = append(result, record)
 	}
 	return result, nil
 }
 
-func (tbl *Table) refresh() {
-	for dataId := range tbl.records {
-		record, err := FileRecord.New(tbl.FullPath, dataId)
-		if err != nil {
-			delete(tbl.records, dataId)
-		}
-		tbl.records[dataId] = record
-	}
+// hidden files are staged data, not records
+func isRecordId(id string) bool {
+	return id != "" && !strings.HasPrefix(id, ".") && !strings.ContainsRune(id, filepath.Separator)
 }
 
+// cached record is reloaded only when file changed, so writes from other processes are picked up
 func (tbl *Table) Get(id string) (map[string]interface{}, error) {
-	tbl.refresh()
-	idList, err := FileRecord.List(tbl.FullPath)
-	if err != nil {
-		return nil, err
-	}
-	for _, dataId := range idList {
-		if *dataId == id {
-			_, ok := tbl.records[id]
-			if !ok {
-				record, err := FileRecord.New(tbl.FullPath, id)
-				if err != nil {
-					return nil, fmt.Errorf("failed to load data [%s] from table [%s] @path=[%s]", id, tbl.Name, tbl.FullPath)
-				}
-				tbl.records[id] = record
-				return tbl.records[id].Data.(map[string]interface{}), nil
-			}
-			err = tbl.records[id].Refresh()
-			if err != nil {
-				return nil, err
+	if !isRecordId(id) {
+		return nil, nil
+	}
+	tbl.lock.Lock()
+	defer tbl.lock.Unlock()
+	record, ok := tbl.records[id]
+	if !ok {
+		record, err := FileRecord.New(tbl.FullPath, id)
+		if err != nil {
+			if errors.Is(err, FileRecord.ErrNotFound) {
+				return nil, nil
 			}
-			return tbl.records[id].Data.(map[string]interface{}), nil
+			return nil, fmt.Errorf("failed to load data [%s] from table [%s] @path=[%s]. Error:%s", id, tbl.Name, tbl.FullPath, err)
 		}
+		tbl.records[id] = record
+		return record.Data.(map[string]interface{}), nil
+	}
+	err := record.Refresh()
+	if err != nil {
+		delete(tbl.records, id)
+		if errors.Is(err, FileRecord.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
 	}
-	return nil, nil
+	return record.Data.(map[string]interface{}), nil
 }
 
 func (tbl *Table) Put(id string, payload map[string]interface{}) error {
@@ -98,15 +104,16 @@ func (tbl *Table) Put(id string, payload map[string]interface{}) error {
 }
 
 func (tbl *Table) Delete(id string) error {
-	idList, err := FileRecord.List(tbl.FullPath)
-	if err != nil {
+	if !isRecordId(id) {
+		return nil
+	}
+	tbl.lock.Lock()
+	delete(tbl.records, id)
+	tbl.lock.Unlock()
+	err := FileRecord.Delete(tbl.FullPath, id)
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	for _, fileId := range idList {
-		if *fileId == id {
-			return FileRecord.Delete(tbl.FullPath, id)
-		}
-	}
 	return nil
 }
 
@@ -149,7 +156,7 @@ func List(rootPath string) ([]interface{}, error) {
 		return nil, err
 	}
 	for _, file := range dirList {
-		if file.IsDir() {
+		if file.IsDir() && !strings.HasPrefix(file.Name(), ".") {
 			dirName := file.Name()
 			result = append(result, dirName)
 		}
@@ -0,0 +1,59 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package FileLock
+
+import (
+	"fmt"
+	"os"
+)
+
+// Lock is an advisory lock on a file shared by processes working on the same directory.
+// each Acquire opens its own handle, so locks also exclude each other within one process.
+type Lock struct {
+	file *os.File
+}
+
+func Acquire(path string, exclusive bool) (*Lock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file [%s], Error:%s", path, err)
+	}
+	err = lockFile(file, exclusive)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock file [%s], Error:%s", path, err)
+	}
+	return &Lock{file: file}, nil
+}
+
+func (l *Lock) Release() error {
+	err := unlockFile(l.file)
+	closeErr := l.file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to unlock file [%s], Error:%s", l.file.Name(), err)
+	}
+	return closeErr
+}
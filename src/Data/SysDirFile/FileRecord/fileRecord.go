@@ -27,49 +27,82 @@ package FileRecord
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
-	"time"
-
-	"github.com/salesforce/UniTAO/lib/Util/Json"
+	"strings"
 )
 
+const TmpSuffix = ".tmp"
+
+var ErrNotFound = errors.New("file record not found")
+
 type Record struct {
-	Id             string
-	FullPath       string
-	lastUpdateTime time.Time
-	Data           interface{}
+	Id       string
+	FullPath string
+	fileInfo os.FileInfo
+	Data     interface{}
 }
 
+// file is replaced by rename on every write, so a change of file identity, mtime or size means stale cache
 func (rec *Record) Refresh() error {
-	fileInfo, err := os.Stat(rec.FullPath)
+	fileInfo, err := stat(rec.FullPath)
 	if err != nil {
-		err = fmt.Errorf("file record id=[%s] is not a file @[%s]", rec.Id, rec.FullPath)
-		log.Print(err)
-		return err
+		return fmt.Errorf("failed to stat record id=[%s] @[%s]. Error:%w", rec.Id, rec.FullPath, err)
 	}
-	if fileInfo.Mode().IsDir() {
-		err = fmt.Errorf("file record id=[%s] is dir @[%s]", rec.Id, rec.FullPath)
-		log.Print(err)
-		return err
+	if os.SameFile(rec.fileInfo, fileInfo) && rec.fileInfo.ModTime().Equal(fileInfo.ModTime()) && rec.fileInfo.Size() == fileInfo.Size() {
+		return nil
 	}
-	fileTime := fileInfo.ModTime()
-	if rec.lastUpdateTime.Before(fileTime) {
-		data, err := Json.LoadJSONMap(rec.FullPath)
-		if err != nil {
-			err = fmt.Errorf("failed to parse data from record id=[%s], path=[%s]. Err:%s", rec.Id, rec.FullPath, err)
-			log.Print(err)
-			return err
-		}
-		rec.Data = data
-		rec.lastUpdateTime = fileTime
+	data, fileInfo, err := load(rec.FullPath)
+	if err != nil {
+		return fmt.Errorf("failed to parse data from record id=[%s], path=[%s]. Error:%w", rec.Id, rec.FullPath, err)
 	}
+	rec.Data = data
+	rec.fileInfo = fileInfo
 	return nil
 }
 
+func stat(fullPath string) (os.FileInfo, error) {
+	fileInfo, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if fileInfo.IsDir() {
+		return nil, ErrNotFound
+	}
+	return fileInfo, nil
+}
+
+// read data and file info from the same handle, so they match even if the file is replaced meanwhile
+func load(fullPath string) (map[string]interface{}, os.FileInfo, error) {
+	file, err := os.Open(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if fileInfo.IsDir() {
+		return nil, nil, ErrNotFound
+	}
+	data := map[string]interface{}{}
+	err = json.NewDecoder(file).Decode(&data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, fileInfo, nil
+}
+
 func List(dirPath string) ([]*string, error) {
 	result := []*string{}
 	fileList, err := ioutil.ReadDir(dirPath)
@@ -77,7 +110,8 @@ func List(dirPath string) ([]*string, error) {
 		return nil, err
 	}
 	for _, file := range fileList {
-		if !file.IsDir() {
+		// hidden files are staged data of transactions and writes
+		if !file.IsDir() && !strings.HasPrefix(file.Name(), ".") {
 			fileName := file.Name()
 			result = append(result, &fileName)
 		}
@@ -87,35 +121,63 @@ func List(dirPath string) ([]*string, error) {
 
 func New(dirPath string, fileName string) (*Record, error) {
 	fullPath := filepath.Join(dirPath, fileName)
+	data, fileInfo, err := load(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load file=[%s]. Error:%w", fullPath, err)
+	}
 	record := Record{
 		Id:       fileName,
 		FullPath: fullPath,
-	}
-	fileInfo, err := os.Stat(fullPath)
-	if err != nil {
-		return nil, fmt.Errorf("%s is not a file. \n Error:%s", fullPath, err)
-	}
-	record.lastUpdateTime = fileInfo.ModTime()
-	record.Data, err = Json.LoadJSONMap(fullPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load file=[%s]. \n Error:%s", fullPath, err)
+		fileInfo: fileInfo,
+		Data:     data,
 	}
 	return &record, nil
 }
 
+// write to a hidden temp file then rename over the target, so readers never see a partial record
 func Put(dirPath string, fileName string, data map[string]interface{}) error {
 	filePath := filepath.Join(dirPath, fileName)
-	file, err := json.MarshalIndent(data, "", " ")
+	raw, err := json.MarshalIndent(data, "", " ")
 	if err != nil {
 		return err
 	}
-	err = ioutil.WriteFile(filePath, file, 0644)
+	file, err := os.CreateTemp(dirPath, fmt.Sprintf(".%s.*%s", fileName, TmpSuffix))
 	if err != nil {
 		return err
 	}
+	tmpPath := file.Name()
+	_, err = file.Write(raw)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, filePath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	syncDir(dirPath)
 	return nil
 }
 
+// persist the rename, best effort since not every platform supports sync on dir
+func syncDir(dirPath string) {
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		return
+	}
+	dir.Sync()
+	dir.Close()
+}
+
 func Delete(dirPath string, fileName string) error {
 	filePath := filepath.Join(dirPath, fileName)
 	return os.Remove(filePath)
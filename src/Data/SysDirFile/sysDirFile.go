@@ -30,31 +30,72 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"Data/DbConfig"
 	"Data/DbIface"
 	"Data/SysDirFile/DirTable"
+	"Data/SysDirFile/FileLock"
 
 	"github.com/salesforce/UniTAO/lib/Schema/Record"
 )
 
 const (
-	Name  = "sysdirfile"
-	Index = "index"
+	Name     = "sysdirfile"
+	Index    = "index"
+	LockFile = ".lock"
 )
 
 type Database struct {
-	logger *log.Logger
-	Path   string
-	config DbConfig.SysDirFileConfig
-	tables map[string]*DirTable.Table
+	logger    *log.Logger
+	Path      string
+	config    DbConfig.SysDirFileConfig
+	tables    map[string]*DirTable.Table
+	tableLock sync.Mutex
+	txnLock   sync.Mutex
 }
 
 func (db *Database) Name() string {
 	return Name
 }
 
+// lock the database path against other processes, reads share the lock and writes take it exclusively.
+// transaction left by a crashed process is replayed before the lock is handed out
+func (db *Database) lock(exclusive bool) (*FileLock.Lock, error) {
+	lockPath := filepath.Join(db.Path, LockFile)
+	lock, err := FileLock.Acquire(lockPath, exclusive)
+	if err != nil {
+		return nil, err
+	}
+	_, err = os.Stat(db.txnLogPath())
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if !exclusive {
+		lock.Release()
+		lock, err = FileLock.Acquire(lockPath, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = db.recoverTxn()
+	if err != nil {
+		lock.Release()
+		return nil, fmt.Errorf("failed to recover transaction at path [%s], Err:%s", db.Path, err)
+	}
+	return lock, nil
+}
+
 func (db *Database) ListTable() ([]interface{}, error) {
+	lock, err := db.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+	return db.listTable()
+}
+
+func (db *Database) listTable() ([]interface{}, error) {
 	result, err := DirTable.List(db.Path)
 	if err != nil {
 		return nil, err
@@ -63,6 +104,11 @@ func (db *Database) ListTable() ([]interface{}, error) {
 }
 
 func (db *Database) CreateTable(name string, data map[string]interface{}) error {
+	lock, err := db.lock(true)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
 	tableList, err := DirTable.List(db.Path)
 	if err != nil {
 		return err
@@ -80,6 +126,11 @@ func (db *Database) CreateTable(name string, data map[string]interface{}) error
 }
 
 func (db *Database) DeleteTable(name string) error {
+	lock, err := db.lock(true)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
 	tableList, err := DirTable.List(db.Path)
 	if err != nil {
 		return err
@@ -90,10 +141,9 @@ func (db *Database) DeleteTable(name string) error {
 			if err != nil {
 				return err
 			}
-			_, ok := db.tables[tableName.(string)]
-			if ok {
-				delete(db.tables, tableName.(string))
-			}
+			db.tableLock.Lock()
+			delete(db.tables, tableName.(string))
+			db.tableLock.Unlock()
 			return nil
 		}
 	}
@@ -107,6 +157,15 @@ func (db *Database) Get(queryArgs map[string]interface{}) ([]map[string]interfac
 		log.Print(err)
 		return nil, err
 	}
+	filter, err := DbIface.GetFilter(queryArgs)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := db.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
 	table, err := db.GetTable(tableName)
 	if err != nil {
 		err = fmt.Errorf("failed to get table, Err: %s", err)
@@ -119,7 +178,7 @@ func (db *Database) Get(queryArgs map[string]interface{}) ([]map[string]interfac
 			err = fmt.Errorf("failed to list table [%s], error: %s", tableName, err)
 			return nil, err
 		}
-		return result, nil
+		return DbIface.FilterRecords(filterType(result, queryArgs), filter), nil
 	}
 
 	record, err := table.Get(dataId)
@@ -131,7 +190,31 @@ func (db *Database) Get(queryArgs map[string]interface{}) ([]map[string]interfac
 	if record != nil {
 		result = append(result, record)
 	}
-	return result, nil
+	return DbIface.FilterRecords(filterType(result, queryArgs), filter), nil
+}
+
+// files are named by record id only, so type in queryArgs is checked against the loaded records
+func filterType(recordList []map[string]interface{}, queryArgs map[string]interface{}) []map[string]interface{} {
+	dataType, ok := queryArgs[Record.DataType].(string)
+	if !ok {
+		return recordList
+	}
+	result := []map[string]interface{}{}
+	for _, record := range recordList {
+		if recordType, _ := record[Record.DataType].(string); recordType == dataType {
+			result = append(result, record)
+		}
+	}
+	return result
+}
+
+// GetPage loads the whole table then page in memory, files in a folder have no stable cursor
+func (db *Database) GetPage(queryArgs map[string]interface{}, pageSize int, pageToken string) ([]map[string]interface{}, string, error) {
+	recordList, err := db.Get(queryArgs)
+	if err != nil {
+		return nil, "", err
+	}
+	return DbIface.PageRecords(recordList, pageSize, pageToken)
 }
 
 func (db *Database) refresh() []error {
@@ -152,12 +235,14 @@ func (db *Database) refresh() []error {
 }
 
 func (db *Database) GetTable(name string) (*DirTable.Table, error) {
+	db.tableLock.Lock()
+	defer db.tableLock.Unlock()
 	db.refresh()
 	table, ok := db.tables[name]
 	if ok {
 		return table, nil
 	}
-	tableList, err := db.ListTable()
+	tableList, err := db.listTable()
 	if err != nil {
 		return nil, err
 	}
@@ -182,6 +267,11 @@ func (db *Database) Create(table string, data interface{}) error {
 		log.Print(err)
 		return err
 	}
+	lock, err := db.lock(true)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
 	tbl, err := db.GetTable(table)
 	if err != nil {
 		log.Print(err)
@@ -202,6 +292,19 @@ func (db *Database) Create(table string, data interface{}) error {
 	}
 	return nil
 }
+
+// check revision condition in keys against record [dataId] in table
+func checkRevision(tbl *DirTable.Table, dataId string, keys map[string]interface{}) error {
+	if _, ok := keys[Record.Revision]; !ok {
+		return nil
+	}
+	current, err := tbl.Get(dataId)
+	if err != nil {
+		return fmt.Errorf("failed to get data [%s]=[%s]. Error:%s", Record.DataId, dataId, err)
+	}
+	return DbIface.CheckRevision(current, keys)
+}
+
 func (db *Database) Update(table string, keys map[string]interface{}, data interface{}) (map[string]interface{}, error) {
 	return nil, nil
 }
@@ -213,11 +316,24 @@ func (db *Database) Replace(table string, keys map[string]interface{}, data inte
 		log.Print(err)
 		return err
 	}
+	lock, err := db.lock(true)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
 	tbl, err := db.GetTable(table)
 	if err != nil {
 		log.Print(err)
 		return err
 	}
+	keyId, ok := keys[Record.DataId].(string)
+	if !ok {
+		keyId = dataId
+	}
+	err = checkRevision(tbl, keyId, keys)
+	if err != nil {
+		return err
+	}
 	err = tbl.Put(dataId, payload)
 	if err != nil {
 		err = fmt.Errorf("failed to put data id=[%s], Error:%s", dataId, err)
@@ -228,6 +344,11 @@ func (db *Database) Replace(table string, keys map[string]interface{}, data inte
 }
 
 func (db *Database) Delete(table string, keys map[string]interface{}) error {
+	lock, err := db.lock(true)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
 	tbl, err := db.GetTable(table)
 	if err != nil {
 		log.Print(err)
@@ -238,6 +359,10 @@ func (db *Database) Delete(table string, keys map[string]interface{}) error {
 		err = fmt.Errorf("missing key field [%s]", Record.DataId)
 		return err
 	}
+	err = checkRevision(tbl, dataId, keys)
+	if err != nil {
+		return err
+	}
 	err = tbl.Delete(dataId)
 	if err != nil {
 		err = fmt.Errorf("failed to put data id=[%s], Err:%s", dataId, err)
@@ -247,33 +372,43 @@ func (db *Database) Delete(table string, keys map[string]interface{}) error {
 	return nil
 }
 
-func Connect(config DbConfig.DatabaseConfig, logger *log.Logger) (DbIface.Database, error) {
+func Connect(config DbConfig.SysDirFileConfig, logger *log.Logger) (DbIface.Database, error) {
 	if logger == nil {
 		logger = log.Default()
 	}
-	if config.SysDirFile.Path == "" {
+	if config.Path == "" {
 		return nil, fmt.Errorf("missing path from config for %s", Name)
 	}
-	absPath, err := filepath.Abs(config.SysDirFile.Path)
+	absPath, err := filepath.Abs(config.Path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse path to absolute path, path=[%s], Error:%s", config.SysDirFile.Path, err)
+		return nil, fmt.Errorf("failed to parse path to absolute path, path=[%s], Error:%s", config.Path, err)
 	}
 	pathInfo, err := os.Stat(absPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, fmt.Errorf("path [%s] does not exists, Err: %s", absPath, err)
 		}
-		return nil, fmt.Errorf("failed to stat path [%s], Err:%s", config.SysDirFile.Path, err)
+		return nil, fmt.Errorf("failed to stat path [%s], Err:%s", config.Path, err)
 	}
 
 	if !pathInfo.IsDir() {
-		return nil, fmt.Errorf("inventory path is not dir, path=[%s], Err:%s", config.SysDirFile.Path, err)
+		return nil, fmt.Errorf("inventory path is not dir, path=[%s], Err:%s", config.Path, err)
 	}
 	db := Database{
 		logger: logger,
 		Path:   absPath,
-		config: config.SysDirFile,
+		config: config,
 		tables: make(map[string]*DirTable.Table),
 	}
+	// a connecting process cleans up staged files, so it waits for writers of other processes
+	lock, err := FileLock.Acquire(filepath.Join(absPath, LockFile), true)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+	err = db.recoverTxn()
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover transaction at path [%s], Err:%s", absPath, err)
+	}
 	return &db, nil
 }
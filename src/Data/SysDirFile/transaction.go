@@ -0,0 +1,314 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package SysDirFile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"Data/DbIface"
+	"Data/SysDirFile/DirTable"
+	"Data/SysDirFile/FileRecord"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+const (
+	TxnLog     = ".txn"
+	TxnSuffix  = ".txn"
+	StepRename = "rename"
+	StepRemove = "remove"
+)
+
+// a batch is staged as temp files plus a transaction log of steps,
+// once the log is written the batch is committed and the steps are replayed on restart if interrupted
+type txnStep struct {
+	Action string `json:"action"`
+	Source string `json:"source,omitempty"`
+	Target string `json:"target"`
+}
+
+type txnState struct {
+	db      *Database
+	steps   []txnStep
+	exists  map[string]bool
+	staged  []string
+	tables  map[string]*DirTable.Table
+	counter int
+}
+
+func (s *txnState) table(name string) (*DirTable.Table, error) {
+	if tbl, ok := s.tables[name]; ok {
+		return tbl, nil
+	}
+	tbl, err := s.db.GetTable(name)
+	if err != nil {
+		return nil, err
+	}
+	s.tables[name] = tbl
+	return tbl, nil
+}
+
+// file exists after all staged steps are applied
+func (s *txnState) fileExists(path string) (bool, error) {
+	if exists, ok := s.exists[path]; ok {
+		return exists, nil
+	}
+	_, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to stat file [%s], Error:%s", path, err)
+	}
+	return true, nil
+}
+
+func (s *txnState) stagePut(tbl *DirTable.Table, dataId string, data interface{}) error {
+	payload, ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("data [%s] is not a map", dataId)
+	}
+	s.counter++
+	tmpName := fmt.Sprintf(".%s.%d%s", dataId, s.counter, TxnSuffix)
+	err := FileRecord.Put(tbl.FullPath, tmpName, payload)
+	if err != nil {
+		return fmt.Errorf("failed to stage data [%s], Error:%s", dataId, err)
+	}
+	tmpPath := filepath.Join(tbl.FullPath, tmpName)
+	target := filepath.Join(tbl.FullPath, dataId)
+	s.staged = append(s.staged, tmpPath)
+	s.steps = append(s.steps, txnStep{
+		Action: StepRename,
+		Source: tmpPath,
+		Target: target,
+	})
+	s.exists[target] = true
+	return nil
+}
+
+func (s *txnState) stageRemove(tbl *DirTable.Table, dataId string) error {
+	target := filepath.Join(tbl.FullPath, dataId)
+	exists, err := s.fileExists(target)
+	if err != nil || !exists {
+		return err
+	}
+	s.steps = append(s.steps, txnStep{
+		Action: StepRemove,
+		Target: target,
+	})
+	s.exists[target] = false
+	return nil
+}
+
+func (s *txnState) stage(op *DbIface.Operation) error {
+	tbl, err := s.table(op.Table)
+	if err != nil {
+		return err
+	}
+	keyId, _ := op.Keys[Record.DataId].(string)
+	if op.Action != DbIface.OpCreate {
+		err = checkRevision(tbl, keyId, op.Keys)
+		if err != nil {
+			return err
+		}
+	}
+	switch op.Action {
+	case DbIface.OpCreate, DbIface.OpReplace:
+		payload, ok := op.Data.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("data is not a map")
+		}
+		dataId, ok := payload[Record.DataId].(string)
+		if !ok || dataId == "" {
+			return fmt.Errorf("missing key [%s] from data", Record.DataId)
+		}
+		if op.Action == DbIface.OpCreate {
+			exists, err := s.fileExists(filepath.Join(tbl.FullPath, dataId))
+			if err != nil {
+				return err
+			}
+			if exists {
+				return fmt.Errorf("data [%s]=[%s] already exists", Record.DataId, dataId)
+			}
+		} else if keyId != "" && keyId != dataId {
+			err = s.stageRemove(tbl, keyId)
+			if err != nil {
+				return err
+			}
+		}
+		return s.stagePut(tbl, dataId, payload)
+	case DbIface.OpDelete:
+		if keyId == "" {
+			return fmt.Errorf("missing key field [%s]", Record.DataId)
+		}
+		return s.stageRemove(tbl, keyId)
+	default:
+		return fmt.Errorf("unknown action [%s]", op.Action)
+	}
+}
+
+func (s *txnState) rollback() {
+	for _, tmpPath := range s.staged {
+		os.Remove(tmpPath)
+	}
+}
+
+func (db *Database) Commit(batch *DbIface.Batch) error {
+	if batch.Empty() {
+		return nil
+	}
+	db.txnLock.Lock()
+	defer db.txnLock.Unlock()
+	lock, err := db.lock(true)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	state := txnState{
+		db:     db,
+		steps:  []txnStep{},
+		exists: map[string]bool{},
+		staged: []string{},
+		tables: map[string]*DirTable.Table{},
+	}
+	for idx, op := range batch.Operations {
+		err := state.stage(op)
+		if err != nil {
+			state.rollback()
+			return fmt.Errorf("batch operation [%d] failed, rollback. Error:%w", idx, err)
+		}
+	}
+	err = db.writeTxnLog(state.steps)
+	if err != nil {
+		state.rollback()
+		return err
+	}
+	err = applySteps(state.steps)
+	if err != nil {
+		// transaction log is kept, steps will be replayed on next connect
+		return fmt.Errorf("failed to apply committed batch. Error:%s", err)
+	}
+	return db.removeTxnLog()
+}
+
+func (db *Database) txnLogPath() string {
+	return filepath.Join(db.Path, TxnLog)
+}
+
+func (db *Database) writeTxnLog(steps []txnStep) error {
+	raw, err := json.MarshalIndent(steps, "", " ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal transaction log. Error:%s", err)
+	}
+	logPath := db.txnLogPath()
+	tmpPath := fmt.Sprintf("%s%s", logPath, TxnSuffix)
+	err = os.WriteFile(tmpPath, raw, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write transaction log [%s]. Error:%s", tmpPath, err)
+	}
+	err = os.Rename(tmpPath, logPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to commit transaction log [%s]. Error:%s", logPath, err)
+	}
+	return nil
+}
+
+func (db *Database) removeTxnLog() error {
+	err := os.Remove(db.txnLogPath())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove transaction log. Error:%s", err)
+	}
+	return nil
+}
+
+// steps are idempotent, so they are safe to be replayed after crash
+func applySteps(steps []txnStep) error {
+	for _, step := range steps {
+		switch step.Action {
+		case StepRename:
+			err := os.Rename(step.Source, step.Target)
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to move [%s] to [%s]. Error:%s", step.Source, step.Target, err)
+			}
+		case StepRemove:
+			err := os.Remove(step.Target)
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove [%s]. Error:%s", step.Target, err)
+			}
+		default:
+			return fmt.Errorf("unknown step [%s] on [%s]", step.Action, step.Target)
+		}
+	}
+	return nil
+}
+
+// replay committed transaction left by crash and clean up staged files of uncommitted ones
+func (db *Database) recoverTxn() error {
+	raw, err := os.ReadFile(db.txnLogPath())
+	if err == nil {
+		steps := []txnStep{}
+		err = json.Unmarshal(raw, &steps)
+		if err != nil {
+			return fmt.Errorf("failed to parse transaction log [%s]. Error:%s", db.txnLogPath(), err)
+		}
+		db.logger.Printf("replay [%d] steps from transaction log [%s]", len(steps), db.txnLogPath())
+		err = applySteps(steps)
+		if err != nil {
+			return err
+		}
+		err = db.removeTxnLog()
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read transaction log [%s]. Error:%s", db.txnLogPath(), err)
+	}
+	tableList, err := DirTable.List(db.Path)
+	if err != nil {
+		return err
+	}
+	for _, name := range tableList {
+		tablePath := filepath.Join(db.Path, name.(string))
+		fileList, err := os.ReadDir(tablePath)
+		if err != nil {
+			return fmt.Errorf("failed to list table [%s]. Error:%s", tablePath, err)
+		}
+		for _, file := range fileList {
+			if !strings.HasPrefix(file.Name(), ".") {
+				continue
+			}
+			if strings.HasSuffix(file.Name(), TxnSuffix) || strings.HasSuffix(file.Name(), FileRecord.TmpSuffix) {
+				os.Remove(filepath.Join(tablePath, file.Name()))
+			}
+		}
+	}
+	return nil
+}
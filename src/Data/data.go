@@ -26,41 +26,135 @@ This copyright notice and license applies to all files in this directory or sub-
 package Data
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
+	"sync"
 
+	"Data/BoltDb"
 	"Data/DbConfig"
 	"Data/DbDynamoDb"
 	"Data/DbIface"
+	"Data/InMemory"
 	MongoDb "Data/Mongodb"
 	"Data/SysDirFile"
 )
 
+// decode raw JSON config section of the backend, raw is nil when the section is not configured
+type ConfigDecoder func(raw json.RawMessage) (interface{}, error)
+
+// connect to backend with config returned from its ConfigDecoder
+type ConnectFunc func(config interface{}, logger *log.Logger) (DbIface.Database, error)
+
+type backend struct {
+	decode  ConfigDecoder
+	connect ConnectFunc
+}
+
+var (
+	backendLock sync.RWMutex
+	backends    = map[string]backend{}
+)
+
+func init() {
+	MustRegister(DbDynamoDb.Name, func(raw json.RawMessage) (interface{}, error) {
+		config := DbConfig.DynmoDbConfig{}
+		err := decodeSection(raw, &config)
+		return config, err
+	}, func(config interface{}, logger *log.Logger) (DbIface.Database, error) {
+		return DbDynamoDb.Connect(config.(DbConfig.DynmoDbConfig), logger)
+	})
+	MustRegister(MongoDb.Name, func(raw json.RawMessage) (interface{}, error) {
+		config := DbConfig.MongoDbConfig{}
+		err := decodeSection(raw, &config)
+		return config, err
+	}, func(config interface{}, logger *log.Logger) (DbIface.Database, error) {
+		return MongoDb.Connect(config.(DbConfig.MongoDbConfig), logger)
+	})
+	MustRegister(SysDirFile.Name, func(raw json.RawMessage) (interface{}, error) {
+		config := DbConfig.SysDirFileConfig{}
+		err := decodeSection(raw, &config)
+		return config, err
+	}, func(config interface{}, logger *log.Logger) (DbIface.Database, error) {
+		return SysDirFile.Connect(config.(DbConfig.SysDirFileConfig), logger)
+	})
+	MustRegister(BoltDb.Name, func(raw json.RawMessage) (interface{}, error) {
+		config := DbConfig.BoltDbConfig{}
+		err := decodeSection(raw, &config)
+		return config, err
+	}, func(config interface{}, logger *log.Logger) (DbIface.Database, error) {
+		return BoltDb.Connect(config.(DbConfig.BoltDbConfig), logger)
+	})
+	MustRegister(InMemory.Name, func(raw json.RawMessage) (interface{}, error) {
+		config := DbConfig.InMemoryConfig{}
+		err := decodeSection(raw, &config)
+		return config, err
+	}, func(config interface{}, logger *log.Logger) (DbIface.Database, error) {
+		return InMemory.Connect(config.(DbConfig.InMemoryConfig), logger)
+	})
+}
+
+func decodeSection(raw json.RawMessage, config interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, config)
+}
+
+// Register a database backend, config section with the same name in DatabaseConfig is passed to decode
+func Register(name string, decode ConfigDecoder, connect ConnectFunc) error {
+	if name == "" {
+		return fmt.Errorf("backend name cannot be empty")
+	}
+	if decode == nil || connect == nil {
+		return fmt.Errorf("backend [%s] missing config decoder or connect function", name)
+	}
+	backendLock.Lock()
+	defer backendLock.Unlock()
+	if _, ok := backends[name]; ok {
+		return fmt.Errorf("backend [%s] already registered", name)
+	}
+	backends[name] = backend{
+		decode:  decode,
+		connect: connect,
+	}
+	return nil
+}
+
+func MustRegister(name string, decode ConfigDecoder, connect ConnectFunc) {
+	err := Register(name, decode, connect)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func Backends() []string {
+	backendLock.RLock()
+	defer backendLock.RUnlock()
+	nameList := make([]string, 0, len(backends))
+	for name := range backends {
+		nameList = append(nameList, name)
+	}
+	sort.Strings(nameList)
+	return nameList
+}
+
 func ConnectDb(config DbConfig.DatabaseConfig, logger *log.Logger) (DbIface.Database, error) {
-	switch config.DbType {
-	case DbDynamoDb.Name:
-		db, err := DbDynamoDb.Connect(config, logger)
-		if err != nil {
-			newError := fmt.Errorf("failed to connect to DynamoDB. Error:%s", err.Error())
-			return nil, newError
-		}
-		log.Printf("dynamodb connected")
-		return db, nil
-	case SysDirFile.Name:
-		db, err := SysDirFile.Connect(config, logger)
-		if err != nil {
-			err = fmt.Errorf("failed to connect to SysDirFile. Error:%s", err)
-			return nil, err
-		}
-		return db, nil
-	case MongoDb.Name:
-		db, err := MongoDb.Connect(config, logger)
-		if err != nil {
-			err = fmt.Errorf("failed to connect to MongoDb. Error:%s", err)
-			return nil, err
-		}
-		return db, nil
-	default:
+	backendLock.RLock()
+	dbBackend, ok := backends[config.DbType]
+	backendLock.RUnlock()
+	if !ok {
 		return nil, fmt.Errorf("unknown dbType:%s, Don't know how to connect", config.DbType)
 	}
+	dbConfig, err := dbBackend.decode(config.Section(config.DbType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode config of %s. Error:%s", config.DbType, err)
+	}
+	db, err := dbBackend.connect(dbConfig, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s. Error:%s", config.DbType, err)
+	}
+	log.Printf("%s connected", config.DbType)
+	return db, nil
 }
@@ -29,7 +29,9 @@ require (
 	github.com/aws/aws-sdk-go v1.44.13
 	github.com/salesforce/UniTAO/lib/Schema v0.0.0-20230322231937-6539d71a6686
 	github.com/salesforce/UniTAO/lib/Util v0.0.0-20230322231937-6539d71a6686
+	go.etcd.io/bbolt v1.3.7
 	go.mongodb.org/mongo-driver v1.11.1
+	golang.org/x/sys v0.4.0
 )
 
 require (
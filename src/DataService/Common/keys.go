@@ -28,4 +28,76 @@ package Common
 
 const (
 	KeyJournal = "journal"
+	// last seq reserved for journal entries of the table, record id is journal
+	KeyJournalSeq = "journalSeq"
+	// counters of database cache on GET /cache
+	KeyCache = "cache"
+	// Database metrics in Prometheus text format on GET /metrics
+	KeyMetrics = "metrics"
+	// restore soft deleted record on POST /undelete/{type}/{id}
+	KeyUndelete = "undelete"
+	// newline-delimited records on GET /export/{type} and POST /import?mode={mode}
+	KeyExport         = "export"
+	KeyImport         = "import"
+	QueryImportMode   = "mode"
+	ContentTypeNdjson = "application/x-ndjson"
+	// document patch on PATCH /{type}/{id}, apply on whole record data instead of value on {path}
+	ContentTypeJsonPatch  = "application/json-patch+json"
+	ContentTypeMergePatch = "application/merge-patch+json"
+	// OpenAPI document generated from current schemas on GET /openapi
+	KeyOpenApi = "openapi"
+	// GraphQL query on POST /graphql, or GET /graphql?query={query}, schema in SDL on GET /graphql
+	KeyGraphQl         = "graphql"
+	QueryGraphQl       = "query"
+	QueryOperationName = "operationName"
+	QueryGraphQlVars   = "variables"
+	// live journal events on GET /stream/{type}/{id}/{path}, as Server-Sent Events or websocket messages,
+	// resume after position of Last-Event-ID header or since={position}
+	KeyStream              = "stream"
+	QuerySince             = "since"
+	HeaderLastEventId      = "Last-Event-ID"
+	ContentTypeEventStream = "text/event-stream"
+	// admin api of outbound webhooks on /webhook/{name}, also type of their records,
+	// deliveries wait in records of type webhookDelivery until delivered, signed in header of signature
+	KeyWebhook             = "webhook"
+	KeyWebhookDelivery     = "webhookDelivery"
+	HeaderWebhookSignature = "X-UniTAO-Signature"
+	HeaderWebhookDelivery  = "X-UniTAO-Delivery"
+	// admin api of role based access control on /role/{name} and /roleBinding/{name}, also types of their records
+	KeyRole        = "role"
+	KeyRoleBinding = "roleBinding"
+	// identity of caller in headers of Handler.Patch, set by server only
+	KeyIdentity = "__identity"
+	// admin api of namespaces on /namespace/{name}, also type of namespace records in default table
+	KeyNamespace = "namespace"
+
+	// query parameters and response keys of paged list on GET /{type}
+	QueryPageSize    = "pageSize"
+	QueryPageToken   = "pageToken"
+	KeyItems         = "items"
+	KeyNextPageToken = "nextPageToken"
+	// query parameters of filtered list on GET /{type}
+	// ex: ?filter=rack/name:eq:r12&filter=state:in:ready,busy&sort=-created&limit=10&view=records
+	QueryFilter = "filter"
+	QuerySort   = "sort"
+	QueryLimit  = "limit"
+	QueryView   = "view"
+	ViewIds     = "ids"
+	ViewRecords = "records"
+
+	// header of expected record revision on PATCH, and of current revision in response of POST/PUT
+	HeaderRevision = "Revision"
+	// conditional request headers, ETag of record on GET and writes, If-Match on PUT/PATCH/DELETE, If-None-Match on GET
+	HeaderETag        = "ETag"
+	HeaderIfMatch     = "If-Match"
+	HeaderIfNoneMatch = "If-None-Match"
+	// header to select namespace of request, default namespace when missing
+	HeaderNamespace = "Namespace"
+	// headers of change reason and request id recorded in journal of changes, request id is generated when missing
+	HeaderChangeReason = "X-Change-Reason"
+	HeaderRequestId    = "X-Request-Id"
+	// query parameters to filter journal entries on GET /journal and GET /journal/{journalId} of a type, record or page, ex: ?actor=alice&reason=maintenance
+	QueryActor     = "actor"
+	QueryReason    = "reason"
+	QueryRequestId = "requestId"
 )
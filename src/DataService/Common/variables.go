@@ -34,12 +34,35 @@ import (
 
 var InternalTypes = map[string]interface{}{
 	KeyJournal:                true,
+	KeyJournalSeq:             true,
+	KeyNamespace:              true,
 	CmtIndex.KeyCmtIdx:        true,
 	CmtIndex.KeyCmtSubscriber: true,
 	JsonKey.Schema:            true,
 	Record.KeyRecord:          true,
+	KeyRole:                   true,
+	KeyRoleBinding:            true,
+	KeyWebhook:                true,
+	KeyWebhookDelivery:        true,
+}
+
+// routes served before data types on /{type}, data type of same name would be shadowed
+var RouteTypes = map[string]interface{}{
+	KeyCache:    true,
+	KeyMetrics:  true,
+	KeyUndelete: true,
+	KeyExport:   true,
+	KeyImport:   true,
+	KeyOpenApi:  true,
+	KeyGraphQl:  true,
+	KeyStream:   true,
 }
 
 var ReadOnlyTypes = map[string]interface{}{
 	KeyJournal: true,
+	KeyCache:   true,
+	KeyMetrics: true,
+	KeyExport:  true,
+	KeyOpenApi: true,
+	KeyStream:  true,
 }
@@ -30,6 +30,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"time"
 
 	"Data/DbConfig"
 
@@ -47,12 +48,48 @@ type Confuguration struct {
 	DataTable DataTableConfig         `json:"table"`
 	Http      Http.Config             `json:"http"`
 	Inv       InvConfig               `json:"inventory"`
+	Cache     DbConfig.CacheConfig    `json:"cache"`
+	Purge     IntervalConfig          `json:"purge"`
+	Expire    IntervalConfig          `json:"expire"`
+	Namespace NamespaceConfig         `json:"namespace"`
+}
+
+// NamespaceConfig is meta to create data table of each namespace, same as meta of data table in DataServiceAdmin
+type NamespaceConfig struct {
+	TableMeta map[string]interface{} `json:"tableMeta"`
+}
+
+// IntervalConfig is how often a background job runs, ex: 1h
+// Purge check tombstones of soft deleted records, Expire check records with ttl
+type IntervalConfig struct {
+	Interval string `json:"interval"`
+}
+
+const (
+	DefaultPurgeInterval  = time.Hour
+	DefaultExpireInterval = time.Minute
+)
+
+func (p *IntervalConfig) GetInterval(defaultInterval time.Duration) (time.Duration, error) {
+	if p.Interval == "" {
+		return defaultInterval, nil
+	}
+	interval, err := time.ParseDuration(p.Interval)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("invalid interval [%s], expect positive duration, ex: 1h", p.Interval)
+	}
+	return interval, nil
 }
 
 type DataTableConfig struct {
 	Data string `json:"data"`
 }
 
+// NamespaceTable return name of data table of namespace
+func (t *DataTableConfig) NamespaceTable(namespace string) string {
+	return fmt.Sprintf("%s_%s", t.Data, namespace)
+}
+
 func (t *DataTableConfig) Map() map[string]interface{} {
 	data, _ := Json.CopyToMap(t)
 	return data
@@ -74,5 +111,13 @@ func Read(configPath string, config *Confuguration) error {
 	if config.DataTable.Data == "" {
 		return fmt.Errorf("missing field data in Config.DataTable")
 	}
+	_, err = config.Purge.GetInterval(DefaultPurgeInterval)
+	if err != nil {
+		return fmt.Errorf("invalid purge config, Error: %s", err)
+	}
+	_, err = config.Expire.GetInterval(DefaultExpireInterval)
+	if err != nil {
+		return fmt.Errorf("invalid expire config, Error: %s", err)
+	}
 	return nil
 }
@@ -0,0 +1,349 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataHandler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"DataService/Common"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Schema/SchemaDoc"
+	"github.com/salesforce/UniTAO/lib/Util"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+	"github.com/salesforce/UniTAO/lib/Util/Json"
+)
+
+const (
+	// conflict modes of import, create-only fail on existing record, replace fail on missing record
+	ImportCreateOnly = "create-only"
+	ImportUpsert     = "upsert"
+	ImportReplace    = "replace"
+
+	ExportPageSize    = 100
+	MaxImportLineSize = 16 * 1024 * 1024
+)
+
+type ImportError struct {
+	Line  int             `json:"line"`
+	Error *Http.HttpError `json:"error"`
+}
+
+type ImportResult struct {
+	Created   int           `json:"created"`
+	Replaced  int           `json:"replaced"`
+	Unchanged int           `json:"unchanged"`
+	Failed    int           `json:"failed"`
+	Errors    []ImportError `json:"errors"`
+}
+
+// ExportTypes return types to export for dataType, empty dataType means all types with schemas first.
+// types are in dependency order, so referenced records are imported before records refer to them
+func (h *Handler) ExportTypes(dataType string) ([]string, *Http.HttpError) {
+	switch dataType {
+	case JsonKey.Schema:
+		return []string{JsonKey.Schema}, nil
+	case "":
+		schemaList, err := h.exportSchemas()
+		if err != nil {
+			return nil, err
+		}
+		typeList := []string{JsonKey.Schema}
+		for _, schema := range schemaList {
+			typeList = append(typeList, schema[Record.DataId].(string))
+		}
+		return typeList, nil
+	}
+	if _, ok := Common.InternalTypes[dataType]; ok {
+		return nil, Http.NewHttpError(fmt.Sprintf("export of internal type [%s] is not supported", dataType), http.StatusBadRequest)
+	}
+	_, err := h.LocalSchema(dataType, "")
+	if err != nil {
+		return nil, err
+	}
+	return []string{dataType}, nil
+}
+
+// Export write records of types as newline-delimited JSON, deleted records are not exported
+func (h *Handler) Export(typeList []string, w io.Writer) *Http.HttpError {
+	encoder := json.NewEncoder(w)
+	for _, dataType := range typeList {
+		if dataType == JsonKey.Schema {
+			schemaList, err := h.exportSchemas()
+			if err != nil {
+				return err
+			}
+			for _, schema := range schemaList {
+				ex := encoder.Encode(schema)
+				if ex != nil {
+					return Http.WrapError(ex, fmt.Sprintf("failed to export schema [%s]", schema[Record.DataId]), http.StatusInternalServerError)
+				}
+			}
+			continue
+		}
+		pageToken := ""
+		for {
+			recordList, nextToken, err := h.QueryDbPage(dataType, nil, ExportPageSize, pageToken)
+			if err != nil {
+				return err
+			}
+			for _, data := range recordList {
+				if IsDeleted(data) {
+					continue
+				}
+				ex := encoder.Encode(data)
+				if ex != nil {
+					return Http.WrapError(ex, fmt.Sprintf("failed to export record [%s/%s]", dataType, data[Record.DataId]), http.StatusInternalServerError)
+				}
+			}
+			if nextToken == "" {
+				break
+			}
+			pageToken = nextToken
+		}
+	}
+	return nil
+}
+
+// current schemas except schemas of internal types, schema is after all schemas it refers to.
+// schemas refer to each other are in id order
+func (h *Handler) exportSchemas() ([]map[string]interface{}, *Http.HttpError) {
+	schemaList, err := h.QueryDb(JsonKey.Schema, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	schemaMap := map[string]map[string]interface{}{}
+	idList := []string{}
+	for _, schema := range schemaList {
+		schemaId, _ := schema[Record.DataId].(string)
+		if _, ver := Util.ParseCustomPath(schemaId, JsonKey.ArchivedSchemaIdDiv); ver != "" {
+			continue
+		}
+		if _, ok := Common.InternalTypes[schemaId]; ok {
+			continue
+		}
+		schemaMap[schemaId] = schema
+		idList = append(idList, schemaId)
+	}
+	sort.Strings(idList)
+	result := make([]map[string]interface{}, 0, len(idList))
+	visited := map[string]bool{}
+	var visit func(schemaId string)
+	visit = func(schemaId string) {
+		if _, ok := visited[schemaId]; ok {
+			return
+		}
+		visited[schemaId] = false
+		for _, refType := range h.schemaRefTypes(schemaMap[schemaId]) {
+			if _, ok := schemaMap[refType]; ok {
+				visit(refType)
+			}
+		}
+		visited[schemaId] = true
+		result = append(result, schemaMap[schemaId])
+	}
+	for _, schemaId := range idList {
+		visit(schemaId)
+	}
+	return result, nil
+}
+
+// sorted types that schema refer to with contentMediaType
+func (h *Handler) schemaRefTypes(schema map[string]interface{}) []string {
+	record, ex := Record.LoadMap(schema)
+	if ex != nil {
+		h.Log(fmt.Sprintf("Export: failed to load schema [%s] as record, Error:%s", schema[Record.DataId], ex))
+		return nil
+	}
+	schemaData, _ := Json.CopyToMap(record.Data)
+	doc, ex := SchemaDoc.New(schemaData)
+	if ex != nil {
+		h.Log(fmt.Sprintf("Export: failed to load schema [%s], Error:%s", record.Id, ex))
+		return nil
+	}
+	refMap := map[string]bool{}
+	collectRefTypes(doc, refMap)
+	delete(refMap, record.Id)
+	refList := make([]string, 0, len(refMap))
+	for refType := range refMap {
+		refList = append(refList, refType)
+	}
+	sort.Strings(refList)
+	return refList
+}
+
+func collectRefTypes(doc *SchemaDoc.SchemaDoc, refMap map[string]bool) {
+	for _, ref := range doc.CmtRefs {
+		refMap[ref.ContentType] = true
+	}
+	for _, subDoc := range doc.SubDocs {
+		collectRefTypes(subDoc, refMap)
+	}
+	for _, defDoc := range doc.Definitions {
+		collectRefTypes(defDoc, refMap)
+	}
+}
+
+// Import load newline-delimited JSON records, each line is validated and saved on its own,
+// failure of one line is reported in result and does not stop the import.
+// identity is checked for POST on type of each created record and PUT on type of each replaced one
+func (h *Handler) Import(r io.Reader, mode string, identity *Http.Identity) (*ImportResult, *Http.HttpError) {
+	if mode == "" {
+		mode = ImportCreateOnly
+	}
+	switch mode {
+	case ImportCreateOnly, ImportUpsert, ImportReplace:
+	default:
+		return nil, Http.NewHttpError(fmt.Sprintf("invalid import mode [%s], expect one of [%s, %s, %s]", mode, ImportCreateOnly, ImportUpsert, ImportReplace), http.StatusBadRequest)
+	}
+	result := ImportResult{
+		Errors: []ImportError{},
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxImportLineSize)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		status, err := h.importLine(line, mode, identity)
+		if err != nil {
+			h.Log(fmt.Sprintf("Import: line [%d] failed, Error:%s", lineNum, err))
+			result.Failed++
+			result.Errors = append(result.Errors, ImportError{
+				Line:  lineNum,
+				Error: err,
+			})
+			continue
+		}
+		switch status {
+		case http.StatusCreated:
+			result.Created++
+		case http.StatusAccepted:
+			result.Replaced++
+		default:
+			result.Unchanged++
+		}
+	}
+	ex := scanner.Err()
+	if ex != nil {
+		// rest of input is not imported, e.g. line exceeds MaxImportLineSize
+		result.Failed++
+		result.Errors = append(result.Errors, ImportError{
+			Line:  lineNum + 1,
+			Error: Http.WrapError(ex, fmt.Sprintf("failed to read import after line [%d]", lineNum), http.StatusBadRequest),
+		})
+	}
+	return &result, nil
+}
+
+// import one record, return StatusCreated, StatusAccepted when replaced, or StatusNotModified
+func (h *Handler) importLine(line []byte, mode string, identity *Http.Identity) (int, *Http.HttpError) {
+	data := map[string]interface{}{}
+	ex := json.Unmarshal(line, &data)
+	if ex != nil {
+		return 0, Http.WrapError(ex, "invalid JSON", http.StatusBadRequest)
+	}
+	record, ex := Record.LoadMap(data)
+	if ex != nil {
+		return 0, Http.WrapError(ex, "failed to load line as Record", http.StatusBadRequest)
+	}
+	if _, ok := Common.InternalTypes[record.Type]; ok && record.Type != JsonKey.Schema {
+		return 0, Http.NewHttpError(fmt.Sprintf("import of internal type [%s] is not supported", record.Type), http.StatusBadRequest)
+	}
+	// revision and deletion of source are not carried over
+	record.Revision = 0
+	record.Deleted = ""
+	current, err := h.localRecord(record.Type, record.Id)
+	if err != nil && err.Status != http.StatusNotFound {
+		return 0, err
+	}
+	if current == nil {
+		if mode == ImportReplace {
+			return 0, Http.NewHttpError(fmt.Sprintf("data [type/id]=[%s/%s] does not exists", record.Type, record.Id), http.StatusNotFound)
+		}
+		err = h.authorizeImport(identity, http.MethodPost, record)
+		if err != nil {
+			return 0, err
+		}
+		err = h.Add(record)
+		if err != nil {
+			return 0, err
+		}
+		return http.StatusCreated, nil
+	}
+	if mode == ImportCreateOnly {
+		return 0, Http.NewHttpError(fmt.Sprintf("data [type/id]=[%s/%s] already exists", record.Type, record.Id), http.StatusConflict)
+	}
+	before, ex := Record.LoadMap(current)
+	if ex != nil {
+		return 0, Http.WrapError(ex, fmt.Sprintf("failed to load data as record.[type/id]=[%s/%s]", record.Type, record.Id), http.StatusInternalServerError)
+	}
+	if before.Deleted == "" && before.Version == record.Version {
+		isSame, err := h.CompareRecords(before, record)
+		if err != nil {
+			return 0, err
+		}
+		if isSame {
+			return http.StatusNotModified, nil
+		}
+	}
+	err = h.authorizeImport(identity, http.MethodPut, record)
+	if err != nil {
+		return 0, err
+	}
+	if record.Type == JsonKey.Schema {
+		// schema is replaced by upgrade, current version is archived
+		err = h.Add(record)
+	} else {
+		err = h.Set(record.Type, record.Id, record)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return http.StatusAccepted, nil
+}
+
+// schema is an internal type, so it is only allowed by rules naming it explicitly
+func (h *Handler) authorizeImport(identity *Http.Identity, method string, record *Record.Record) *Http.HttpError {
+	if h.Authorize == nil {
+		return nil
+	}
+	err := h.Authorize(identity, strings.ToLower(method), record.Type, "")
+	if err != nil {
+		h.Log(fmt.Sprintf("Import: %s of [%s/%s] denied", method, record.Type, record.Id))
+	}
+	return err
+}
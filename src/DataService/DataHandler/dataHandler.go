@@ -27,16 +27,22 @@ package DataHandler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"path"
 	"strings"
+	"time"
 
+	"Data/DbCache"
 	"Data/DbConfig"
 	"Data/DbIface"
+	"Data/DbMetrics"
 	"DataService/Common"
 	"DataService/Config"
+	"DataService/DataJournal/ProcessIface"
 
 	"github.com/salesforce/UniTAO/lib/Schema"
 	"github.com/salesforce/UniTAO/lib/Schema/CmtIndex"
@@ -53,7 +59,11 @@ import (
 	"github.com/salesforce/UniTAO/lib/Util/Template"
 )
 
-type JournalAdd func(dataType string, dataId string, before map[string]interface{}, after map[string]interface{}) *Http.HttpError
+// JournalAdd record change of [dataType/dataId] and commit it with data changes in batch
+type JournalAdd func(batch *DbIface.Batch, dataType string, dataId string, before map[string]interface{}, after map[string]interface{}, meta *ProcessIface.ChangeMeta) *Http.HttpError
+
+// AccessCheck return StatusForbidden when identity is not allowed to do verb on path of data of dataType
+type AccessCheck func(identity *Http.Identity, verb string, dataType string, path string) *Http.HttpError
 
 type Handler struct {
 	DB         DbIface.Database
@@ -62,7 +72,10 @@ type Handler struct {
 	Lock       *HashLock.HashLock
 	Inventory  *DataServiceProxy
 	AddJournal JournalAdd
+	Authorize  AccessCheck
+	change     *ProcessIface.ChangeMeta
 	log        *log.Logger
+	metrics    *DbMetrics.Database
 }
 
 func New(config Config.Confuguration, logger *log.Logger, connectDb func(db DbConfig.DatabaseConfig, logger *log.Logger) (DbIface.Database, error)) (*Handler, *Http.HttpError) {
@@ -73,28 +86,94 @@ func New(config Config.Confuguration, logger *log.Logger, connectDb func(db DbCo
 	if err != nil {
 		return nil, Http.WrapError(err, "failed to connect to Database", http.StatusInternalServerError)
 	}
+	// metrics measure the backend, so cache hits are not counted in
+	metrics, err := DbMetrics.New(db, logger)
+	if err != nil {
+		return nil, Http.WrapError(err, "failed to instrument Database", http.StatusInternalServerError)
+	}
+	db = metrics
+	if config.Cache.Size > 0 {
+		db, err = DbCache.New(db, config.Cache, logger)
+		if err != nil {
+			return nil, Http.WrapError(err, "failed to create Database cache", http.StatusInternalServerError)
+		}
+	}
 	handler := Handler{
 		schemaMap: make(map[string]*Schema.SchemaOps),
 		DB:        db,
 		Config:    config,
 		Lock:      HashLock.NewHashLock(logger),
 		log:       logger,
+		metrics:   metrics,
 	}
 	handler.Inventory = CreateDsProxy(&handler)
 	return &handler, nil
 }
 
+// ForTable return handler of data in table, it shares Database connection with h.
+// schemas and locks are not shared and types are not resolved from inventory, so data in table are isolated
+func (h *Handler) ForTable(table string) *Handler {
+	config := h.Config
+	config.DataTable.Data = table
+	config.Inv.Url = ""
+	handler := Handler{
+		schemaMap: make(map[string]*Schema.SchemaOps),
+		DB:        h.DB,
+		Config:    config,
+		Lock:      HashLock.NewHashLock(h.log),
+		Authorize: h.Authorize,
+		log:       h.log,
+		metrics:   h.metrics,
+	}
+	handler.Inventory = CreateDsProxy(&handler)
+	return &handler
+}
+
+// WithChange return a handler sharing database, schemas and locks with h that records meta in journal of its changes
+func (h *Handler) WithChange(meta *ProcessIface.ChangeMeta) *Handler {
+	handler := *h
+	handler.change = meta
+	return &handler
+}
+
+// CacheStats return counters of Database cache, nil when cache is not configured
+func (h *Handler) CacheStats() *DbCache.Stats {
+	cache, ok := h.DB.(*DbCache.Database)
+	if !ok {
+		return nil
+	}
+	stats := cache.Stats()
+	return &stats
+}
+
+// WriteMetrics write Database metrics and cache counters in Prometheus text exposition format
+func (h *Handler) WriteMetrics(w io.Writer) {
+	h.metrics.WriteText(w)
+	stats := h.CacheStats()
+	if stats != nil {
+		stats.WriteText(w)
+	}
+}
+
 func (h *Handler) Log(message string) {
 	h.log.Printf("Handler: %s", message)
 }
 
-func (h *Handler) QueryDb(dataType string, dataId string) ([]map[string]interface{}, *Http.HttpError) {
+// QueryDb query records of dataType, dataId and filter are optional
+func (h *Handler) QueryDb(dataType string, dataId string, filter *DbIface.FilterExpr) ([]map[string]interface{}, *Http.HttpError) {
 	args := make(map[string]interface{})
 	args[DbIface.Table] = h.Config.DataTable.Data
 	args[Record.DataType] = dataType
 	if dataId != "" {
 		args[Record.DataId] = dataId
 	}
+	if filter != nil {
+		err := filter.Validate()
+		if err != nil {
+			return nil, Http.WrapError(err, fmt.Sprintf("invalid filter on type [%s]", dataType), http.StatusBadRequest)
+		}
+		args[DbIface.Filter] = filter
+	}
 	recordList, err := h.DB.Get(args)
 	if err != nil {
 		return nil, Http.NewHttpError(err.Error(), http.StatusInternalServerError)
@@ -102,28 +181,44 @@ func (h *Handler) QueryDb(dataType string, dataId string) ([]map[string]interfac
 	return recordList, nil
 }
 
-func (h *Handler) List(dataType string) ([]interface{}, *Http.HttpError) {
-	if dataType != JsonKey.Schema && dataType != "" {
-		_, err := h.LocalData(JsonKey.Schema, dataType)
+// QueryDbPage return one page of records of dataType and the token of next page
+func (h *Handler) QueryDbPage(dataType string, filter *DbIface.FilterExpr, pageSize int, pageToken string) ([]map[string]interface{}, string, *Http.HttpError) {
+	if pageSize < 0 {
+		return nil, "", Http.NewHttpError(fmt.Sprintf("invalid page size [%d] on type [%s]", pageSize, dataType), http.StatusBadRequest)
+	}
+	args := make(map[string]interface{})
+	args[DbIface.Table] = h.Config.DataTable.Data
+	args[Record.DataType] = dataType
+	if filter != nil {
+		err := filter.Validate()
 		if err != nil {
-			return nil, Http.WrapError(err, fmt.Sprintf("object of type “%s” does not exist", dataType), err.Status)
+			return nil, "", Http.WrapError(err, fmt.Sprintf("invalid filter on type [%s]", dataType), http.StatusBadRequest)
 		}
+		args[DbIface.Filter] = filter
 	}
-	if dataType == "" {
-		dataType = JsonKey.Schema
-	}
-	recordList, e := h.QueryDb(dataType, "")
-	if e != nil {
-		return nil, Http.NewHttpError(e.Error(), http.StatusInternalServerError)
-	}
-	result := make([]interface{}, 0, len(recordList))
-	for _, record := range recordList {
-		// not record schema is only for schema.
-		if record[Record.DataId] != Record.KeyRecord {
-			result = append(result, record[Record.DataId].(string))
+	recordList, nextToken, err := h.DB.GetPage(args, pageSize, pageToken)
+	if err != nil {
+		if errors.Is(err, DbIface.ErrInvalidPageToken) {
+			return nil, "", Http.WrapError(err, fmt.Sprintf("failed to list type [%s]", dataType), http.StatusBadRequest)
 		}
+		return nil, "", Http.NewHttpError(err.Error(), http.StatusInternalServerError)
 	}
-	return result, nil
+	return recordList, nextToken, nil
+}
+
+func (h *Handler) List(dataType string) ([]interface{}, *Http.HttpError) {
+	result, _, err := h.ListPage(dataType, 0, "")
+	return result, err
+}
+
+// ListPage list ids of dataType with at most pageSize ids after pageToken, pageSize 0 means no limit.
+// returned token is empty when there is no more page
+func (h *Handler) ListPage(dataType string, pageSize int, pageToken string) ([]interface{}, string, *Http.HttpError) {
+	return h.Query(dataType, &ListQuery{
+		Paged:     true,
+		PageSize:  pageSize,
+		PageToken: pageToken,
+	})
 }
 
 func (h *Handler) Get(dataType string, idPath string) (interface{}, *Http.HttpError) {
@@ -174,8 +269,29 @@ func (h *Handler) GetDataByPath(dataType string, idPath string, nextPath string)
 	return result, nil
 }
 
+// PathTargets resolve SchemaPath of a record to records and attribute paths it covers
+func (h *Handler) PathTargets(dataType string, dataId string, nextPath string) ([]*SchemaPath.Target, *Http.HttpError) {
+	conn := SchemaPathData.Connection{
+		FuncRecord: h.Inventory.Get,
+	}
+	return SchemaPath.Targets(&conn, fmt.Sprintf("%s/%s/%s", dataType, dataId, nextPath))
+}
+
+// LocalData return record of [dataType/dataId], soft deleted record is not found
 func (h *Handler) LocalData(dataType string, dataId string) (map[string]interface{}, *Http.HttpError) {
-	recordList, err := h.QueryDb(dataType, dataId)
+	data, err := h.localRecord(dataType, dataId)
+	if err != nil {
+		return nil, err
+	}
+	if IsDeleted(data) {
+		return nil, Http.NewHttpError(fmt.Sprintf("object of type '%s' with id '%s' is deleted", dataType, dataId), http.StatusNotFound)
+	}
+	return data, nil
+}
+
+// localRecord return record of [dataType/dataId] including tombstone of soft deleted one
+func (h *Handler) localRecord(dataType string, dataId string) (map[string]interface{}, *Http.HttpError) {
+	recordList, err := h.QueryDb(dataType, dataId, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -276,7 +392,16 @@ func (h *Handler) Validate(record *Record.Record) *Http.HttpError {
 	if record.Type != JsonKey.Schema {
 		err = h.ValidateDataRefs(schema.Schema, record.Data, path.Join(record.Type, record.Id))
 	} else {
-		err = h.validateCmtAutoIdxOnSchema(record)
+		err = validateRouteOnSchema(record)
+		if err == nil {
+			err = h.validateCmtAutoIdxOnSchema(record)
+		}
+		if err == nil {
+			err = validateSoftDeleteOnSchema(record)
+		}
+		if err == nil {
+			err = validateTtlOnSchema(record)
+		}
 	}
 	if err != nil {
 		h.Log(err.Error())
@@ -400,6 +525,14 @@ func (h *Handler) validateCmtRefValue(ref *SchemaDoc.CMTDocRef, value string, da
 	return nil
 }
 
+func validateRouteOnSchema(record *Record.Record) *Http.HttpError {
+	dataType, _ := Util.ParseCustomPath(record.Id, JsonKey.ArchivedSchemaIdDiv)
+	if _, ok := Common.RouteTypes[dataType]; ok {
+		return Http.NewHttpError(fmt.Sprintf("invalid schema id=[%s], it is reserved by api route [/%s]", record.Id, dataType), http.StatusBadRequest)
+	}
+	return nil
+}
+
 func (h *Handler) validateCmtAutoIdxOnSchema(record *Record.Record) *Http.HttpError {
 	schemaData, _ := Json.CopyToMap(record.Data)
 	schema, _ := SchemaDoc.New(schemaData)
@@ -446,11 +579,15 @@ func (h *Handler) Add(record *Record.Record) *Http.HttpError {
 	h.Lock.Aquire(idKey, "HandlerAdd")
 	defer h.Lock.Release(idKey, "HandlerAdd")
 	h.Log(fmt.Sprintf("HandlerAdd: query exists.[%s/%s]", record.Type, record.Id))
-	recordList, err := h.QueryDb(record.Type, record.Id)
+	recordList, err := h.QueryDb(record.Type, record.Id, nil)
 	if err != nil {
 		h.Log(fmt.Sprintf("HandlerAdd: query failed.[%s/%s]", record.Type, record.Id))
 		return err
 	}
+	if len(recordList) > 0 && IsDeleted(recordList[0]) {
+		h.Log(fmt.Sprintf("HandlerAdd: deleted.[%s/%s]", record.Type, record.Id))
+		return Http.NewHttpError(fmt.Sprintf("data [type/id]=[%s/%s] is deleted, undelete it or wait for it to be purged", record.Type, record.Id), http.StatusConflict)
+	}
 	if len(recordList) > 0 {
 		h.Log(fmt.Sprintf("HandlerAdd: already exists.[%s/%s]", record.Type, record.Id))
 		if record.Type != JsonKey.Schema {
@@ -509,32 +646,51 @@ func (h *Handler) archiveCurrentSchema(newSchema *Schema.SchemaOps) *Http.HttpEr
 	}
 	schema.Record.Id = SchemaDoc.ArchivedSchemaId(schema.Schema.Id, schema.Schema.Version)
 	h.Log(fmt.Sprintf("HandlerAdd: updating schema record [%s]", newSchema.Schema.Id))
-	err = h.updateRecord(JsonKey.Schema, schema.Schema.Id, schema.Record)
+	batch := DbIface.NewBatch()
+	err = h.updateRecord(batch, JsonKey.Schema, schema.Schema.Id, before.Revision, schema.Record)
+	if err != nil {
+		schema.Record.Id = before.Id
+		schema.Record.Revision = before.Revision
+		return err
+	}
+	h.Log(fmt.Sprintf("HandlerAdd: Add Journal of schema archive. [%s]->[%s]", before.Id, schema.Record.Id))
+	err = h.commit(batch, before.Type, before.Id, before.Map(), schema.Record.Map())
 	if err != nil {
+		schema.Record.Id = before.Id
+		schema.Record.Revision = before.Revision
 		return err
 	}
 	h.Log(fmt.Sprintf("HandlerAdd: schema archived [%s]", newSchema.Schema.Id))
 	h.SetLocalSchema(schema.Schema.Id, nil)
 	h.SetLocalSchema(schema.Record.Id, schema)
+	return nil
+}
+
+// commit batch with journal of the change, so data and journal are saved or failed together
+func (h *Handler) commit(batch *DbIface.Batch, dataType string, dataId string, before map[string]interface{}, after map[string]interface{}) *Http.HttpError {
 	if h.AddJournal != nil {
-		h.Log(fmt.Sprintf("HandlerAdd: Add Journal of schema archive. [%s]->[%s]", before.Id, schema.Record.Id))
-		h.AddJournal(before.Type, before.Id, before.Map(), schema.Record.Map())
+		return h.AddJournal(batch, dataType, dataId, before, after, h.change)
+	}
+	e := h.DB.Commit(batch)
+	if e != nil {
+		return Http.WrapError(e, fmt.Sprintf("failed to commit changes of [%s/%s]", dataType, dataId), Common.CommitStatus(e))
 	}
 	return nil
 }
 
 func (h *Handler) addData(record *Record.Record) *Http.HttpError {
 	h.Log(fmt.Sprintf("HandlerAdd: add record [%s/%s]", record.Type, record.Id))
-	e := h.DB.Create(h.Config.DataTable.Data, record.Map())
-	if e != nil {
-		return Http.WrapError(e, fmt.Sprintf("failed to create record [{type}/{id}]=[%s]/%s", record.Type, record.Id), http.StatusInternalServerError)
+	err := h.setExpire(record, time.Now())
+	if err != nil {
+		return err
 	}
-	h.Log(fmt.Sprintf("HandlerAdd: record added [%s/%s]", record.Type, record.Id))
-	if h.AddJournal != nil {
-		h.Log(fmt.Sprintf("HandlerAdd: add journal for new record [%s/%s]", record.Type, record.Id))
-		h.AddJournal(record.Type, record.Id, nil, record.Map())
-		// we should log err if failed to add Journal
+	record.Revision = 1
+	batch := DbIface.NewBatch().Create(h.Config.DataTable.Data, record.Map())
+	err = h.commit(batch, record.Type, record.Id, nil, record.Map())
+	if err != nil {
+		return Http.WrapError(err, fmt.Sprintf("failed to create record [{type}/{id}]=[%s]/%s", record.Type, record.Id), err.Status)
 	}
+	h.Log(fmt.Sprintf("HandlerAdd: record added [%s/%s]", record.Type, record.Id))
 	return nil
 }
 
@@ -577,9 +733,31 @@ func (h *Handler) CompareRecords(before *Record.Record, after *Record.Record) (b
 }
 
 func (h *Handler) Set(dataType string, dataId string, record *Record.Record) *Http.HttpError {
+	return h.SetIfMatch(dataType, dataId, record, "")
+}
+
+// SetIfMatch set record when current record match ETag in ifMatch, StatusPreconditionFailed otherwise
+func (h *Handler) SetIfMatch(dataType string, dataId string, record *Record.Record, ifMatch string) *Http.HttpError {
 	if _, ok := Common.InternalTypes[record.Type]; ok {
 		return Http.NewHttpError(fmt.Sprintf("method[%s] on type[%s] is not allowed", http.MethodPut, record.Type), http.StatusBadRequest)
 	}
+	return h.setRecord(dataType, dataId, record, ifMatch)
+}
+
+// SetInternal create or replace record of internal type, such as role, which are not allowed in Set.
+// record is saved with journal like other records, and replaced only on revision it is loaded
+func (h *Handler) SetInternal(record *Record.Record) *Http.HttpError {
+	if _, ok := Common.InternalTypes[record.Type]; !ok {
+		return Http.NewHttpError(fmt.Sprintf("type[%s] is not internal", record.Type), http.StatusBadRequest)
+	}
+	err := h.Add(record)
+	if err == nil || err.Status != http.StatusConflict {
+		return err
+	}
+	return h.setRecord(record.Type, record.Id, record, "")
+}
+
+func (h *Handler) setRecord(dataType string, dataId string, record *Record.Record, ifMatch string) *Http.HttpError {
 	if dataType == "" {
 		dataType = record.Type
 	}
@@ -589,10 +767,17 @@ func (h *Handler) Set(dataType string, dataId string, record *Record.Record) *Ht
 	idKey := fmt.Sprintf("%s/%s", dataType, dataId)
 	h.Lock.Aquire(idKey, "HandlerSet")
 	defer h.Lock.Release(idKey, "HandlerSet")
-	data, err := h.LocalData(dataType, dataId)
+	data, err := h.localRecord(dataType, dataId)
 	if err != nil && err.Status != http.StatusNotFound {
 		return err
 	}
+	if IsDeleted(data) {
+		return Http.NewHttpError(fmt.Sprintf("data [type/id]=[%s/%s] is deleted, undelete it before update", dataType, dataId), http.StatusConflict)
+	}
+	err = checkIfMatch(ifMatch, dataType, dataId, data)
+	if err != nil {
+		return err
+	}
 	var before *Record.Record
 	if data != nil {
 		record, ex := Record.LoadMap(data)
@@ -601,110 +786,162 @@ func (h *Handler) Set(dataType string, dataId string, record *Record.Record) *Ht
 		}
 		before = record
 	}
+	if before == nil {
+		return Http.NewHttpError(fmt.Sprintf("data [type/id]=[%s/%s] does not exists", dataType, dataId), http.StatusNotFound)
+	}
+	if record.Revision != 0 && record.Revision != before.Revision {
+		return Http.NewHttpError(fmt.Sprintf("record [%s/%s] is on revision [%d], not match specified revision [%d]", dataType, dataId, before.Revision, record.Revision), http.StatusConflict)
+	}
 	isSame, err := h.CompareRecords(before, record)
 	if err != nil {
 		return err
 	}
-	if !isSame {
-		err = h.updateRecord(before.Type, before.Id, record)
-		if err != nil {
-			return err
-		}
-		if h.AddJournal != nil {
-			h.AddJournal(record.Type, record.Id, before.Map(), record.Map())
-		}
+	if isSame {
+		record.Revision = before.Revision
+		record.Expire = before.Expire
+		return nil
 	}
-	return nil
+	batch := DbIface.NewBatch()
+	err = h.updateRecord(batch, before.Type, before.Id, before.Revision, record)
+	if err != nil {
+		return err
+	}
+	return h.commit(batch, record.Type, record.Id, before.Map(), record.Map())
 }
 
-func (h *Handler) updateRecord(dataType string, dataId string, record *Record.Record) *Http.HttpError {
+// validate record and add replace of current record into batch.
+// replace only succeed when current record is still on revision, record is saved with next revision
+func (h *Handler) updateRecord(batch *DbIface.Batch, dataType string, dataId string, revision int64, record *Record.Record) *Http.HttpError {
 	err := h.Validate(record)
 	if err != nil {
 		return err
 	}
-	e := h.DB.Replace(h.Config.DataTable.Data, map[string]interface{}{
+	err = h.setExpire(record, time.Now())
+	if err != nil {
+		return err
+	}
+	record.Revision = revision + 1
+	batch.Replace(h.Config.DataTable.Data, map[string]interface{}{
 		Record.DataType: dataType,
 		Record.DataId:   dataId,
+		Record.Revision: revision,
 	}, record.Map())
-	if e != nil {
-		return Http.NewHttpError(e.Error(), http.StatusInternalServerError)
-	}
 	return nil
 }
 
 func (h *Handler) Delete(dataType string, dataId string) *Http.HttpError {
+	return h.DeleteIfMatch(dataType, dataId, "")
+}
+
+// DeleteIfMatch delete record when current record match ETag in ifMatch, StatusPreconditionFailed otherwise
+func (h *Handler) DeleteIfMatch(dataType string, dataId string, ifMatch string) *Http.HttpError {
+	// ETag is compared on current record under lock of the record, so it is not changed before delete
+	var matchErr *Http.HttpError
+	var match func(record *Record.Record) bool
+	if ifMatch != "" {
+		match = func(record *Record.Record) bool {
+			matchErr = checkIfMatch(ifMatch, dataType, dataId, record.Map())
+			return matchErr == nil
+		}
+	}
+	var deleted bool
+	var err *Http.HttpError
 	if dataType == JsonKey.Schema {
-		err := h.deleteSchema(dataId)
+		deleted, err = h.deleteSchema(dataId, match)
+		if err != nil {
+			return err
+		}
+		if matchErr == nil {
+			delete(h.schemaMap, dataId)
+		}
+	} else {
+		_, err = h.LocalSchema(dataType, "")
+		if err != nil {
+			return err
+		}
+		deleted, err = h.deleteRecord(dataType, dataId, match)
 		if err != nil {
 			return err
 		}
-		delete(h.schemaMap, dataId)
 	}
-	_, err := h.LocalSchema(dataType, "")
-	if err != nil {
-		return err
+	if matchErr != nil {
+		return matchErr
+	}
+	if !deleted {
+		return checkIfMatch(ifMatch, dataType, dataId, nil)
 	}
-	return h.deleteData(dataType, dataId)
+	return nil
 }
 
-func (h *Handler) deleteSchema(dataType string) *Http.HttpError {
+func (h *Handler) deleteSchema(dataType string, match func(record *Record.Record) bool) (bool, *Http.HttpError) {
 	schemaId, schemaVer := Util.ParseCustomPath(dataType, JsonKey.ArchivedSchemaIdDiv)
 	if schemaVer != "" {
-		recordList, err := h.QueryDb(schemaId, "")
+		recordList, err := h.QueryDb(schemaId, "", nil)
 		if err != nil {
-			return err
+			return false, err
 		}
 		for _, data := range recordList {
 			rec, ex := Record.LoadMap(data)
 			if ex != nil {
-				return Http.WrapError(ex, fmt.Sprintf("failed to load data as Record. dataType=[%s]", schemaId), http.StatusInternalServerError)
+				return false, Http.WrapError(ex, fmt.Sprintf("failed to load data as Record. dataType=[%s]", schemaId), http.StatusInternalServerError)
 			}
 			if rec.Version == schemaVer {
-				return Http.NewHttpError(fmt.Sprintf("data exists, cannot delete schema=[%s], version=[%s]", schemaId, schemaVer), http.StatusBadRequest)
+				return false, Http.NewHttpError(fmt.Sprintf("data exists, cannot delete schema=[%s], version=[%s]", schemaId, schemaVer), http.StatusBadRequest)
 			}
 		}
-		return h.deleteData(JsonKey.Schema, dataType)
+		return h.deleteRecord(JsonKey.Schema, dataType, match)
 	}
 	archivedPrefix := SchemaDoc.ArchivedSchemaId(dataType, "")
 	schemaList, err := h.List(JsonKey.Schema)
 	if err != nil {
-		return err
+		return false, err
 	}
 	for _, queryType := range schemaList {
 		if strings.HasPrefix(queryType.(string), archivedPrefix) {
-			return Http.NewHttpError(fmt.Sprintf("there are archived schema for type=[%s], please delete all archived schema before delete type", dataType), http.StatusBadRequest)
+			return false, Http.NewHttpError(fmt.Sprintf("there are archived schema for type=[%s], please delete all archived schema before delete type", dataType), http.StatusBadRequest)
 		}
 	}
-	return h.deleteData(JsonKey.Schema, dataType)
+	return h.deleteRecord(JsonKey.Schema, dataType, match)
 }
 
-func (h *Handler) deleteData(dataType string, dataId string) *Http.HttpError {
+// delete record of [dataType/dataId] when match is nil or match return true on current record,
+// return true when record is deleted
+func (h *Handler) deleteRecord(dataType string, dataId string, match func(record *Record.Record) bool) (bool, *Http.HttpError) {
 	idKey := fmt.Sprintf("%s/%s", dataType, dataId)
 	h.Lock.Aquire(idKey, "HandlerDelete")
 	defer h.Lock.Release(idKey, "HandlerDelete")
-	recordList, err := h.QueryDb(dataType, dataId)
+	recordList, err := h.QueryDb(dataType, dataId, nil)
 	if err != nil {
-		return err
+		return false, err
 	}
-	if len(recordList) == 0 {
-		return nil
+	if len(recordList) == 0 || IsDeleted(recordList[0]) {
+		return false, nil
 	}
 	beforeRec, e := Record.LoadMap(recordList[0])
 	if e != nil {
-		return Http.WrapError(e, fmt.Sprintf("failed to load data as record.[type/id]=[%s/%s]", dataType, dataId), http.StatusInternalServerError)
+		return false, Http.WrapError(e, fmt.Sprintf("failed to load data as record.[type/id]=[%s/%s]", dataType, dataId), http.StatusInternalServerError)
+	}
+	if match != nil && !match(beforeRec) {
+		return false, nil
+	}
+	softDelete, _, err := h.softDelete(dataType)
+	if err != nil {
+		return false, err
+	}
+	if softDelete {
+		err = h.tombstone(beforeRec)
+		return err == nil, err
 	}
 
 	keys := make(map[string]interface{})
 	keys[Record.DataType] = dataType
 	keys[Record.DataId] = dataId
-	e = h.DB.Delete(h.Config.DataTable.Data, keys)
-	if e != nil {
-		return Http.WrapError(e, fmt.Sprintf("failed to delete record [type/id]=[%s/%s]", dataType, dataId), http.StatusInternalServerError)
-	}
-	if h.AddJournal != nil {
-		h.AddJournal(dataType, dataId, beforeRec.Map(), nil)
+	batch := DbIface.NewBatch().Delete(h.Config.DataTable.Data, keys)
+	err = h.commit(batch, dataType, dataId, beforeRec.Map(), nil)
+	if err != nil {
+		return false, Http.WrapError(err, fmt.Sprintf("failed to delete record [type/id]=[%s/%s]", dataType, dataId), err.Status)
 	}
-	return nil
+	return true, nil
 }
 
 func (h *Handler) Patch(dataType string, idPath string, headers map[string]interface{}, data interface{}) (map[string]interface{}, *Http.HttpError) {
@@ -714,12 +951,31 @@ func (h *Handler) Patch(dataType string, idPath string, headers map[string]inter
 		h.Log(errMsg)
 		return nil, Http.NewHttpError(errMsg, http.StatusBadRequest)
 	}
-	if nextPath == "" {
+	contentType := patchContentType(headers)
+	if contentType != "" {
+		if nextPath != "" {
+			errMsg := fmt.Sprintf("invalid path, [%s] apply on whole record, expect format=[{dataType}/{dataId}]", contentType)
+			h.Log(errMsg)
+			return nil, Http.NewHttpError(errMsg, http.StatusBadRequest)
+		}
+		if strData, ok := data.(string); ok {
+			var patchDoc interface{}
+			e := json.Unmarshal([]byte(strData), &patchDoc)
+			if e != nil {
+				return nil, Http.WrapError(e, fmt.Sprintf("failed to parse [%s] document", contentType), http.StatusBadRequest)
+			}
+			data = patchDoc
+		}
+	} else if nextPath == "" {
 		errMsg := "invalid path, no data path to drill in, expect format=[{dataType}/{dataId}/{dataPath}]"
 		h.Log(errMsg)
 		return nil, Http.NewHttpError(errMsg, http.StatusBadRequest)
 	}
-	_, err := h.LocalSchema(dataType, "")
+	currentSchema, err := h.LocalSchema(dataType, "")
+	if err != nil {
+		return nil, err
+	}
+	err = h.authorizePatch(currentSchema.Schema, dataType, dataId, nextPath, contentType, headers, data)
 	if err != nil {
 		return nil, err
 	}
@@ -741,16 +997,32 @@ func (h *Handler) Patch(dataType string, idPath string, headers map[string]inter
 		h.Log(e.Error())
 		return nil, Http.WrapError(e, fmt.Sprintf("failed to load data [%s/%s] as record", dataType, dataId), http.StatusInternalServerError)
 	}
+	// version mismatch is a failed precondition like If-Match, it was StatusNotModified before ETag support
 	patchVer, ok := headers[JsonKey.Version]
 	if ok {
 		h.Log(fmt.Sprintf("PATCH[%s/%s]: header path version [%s]", dataType, dataId, patchVer))
 		if patchRecord.Version != patchVer {
 			errMsg := fmt.Sprintf("current record:[%s/%s] version:[%s] does not match specified version:[%s]", dataType, dataId, patchRecord.Version, patchVer)
 			h.Log(errMsg)
-			return nil, Http.NewHttpError(errMsg, http.StatusNotModified)
+			return nil, Http.NewHttpError(errMsg, http.StatusPreconditionFailed)
 		}
 		h.Log("version match with header")
 	}
+	if ifMatch, ok := headers[strings.ToLower(Common.HeaderIfMatch)].(string); ok {
+		err = checkIfMatch(ifMatch, dataType, dataId, patchData)
+		if err != nil {
+			h.Log(err.Error())
+			return nil, err
+		}
+	}
+	if patchRev, ok := headers[strings.ToLower(Common.HeaderRevision)]; ok {
+		h.Log(fmt.Sprintf("PATCH[%s/%s]: header revision [%s]", dataType, dataId, patchRev))
+		if fmt.Sprint(patchRecord.Revision) != patchRev {
+			errMsg := fmt.Sprintf("current record:[%s/%s] revision:[%d] does not match specified revision:[%s]", dataType, dataId, patchRecord.Revision, patchRev)
+			h.Log(errMsg)
+			return nil, Http.NewHttpError(errMsg, http.StatusConflict)
+		}
+	}
 	h.Log(fmt.Sprintf("Handler PATCH[%s/%s]: get version schema [%s]", dataType, dataId, patchRecord.Version))
 	schema, err := h.LocalSchema(dataType, patchRecord.Version)
 	if err != nil {
@@ -765,14 +1037,30 @@ func (h *Handler) Patch(dataType string, idPath string, headers map[string]inter
 		h.Log(e.Error())
 		return nil, Http.WrapError(e, errMsg, http.StatusInternalServerError)
 	}
-	h.Log(fmt.Sprintf("PATCH [%s/%s] @[%s]", dataType, dataId, nextPath))
-	err = patchRecordByPath(schema.Schema, patchRecord, nextPath, fmt.Sprintf("%s/%s", dataType, dataId), data)
-	if err != nil {
-		if err.Status != http.StatusNotModified {
+	if contentType != "" {
+		h.Log(fmt.Sprintf("PATCH [%s/%s] with [%s]", dataType, dataId, contentType))
+		err = patchRecordByDoc(schema.Schema, patchRecord, contentType, fmt.Sprintf("%s/%s", dataType, dataId), data)
+		if err != nil {
 			h.Log(err.Error())
 			return nil, err
 		}
-		return patchRecord.Map(), nil
+		isSame, err := h.CompareRecords(&before, patchRecord)
+		if err != nil {
+			return nil, err
+		}
+		if isSame {
+			return patchRecord.Map(), nil
+		}
+	} else {
+		h.Log(fmt.Sprintf("PATCH [%s/%s] @[%s]", dataType, dataId, nextPath))
+		err = patchRecordByPath(schema.Schema, patchRecord, nextPath, fmt.Sprintf("%s/%s", dataType, dataId), data)
+		if err != nil {
+			if err.Status != http.StatusNotModified {
+				h.Log(err.Error())
+				return nil, err
+			}
+			return patchRecord.Map(), nil
+		}
 	}
 	verComp, err := CompareVersion(before.Version, patchRecord.Version)
 	if err != nil {
@@ -781,22 +1069,78 @@ func (h *Handler) Patch(dataType string, idPath string, headers map[string]inter
 	if verComp < 0 {
 		return nil, Http.NewHttpError(fmt.Sprintf("downgrade data format are not supported. version[%s] -> [%s]", before.Version, patchRecord.Version), http.StatusBadRequest)
 	}
-	err = h.updateRecord(before.Type, before.Id, patchRecord)
+	batch := DbIface.NewBatch()
+	err = h.updateRecord(batch, before.Type, before.Id, before.Revision, patchRecord)
 	if err != nil {
 		h.Log(err.Error())
 		return nil, err
 	}
-	h.Log(fmt.Sprintf("PATCH [%s/%s] complete", dataType, dataId))
-	if h.AddJournal != nil {
-		h.Log(fmt.Sprintf("PATCH [%s/%s] add journal", dataType, dataId))
-		h.AddJournal(dataType, dataId, before.Map(), patchRecord.Map())
-	} else {
-		h.Log("AddJournal is not defined here.")
+	h.Log(fmt.Sprintf("PATCH [%s/%s] commit with journal", dataType, dataId))
+	err = h.commit(batch, dataType, dataId, before.Map(), patchRecord.Map())
+	if err != nil {
+		h.Log(err.Error())
+		return nil, err
 	}
+	h.Log(fmt.Sprintf("PATCH [%s/%s] complete", dataType, dataId))
 	h.Log(fmt.Sprintf("PATCH [%s/%s] return patched record", dataType, dataId))
 	return patchRecord.Map(), nil
 }
 
+// authorizePatch check each path changed by patch when identity of caller is in headers,
+// internal calls without identity are not checked
+func (h *Handler) authorizePatch(schema *SchemaDoc.SchemaDoc, dataType string, dataId string, dataPath string, contentType string, headers map[string]interface{}, patch interface{}) *Http.HttpError {
+	value, ok := headers[Common.KeyIdentity]
+	if !ok || h.Authorize == nil {
+		return nil
+	}
+	identity, _ := value.(*Http.Identity)
+	pathList, err := patchPaths(schema, dataPath, contentType, patch)
+	if err != nil {
+		return err
+	}
+	for _, path := range pathList {
+		err := h.Authorize(identity, strings.ToLower(http.MethodPatch), dataType, path)
+		if err != nil {
+			h.Log(fmt.Sprintf("PATCH[%s/%s] denied on path [%s]", dataType, dataId, path))
+			return err
+		}
+	}
+	return nil
+}
+
+// patchPaths return paths in record data changed by patch, path of each operation in JSON Patch,
+// path of each value in JSON Merge Patch, dataPath when patch is not a document
+func patchPaths(schema *SchemaDoc.SchemaDoc, dataPath string, contentType string, patch interface{}) ([]string, *Http.HttpError) {
+	switch contentType {
+	case "":
+		return []string{dataPath}, nil
+	case Common.ContentTypeJsonPatch:
+		return Schema.JsonPatchPaths(schema, patch)
+	}
+	return Schema.MergePatchPaths(schema, patch)
+}
+
+// content type of document patch in headers, empty when patch a single value on path
+func patchContentType(headers map[string]interface{}) string {
+	contentType, _ := headers["content-type"].(string)
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch contentType {
+	case Common.ContentTypeJsonPatch, Common.ContentTypeMergePatch:
+		return contentType
+	}
+	return ""
+}
+
+func patchRecordByDoc(schema *SchemaDoc.SchemaDoc, record *Record.Record, contentType string, dataPath string, patch interface{}) *Http.HttpError {
+	if record.Type == JsonKey.Schema {
+		return Http.NewHttpError(fmt.Sprintf("Patch on [%s] not allowed", JsonKey.Schema), http.StatusBadRequest)
+	}
+	if contentType == Common.ContentTypeJsonPatch {
+		return Schema.ApplyJsonPatch(schema, record.Data, patch, dataPath)
+	}
+	return Schema.ApplyMergePatch(schema, record.Data, patch, dataPath)
+}
+
 func patchRecordByPath(schema *SchemaDoc.SchemaDoc, record *Record.Record, nextPath string, dataPath string, newData interface{}) *Http.HttpError {
 	switch nextPath {
 	case Record.DataId:
@@ -32,27 +32,30 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/salesforce/UniTAO/lib/Schema/CmtIndex"
 	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
 	"github.com/salesforce/UniTAO/lib/Schema/Record"
 	"github.com/salesforce/UniTAO/lib/Schema/SchemaDoc"
-	"github.com/salesforce/UniTAO/lib/Util"
 	"github.com/salesforce/UniTAO/lib/Util/Http"
 	"github.com/salesforce/UniTAO/lib/Util/Json"
 )
 
 type DataServiceProxy struct {
-	handler *Handler
-	Url     string
-	DsInfo  map[string]*InvRecord.DataServiceInfo
+	handler  *Handler
+	Url      string
+	DsInfo   map[string]*InvRecord.DataServiceInfo
+	AuthType map[string]string
 }
 
 func CreateDsProxy(hdl *Handler) *DataServiceProxy {
 	inv := DataServiceProxy{
-		handler: hdl,
-		Url:     hdl.Config.Inv.Url,
-		DsInfo:  map[string]*InvRecord.DataServiceInfo{},
+		handler:  hdl,
+		Url:      hdl.Config.Inv.Url,
+		DsInfo:   map[string]*InvRecord.DataServiceInfo{},
+		AuthType: map[string]string{},
 	}
 	inv.refresh()
 	return &inv
@@ -63,12 +66,16 @@ func (i *DataServiceProxy) Log(message string) {
 }
 
 func (i *DataServiceProxy) refresh() {
+	if i.Url == "" {
+		// handler is isolated, all types are local
+		return
+	}
 	schemaUrl, ex := Http.URLPathJoin(i.Url, JsonKey.Schema)
 	if ex != nil {
 		i.Log(fmt.Sprintf("failed to build inv schema url, Error:%s", ex))
 		return
 	}
-	data, code, ex := Http.GetRestData(*schemaUrl)
+	data, code, ex := Http.GetRestDataAuth(*schemaUrl, i.credential(""))
 	if ex != nil {
 		i.Log(fmt.Sprintf("inventory=[%s] does not work, code: %d Error: %s", *schemaUrl, code, ex))
 		return
@@ -113,7 +120,7 @@ func (i *DataServiceProxy) GetDsInfo(dataType string) (*InvRecord.DataServiceInf
 	}
 	if dsInfo == nil {
 		refUrl, _ := Http.URLPathJoin(i.Url, RefRecord.Referral, schemaId)
-		dsReferralInfo, status, err := Http.GetRestData(*refUrl)
+		dsReferralInfo, status, err := Http.GetRestDataAuth(*refUrl, i.credential(""))
 		if err != nil {
 			errMsg := fmt.Sprintf("failed to get referral data type=[%s] from inventory=[%s]", dataType, i.Url)
 			i.Log(errMsg)
@@ -142,18 +149,37 @@ func (i *DataServiceProxy) GetDsInfo(dataType string) (*InvRecord.DataServiceInf
 			return nil, Http.WrapError(err, errMsg, http.StatusInternalServerError)
 		}
 		i.DsInfo[schemaId] = dsRef.DsInfo
+		i.AuthType[schemaId] = dsRef.AuthType
 		dsInfo = dsRef.DsInfo
 	}
 	i.Log(fmt.Sprintf("DataService[%s] for dataType[%s]", dsInfo.Id, schemaId))
 	return dsInfo, nil
 }
 
-func (i *DataServiceProxy) getDsUrl(dataType string, dataId string) (string, *Http.HttpError) {
-	queryType := dataType
+// queryType is the type to find data service, id of schema and cmtIdx records is the type
+func queryType(dataType string, dataId string) string {
 	if dataType == CmtIndex.KeyCmtIdx || dataType == JsonKey.Schema {
-		queryType = dataId
+		return dataId
 	}
-	dsInfo, ex := i.GetDsInfo(queryType)
+	return dataType
+}
+
+// credential of this service for peers, limited to AuthType in referral of data service of dataType.
+// empty dataType is for inventory
+func (i *DataServiceProxy) credential(dataType string) *Http.Credential {
+	cred := i.handler.Config.Http.Auth.Peer
+	if dataType == "" {
+		return cred
+	}
+	schemaId, _, ex := SchemaDoc.ParseDataType(dataType)
+	if ex != nil {
+		return cred
+	}
+	return cred.For(i.AuthType[schemaId])
+}
+
+func (i *DataServiceProxy) getDsUrl(dataType string, dataId string) (string, *Http.HttpError) {
+	dsInfo, ex := i.GetDsInfo(queryType(dataType, dataId))
 	if ex != nil {
 		return "", ex
 	}
@@ -196,7 +222,7 @@ func (i *DataServiceProxy) List(dataType string) ([]interface{}, *Http.HttpError
 	if ex != nil {
 		return nil, Http.WrapError(err, "failed to build data list url", http.StatusInternalServerError)
 	}
-	data, code, ex := Http.GetRestData(*typeUrl)
+	data, code, ex := Http.GetRestDataAuth(*typeUrl, i.credential(""))
 	if ex != nil {
 		return nil, Http.WrapError(ex, fmt.Sprintf("inventory query=[%s] does not work", *typeUrl), code)
 	}
@@ -207,6 +233,50 @@ func (i *DataServiceProxy) List(dataType string) ([]interface{}, *Http.HttpError
 	return idList, nil
 }
 
+// ListPage list one page of ids of dataType. remote page is read from the owner Data Service directly,
+// inventory only forwards the full list
+func (i *DataServiceProxy) ListPage(dataType string, pageSize int, pageToken string) ([]interface{}, string, *Http.HttpError) {
+	_, err := i.handler.LocalSchema(dataType, "")
+	if err == nil {
+		return i.handler.ListPage(dataType, pageSize, pageToken)
+	}
+	if err.Status != http.StatusNotFound {
+		return nil, "", err
+	}
+	if pageSize == 0 && pageToken == "" {
+		idList, err := i.List(dataType)
+		return idList, "", err
+	}
+	dsUrl, err := i.getDsUrl(dataType, "")
+	if err != nil {
+		return nil, "", err
+	}
+	typeUrl, ex := Http.URLPathJoin(dsUrl, dataType)
+	if ex != nil {
+		return nil, "", Http.WrapError(ex, "failed to build data list url", http.StatusInternalServerError)
+	}
+	query := url.Values{}
+	query.Set(Common.QueryPageSize, strconv.Itoa(pageSize))
+	if pageToken != "" {
+		query.Set(Common.QueryPageToken, pageToken)
+	}
+	pageUrl := fmt.Sprintf("%s?%s", *typeUrl, query.Encode())
+	data, code, ex := Http.GetRestDataAuth(pageUrl, i.credential(dataType))
+	if ex != nil {
+		return nil, "", Http.WrapError(ex, fmt.Sprintf("data service query=[%s] does not work", pageUrl), code)
+	}
+	page, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, "", Http.NewHttpError("bad result from data service, failed convert to page", http.StatusBadRequest)
+	}
+	idList, ok := page[Common.KeyItems].([]interface{})
+	if !ok {
+		return nil, "", Http.NewHttpError(fmt.Sprintf("bad result from data service, [%s] is not array", Common.KeyItems), http.StatusBadRequest)
+	}
+	nextToken, _ := page[Common.KeyNextPageToken].(string)
+	return idList, nextToken, nil
+}
+
 func (i *DataServiceProxy) IsLocal(dataType string, dataId string) (bool, *Http.HttpError) {
 	var err *Http.HttpError
 	if dataType == JsonKey.Schema || dataType == CmtIndex.KeyCmtIdx {
@@ -214,9 +284,12 @@ func (i *DataServiceProxy) IsLocal(dataType string, dataId string) (bool, *Http.
 			return false, nil
 		}
 		if dataType == JsonKey.Schema {
-			schemaId, schemaVer := Util.ParsePath(dataId)
-			_, ex := i.handler.LocalSchema(schemaId, schemaVer)
-			err = ex
+			// id of schema is {type}/{version} or {type}__{version} of archived schema
+			schemaId, schemaVer, ex := SchemaDoc.ParseDataType(dataId)
+			if ex != nil {
+				return false, Http.WrapError(ex, fmt.Sprintf("invalid schema id=[%s]", dataId), http.StatusBadRequest)
+			}
+			_, err = i.handler.LocalSchema(schemaId, schemaVer)
 		} else {
 			_, ex := i.handler.LocalSchema(dataId, "")
 			err = ex
@@ -241,7 +314,7 @@ func (i *DataServiceProxy) Get(dataType string, dataId string) (*Record.Record,
 	}
 	if isLocal {
 		if dataType == JsonKey.Schema {
-			schemaId, schemaVer := Util.ParsePath(dataId)
+			schemaId, schemaVer, _ := SchemaDoc.ParseDataType(dataId)
 			schema, err := i.handler.LocalSchema(schemaId, schemaVer)
 			if err != nil {
 				i.Log(fmt.Sprintf("failed to get local schema [%s/%s]", schemaId, schemaVer))
@@ -272,7 +345,7 @@ func (i *DataServiceProxy) Get(dataType string, dataId string) (*Record.Record,
 		return nil, err
 	}
 	i.Log(fmt.Sprintf("Request GET from [%s]", queryUrl))
-	data, code, ex := Http.GetRestData(queryUrl)
+	data, code, ex := Http.GetRestDataAuth(queryUrl, i.credential(queryType(dataType, dataId)))
 	if ex == nil {
 		mapData, ok := data.(map[string]interface{})
 		if !ok {
@@ -312,7 +385,7 @@ func (i *DataServiceProxy) Post(record *Record.Record) *Http.HttpError {
 		i.Log(err.Error())
 		return err
 	}
-	_, status, ex := Http.SubmitPayload(queryUrl, http.MethodPost, nil, record.Map())
+	_, status, ex := Http.SubmitPayloadAuth(queryUrl, http.MethodPost, nil, record.Map(), i.credential(queryType(record.Type, record.Id)))
 	if ex != nil {
 		errMsg := fmt.Sprintf("failed to post [%s]", queryUrl)
 		i.Log(errMsg)
@@ -339,7 +412,7 @@ func (i *DataServiceProxy) Put(record *Record.Record) *Http.HttpError {
 	if err != nil {
 		return err
 	}
-	_, status, ex := Http.SubmitPayload(queryUrl, http.MethodPut, nil, record.Map())
+	_, status, ex := Http.SubmitPayloadAuth(queryUrl, http.MethodPut, nil, record.Map(), i.credential(queryType(record.Type, record.Id)))
 	if ex != nil {
 		return Http.WrapError(ex, fmt.Sprintf("failed to put [%s]", queryUrl), http.StatusInternalServerError)
 	}
@@ -367,7 +440,7 @@ func (i *DataServiceProxy) Patch(dataType string, dataId string, dataPath string
 		return err
 	}
 	pUrl := fmt.Sprintf("%s/%s", idUrl, dataPath)
-	resp, status, ex := Http.SubmitPayload(pUrl, http.MethodPatch, headers, data)
+	resp, status, ex := Http.SubmitPayloadAuth(pUrl, http.MethodPatch, headers, data, i.credential(queryType(dataType, dataId)))
 	respTxt := ""
 	if resp != nil {
 		respData, err := ioutil.ReadAll(resp.Body)
@@ -406,7 +479,7 @@ func (i *DataServiceProxy) Delete(dataType string, dataId string) *Http.HttpErro
 	if err != nil {
 		return err
 	}
-	_, status, ex := Http.SubmitPayload(idUrl, http.MethodDelete, nil, nil)
+	_, status, ex := Http.SubmitPayloadAuth(idUrl, http.MethodDelete, nil, nil, i.credential(queryType(dataType, dataId)))
 	if ex != nil {
 		return Http.WrapError(ex, fmt.Sprintf("failed to delete [%s]", idUrl), http.StatusInternalServerError)
 	}
@@ -0,0 +1,76 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataHandler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+	"github.com/salesforce/UniTAO/lib/Util/Json"
+)
+
+// ETag of record data, see Record.ETag
+func ETag(data map[string]interface{}) (string, *Http.HttpError) {
+	record := Record.Record{}
+	e := Json.CopyTo(data, &record)
+	if e != nil {
+		return "", Http.WrapError(e, "failed to load data as record", http.StatusInternalServerError)
+	}
+	return record.ETag(), nil
+}
+
+// MatchETag return true when etag is in list of If-Match or If-None-Match header, "*" match any etag
+func MatchETag(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch return StatusPreconditionFailed when If-Match header is set and does not match current record,
+// nil data means record does not exists
+func checkIfMatch(ifMatch string, dataType string, dataId string, data map[string]interface{}) *Http.HttpError {
+	if ifMatch == "" {
+		return nil
+	}
+	if data == nil || IsDeleted(data) {
+		return Http.NewHttpError(fmt.Sprintf("record [%s/%s] does not exists to match [%s]", dataType, dataId, ifMatch), http.StatusPreconditionFailed)
+	}
+	etag, err := ETag(data)
+	if err != nil {
+		return err
+	}
+	if !MatchETag(ifMatch, etag) {
+		return Http.NewHttpError(fmt.Sprintf("record [%s/%s] ETag [%s] does not match [%s]", dataType, dataId, etag, ifMatch), http.StatusPreconditionFailed)
+	}
+	return nil
+}
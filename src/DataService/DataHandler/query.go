@@ -0,0 +1,306 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataHandler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"Data/DbIface"
+	"DataService/Common"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Schema/SchemaDoc"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+const (
+	// value separator of filter parameter, format: {path}:{op}:{value}
+	FilterDiv = ":"
+	// value separator of filter op [in] and of sort parameter
+	ListDiv = ","
+	// prefix of sort path for descending order
+	SortDesc = "-"
+)
+
+// ListQuery on records of a type. Filter is pushed down to database,
+// Sort is applied in memory on all matched records, so it cannot be paged.
+// Paged is true when pageSize or pageToken is in query parameters
+type ListQuery struct {
+	Filter    *DbIface.FilterExpr
+	Sort      []DbIface.SortKey
+	Limit     int
+	Records   bool
+	Paged     bool
+	PageSize  int
+	PageToken string
+}
+
+// ParseListQuery parse query parameters of GET /{type}, attribute paths are validated against current schema of dataType
+func (h *Handler) ParseListQuery(dataType string, query url.Values) (*ListQuery, *Http.HttpError) {
+	q := ListQuery{
+		Paged:     query.Has(Common.QueryPageSize) || query.Has(Common.QueryPageToken),
+		PageToken: query.Get(Common.QueryPageToken),
+	}
+	var err *Http.HttpError
+	q.PageSize, err = queryInt(query, Common.QueryPageSize)
+	if err != nil {
+		return nil, err
+	}
+	q.Limit, err = queryInt(query, Common.QueryLimit)
+	if err != nil {
+		return nil, err
+	}
+	switch query.Get(Common.QueryView) {
+	case "", Common.ViewIds:
+	case Common.ViewRecords:
+		q.Records = true
+	default:
+		return nil, Http.NewHttpError(fmt.Sprintf("invalid %s=[%s], expect [%s] or [%s]", Common.QueryView, query.Get(Common.QueryView), Common.ViewIds, Common.ViewRecords), http.StatusBadRequest)
+	}
+	filterList := query[Common.QueryFilter]
+	sortList := query[Common.QuerySort]
+	if len(filterList) == 0 && len(sortList) == 0 {
+		return q.check()
+	}
+	if dataType == "" || dataType == JsonKey.Schema {
+		return nil, Http.NewHttpError(fmt.Sprintf("[%s] and [%s] are not supported on type [%s]", Common.QueryFilter, Common.QuerySort, JsonKey.Schema), http.StatusBadRequest)
+	}
+	schema, err := h.LocalSchema(dataType, "")
+	if err != nil {
+		return nil, err
+	}
+	itemList := []*DbIface.FilterExpr{}
+	for _, filterStr := range filterList {
+		filter, err := parseFilter(schema.Schema, filterStr)
+		if err != nil {
+			return nil, err
+		}
+		itemList = append(itemList, filter)
+	}
+	switch len(itemList) {
+	case 0:
+	case 1:
+		q.Filter = itemList[0]
+	default:
+		q.Filter = DbIface.And(itemList...)
+	}
+	for _, sortStr := range sortList {
+		for _, sortPath := range strings.Split(sortStr, ListDiv) {
+			key := DbIface.SortKey{
+				Path: strings.TrimPrefix(sortPath, SortDesc),
+				Desc: strings.HasPrefix(sortPath, SortDesc),
+			}
+			_, ex := queryAttrType(schema.Schema, key.Path)
+			if ex != nil {
+				return nil, Http.WrapError(ex, fmt.Sprintf("invalid %s=[%s]", Common.QuerySort, sortStr), http.StatusBadRequest)
+			}
+			q.Sort = append(q.Sort, key)
+		}
+	}
+	return q.check()
+}
+
+func (q ListQuery) check() (*ListQuery, *Http.HttpError) {
+	if !q.Paged {
+		return &q, nil
+	}
+	if len(q.Sort) > 0 || q.Limit > 0 {
+		return nil, Http.NewHttpError(fmt.Sprintf("[%s] and [%s] cannot be used with [%s] or [%s]", Common.QuerySort, Common.QueryLimit, Common.QueryPageSize, Common.QueryPageToken), http.StatusBadRequest)
+	}
+	return &q, nil
+}
+
+func queryInt(query url.Values, name string) (int, *Http.HttpError) {
+	valueStr := query.Get(name)
+	if valueStr == "" {
+		return 0, nil
+	}
+	value, ex := strconv.Atoi(valueStr)
+	if ex != nil || value < 0 {
+		return 0, Http.NewHttpError(fmt.Sprintf("invalid %s=[%s], expect non-negative integer", name, valueStr), http.StatusBadRequest)
+	}
+	return value, nil
+}
+
+func parseFilter(schema *SchemaDoc.SchemaDoc, filterStr string) (*DbIface.FilterExpr, *Http.HttpError) {
+	parts := strings.SplitN(filterStr, FilterDiv, 3)
+	if len(parts) != 3 {
+		return nil, Http.NewHttpError(fmt.Sprintf("invalid %s=[%s], expect format={path}%s{op}%s{value}", Common.QueryFilter, filterStr, FilterDiv, FilterDiv), http.StatusBadRequest)
+	}
+	attrPath, op, valueStr := parts[0], parts[1], parts[2]
+	attrType, ex := queryAttrType(schema, attrPath)
+	if ex != nil {
+		return nil, Http.WrapError(ex, fmt.Sprintf("invalid %s=[%s]", Common.QueryFilter, filterStr), http.StatusBadRequest)
+	}
+	filter := &DbIface.FilterExpr{Op: op, Path: attrPath}
+	switch op {
+	case DbIface.FilterIn:
+		for _, itemStr := range strings.Split(valueStr, ListDiv) {
+			value, ex := queryValue(attrType, itemStr)
+			if ex != nil {
+				return nil, Http.WrapError(ex, fmt.Sprintf("invalid %s=[%s]", Common.QueryFilter, filterStr), http.StatusBadRequest)
+			}
+			filter.Values = append(filter.Values, value)
+		}
+		return filter, nil
+	case DbIface.FilterGt, DbIface.FilterGte, DbIface.FilterLt, DbIface.FilterLte:
+		if attrType == JsonKey.Boolean {
+			return nil, Http.NewHttpError(fmt.Sprintf("invalid %s=[%s], op [%s] is not supported on [%s]", Common.QueryFilter, filterStr, op, attrType), http.StatusBadRequest)
+		}
+	case DbIface.FilterEq, DbIface.FilterNe:
+	default:
+		return nil, Http.NewHttpError(fmt.Sprintf("invalid %s=[%s], unknown op [%s]", Common.QueryFilter, filterStr, op), http.StatusBadRequest)
+	}
+	filter.Value, ex = queryValue(attrType, valueStr)
+	if ex != nil {
+		return nil, Http.WrapError(ex, fmt.Sprintf("invalid %s=[%s]", Common.QueryFilter, filterStr), http.StatusBadRequest)
+	}
+	return filter, nil
+}
+
+// type of attribute on path, only attribute of simple type can be filtered or sorted
+func queryAttrType(schema *SchemaDoc.SchemaDoc, attrPath string) (string, error) {
+	attrList := strings.Split(attrPath, DbIface.FilterPathDiv)
+	doc := schema
+	for idx, attrName := range attrList {
+		attrDef, ok := doc.Data[JsonKey.Properties].(map[string]interface{})[attrName].(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("attr=[%s] not defined on path=[%s]", attrName, attrPath)
+		}
+		attrType, _ := attrDef[JsonKey.Type].(string)
+		if idx < len(attrList)-1 {
+			if attrType != JsonKey.Object || SchemaDoc.IsMap(attrDef) || doc.SubDocs[attrName] == nil {
+				return "", fmt.Errorf("attr=[%s] type=[%s] cannot walk in on path=[%s]", attrName, attrType, attrPath)
+			}
+			doc = doc.SubDocs[attrName]
+			continue
+		}
+		switch attrType {
+		case JsonKey.String, JsonKey.Integer, JsonKey.Number, JsonKey.Boolean:
+			return attrType, nil
+		}
+		return "", fmt.Errorf("attr=[%s] type=[%s] is not a simple type on path=[%s]", attrName, attrType, attrPath)
+	}
+	return "", fmt.Errorf("empty path")
+}
+
+func queryValue(attrType string, valueStr string) (interface{}, error) {
+	switch attrType {
+	case JsonKey.Integer:
+		value, err := strconv.ParseInt(valueStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("value=[%s] is not [%s]", valueStr, attrType)
+		}
+		return float64(value), nil
+	case JsonKey.Number:
+		value, err := strconv.ParseFloat(valueStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("value=[%s] is not [%s]", valueStr, attrType)
+		}
+		return value, nil
+	case JsonKey.Boolean:
+		value, err := strconv.ParseBool(valueStr)
+		if err != nil {
+			return nil, fmt.Errorf("value=[%s] is not [%s]", valueStr, attrType)
+		}
+		return value, nil
+	}
+	return valueStr, nil
+}
+
+// Query return ids or records of dataType that match query, and token of next page when query is paged
+func (h *Handler) Query(dataType string, q *ListQuery) ([]interface{}, string, *Http.HttpError) {
+	if dataType != JsonKey.Schema && dataType != "" {
+		_, err := h.LocalData(JsonKey.Schema, dataType)
+		if err != nil {
+			return nil, "", Http.WrapError(err, fmt.Sprintf("object of type “%s” does not exist", dataType), err.Status)
+		}
+	}
+	if dataType == "" {
+		dataType = JsonKey.Schema
+	}
+	var recordList []map[string]interface{}
+	nextToken := ""
+	switch {
+	case len(q.Sort) > 0:
+		allList, err := h.QueryDb(dataType, "", q.Filter)
+		if err != nil {
+			return nil, "", err
+		}
+		recordList = liveRecords(allList)
+		DbIface.SortRecords(recordList, q.Sort)
+	case q.Limit > 0:
+		// page could be shorter than limit when it has tombstones
+		pageToken := ""
+		for {
+			pageList, token, err := h.QueryDbPage(dataType, q.Filter, q.Limit, pageToken)
+			if err != nil {
+				return nil, "", err
+			}
+			recordList = append(recordList, liveRecords(pageList)...)
+			if token == "" || len(recordList) >= q.Limit {
+				break
+			}
+			pageToken = token
+		}
+	default:
+		pageList, token, err := h.QueryDbPage(dataType, q.Filter, q.PageSize, q.PageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		recordList = liveRecords(pageList)
+		nextToken = token
+	}
+	if q.Limit > 0 && len(recordList) > q.Limit {
+		recordList = recordList[:q.Limit]
+	}
+	result := make([]interface{}, 0, len(recordList))
+	for _, record := range recordList {
+		if q.Records {
+			result = append(result, record)
+			continue
+		}
+		result = append(result, record[Record.DataId].(string))
+	}
+	return result, nextToken, nil
+}
+
+// records without tombstones, not record schema is only for schema.
+func liveRecords(recordList []map[string]interface{}) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(recordList))
+	for _, record := range recordList {
+		if IsDeleted(record) || record[Record.DataId] == Record.KeyRecord {
+			continue
+		}
+		result = append(result, record)
+	}
+	return result
+}
@@ -0,0 +1,215 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataHandler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"Data/DbIface"
+	"DataService/Common"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Schema/SchemaDoc"
+	"github.com/salesforce/UniTAO/lib/Util"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+	"github.com/salesforce/UniTAO/lib/Util/Json"
+)
+
+// IsDeleted return true when data is tombstone of soft deleted record
+func IsDeleted(data map[string]interface{}) bool {
+	deleted, ok := data[Record.Deleted].(string)
+	return ok && deleted != ""
+}
+
+func validateSoftDeleteOnSchema(record *Record.Record) *Http.HttpError {
+	schemaData, _ := Json.CopyToMap(record.Data)
+	schema, ex := SchemaDoc.New(schemaData)
+	if ex != nil {
+		return Http.WrapError(ex, fmt.Sprintf("failed to load schema: [%s]", record.Id), http.StatusBadRequest)
+	}
+	_, _, ex = schema.SoftDelete()
+	if ex != nil {
+		return Http.WrapError(ex, fmt.Sprintf("invalid schema: [%s]", record.Id), http.StatusBadRequest)
+	}
+	return nil
+}
+
+// softDelete return soft delete setting of current schema of dataType
+func (h *Handler) softDelete(dataType string) (bool, time.Duration, *Http.HttpError) {
+	if dataType == JsonKey.Schema {
+		return false, 0, nil
+	}
+	if _, ok := Common.InternalTypes[dataType]; ok {
+		return false, 0, nil
+	}
+	schema, err := h.LocalSchema(dataType, "")
+	if err != nil {
+		return false, 0, err
+	}
+	softDelete, retention, ex := schema.Schema.SoftDelete()
+	if ex != nil {
+		return false, 0, Http.WrapError(ex, fmt.Sprintf("failed to get soft delete setting of type [%s]", dataType), http.StatusInternalServerError)
+	}
+	return softDelete, retention, nil
+}
+
+// mark record as deleted, caller holds lock of the record
+func (h *Handler) tombstone(before *Record.Record) *Http.HttpError {
+	after := Record.Record{}
+	ex := Json.CopyTo(before, &after)
+	if ex != nil {
+		return Http.WrapError(ex, fmt.Sprintf("failed to snapshot record [%s/%s]", before.Type, before.Id), http.StatusInternalServerError)
+	}
+	after.Revision = before.Revision + 1
+	after.Deleted = time.Now().UTC().Format(time.RFC3339)
+	batch := DbIface.NewBatch().Replace(h.Config.DataTable.Data, map[string]interface{}{
+		Record.DataType: before.Type,
+		Record.DataId:   before.Id,
+		Record.Revision: before.Revision,
+	}, after.Map())
+	err := h.commit(batch, before.Type, before.Id, before.Map(), after.Map())
+	if err != nil {
+		return Http.WrapError(err, fmt.Sprintf("failed to soft delete record [type/id]=[%s/%s]", before.Type, before.Id), err.Status)
+	}
+	return nil
+}
+
+// Undelete restore soft deleted record, references of the record are validated again
+func (h *Handler) Undelete(dataType string, dataId string) (*Record.Record, *Http.HttpError) {
+	_, err := h.LocalSchema(dataType, "")
+	if err != nil {
+		return nil, err
+	}
+	idKey := fmt.Sprintf("%s/%s", dataType, dataId)
+	h.Lock.Aquire(idKey, "HandlerUndelete")
+	defer h.Lock.Release(idKey, "HandlerUndelete")
+	data, err := h.localRecord(dataType, dataId)
+	if err != nil {
+		return nil, err
+	}
+	record, ex := Record.LoadMap(data)
+	if ex != nil {
+		return nil, Http.WrapError(ex, fmt.Sprintf("failed to load data as record.[type/id]=[%s/%s]", dataType, dataId), http.StatusInternalServerError)
+	}
+	if record.Deleted == "" {
+		return nil, Http.NewHttpError(fmt.Sprintf("data [type/id]=[%s/%s] is not deleted", dataType, dataId), http.StatusNotModified)
+	}
+	before := record.Map()
+	record.Deleted = ""
+	batch := DbIface.NewBatch()
+	err = h.updateRecord(batch, dataType, dataId, record.Revision, record)
+	if err != nil {
+		return nil, err
+	}
+	err = h.commit(batch, dataType, dataId, before, record.Map())
+	if err != nil {
+		return nil, Http.WrapError(err, fmt.Sprintf("failed to undelete record [type/id]=[%s/%s]", dataType, dataId), err.Status)
+	}
+	return record, nil
+}
+
+// Purge remove tombstones that are kept longer than retention of their type, return number of records purged
+func (h *Handler) Purge(now time.Time) (int, *Http.HttpError) {
+	schemaList, err := h.QueryDb(JsonKey.Schema, "", nil)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, schemaData := range schemaList {
+		dataType, _ := schemaData[Record.DataId].(string)
+		if _, ver := Util.ParseCustomPath(dataType, JsonKey.ArchivedSchemaIdDiv); ver != "" {
+			continue
+		}
+		softDelete, retention, err := h.softDelete(dataType)
+		if err != nil {
+			h.Log(fmt.Sprintf("Purge: skip type [%s], Error:%s", dataType, err))
+			continue
+		}
+		if !softDelete || retention == 0 {
+			continue
+		}
+		recordList, err := h.QueryDb(dataType, "", nil)
+		if err != nil {
+			return purged, err
+		}
+		for _, data := range recordList {
+			if !IsDeleted(data) {
+				continue
+			}
+			deleted, ex := time.Parse(time.RFC3339, data[Record.Deleted].(string))
+			if ex != nil {
+				h.Log(fmt.Sprintf("Purge: invalid [%s]=[%s] on [%s/%s]", Record.Deleted, data[Record.Deleted], dataType, data[Record.DataId]))
+				continue
+			}
+			if now.Sub(deleted) < retention {
+				continue
+			}
+			removed, err := h.purgeRecord(dataType, data[Record.DataId].(string))
+			if err != nil {
+				return purged, err
+			}
+			if removed {
+				purged++
+			}
+		}
+	}
+	return purged, nil
+}
+
+// remove tombstone of [dataType/dataId], skip if it is undeleted meanwhile
+func (h *Handler) purgeRecord(dataType string, dataId string) (bool, *Http.HttpError) {
+	idKey := fmt.Sprintf("%s/%s", dataType, dataId)
+	h.Lock.Aquire(idKey, "HandlerPurge")
+	defer h.Lock.Release(idKey, "HandlerPurge")
+	data, err := h.localRecord(dataType, dataId)
+	if err != nil {
+		if err.Status == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	if !IsDeleted(data) {
+		return false, nil
+	}
+	record, ex := Record.LoadMap(data)
+	if ex != nil {
+		return false, Http.WrapError(ex, fmt.Sprintf("failed to load data as record.[type/id]=[%s/%s]", dataType, dataId), http.StatusInternalServerError)
+	}
+	batch := DbIface.NewBatch().Delete(h.Config.DataTable.Data, map[string]interface{}{
+		Record.DataType: dataType,
+		Record.DataId:   dataId,
+		Record.Revision: record.Revision,
+	})
+	err = h.commit(batch, dataType, dataId, record.Map(), nil)
+	if err != nil {
+		return false, Http.WrapError(err, fmt.Sprintf("failed to purge record [type/id]=[%s/%s]", dataType, dataId), err.Status)
+	}
+	h.Log(fmt.Sprintf("Purge: record [%s/%s] deleted at [%s] purged", dataType, dataId, record.Deleted))
+	return true, nil
+}
@@ -0,0 +1,155 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataHandler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"DataService/Common"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Schema/SchemaDoc"
+	"github.com/salesforce/UniTAO/lib/Util"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+	"github.com/salesforce/UniTAO/lib/Util/Json"
+)
+
+func validateTtlOnSchema(record *Record.Record) *Http.HttpError {
+	schemaData, _ := Json.CopyToMap(record.Data)
+	schema, ex := SchemaDoc.New(schemaData)
+	if ex != nil {
+		return Http.WrapError(ex, fmt.Sprintf("failed to load schema: [%s]", record.Id), http.StatusBadRequest)
+	}
+	_, _, ex = schema.Ttl()
+	if ex != nil {
+		return Http.WrapError(ex, fmt.Sprintf("invalid schema: [%s]", record.Id), http.StatusBadRequest)
+	}
+	return nil
+}
+
+// ttl return expiry attribute and default time to live of current schema of dataType
+func (h *Handler) ttl(dataType string) (string, time.Duration, *Http.HttpError) {
+	if dataType == JsonKey.Schema {
+		return "", 0, nil
+	}
+	if _, ok := Common.InternalTypes[dataType]; ok {
+		return "", 0, nil
+	}
+	schema, err := h.LocalSchema(dataType, "")
+	if err != nil {
+		return "", 0, err
+	}
+	attr, ttl, ex := schema.Schema.Ttl()
+	if ex != nil {
+		return "", 0, Http.WrapError(ex, fmt.Sprintf("failed to get ttl setting of type [%s]", dataType), http.StatusInternalServerError)
+	}
+	return attr, ttl, nil
+}
+
+// setExpire stamp expiry time of record before it is saved.
+// expiry attribute in data take priority, otherwise default ttl is counted from now, so each update renew the record
+func (h *Handler) setExpire(record *Record.Record, now time.Time) *Http.HttpError {
+	record.Expire = ""
+	attr, ttl, err := h.ttl(record.Type)
+	if err != nil {
+		return err
+	}
+	if attr != "" {
+		if value, ok := record.Data[attr].(string); ok && value != "" {
+			expire, ex := time.Parse(time.RFC3339, value)
+			if ex != nil {
+				return Http.WrapError(ex, fmt.Sprintf("invalid expiry time [%s]=[%s] on [%s/%s], expect RFC3339 time", attr, value, record.Type, record.Id), http.StatusBadRequest)
+			}
+			record.Expire = expire.UTC().Format(time.RFC3339)
+			return nil
+		}
+	}
+	if ttl > 0 {
+		record.Expire = now.Add(ttl).UTC().Format(time.RFC3339)
+	}
+	return nil
+}
+
+// Expire delete records whose expiry time passed, return number of records deleted.
+// records are deleted same as Delete, so they are kept as tombstone when type has soft delete
+func (h *Handler) Expire(now time.Time) (int, *Http.HttpError) {
+	schemaList, err := h.QueryDb(JsonKey.Schema, "", nil)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, schemaData := range schemaList {
+		dataType, _ := schemaData[Record.DataId].(string)
+		if _, ver := Util.ParseCustomPath(dataType, JsonKey.ArchivedSchemaIdDiv); ver != "" {
+			continue
+		}
+		attr, ttl, err := h.ttl(dataType)
+		if err != nil {
+			h.Log(fmt.Sprintf("Expire: skip type [%s], Error:%s", dataType, err))
+			continue
+		}
+		if attr == "" && ttl == 0 {
+			continue
+		}
+		recordList, err := h.QueryDb(dataType, "", nil)
+		if err != nil {
+			return expired, err
+		}
+		for _, data := range recordList {
+			if IsDeleted(data) || !isExpired(data[Record.Expire], now) {
+				continue
+			}
+			dataId := data[Record.DataId].(string)
+			deleted, err := h.deleteRecord(dataType, dataId, func(record *Record.Record) bool {
+				// record may be renewed after it is listed
+				return isExpired(record.Expire, now)
+			})
+			if err != nil {
+				return expired, err
+			}
+			if deleted {
+				h.Log(fmt.Sprintf("Expire: record [%s/%s] expired", dataType, dataId))
+				expired++
+			}
+		}
+	}
+	return expired, nil
+}
+
+func isExpired(value interface{}, now time.Time) bool {
+	expireStr, ok := value.(string)
+	if !ok || expireStr == "" {
+		return false
+	}
+	expire, ex := time.Parse(time.RFC3339, expireStr)
+	if ex != nil {
+		return false
+	}
+	return !now.Before(expire)
+}
@@ -247,6 +247,11 @@ func (s *CmtIndexChanges) setIndex(dataType string, version string, dataPath str
 	} else {
 		err = s.Data.Inventory.Patch(dataType, dataId, nextPath, headers, idxId)
 	}
+	// PATCH on version other than the record is StatusPreconditionFailed
+	if err != nil && err.Status == http.StatusPreconditionFailed {
+		s.Log(fmt.Sprintf("SetIdx[%s/%s]: record is not on version [%s], skip", dataType, dataId, version))
+		return nil
+	}
 	if err != nil && err.Status != http.StatusNotModified {
 		s.Log(fmt.Sprintf("SetIdx[%s/%s]: failed to patch [%s] with id=[%s],\nError:%s", dataType, dataId, nextPath, idxId, err))
 		return err
@@ -0,0 +1,421 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+// process module for outbound webhook on journal entries
+package Process
+
+import (
+	"DataService/Common"
+	"DataService/DataHandler"
+	"DataService/DataJournal/ProcessIface"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/SchemaPath"
+	SchemaPathData "github.com/salesforce/UniTAO/lib/SchemaPath/Data"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+	"github.com/salesforce/UniTAO/lib/Util/Json"
+)
+
+const (
+	WebhookVer           = "0.0.1"
+	WebhookMaxAttempts   = 8
+	WebhookRetryInterval = time.Second
+	WebhookMaxInterval   = 5 * time.Minute
+	WebhookTimeout       = 30 * time.Second
+	SignaturePrefix      = "sha256="
+	// value of secret in webhook on read, set it back to keep current secret
+	SecretRedacted = "******"
+)
+
+// subscription record of type [webhook]
+type Webhook struct {
+	Name     string `json:"name"`
+	Url      string `json:"url"`
+	DataType string `json:"dataType"`
+	Path     string `json:"path"`
+	Secret   string `json:"secret"`
+}
+
+type WebhookPayload struct {
+	Webhook  string                 `json:"webhook"`
+	DataType string                 `json:"dataType"`
+	DataId   string                 `json:"dataId"`
+	Page     int                    `json:"page"`
+	Idx      int                    `json:"idx"`
+	Time     string                 `json:"time"`
+	Before   map[string]interface{} `json:"before"`
+	After    map[string]interface{} `json:"after"`
+	ProcessIface.ChangeMeta
+}
+
+// WebhookChanges only save deliveries to outbox in ProcessEntry,
+// so an unreachable target never hold the journal of the record for other processes
+type WebhookChanges struct {
+	Data          *DataHandler.Handler
+	Client        *http.Client
+	MaxAttempts   int
+	RetryInterval time.Duration
+	MaxInterval   time.Duration
+	log           *log.Logger
+	lock          sync.Mutex
+	wakes         map[string]chan bool
+	// webhooks of table by name, updated by SetWebhook and DeleteWebhook
+	hooks  map[string]*Webhook
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// webhook processes by table, so change of webhooks is applied to the index of processes on the table
+var processLock sync.Mutex
+var processMap = map[string]map[*WebhookChanges]bool{}
+
+// NewWebhookProcess resume deliveries left in outbox
+func NewWebhookProcess(data *DataHandler.Handler, logger *log.Logger) (ProcessIface.JournalProcess, error) {
+	if data == nil {
+		return nil, fmt.Errorf("dataHander cannot be nil")
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	process := WebhookChanges{
+		Data:          data,
+		Client:        &http.Client{Timeout: WebhookTimeout},
+		MaxAttempts:   WebhookMaxAttempts,
+		RetryInterval: WebhookRetryInterval,
+		MaxInterval:   WebhookMaxInterval,
+		log:           logger,
+		wakes:         map[string]chan bool{},
+		hooks:         map[string]*Webhook{},
+	}
+	process.ctx, process.cancel = context.WithCancel(context.Background())
+	err := process.loadWebhooks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhooks, Error:%s", err)
+	}
+	table := data.Config.DataTable.Data
+	processLock.Lock()
+	if _, ok := processMap[table]; !ok {
+		processMap[table] = map[*WebhookChanges]bool{}
+	}
+	processMap[table][&process] = true
+	processLock.Unlock()
+	err = process.resume()
+	if err != nil {
+		process.Stop()
+		return nil, fmt.Errorf("failed to resume webhook deliveries, Error:%s", err)
+	}
+	return &process, nil
+}
+
+// SignWebhookPayload return value of header X-UniTAO-Signature, HMAC-SHA256 of body with secret in hex
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *WebhookChanges) Name() string {
+	return "webhook process"
+}
+
+func (w *WebhookChanges) Log(message string) {
+	w.log.Printf("%s: %s", w.Name(), message)
+}
+
+func (w *WebhookChanges) HandleType(dataType string, version string) (bool, error) {
+	if _, ok := Common.InternalTypes[dataType]; ok {
+		return false, nil
+	}
+	return len(w.listWebhooks(dataType)) > 0, nil
+}
+
+// ProcessEntry save a delivery of entry for each matched webhook, entry is processed again when save failed
+func (w *WebhookChanges) ProcessEntry(dataType string, dataId string, entry *ProcessIface.JournalEntry) *Http.HttpError {
+	entryId := fmt.Sprintf("%s/%s/%d-%d", dataType, dataId, entry.Page, entry.Idx)
+	hookList := w.listWebhooks(dataType)
+	deliveryList := []*webhookDelivery{}
+	for _, hook := range hookList {
+		if hook.Path != "" && !w.pathChanged(dataType, dataId, hook.Path, entry) {
+			continue
+		}
+		payload := WebhookPayload{
+			Webhook:    hook.Name,
+			DataType:   dataType,
+			DataId:     dataId,
+			Page:       entry.Page,
+			Idx:        entry.Idx,
+			Time:       entry.Time,
+			Before:     entry.Before,
+			After:      entry.After,
+			ChangeMeta: entry.ChangeMeta,
+		}
+		body, ex := json.Marshal(payload)
+		if ex != nil {
+			w.Log(fmt.Sprintf("failed to marshal payload of entry [%s] for webhook [%s], Error:%s", entryId, hook.Name, ex))
+			continue
+		}
+		deliveryList = append(deliveryList, &webhookDelivery{
+			Webhook:  hook.Name,
+			DataType: dataType,
+			DataId:   dataId,
+			Page:     entry.Page,
+			Idx:      entry.Idx,
+			Seq:      entry.Seq,
+			Payload:  string(body),
+		})
+	}
+	if len(deliveryList) == 0 {
+		return Http.NewHttpError(fmt.Sprintf("no webhook delivery queued for entry [%s]", entryId), http.StatusNotModified)
+	}
+	err := w.saveDeliveries(deliveryList)
+	if err != nil {
+		return Http.WrapError(err, fmt.Sprintf("failed to save deliveries of entry [%s]", entryId), err.Status)
+	}
+	for _, delivery := range deliveryList {
+		w.wake(delivery.Webhook)
+	}
+	return nil
+}
+
+// Stop dispatchers of webhooks and wait for them to exit, deliveries not done are left in outbox for next run
+func (w *WebhookChanges) Stop() {
+	processLock.Lock()
+	delete(processMap[w.Data.Config.DataTable.Data], w)
+	processLock.Unlock()
+	w.lock.Lock()
+	w.cancel()
+	w.wakes = map[string]chan bool{}
+	w.lock.Unlock()
+	w.wg.Wait()
+}
+
+func (w *WebhookChanges) loadWebhooks() *Http.HttpError {
+	recordList, err := w.Data.QueryDb(Common.KeyWebhook, "", nil)
+	if err != nil {
+		return err
+	}
+	for _, data := range recordList {
+		hook, ex := loadWebhook(data)
+		if ex != nil {
+			w.Log(ex.Error())
+			continue
+		}
+		w.hooks[hook.Name] = hook
+	}
+	return nil
+}
+
+func (w *WebhookChanges) listWebhooks(dataType string) []Webhook {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	hookList := []Webhook{}
+	for _, hook := range w.hooks {
+		if hook.DataType != "" && hook.DataType != dataType {
+			continue
+		}
+		hookList = append(hookList, *hook)
+	}
+	return hookList
+}
+
+func (w *WebhookChanges) getWebhook(name string) *Webhook {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.hooks[name]
+}
+
+// updateWebhook set webhook in index, nil hook remove it and close its dispatcher
+func (w *WebhookChanges) updateWebhook(name string, hook *Webhook) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if hook != nil {
+		w.hooks[name] = hook
+		return
+	}
+	delete(w.hooks, name)
+	if wake, ok := w.wakes[name]; ok {
+		close(wake)
+		delete(w.wakes, name)
+	}
+}
+
+// notifyWebhook apply change of webhook to processes on table of data
+func notifyWebhook(data *DataHandler.Handler, name string, hook *Webhook) {
+	processLock.Lock()
+	defer processLock.Unlock()
+	for process := range processMap[data.Config.DataTable.Data] {
+		process.updateWebhook(name, hook)
+	}
+}
+
+// pathChanged return true when change of record is on targets of SchemaPath from the record, resolved on before and after.
+// targets of records behind references are only changed by entries of those records, deliver to webhook of their types
+func (w *WebhookChanges) pathChanged(dataType string, dataId string, path string, entry *ProcessIface.JournalEntry) bool {
+	targets := []*SchemaPath.Target{}
+	for _, data := range []map[string]interface{}{entry.Before, entry.After} {
+		if data == nil {
+			continue
+		}
+		conn := SchemaPathData.Connection{
+			FuncRecord: func(recordType string, recordId string) (*Record.Record, *Http.HttpError) {
+				if recordType == dataType && recordId == dataId {
+					record, ex := Record.LoadMap(data)
+					if ex != nil {
+						return nil, Http.WrapError(ex, fmt.Sprintf("failed to load [%s/%s] of entry", dataType, dataId), http.StatusInternalServerError)
+					}
+					return record, nil
+				}
+				return w.Data.Inventory.Get(recordType, recordId)
+			},
+		}
+		pathTargets, err := SchemaPath.Targets(&conn, fmt.Sprintf("%s/%s/%s", dataType, dataId, path))
+		if err != nil {
+			// path does not exist on one side of the change
+			continue
+		}
+		targets = append(targets, pathTargets...)
+	}
+	return SchemaPath.MatchTargets(targets, dataType, dataId, entry.Before, entry.After)
+}
+
+func loadWebhook(data map[string]interface{}) (*Webhook, error) {
+	record, ex := Record.LoadMap(data)
+	if ex != nil {
+		return nil, fmt.Errorf("invalid webhook record, Error:%s", ex)
+	}
+	hook := Webhook{}
+	ex = Json.CopyTo(record.Data, &hook)
+	if ex != nil || hook.Url == "" {
+		return nil, fmt.Errorf("invalid webhook [%s], skip", record.Id)
+	}
+	if hook.Name == "" {
+		hook.Name = record.Id
+	}
+	return &hook, nil
+}
+
+// ListWebhooks return names of webhooks in table of data handler
+func ListWebhooks(data *DataHandler.Handler) ([]string, *Http.HttpError) {
+	recordList, err := data.QueryDb(Common.KeyWebhook, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	nameList := make([]string, 0, len(recordList))
+	for _, record := range recordList {
+		if name, ok := record[Record.DataId].(string); ok {
+			nameList = append(nameList, name)
+		}
+	}
+	return nameList, nil
+}
+
+// GetWebhook return webhook record with secret redacted
+func GetWebhook(data *DataHandler.Handler, name string) (*Record.Record, *Http.HttpError) {
+	record, err := getWebhookRecord(data, name)
+	if err != nil {
+		return nil, err
+	}
+	if secret, ok := record.Data["secret"].(string); ok && secret != "" {
+		record.Data["secret"] = SecretRedacted
+	}
+	return record, nil
+}
+
+func getWebhookRecord(data *DataHandler.Handler, name string) (*Record.Record, *Http.HttpError) {
+	recordList, err := data.QueryDb(Common.KeyWebhook, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(recordList) == 0 {
+		return nil, Http.NewHttpError(fmt.Sprintf("%s [%s] does not exists", Common.KeyWebhook, name), http.StatusNotFound)
+	}
+	record, ex := Record.LoadMap(recordList[0])
+	if ex != nil {
+		return nil, Http.WrapError(ex, fmt.Sprintf("invalid record of %s [%s]", Common.KeyWebhook, name), http.StatusInternalServerError)
+	}
+	return record, nil
+}
+
+// SetWebhook create or replace webhook with name, secret of SecretRedacted keep the current secret.
+// webhooks are saved without journal, so secret is not in journal entries and change events
+func SetWebhook(data *DataHandler.Handler, name string, hook map[string]interface{}) (*Record.Record, *Http.HttpError) {
+	if name == "" {
+		return nil, Http.NewHttpError(fmt.Sprintf("invalid %s name [%s]", Common.KeyWebhook, name), http.StatusBadRequest)
+	}
+	hook["name"] = name
+	if hook["secret"] == SecretRedacted {
+		current, err := getWebhookRecord(data, name)
+		if err != nil {
+			return nil, Http.WrapError(err, fmt.Sprintf("no secret of %s [%s] to keep", Common.KeyWebhook, name), http.StatusBadRequest)
+		}
+		hook["secret"] = current.Data["secret"]
+	}
+	record := Record.NewRecord(Common.KeyWebhook, WebhookVer, name, hook)
+	err := data.Validate(record)
+	if err != nil {
+		return nil, err
+	}
+	saved, ex := loadWebhook(record.Map())
+	if ex != nil {
+		return nil, Http.WrapError(ex, fmt.Sprintf("invalid %s [%s]", Common.KeyWebhook, name), http.StatusBadRequest)
+	}
+	ex = data.DB.Replace(data.Config.DataTable.Data, map[string]interface{}{
+		Record.DataType: Common.KeyWebhook,
+		Record.DataId:   name,
+	}, record.Map())
+	if ex != nil {
+		return nil, Http.WrapError(ex, fmt.Sprintf("failed to save %s [%s]", Common.KeyWebhook, name), http.StatusInternalServerError)
+	}
+	notifyWebhook(data, name, saved)
+	return record, nil
+}
+
+// DeleteWebhook remove webhook, its deliveries left in outbox are dropped by the process
+func DeleteWebhook(data *DataHandler.Handler, name string) *Http.HttpError {
+	_, err := getWebhookRecord(data, name)
+	if err != nil {
+		return err
+	}
+	ex := data.DB.Delete(data.Config.DataTable.Data, map[string]interface{}{
+		Record.DataType: Common.KeyWebhook,
+		Record.DataId:   name,
+	})
+	if ex != nil {
+		return Http.WrapError(ex, fmt.Sprintf("failed to delete %s [%s]", Common.KeyWebhook, name), http.StatusInternalServerError)
+	}
+	notifyWebhook(data, name, nil)
+	return nil
+}
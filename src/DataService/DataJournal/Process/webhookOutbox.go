@@ -0,0 +1,293 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+// outbox of webhook deliveries, a delivery is only removed after target accepted or rejected it
+package Process
+
+import (
+	"Data/DbIface"
+	"DataService/Common"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+	"github.com/salesforce/UniTAO/lib/Util/Json"
+)
+
+const (
+	// attribute of delivery record to query deliveries of webhook
+	keyDeliveryWebhook = "webhook"
+)
+
+const (
+	deliveryDelivered = iota
+	deliveryRejected
+	deliveryFailed
+)
+
+// record of type [webhookDelivery]
+type webhookDelivery struct {
+	Webhook  string `json:"webhook"`
+	DataType string `json:"dataType"`
+	DataId   string `json:"dataId"`
+	Page     int    `json:"page"`
+	Idx      int    `json:"idx"`
+	Seq      uint64 `json:"seq,omitempty"`
+	Payload  string `json:"payload"`
+}
+
+func (d *webhookDelivery) Id() string {
+	return fmt.Sprintf("webhook:%s_dataType:%s_dataId:%s_page:%d_idx:%d", d.Webhook, d.DataType, d.DataId, d.Page, d.Idx)
+}
+
+// EntryId is value of header X-UniTAO-Delivery
+func (d *webhookDelivery) EntryId() string {
+	return fmt.Sprintf("%s/%s/%d-%d", d.DataType, d.DataId, d.Page, d.Idx)
+}
+
+func (w *WebhookChanges) saveDeliveries(deliveryList []*webhookDelivery) *Http.HttpError {
+	table := w.Data.Config.DataTable.Data
+	batch := DbIface.NewBatch()
+	for _, delivery := range deliveryList {
+		data, ex := Json.CopyToMap(delivery)
+		if ex != nil {
+			return Http.WrapError(ex, fmt.Sprintf("failed to convert delivery [%s] to map", delivery.Id()), http.StatusInternalServerError)
+		}
+		record := Record.NewRecord(Common.KeyWebhookDelivery, WebhookVer, delivery.Id(), data)
+		// replace so same entry processed again is delivered once
+		batch.Replace(table, map[string]interface{}{
+			Record.DataType: Common.KeyWebhookDelivery,
+			Record.DataId:   delivery.Id(),
+		}, record.Map())
+	}
+	ex := w.Data.DB.Commit(batch)
+	if ex != nil {
+		return Http.WrapError(ex, "failed to commit webhook deliveries", Common.CommitStatus(ex))
+	}
+	return nil
+}
+
+// pendingDeliveries of webhook in order of journal entries, all webhooks when name is empty
+func (w *WebhookChanges) pendingDeliveries(name string) ([]*webhookDelivery, *Http.HttpError) {
+	var filter *DbIface.FilterExpr
+	if name != "" {
+		filter = DbIface.Eq(keyDeliveryWebhook, name)
+	}
+	recordList, err := w.Data.QueryDb(Common.KeyWebhookDelivery, "", filter)
+	if err != nil {
+		return nil, err
+	}
+	deliveryList := []*webhookDelivery{}
+	for _, data := range recordList {
+		record, ex := Record.LoadMap(data)
+		if ex != nil {
+			w.Log(fmt.Sprintf("invalid delivery record, Error:%s", ex))
+			continue
+		}
+		delivery := webhookDelivery{}
+		ex = Json.CopyTo(record.Data, &delivery)
+		if ex != nil {
+			w.Log(fmt.Sprintf("invalid delivery [%s], Error:%s", record.Id, ex))
+			continue
+		}
+		deliveryList = append(deliveryList, &delivery)
+	}
+	sort.Slice(deliveryList, func(i, j int) bool {
+		a, b := deliveryList[i], deliveryList[j]
+		if a.Seq != b.Seq {
+			return a.Seq < b.Seq
+		}
+		if a.DataType != b.DataType || a.DataId != b.DataId {
+			return a.EntryId() < b.EntryId()
+		}
+		if a.Page != b.Page {
+			return a.Page < b.Page
+		}
+		return a.Idx < b.Idx
+	})
+	return deliveryList, nil
+}
+
+func (w *WebhookChanges) removeDelivery(delivery *webhookDelivery) *Http.HttpError {
+	ex := w.Data.DB.Delete(w.Data.Config.DataTable.Data, map[string]interface{}{
+		Record.DataType: Common.KeyWebhookDelivery,
+		Record.DataId:   delivery.Id(),
+	})
+	if ex != nil {
+		return Http.WrapError(ex, fmt.Sprintf("failed to remove delivery [%s]", delivery.Id()), http.StatusInternalServerError)
+	}
+	return nil
+}
+
+// resume deliveries left in outbox by last run
+func (w *WebhookChanges) resume() *Http.HttpError {
+	deliveryList, err := w.pendingDeliveries("")
+	if err != nil {
+		return err
+	}
+	for _, delivery := range deliveryList {
+		w.wake(delivery.Webhook)
+	}
+	return nil
+}
+
+// wake dispatcher of the webhook, each webhook deliver in order of entries on its own go routine
+func (w *WebhookChanges) wake(name string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.ctx.Err() != nil {
+		// process is stopped, deliveries are resumed by next run
+		return
+	}
+	wake, ok := w.wakes[name]
+	if !ok {
+		wake = make(chan bool, 1)
+		w.wakes[name] = wake
+		w.wg.Add(1)
+		go w.dispatch(name, wake)
+	}
+	select {
+	case wake <- true:
+	default:
+		// dispatcher is already woken, it reads outbox for all deliveries
+	}
+}
+
+// dispatch deliveries in outbox of webhook when woken, deliveries failed after MaxAttempts are tried again after MaxInterval.
+// dispatcher exit when process is stopped, or when wake is closed on delete of the webhook
+func (w *WebhookChanges) dispatch(name string, wake chan bool) {
+	defer w.wg.Done()
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case _, ok := <-wake:
+			if !ok {
+				// webhook is deleted, drop its deliveries left in outbox
+				w.deliverPending(name)
+				return
+			}
+		case <-retry:
+		}
+		retry = nil
+		if w.deliverPending(name) {
+			w.Log(fmt.Sprintf("deliveries of webhook [%s] are pending, retry in %s", name, w.MaxInterval))
+			retry = time.After(w.MaxInterval)
+		}
+	}
+}
+
+// deliverPending return true when deliveries are left in outbox to try later
+func (w *WebhookChanges) deliverPending(name string) bool {
+	for {
+		deliveryList, err := w.pendingDeliveries(name)
+		if err != nil {
+			w.Log(fmt.Sprintf("failed to load deliveries of webhook [%s], Error:%s", name, err))
+			return true
+		}
+		if len(deliveryList) == 0 {
+			return false
+		}
+		hook := w.getWebhook(name)
+		for _, delivery := range deliveryList {
+			if w.ctx.Err() != nil {
+				return true
+			}
+			if hook == nil {
+				w.Log(fmt.Sprintf("webhook [%s] no longer exists, drop entry [%s]", name, delivery.EntryId()))
+			} else if w.deliver(hook, delivery) == deliveryFailed {
+				return true
+			}
+			err = w.removeDelivery(delivery)
+			if err != nil {
+				w.Log(err.Error())
+				return true
+			}
+		}
+	}
+}
+
+// deliver retry with exponential backoff on network error, 408, 429 and 5xx
+func (w *WebhookChanges) deliver(hook *Webhook, delivery *webhookDelivery) int {
+	interval := w.RetryInterval
+	for attempt := 1; ; attempt++ {
+		err := w.post(hook, delivery)
+		if err == nil {
+			w.Log(fmt.Sprintf("entry [%s] delivered to webhook [%s]", delivery.EntryId(), hook.Name))
+			return deliveryDelivered
+		}
+		if !retryable(err.Status) {
+			w.Log(fmt.Sprintf("entry [%s] rejected by webhook [%s], Error:%s", delivery.EntryId(), hook.Name, err))
+			return deliveryRejected
+		}
+		if attempt >= w.MaxAttempts {
+			w.Log(fmt.Sprintf("entry [%s] to webhook [%s] failed after %d attempts, kept in outbox. Error:%s", delivery.EntryId(), hook.Name, attempt, err))
+			return deliveryFailed
+		}
+		w.Log(fmt.Sprintf("attempt %d of entry [%s] to webhook [%s] failed, retry in %s", attempt, delivery.EntryId(), hook.Name, interval))
+		select {
+		case <-w.ctx.Done():
+			return deliveryFailed
+		case <-time.After(interval):
+		}
+		interval *= 2
+		if interval > w.MaxInterval {
+			interval = w.MaxInterval
+		}
+	}
+}
+
+func (w *WebhookChanges) post(hook *Webhook, delivery *webhookDelivery) *Http.HttpError {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return Http.WrapError(err, fmt.Sprintf("invalid url [%s] of webhook [%s]", hook.Url, hook.Name), http.StatusBadRequest)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(Common.HeaderWebhookDelivery, delivery.EntryId())
+	if hook.Secret != "" {
+		req.Header.Set(Common.HeaderWebhookSignature, SignWebhookPayload(hook.Secret, body))
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return Http.WrapError(err, fmt.Sprintf("failed to post to webhook [%s]", hook.Name), http.StatusBadGateway)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return Http.NewHttpError(fmt.Sprintf("webhook [%s] responded with status [%d]", hook.Name, resp.StatusCode), resp.StatusCode)
+	}
+	return nil
+}
+
+func retryable(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
@@ -39,20 +39,35 @@ const (
 	KeyDataId   = "dataId"
 	KeyDataType = "dataType"
 	KeyPage     = "page"
+	KeyMaxSeq   = "maxSeq"
 )
 
+// ChangeMeta who made a change, why and in which request
+type ChangeMeta struct {
+	Actor     string `json:"actor,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	RequestId string `json:"requestId,omitempty"`
+}
+
+// JournalEntry is a change of record, Seq is order of the change in all journal of the table
 type JournalEntry struct {
 	Page   int                    `json:"page"`
 	Idx    int                    `json:"idx"`
+	Seq    uint64                 `json:"seq,omitempty"`
 	Time   string                 `json:"time"`
 	Before map[string]interface{} `json:"before"`
 	After  map[string]interface{} `json:"after"`
+	ChangeMeta
 }
 
+// JournalPage of record, PrevSeq is Seq of last entry in previous page,
+// MaxSeq is Seq of last entry added to page, to query pages with entries after a seq
 type JournalPage struct {
 	DataType string          `json:"dataType"`
 	DataId   string          `json:"dataId"`
 	Idx      int             `json:"idx"`
+	PrevSeq  uint64          `json:"prevSeq,omitempty"`
+	MaxSeq   uint64          `json:"maxSeq,omitempty"`
 	Active   []*JournalEntry `json:"active"`
 	Archived []*JournalEntry `json:"archived"`
 }
@@ -88,6 +103,49 @@ func (page *JournalPage) LoadStr(pageStr string) error {
 	return nil
 }
 
+// Match return true when entry is changed by Actor in request of RequestId with reason contains Reason, empty field matches all
+func (m *ChangeMeta) Match(entry *JournalEntry) bool {
+	if m.Actor != "" && entry.Actor != m.Actor {
+		return false
+	}
+	if m.RequestId != "" && entry.RequestId != m.RequestId {
+		return false
+	}
+	return m.Reason == "" || strings.Contains(strings.ToLower(entry.Reason), strings.ToLower(m.Reason))
+}
+
+// Filter keep only active and archived entries of page matching filter
+func (page *JournalPage) Filter(filter *ChangeMeta) {
+	page.Active = filterEntries(page.Active, filter)
+	page.Archived = filterEntries(page.Archived, filter)
+}
+
+func filterEntries(entries []*JournalEntry, filter *ChangeMeta) []*JournalEntry {
+	result := []*JournalEntry{}
+	for _, entry := range entries {
+		if filter.Match(entry) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// LastSeq return Seq of last entry in page, PrevSeq when page is empty
+func (page *JournalPage) LastSeq() uint64 {
+	seq := page.PrevSeq
+	if page.MaxSeq > seq {
+		seq = page.MaxSeq
+	}
+	for _, entries := range [][]*JournalEntry{page.Archived, page.Active} {
+		for _, entry := range entries {
+			if entry.Seq > seq {
+				seq = entry.Seq
+			}
+		}
+	}
+	return seq
+}
+
 func (page *JournalPage) LastEntry() int {
 	return len(page.Active) + len(page.Archived)
 }
@@ -38,6 +38,11 @@ type JournalProcess interface {
 	Log(message string)
 }
 
+// StoppableProcess run go routines of its own, they are stopped by journal handler on exit
+type StoppableProcess interface {
+	Stop()
+}
+
 type JournalEvent struct {
 	DataType string `json:"journalDataType"`
 	DataId   string `json:"journalDataId"`
@@ -0,0 +1,319 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+// functions to record all data changes
+package DataJournal
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"Data/DbIface"
+	"DataService/Common"
+	"DataService/DataJournal/ProcessIface"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/SchemaPath"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+const (
+	// events queued for one subscriber, subscriber is dropped when it is full
+	SubscriberBufferSize = 100
+)
+
+// ChangeEvent is a journal entry published by AddJournal, Position is Seq of the entry in journal of the table,
+// Page and Idx are position of entry in journal of the record
+type ChangeEvent struct {
+	Position string                 `json:"position"`
+	DataType string                 `json:"dataType"`
+	DataId   string                 `json:"dataId"`
+	Page     int                    `json:"page"`
+	Idx      int                    `json:"idx"`
+	Time     string                 `json:"time"`
+	Before   map[string]interface{} `json:"before"`
+	After    map[string]interface{} `json:"after"`
+	ProcessIface.ChangeMeta
+	seq uint64
+}
+
+// ChangeFilter select events of a type, of a record, or on targets of a SchemaPath prefix
+type ChangeFilter struct {
+	DataType string
+	DataId   string
+	// resolved from SchemaPath when subscribed, re-subscribe to follow changed references
+	Targets []*SchemaPath.Target
+	// types subscriber is allowed to see, nil for all
+	Allow func(dataType string) bool
+}
+
+// ChangeFeed broadcast journal events to subscribers. publisher never waits for subscribers,
+// subscriber that falls behind for more than SubscriberBufferSize events is dropped and could resume from its last position
+type ChangeFeed struct {
+	lock        sync.Mutex
+	subscribers map[*Subscription]bool
+	// reason feed is closed, nil when it is open
+	closed *Http.HttpError
+}
+
+type Subscription struct {
+	Events <-chan *ChangeEvent
+	events chan *ChangeEvent
+	filter *ChangeFilter
+	feed   *ChangeFeed
+	err    *Http.HttpError
+	// events at or before seq are replayed from journal
+	after uint64
+}
+
+func NewChangeFeed() *ChangeFeed {
+	return &ChangeFeed{
+		subscribers: map[*Subscription]bool{},
+	}
+}
+
+func NewChangeEvent(dataType string, dataId string, entry *ProcessIface.JournalEntry) *ChangeEvent {
+	return &ChangeEvent{
+		Position:   strconv.FormatUint(entry.Seq, 10),
+		DataType:   dataType,
+		DataId:     dataId,
+		Page:       entry.Page,
+		Idx:        entry.Idx,
+		Time:       entry.Time,
+		Before:     entry.Before,
+		After:      entry.After,
+		ChangeMeta: entry.ChangeMeta,
+		seq:        entry.Seq,
+	}
+}
+
+func (f *ChangeFeed) Publish(dataType string, dataId string, entry *ProcessIface.JournalEntry) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	event := NewChangeEvent(dataType, dataId, entry)
+	for sub := range f.subscribers {
+		if (sub.after > 0 && event.seq <= sub.after) || !sub.filter.Match(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			sub.err = Http.NewHttpError(fmt.Sprintf("subscriber is behind more than [%d] events, resume from last position", SubscriberBufferSize), http.StatusTooManyRequests)
+			f.remove(sub)
+		}
+	}
+}
+
+// Subscribe to events published after now that match filter
+func (f *ChangeFeed) Subscribe(filter *ChangeFilter) *Subscription {
+	return f.subscribe(filter, 0)
+}
+
+func (f *ChangeFeed) subscribe(filter *ChangeFilter, after uint64) *Subscription {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	events := make(chan *ChangeEvent, SubscriberBufferSize)
+	sub := Subscription{
+		Events: events,
+		events: events,
+		filter: filter,
+		feed:   f,
+		after:  after,
+	}
+	if f.closed != nil {
+		sub.err = f.closed
+		close(events)
+		return &sub
+	}
+	f.subscribers[&sub] = true
+	return &sub
+}
+
+// Close end all subscriptions with err, subscription of closed feed is ended with err right away
+func (f *ChangeFeed) Close(err *Http.HttpError) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.closed = err
+	for sub := range f.subscribers {
+		sub.err = err
+		f.remove(sub)
+	}
+}
+
+func (f *ChangeFeed) remove(sub *Subscription) {
+	if _, ok := f.subscribers[sub]; !ok {
+		return
+	}
+	delete(f.subscribers, sub)
+	close(sub.events)
+}
+
+// Close stop subscription, Events is closed
+func (s *Subscription) Close() {
+	s.feed.lock.Lock()
+	defer s.feed.lock.Unlock()
+	s.feed.remove(s)
+}
+
+// Err is the reason Events is closed by feed, nil when it is closed by Close
+func (s *Subscription) Err() *Http.HttpError {
+	s.feed.lock.Lock()
+	defer s.feed.lock.Unlock()
+	return s.err
+}
+
+func (f *ChangeFilter) Match(event *ChangeEvent) bool {
+	if !f.inScope(event.DataType, event.DataId) {
+		return false
+	}
+	return f.Targets == nil || SchemaPath.MatchTargets(f.Targets, event.DataType, event.DataId, event.Before, event.After)
+}
+
+// inScope return true when changes of record could match filter
+func (f *ChangeFilter) inScope(dataType string, dataId string) bool {
+	if f.Allow != nil && !f.Allow(dataType) {
+		return false
+	}
+	if f.Targets != nil {
+		for _, target := range f.Targets {
+			if target.DataType == dataType && target.DataId == dataId {
+				return true
+			}
+		}
+		return false
+	}
+	if f.DataType != "" && f.DataType != dataType {
+		return false
+	}
+	return f.DataId == "" || f.DataId == dataId
+}
+
+// Position of last entry published, entries up to it are committed in journal
+func (j *JournalLib) Position() string {
+	j.seqLock.Lock()
+	defer j.seqLock.Unlock()
+	return strconv.FormatUint(j.position, 10)
+}
+
+// Subscribe to journal events that match filter. when since is a position, entries after it in journal are returned
+// to replay before the ones from Events, 410 Gone when some of them are already removed from journal
+func (j *JournalLib) Subscribe(filter *ChangeFilter, since string) (*Subscription, []*ChangeEvent, *Http.HttpError) {
+	var seq uint64
+	if since != "" {
+		var ex error
+		seq, ex = strconv.ParseUint(since, 10, 64)
+		if ex != nil {
+			return nil, nil, Http.NewHttpError(fmt.Sprintf("invalid position=[%s], expect seq of journal entry", since), http.StatusBadRequest)
+		}
+	}
+	// entries are published with seqLock held, so Events start right after position
+	j.seqLock.Lock()
+	position := j.position
+	if seq > position {
+		j.seqLock.Unlock()
+		return nil, nil, Http.NewHttpError(fmt.Sprintf("position=[%s] is ahead of journal position=[%d]", since, position), http.StatusBadRequest)
+	}
+	sub := j.Feed.subscribe(filter, position)
+	j.seqLock.Unlock()
+	if since == "" {
+		return sub, []*ChangeEvent{}, nil
+	}
+	replay, err := j.replay(filter, seq, position)
+	if err != nil {
+		sub.Close()
+		return nil, nil, err
+	}
+	return sub, replay, nil
+}
+
+// entries in (since, until] of journal pages that match filter, in order of seq
+func (j *JournalLib) replay(filter *ChangeFilter, since uint64, until uint64) ([]*ChangeEvent, *Http.HttpError) {
+	if since == until {
+		return []*ChangeEvent{}, nil
+	}
+	pageFilter := DbIface.Gt(ProcessIface.KeyMaxSeq, int64(since))
+	if filter.Targets == nil && filter.DataType != "" {
+		pageFilter = DbIface.And(pageFilter, DbIface.Eq(ProcessIface.KeyDataType, filter.DataType))
+	}
+	dataList, ex := j.db.Get(map[string]interface{}{
+		DbIface.Table:   j.table,
+		Record.DataType: Common.KeyJournal,
+		DbIface.Filter:  pageFilter,
+	})
+	if ex != nil {
+		return nil, Http.WrapError(ex, fmt.Sprintf("failed to query journal after position=[%d]", since), http.StatusInternalServerError)
+	}
+	heads := map[string]*ProcessIface.JournalPage{}
+	events := []*ProcessIface.JournalEntry{}
+	eventMap := map[*ProcessIface.JournalEntry]*ChangeEvent{}
+	for idx, data := range dataList {
+		record, ex := Record.LoadMap(data)
+		if ex != nil {
+			return nil, Http.WrapError(ex, fmt.Sprintf("failed to load %d journal as record", idx), http.StatusInternalServerError)
+		}
+		page := ProcessIface.NewPage("", "", 0)
+		ex = page.LoadMap(record.Data)
+		if ex != nil {
+			return nil, Http.WrapError(ex, fmt.Sprintf("failed to load journalPage from record [%s/%s]", record.Type, record.Id), http.StatusInternalServerError)
+		}
+		if !filter.inScope(page.DataType, page.DataId) {
+			continue
+		}
+		workId := WorkId(page.DataType, page.DataId)
+		if head, ok := heads[workId]; !ok || head.Idx > page.Idx {
+			heads[workId] = page
+		}
+		for _, entries := range [][]*ProcessIface.JournalEntry{page.Archived, page.Active} {
+			for _, entry := range entries {
+				// entries after until are committed after subscribed, they come from Events
+				if entry.Seq <= since || entry.Seq > until {
+					continue
+				}
+				event := NewChangeEvent(page.DataType, page.DataId, entry)
+				if filter.Match(event) {
+					events = append(events, entry)
+					eventMap[entry] = event
+				}
+			}
+		}
+	}
+	for workId, head := range heads {
+		// entries of pages before head are removed after processed
+		if head.PrevSeq > since {
+			return nil, Http.NewHttpError(fmt.Sprintf("entries of [%s] after position=[%d] are no longer kept in journal, resync from data", workId, since), http.StatusGone)
+		}
+	}
+	sort.Slice(events, func(a, b int) bool {
+		return events[a].Seq < events[b].Seq
+	})
+	replay := make([]*ChangeEvent, 0, len(events))
+	for _, entry := range events {
+		replay = append(replay, eventMap[entry])
+	}
+	return replay, nil
+}
@@ -66,6 +66,12 @@ func LoadProcesses(data *DataHandler.Handler, log *log.Logger) ([]ProcessIface.J
 	}
 	log.Printf("process [%s] created", cmtIdx.Name())
 	processList = append(processList, cmtIdx)
+	webhook, err := Process.NewWebhookProcess(data, log)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("process [%s] created", webhook.Name())
+	processList = append(processList, webhook)
 	return processList, nil
 }
 
@@ -111,6 +117,9 @@ func (o *JournalHandler) Run(notify chan interface{}) error {
 			if ok && signal == syscall.SIGINT {
 				o.Log("exit signal")
 				o.OpsCtrl.Broadcast(event)
+				// journal workers write to the table, wait for them so table could be removed after exit
+				o.OpsCtrl.Wait()
+				o.stopProcesses()
 				return nil
 			}
 			journalEvent, ok := event.(ProcessIface.JournalEvent)
@@ -142,6 +151,16 @@ func (o *JournalHandler) Run(notify chan interface{}) error {
 	}
 }
 
+// stop go routines of processes after journal workers exit, so no process is woken again
+func (o *JournalHandler) stopProcesses() {
+	for _, process := range o.processList {
+		if stoppable, ok := process.(ProcessIface.StoppableProcess); ok {
+			o.Log(fmt.Sprintf("stop process [%s]", process.Name()))
+			stoppable.Stop()
+		}
+	}
+}
+
 func (o *JournalHandler) ProcessAllJournals() bool {
 	o.Log("start threads process all journals")
 	hasChange := false
@@ -33,6 +33,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/salesforce/UniTAO/lib/Schema/Record"
@@ -58,6 +60,17 @@ type JournalLib struct {
 	Cache         map[string]map[string]*JournalCache
 	Logger        *log.Logger
 	HandlerNotify func(event interface{})
+	// live journal events for subscribers
+	Feed *ChangeFeed
+	// guard Cache map, records are added by writers while journal handler reads it
+	cacheLock sync.RWMutex
+	// seq is last one reserved in block up to seqLimit, position is last one published,
+	// reserved are not published yet, they are in order of seq. all guarded by seqLock
+	seq      uint64
+	seqLimit uint64
+	position uint64
+	reserved []*seqReservation
+	seqLock  sync.Mutex
 }
 
 func NewJournalLib(db DbIface.Database, table string, logger *log.Logger) (*JournalLib, *Http.HttpError) {
@@ -69,6 +82,7 @@ func NewJournalLib(db DbIface.Database, table string, logger *log.Logger) (*Jour
 		table:  table,
 		Cache:  map[string]map[string]*JournalCache{},
 		Logger: logger,
+		Feed:   NewChangeFeed(),
 	}
 	err := lib.initCache()
 	if err != nil {
@@ -116,6 +130,14 @@ func (j *JournalLib) initCache() *Http.HttpError {
 			cache.Tail.LoadMap(tailRecord.Data)
 		}
 	}
+	for dataType := range j.Cache {
+		for _, cache := range j.Cache[dataType] {
+			if seq := cache.Tail.LastSeq(); seq > j.seq {
+				j.seq = seq
+			}
+		}
+	}
+	j.position = j.seq
 	return nil
 }
 
@@ -139,7 +161,16 @@ func (j *JournalLib) GetJournal(journalId string) (interface{}, *Http.HttpError)
 	}
 }
 
+func (j *JournalLib) getCache(dataType string, dataId string) (*JournalCache, bool) {
+	j.cacheLock.RLock()
+	defer j.cacheLock.RUnlock()
+	cache, ok := j.Cache[dataType][dataId]
+	return cache, ok
+}
+
 func (j *JournalLib) ListJournalTypes() []string {
+	j.cacheLock.RLock()
+	defer j.cacheLock.RUnlock()
 	typeList := make([]string, 0, len(j.Cache))
 	for name := range j.Cache {
 		typeList = append(typeList, name)
@@ -148,6 +179,8 @@ func (j *JournalLib) ListJournalTypes() []string {
 }
 
 func (j *JournalLib) ListJournalIds(dataType string) []string {
+	j.cacheLock.RLock()
+	defer j.cacheLock.RUnlock()
 	if _, ok := j.Cache[dataType]; !ok {
 		return []string{}
 	}
@@ -159,10 +192,7 @@ func (j *JournalLib) ListJournalIds(dataType string) []string {
 }
 
 func (j *JournalLib) ListJournalPages(dataType string, dataId string) ([]string, *Http.HttpError) {
-	if _, ok := j.Cache[dataType]; !ok {
-		return nil, Http.NewHttpError(fmt.Sprintf("journal page of [%s] does not exists", dataType), http.StatusNotFound)
-	}
-	cache, ok := j.Cache[dataType][dataId]
+	cache, ok := j.getCache(dataType, dataId)
 	if !ok {
 		return nil, Http.NewHttpError(fmt.Sprintf("journal page of [%s/%s] does not exists", dataType, dataId), http.StatusNotFound)
 	}
@@ -170,10 +200,7 @@ func (j *JournalLib) ListJournalPages(dataType string, dataId string) ([]string,
 }
 
 func (j *JournalLib) NextJournalEntry(dataType string, dataId string) *ProcessIface.JournalEntry {
-	if _, ok := j.Cache[dataType]; !ok {
-		return nil
-	}
-	cache, ok := j.Cache[dataType][dataId]
+	cache, ok := j.getCache(dataType, dataId)
 	if !ok {
 		return nil
 	}
@@ -228,7 +255,51 @@ func (j *JournalLib) QueryJournal(journalId string) (interface{}, *Http.HttpErro
 	return result, nil
 }
 
-func (j *JournalLib) AddJournal(dataType string, dataId string, before map[string]interface{}, after map[string]interface{}) *Http.HttpError {
+// FilterJournal return pages of journalId with only entries matching filter, journalId could be of a type, a record, a page or empty for all,
+// pages without matched entries are skipped
+func (j *JournalLib) FilterJournal(journalId string, filter *ProcessIface.ChangeMeta) ([]*ProcessIface.JournalPage, *Http.HttpError) {
+	dataType, dataId, idx := "", "", 0
+	if journalId != "" {
+		var err error
+		dataType, dataId, idx, err = ProcessIface.ParseJournalId(journalId)
+		if err != nil {
+			return nil, Http.NewHttpError(err.Error(), http.StatusBadRequest)
+		}
+	}
+	data, err := j.QueryJournal("")
+	if err != nil {
+		return nil, err
+	}
+	result := []*ProcessIface.JournalPage{}
+	for _, record := range data.([]*Record.Record) {
+		page := ProcessIface.NewPage("", "", 0)
+		ex := page.LoadMap(record.Data)
+		if ex != nil {
+			return nil, Http.WrapError(ex, fmt.Sprintf("failed to load journalPage from record [%s/%s]", Common.KeyJournal, record.Id), http.StatusInternalServerError)
+		}
+		if (dataType != "" && page.DataType != dataType) || (dataId != "" && page.DataId != dataId) || (idx > 0 && page.Idx != idx) {
+			continue
+		}
+		page.Filter(filter)
+		if len(page.Active)+len(page.Archived) > 0 {
+			result = append(result, page)
+		}
+	}
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].DataType != result[b].DataType {
+			return result[a].DataType < result[b].DataType
+		}
+		if result[a].DataId != result[b].DataId {
+			return result[a].DataId < result[b].DataId
+		}
+		return result[a].Idx < result[b].Idx
+	})
+	return result, nil
+}
+
+// AddJournal commit journal entry together with data changes in batch, batch could be nil to only record journal
+func (j *JournalLib) AddJournal(batch *DbIface.Batch, dataType string, dataId string, before map[string]interface{}, after map[string]interface{}, meta *ProcessIface.ChangeMeta) *Http.HttpError {
+	j.cacheLock.Lock()
 	if _, ok := j.Cache[dataType]; !ok {
 		j.Cache[dataType] = map[string]*JournalCache{}
 	}
@@ -237,8 +308,9 @@ func (j *JournalLib) AddJournal(dataType string, dataId string, before map[strin
 		c.Head = c.Tail
 		j.Cache[dataType][dataId] = c
 	}
+	j.cacheLock.Unlock()
 	j.Logger.Printf("AddJournal: [%s/%s] adding Journal", dataType, dataId)
-	err := j.addJournalEntry(dataType, dataId, before, after)
+	err := j.addJournalEntry(batch, dataType, dataId, before, after, meta)
 	if err != nil {
 		j.Logger.Printf("AddJournal: error while addJournalEntry. Error:%s", err)
 		return err
@@ -259,15 +331,11 @@ func (j *JournalLib) AddJournal(dataType string, dataId string, before map[strin
 }
 
 func (j *JournalLib) ArchiveJournalEntry(dataType string, dataId string, entry *ProcessIface.JournalEntry) *Http.HttpError {
-	if _, ok := j.Cache[dataType]; !ok {
-		j.Logger.Printf("Archive: no journal for type=[%s]", dataType)
-		return nil
-	}
-	if _, ok := j.Cache[dataType][dataId]; !ok {
+	cache, ok := j.getCache(dataType, dataId)
+	if !ok {
 		j.Logger.Printf("Archive: no journal for data=[%s/%s]", dataType, dataId)
 		return nil
 	}
-	cache := j.Cache[dataType][dataId]
 	if cache.Head.Idx != entry.Page {
 		j.Logger.Printf("Archive: entry page [%d]!= head page [%d]", entry.Page, cache.Head.Idx)
 		return nil
@@ -341,14 +409,21 @@ func (j *JournalLib) removeJournal(journalId string) *Http.HttpError {
 	return nil
 }
 
-func (j *JournalLib) updateJournal(page *ProcessIface.JournalPage) *Http.HttpError {
+func (j *JournalLib) pageRecord(page *ProcessIface.JournalPage) (*Record.Record, *Http.HttpError) {
 	pageData := map[string]interface{}{}
 	err := Json.CopyTo(page, &pageData)
 	if err != nil {
-		return Http.WrapError(err, fmt.Sprintf("failed to create Record Data. Error:%s", err), http.StatusBadRequest)
+		return nil, Http.WrapError(err, fmt.Sprintf("failed to create Record Data. Error:%s", err), http.StatusBadRequest)
 	}
-	pageRecord := Record.NewRecord(Common.KeyJournal, CurrentVer, page.Id(), pageData)
-	err = j.db.Replace(j.table, map[string]interface{}{
+	return Record.NewRecord(Common.KeyJournal, CurrentVer, page.Id(), pageData), nil
+}
+
+func (j *JournalLib) updateJournal(page *ProcessIface.JournalPage) *Http.HttpError {
+	pageRecord, ex := j.pageRecord(page)
+	if ex != nil {
+		return ex
+	}
+	err := j.db.Replace(j.table, map[string]interface{}{
 		Record.DataType: Common.KeyJournal,
 		Record.DataId:   page.Id(),
 	}, pageRecord.Map())
@@ -358,8 +433,8 @@ func (j *JournalLib) updateJournal(page *ProcessIface.JournalPage) *Http.HttpErr
 	return nil
 }
 
-func (j *JournalLib) addJournalEntry(dataType string, dataId string, before map[string]interface{}, after map[string]interface{}) *Http.HttpError {
-	cache := j.Cache[dataType][dataId]
+func (j *JournalLib) addJournalEntry(batch *DbIface.Batch, dataType string, dataId string, before map[string]interface{}, after map[string]interface{}, meta *ProcessIface.ChangeMeta) *Http.HttpError {
+	cache, _ := j.getCache(dataType, dataId)
 	j.Logger.Printf("AddJournal: acquire lock for [%s/%s]", dataType, dataId)
 	ex := cache.Lock.Lock(10 * time.Second)
 	if ex != nil {
@@ -367,31 +442,72 @@ func (j *JournalLib) addJournalEntry(dataType string, dataId string, before map[
 	}
 	defer cache.Lock.Unlock()
 	defer j.Logger.Printf("AddJournal: lock for [%s/%s] released", dataType, dataId)
-	if j.Cache[dataType][dataId].Tail.LastEntry() >= MaxEntryPerPage {
-		nextIdx := j.Cache[dataType][dataId].Tail.Idx + 1
-		j.Cache[dataType][dataId].Tail = ProcessIface.NewPage(dataType, dataId, nextIdx)
-		j.Logger.Printf("[%s]: create new journal page[%s]", WorkId(dataType, dataId), j.Cache[dataType][dataId].Tail.Id())
-		j.Logger.Printf("[%s]: head page[%s]", WorkId(dataType, dataId), j.Cache[dataType][dataId].Head.Id())
-	}
-	tail := j.Cache[dataType][dataId].Tail
-	if tail.Idx == -1 {
-		tail.Idx = 1
-	}
-	entryIdx := tail.LastEntry() + 1
-	j.Logger.Printf("[%s]: add Journal[%d] to page %d", WorkId(dataType, dataId), entryIdx, tail.Idx)
+	// seq is reserved with lock of record held, so entries of a record are in order of seq
+	reservation, err := j.reserveSeq()
+	if err != nil {
+		return err
+	}
+	var committed *ProcessIface.JournalEntry
+	defer func() {
+		j.finishSeq(reservation, dataType, dataId, committed)
+	}()
+	// work on a copy of tail page, cache only changes after batch committed
+	tail := cache.Tail
+	newPage := false
+	if tail.LastEntry() >= MaxEntryPerPage {
+		prevSeq := tail.LastSeq()
+		tail = ProcessIface.NewPage(dataType, dataId, tail.Idx+1)
+		tail.PrevSeq = prevSeq
+		newPage = true
+		j.Logger.Printf("[%s]: create new journal page[%s]", WorkId(dataType, dataId), tail.Id())
+		j.Logger.Printf("[%s]: head page[%s]", WorkId(dataType, dataId), cache.Head.Id())
+	}
+	page := ProcessIface.NewPage(dataType, dataId, tail.Idx)
+	if page.Idx == -1 {
+		page.Idx = 1
+	}
+	page.PrevSeq = tail.PrevSeq
+	page.MaxSeq = reservation.seq
+	page.Active = append(page.Active, tail.Active...)
+	page.Archived = append(page.Archived, tail.Archived...)
+	entryIdx := page.LastEntry() + 1
+	j.Logger.Printf("[%s]: add Journal[%d] to page %d", WorkId(dataType, dataId), entryIdx, page.Idx)
 	entry := ProcessIface.JournalEntry{
+		Seq:    reservation.seq,
 		Time:   time.Now().String(),
-		Page:   tail.Idx,
+		Page:   page.Idx,
 		Idx:    entryIdx,
 		Before: before,
 		After:  after,
 	}
-	tail.Active = append(tail.Active, &entry)
-	err := j.updateJournal(tail)
+	if meta != nil {
+		entry.ChangeMeta = *meta
+	}
+	page.Active = append(page.Active, &entry)
+	pageRecord, err := j.pageRecord(page)
 	if err != nil {
-		j.Logger.Printf("[%s]: update Journal page [%s] error:%s", WorkId(dataType, dataId), tail.Id(), err)
 		return err
 	}
-	j.Logger.Printf("[%s]: update Journal page [%s] saved", WorkId(dataType, dataId), tail.Id())
+	if batch == nil {
+		batch = DbIface.NewBatch()
+	}
+	batch.Replace(j.table, map[string]interface{}{
+		Record.DataType: Common.KeyJournal,
+		Record.DataId:   page.Id(),
+	}, pageRecord.Map())
+	e := j.db.Commit(batch)
+	if e != nil {
+		j.Logger.Printf("[%s]: commit Journal page [%s] error:%s", WorkId(dataType, dataId), page.Id(), e)
+		return Http.WrapError(e, fmt.Sprintf("failed to commit journal page [%s] with data changes", page.Id()), Common.CommitStatus(e))
+	}
+	if newPage {
+		cache.Tail = tail
+	}
+	cache.Tail.Idx = page.Idx
+	cache.Tail.MaxSeq = entry.Seq
+	cache.Tail.Active = append(cache.Tail.Active, &entry)
+	j.Logger.Printf("[%s]: update Journal page [%s] saved", WorkId(dataType, dataId), page.Id())
+	// published by finishSeq after entries of seq before it
+	committed = &entry
 	return nil
 }
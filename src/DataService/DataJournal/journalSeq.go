@@ -0,0 +1,155 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+// functions to record all data changes
+package DataJournal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"Data/DbIface"
+	"DataService/Common"
+	"DataService/DataJournal/ProcessIface"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+	"github.com/salesforce/UniTAO/lib/Util/Json"
+)
+
+const (
+	// seq reserved from journalSeq record at a time, seq left in block are skipped when process stops
+	SeqBlockSize = 100
+	// attempts to reserve a block when other service instances reserve at the same time
+	SeqReserveRetry = 10
+	KeyLast         = "last"
+)
+
+type journalSeq struct {
+	Last uint64 `json:"last"`
+}
+
+// seq of a journal entry being committed, entry is nil when commit failed
+type seqReservation struct {
+	seq      uint64
+	done     bool
+	dataType string
+	dataId   string
+	entry    *ProcessIface.JournalEntry
+}
+
+// reserveSeq return next seq for a journal entry, finishSeq must be called with it after commit
+func (j *JournalLib) reserveSeq() (*seqReservation, *Http.HttpError) {
+	j.seqLock.Lock()
+	defer j.seqLock.Unlock()
+	if j.seq >= j.seqLimit {
+		err := j.reserveBlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	j.seq++
+	reservation := seqReservation{
+		seq: j.seq,
+	}
+	j.reserved = append(j.reserved, &reservation)
+	return &reservation, nil
+}
+
+// reserve next SeqBlockSize seq in journalSeq record with revision check, so no seq is given to 2 service instances
+func (j *JournalLib) reserveBlock() *Http.HttpError {
+	keys := map[string]interface{}{
+		DbIface.Table:   j.table,
+		Record.DataType: Common.KeyJournalSeq,
+		Record.DataId:   Common.KeyJournal,
+	}
+	var createErr error
+	for attempt := 0; attempt < SeqReserveRetry; attempt++ {
+		dataList, ex := j.db.Get(keys)
+		if ex != nil {
+			return Http.WrapError(ex, fmt.Sprintf("failed to get [%s/%s]", Common.KeyJournalSeq, Common.KeyJournal), http.StatusInternalServerError)
+		}
+		if len(dataList) == 0 && createErr != nil {
+			return Http.WrapError(createErr, fmt.Sprintf("failed to create [%s/%s]", Common.KeyJournalSeq, Common.KeyJournal), http.StatusInternalServerError)
+		}
+		current := journalSeq{}
+		if len(dataList) > 0 {
+			ex = Json.CopyTo(dataList[0][Record.Data], &current)
+			if ex != nil {
+				return Http.WrapError(ex, fmt.Sprintf("invalid record [%s/%s]", Common.KeyJournalSeq, Common.KeyJournal), http.StatusInternalServerError)
+			}
+		}
+		start := current.Last
+		if j.seq > start {
+			start = j.seq
+		}
+		record := Record.NewRecord(Common.KeyJournalSeq, CurrentVer, Common.KeyJournal, map[string]interface{}{
+			KeyLast: start + SeqBlockSize,
+		})
+		if len(dataList) == 0 {
+			ex = j.db.Create(j.table, record.Map())
+			createErr = ex
+		} else {
+			revision := DbIface.RecordRevision(dataList[0])
+			record.Revision = revision + 1
+			ex = j.db.Replace(j.table, map[string]interface{}{
+				Record.DataType: Common.KeyJournalSeq,
+				Record.DataId:   Common.KeyJournal,
+				Record.Revision: revision,
+			}, record.Map())
+		}
+		if ex == nil {
+			j.seq = start
+			j.seqLimit = start + SeqBlockSize
+			j.Logger.Printf("reserved journal seq [%d-%d] of table [%s]", start+1, j.seqLimit, j.table)
+			return nil
+		}
+		if len(dataList) > 0 && !errors.Is(ex, DbIface.ErrRevisionConflict) {
+			return Http.WrapError(ex, fmt.Sprintf("failed to reserve journal seq of table [%s]", j.table), http.StatusInternalServerError)
+		}
+		// created or updated by other instance, read it again
+	}
+	return Http.NewHttpError(fmt.Sprintf("failed to reserve journal seq of table [%s] after [%d] attempts", j.table, SeqReserveRetry), http.StatusConflict)
+}
+
+// finishSeq mark reservation done and publish entries in order of seq,
+// entry is held until all seq reserved before it are committed or failed
+func (j *JournalLib) finishSeq(reservation *seqReservation, dataType string, dataId string, entry *ProcessIface.JournalEntry) {
+	j.seqLock.Lock()
+	defer j.seqLock.Unlock()
+	reservation.done = true
+	reservation.dataType = dataType
+	reservation.dataId = dataId
+	reservation.entry = entry
+	for len(j.reserved) > 0 && j.reserved[0].done {
+		head := j.reserved[0]
+		j.reserved = j.reserved[1:]
+		j.position = head.seq
+		if head.entry != nil {
+			j.Feed.Publish(head.dataType, head.dataId, head.entry)
+		}
+	}
+}
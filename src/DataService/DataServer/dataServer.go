@@ -27,20 +27,33 @@ package DataServer
 
 import (
 	"Data"
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"DataService/Common"
 	"DataService/Config"
 	"DataService/DataHandler"
-	"DataService/DataJournal"
+	"DataService/DataJournal/ProcessIface"
+	"DataService/GraphQl"
+	"DataService/Namespace"
+	"DataService/OpenApi"
+	"DataService/Rbac"
 
+	"github.com/google/uuid"
 	"github.com/salesforce/UniTAO/lib/Schema/Record"
 	"github.com/salesforce/UniTAO/lib/Util"
 	"github.com/salesforce/UniTAO/lib/Util/CustomLogger"
 	"github.com/salesforce/UniTAO/lib/Util/Http"
+	"github.com/salesforce/UniTAO/lib/Util/Json"
 	"github.com/salesforce/UniTAO/lib/Util/Thread"
 )
 
@@ -51,16 +64,18 @@ const (
 )
 
 type Server struct {
-	Id             string
-	Port           string
-	args           map[string]string
-	config         Config.Confuguration
-	data           *DataHandler.Handler
-	journal        *DataJournal.JournalLib
-	journalHandler *DataJournal.JournalHandler
-	BackendCtl     *Thread.ThreadCtrl
-	logPath        string
-	log            *log.Logger
+	Id         string
+	Port       string
+	args       map[string]string
+	config     Config.Confuguration
+	data       *DataHandler.Handler
+	namespaces *Namespace.Manager
+	auth       *Http.AuthHandler
+	rbac       *Rbac.Manager
+	graphQl    *GraphQl.Cache
+	BackendCtl *Thread.ThreadCtrl
+	logPath    string
+	log        *log.Logger
 }
 
 func New() (Server, error) {
@@ -69,6 +84,7 @@ func New() (Server, error) {
 		args:    make(map[string]string),
 		config:  Config.Confuguration{},
 		logPath: "",
+		graphQl: GraphQl.NewCache(),
 	}
 	err := srv.init()
 	if err != nil {
@@ -95,6 +111,16 @@ func (srv *Server) Run() {
 		srv.log.Fatalf("failed to initialize data layer, Err:%s", err)
 	}
 	srv.data = handler
+	auth, err := Http.NewAuthHandler(srv.config.Http.Auth)
+	if err != nil {
+		srv.log.Fatalf("failed to load auth config, Err:%s", err)
+	}
+	srv.auth = auth
+	// access is controlled once callers are authenticated, handlers of namespaces inherit the check
+	srv.rbac = Rbac.NewManager(handler, auth.Enabled())
+	if srv.rbac.Enabled {
+		handler.Authorize = srv.rbac.Check
+	}
 	jLogFile, jLogger, ex := CustomLogger.FileLoger(srv.logPath, fmt.Sprintf("%s_Journal", srv.Id))
 	if ex != nil {
 		srv.log.Fatalf("failed to create file logger[%s_Journal], Error: %s", srv.Id, err)
@@ -102,33 +128,85 @@ func (srv *Server) Run() {
 	if jLogFile != nil {
 		defer jLogFile.Close()
 	}
-	journal, err := DataJournal.NewJournalLib(handler.DB, srv.config.DataTable.Data, jLogger)
+	namespaces, err := Namespace.NewManager(handler, srv.BackendCtl, srv.log, jLogger)
 	if err != nil {
-		srv.log.Fatalf("failed to create Journal Library. Error: %s", err)
+		srv.log.Fatalf("failed to load namespaces. Error: %s", err)
 	}
-	srv.journal = journal
-	srv.data.AddJournal = srv.journal.AddJournal
-	srv.RunJournalHandler()
+	srv.namespaces = namespaces
+	srv.RunPurgeHandler()
+	srv.RunExpireHandler()
 	srv.RunHttp()
 }
 
 func (srv *Server) RunHttp() {
-	http.HandleFunc("/", srv.handler)
+	http.HandleFunc("/", srv.auth.Middleware(srv.handler, srv.config.Http))
 	srv.log.Printf("Data Server Listen @%s://%s:%s", srv.config.Http.HttpType, srv.config.Http.DnsName, srv.Port)
-	srv.log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", srv.Port), nil))
+	srv.log.Fatal(Http.ListenAndServe(srv.Port, nil, srv.config.Http))
 }
 
-func (srv *Server) RunJournalHandler() {
-	journal, err := DataJournal.NewJournalHandler(srv.data, srv.journal, srv.journal.Logger)
-	if err != nil {
-		srv.log.Fatalf("failed to load Journal Handler. Error:%s", err)
+// purge tombstones of soft deleted records periodically
+func (srv *Server) RunPurgeHandler() {
+	interval, ex := srv.config.Purge.GetInterval(Config.DefaultPurgeInterval)
+	if ex != nil {
+		srv.log.Fatalf("failed to load purge config. Error:%s", ex)
+	}
+	worker, ex := srv.BackendCtl.AddWorker("purgeHandler", func(notify chan interface{}) error {
+		for {
+			select {
+			case event := <-notify:
+				if signal, ok := event.(os.Signal); ok && signal == syscall.SIGINT {
+					srv.log.Printf("purge handler exit")
+					return nil
+				}
+			case <-time.After(interval):
+				for _, ns := range srv.namespaces.All() {
+					purged, err := ns.Data.Purge(time.Now())
+					if err != nil {
+						srv.log.Printf("failed to purge deleted records of namespace [%s]. Error:%s", ns.Name, err)
+					}
+					if purged > 0 {
+						srv.log.Printf("purged [%d] deleted records of namespace [%s]", purged, ns.Name)
+					}
+				}
+			}
+		}
+	})
+	if ex != nil {
+		srv.log.Fatalf("failed to create purge handler as backend process.")
 	}
-	srv.journalHandler = journal
-	worker, err := srv.BackendCtl.AddWorker("journalHandler", srv.journalHandler.Run)
-	if err != nil {
-		srv.log.Fatalf("failed to create Journal Handler as backend process.")
+	worker.Run()
+}
+
+// delete records passed their expiry time periodically
+func (srv *Server) RunExpireHandler() {
+	interval, ex := srv.config.Expire.GetInterval(Config.DefaultExpireInterval)
+	if ex != nil {
+		srv.log.Fatalf("failed to load expire config. Error:%s", ex)
+	}
+	worker, ex := srv.BackendCtl.AddWorker("expireHandler", func(notify chan interface{}) error {
+		for {
+			select {
+			case event := <-notify:
+				if signal, ok := event.(os.Signal); ok && signal == syscall.SIGINT {
+					srv.log.Printf("expire handler exit")
+					return nil
+				}
+			case <-time.After(interval):
+				for _, ns := range srv.namespaces.All() {
+					expired, err := ns.Data.Expire(time.Now())
+					if err != nil {
+						srv.log.Printf("failed to delete expired records of namespace [%s]. Error:%s", ns.Name, err)
+					}
+					if expired > 0 {
+						srv.log.Printf("deleted [%d] expired records of namespace [%s]", expired, ns.Name)
+					}
+				}
+			}
+		}
+	})
+	if ex != nil {
+		srv.log.Fatalf("failed to create expire handler as backend process.")
 	}
-	srv.journal.HandlerNotify = worker.Notify
 	worker.Run()
 }
 
@@ -176,6 +254,10 @@ func (srv *Server) handler(w http.ResponseWriter, r *http.Request) {
 		Http.ResponseJson(w, err, err.Status, srv.config.Http)
 	}
 	dataType, idPath := Util.ParsePath(requestUrl)
+	if idPath == "" {
+		// query string on type list, ex: /{type}?pageSize=10
+		dataType = strings.SplitN(dataType, "?", 2)[0]
+	}
 	srv.log.Printf("process request[%s] on [%s/%s]", r.Method, dataType, idPath)
 	if dataType == Record.KeyRecord {
 		srv.log.Printf("Invalid request on [%s]", dataType)
@@ -197,17 +279,52 @@ func (srv *Server) handler(w http.ResponseWriter, r *http.Request) {
 		}, http.StatusBadRequest, srv.config.Http)
 		return
 	}
+	if !srv.authorize(w, r, dataType, idPath) {
+		return
+	}
+	if dataType == Common.KeyNamespace {
+		srv.handleNamespace(w, r, idPath)
+		return
+	}
+	if dataType == Common.KeyRole || dataType == Common.KeyRoleBinding {
+		srv.handleRbac(w, r, dataType, idPath)
+		return
+	}
+	ns, err := srv.namespaces.Get(r.Header.Get(Common.HeaderNamespace))
+	if err != nil {
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	if dataType == Common.KeyGraphQl {
+		srv.handleGraphQl(w, r, ns)
+		return
+	}
+	if dataType == Common.KeyWebhook {
+		srv.handleWebhook(w, r, ns, idPath)
+		return
+	}
+	if r.Method != http.MethodGet {
+		ns = srv.withChange(w, r, ns)
+	}
 	switch r.Method {
 	case http.MethodGet:
-		srv.handleGet(w, dataType, idPath)
+		srv.handleGet(w, r, ns, dataType, idPath)
 	case http.MethodPost:
-		srv.handlePost(w, r, dataType, idPath)
+		if dataType == Common.KeyUndelete {
+			srv.handleUndelete(w, ns, idPath)
+			return
+		}
+		if dataType == Common.KeyImport {
+			srv.handleImport(w, r, ns)
+			return
+		}
+		srv.handlePost(w, r, ns, dataType, idPath)
 	case http.MethodDelete:
-		srv.handleDelete(w, dataType, idPath)
+		srv.handleDelete(w, r, ns, dataType, idPath)
 	case http.MethodPut:
-		srv.handlePut(w, r, dataType, idPath)
+		srv.handlePut(w, r, ns, dataType, idPath)
 	case http.MethodPatch:
-		srv.handlePatch(w, r, dataType, idPath)
+		srv.handlePatch(w, r, ns, dataType, idPath)
 	default:
 		Http.ResponseJson(w, Http.HttpError{
 			Status: http.StatusMethodNotAllowed,
@@ -218,15 +335,58 @@ func (srv *Server) handler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (srv *Server) handleGet(w http.ResponseWriter, dataType string, idPath string) {
-	if idPath == "" {
-		srv.log.Printf("list id of [%s]", dataType)
-		idList, err := srv.data.List(dataType)
-		if err != nil {
-			Http.ResponseJson(w, err, err.Status, srv.config.Http)
+// namespace of request recording caller identity, change reason and request id in journal of its changes,
+// request id is generated when missing and returned in response header
+func (srv *Server) withChange(w http.ResponseWriter, r *http.Request, ns *Namespace.Namespace) *Namespace.Namespace {
+	meta := ProcessIface.ChangeMeta{
+		Reason:    r.Header.Get(Common.HeaderChangeReason),
+		RequestId: r.Header.Get(Common.HeaderRequestId),
+	}
+	if identity := Http.GetIdentity(r); identity != nil {
+		meta.Actor = identity.Name
+	}
+	if meta.RequestId == "" {
+		meta.RequestId = uuid.NewString()
+	}
+	w.Header().Set(Common.HeaderRequestId, meta.RequestId)
+	reqNs := *ns
+	reqNs.Data = ns.Data.WithChange(&meta)
+	return &reqNs
+}
+
+func (srv *Server) handleGet(w http.ResponseWriter, r *http.Request, ns *Namespace.Namespace, dataType string, idPath string) {
+	switch dataType {
+	case Common.KeyCache:
+		srv.handleCacheStats(w)
+		return
+	case Common.KeyMetrics:
+		srv.handleMetrics(w)
+		return
+	case Common.KeyExport:
+		srv.handleExport(w, r, ns, idPath)
+		return
+	case Common.KeyOpenApi:
+		srv.handleOpenApi(w, ns)
+		return
+	case Common.KeyStream:
+		srv.handleStream(w, r, ns, idPath)
+		return
+	}
+	if dataType == Common.KeyJournal {
+		if filter, ok := journalFilter(r); ok {
+			journalId := strings.SplitN(idPath, "?", 2)[0]
+			srv.log.Printf("filter Journal of [%s], query=[%s]", journalId, r.URL.RawQuery)
+			result, err := ns.Journal.FilterJournal(journalId, filter)
+			if err != nil {
+				Http.ResponseJson(w, err, err.Status, srv.config.Http)
+				return
+			}
+			Http.ResponseJson(w, result, http.StatusOK, srv.config.Http)
 			return
 		}
-		Http.ResponseJson(w, idList, http.StatusOK, srv.config.Http)
+	}
+	if idPath == "" {
+		srv.handleList(w, r, ns, dataType)
 		return
 	}
 	var result interface{}
@@ -234,26 +394,209 @@ func (srv *Server) handleGet(w http.ResponseWriter, dataType string, idPath stri
 	switch dataType {
 	case Common.KeyJournal:
 		srv.log.Printf("get Journal of type [%s]", idPath)
-		result, err = srv.journal.GetJournal(idPath)
+		result, err = ns.Journal.GetJournal(idPath)
 	default:
 		srv.log.Printf("get data of [%s/%s]", dataType, idPath)
-		result, err = srv.data.Get(dataType, idPath)
+		result, err = ns.Data.Get(dataType, idPath)
 	}
 	if err != nil {
 		Http.ResponseJson(w, err, err.Status, srv.config.Http)
 		return
 	}
+	if _, nextPath := Util.ParsePath(idPath); nextPath == "" && dataType != Common.KeyJournal {
+		if data, ok := result.(map[string]interface{}); ok && data[Record.DataId] != nil {
+			etag, err := DataHandler.ETag(data)
+			if err != nil {
+				Http.ResponseJson(w, err, err.Status, srv.config.Http)
+				return
+			}
+			w.Header().Set(Common.HeaderETag, etag)
+			if ifNoneMatch := r.Header.Get(Common.HeaderIfNoneMatch); ifNoneMatch != "" && DataHandler.MatchETag(ifNoneMatch, etag) {
+				Http.Response(w, nil, http.StatusNotModified, srv.config.Http)
+				return
+			}
+		}
+	}
 	Http.ResponseJson(w, result, http.StatusOK, srv.config.Http)
 }
 
-func (srv *Server) BuildRecord(payload map[string]interface{}, dataType string, dataId string) (*Record.Record, *Http.HttpError) {
+// filter of journal entries in query of GET /journal, false when query has no filter
+func journalFilter(r *http.Request) (*ProcessIface.ChangeMeta, bool) {
+	query := r.URL.Query()
+	filter := ProcessIface.ChangeMeta{
+		Actor:     query.Get(Common.QueryActor),
+		Reason:    query.Get(Common.QueryReason),
+		RequestId: query.Get(Common.QueryRequestId),
+	}
+	if filter == (ProcessIface.ChangeMeta{}) {
+		return nil, false
+	}
+	return &filter, true
+}
+
+func (srv *Server) handleCacheStats(w http.ResponseWriter) {
+	stats := srv.data.CacheStats()
+	if stats == nil {
+		err := Http.NewHttpError("database cache is not configured", http.StatusNotFound)
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	Http.ResponseJson(w, stats, http.StatusOK, srv.config.Http)
+}
+
+func (srv *Server) handleMetrics(w http.ResponseWriter) {
+	buf := bytes.Buffer{}
+	srv.data.WriteMetrics(&buf)
+	Http.ResponseText(w, buf.Bytes(), http.StatusOK, srv.config.Http)
+}
+
+// stream records of dataType as newline-delimited JSON, all records with schemas first when dataType is empty
+func (srv *Server) handleExport(w http.ResponseWriter, r *http.Request, ns *Namespace.Namespace, dataType string) {
+	typeList, err := ns.Data.ExportTypes(dataType)
+	if err != nil {
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	if visible := srv.rbac.Visible(Http.GetIdentity(r), Rbac.VerbGet); visible != nil && dataType == "" {
+		allowed := []string{}
+		for _, exportType := range typeList {
+			if visible(exportType) {
+				allowed = append(allowed, exportType)
+			}
+		}
+		typeList = allowed
+	}
+	srv.log.Printf("export types %v", typeList)
+	Http.ResponseStream(w, Common.ContentTypeNdjson, http.StatusOK, srv.config.Http)
+	err = ns.Data.Export(typeList, w)
+	if err != nil {
+		// response is already started, export is left incomplete
+		srv.log.Printf("export of %v aborted. Error:%s", typeList, err)
+	}
+}
+
+// OpenAPI document is generated on every request, so it is always in sync with schemas
+func (srv *Server) handleOpenApi(w http.ResponseWriter, ns *Namespace.Namespace) {
+	doc, err := OpenApi.Build(ns.Data, fmt.Sprintf("UniTAO DataService %s", srv.config.Http.Id))
+	if err != nil {
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	Http.ResponseJson(w, doc, http.StatusOK, srv.config.Http)
+}
+
+// GraphQL schema is cached, and built again when schema records are changed.
+// request is invalid when response has no data
+func (srv *Server) handleGraphQl(w http.ResponseWriter, r *http.Request, ns *Namespace.Namespace) {
+	schema, err := srv.graphQl.Get(ns.Data)
+	if err != nil {
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	req := GraphQl.Request{}
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		if !query.Has(Common.QueryGraphQl) {
+			Http.ResponseText(w, []byte(schema.SDL()), http.StatusOK, srv.config.Http)
+			return
+		}
+		req.Query = query.Get(Common.QueryGraphQl)
+		req.OperationName = query.Get(Common.QueryOperationName)
+		if varStr := query.Get(Common.QueryGraphQlVars); varStr != "" {
+			ex := json.Unmarshal([]byte(varStr), &req.Variables)
+			if ex != nil {
+				err = Http.WrapError(ex, fmt.Sprintf("invalid [%s] of GraphQL request", Common.QueryGraphQlVars), http.StatusBadRequest)
+				Http.ResponseJson(w, err, err.Status, srv.config.Http)
+				return
+			}
+		}
+	case http.MethodPost:
+		reqBody, err := Http.LoadRequest(r)
+		if err != nil {
+			Http.ResponseJson(w, err, err.Status, srv.config.Http)
+			return
+		}
+		ex := Json.CopyTo(reqBody, &req)
+		if ex != nil {
+			err = Http.WrapError(ex, "failed to load GraphQL request", http.StatusBadRequest)
+			Http.ResponseJson(w, err, err.Status, srv.config.Http)
+			return
+		}
+	default:
+		err = Http.NewHttpError(fmt.Sprintf("method [%s] not supported on [%s]", r.Method, Common.KeyGraphQl), http.StatusMethodNotAllowed)
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	srv.log.Printf("GraphQL request, operation=[%s]", req.OperationName)
+	result := schema.Execute(&req)
+	status := http.StatusOK
+	if result.Data == nil {
+		status = http.StatusBadRequest
+	}
+	Http.ResponseJson(w, result, status, srv.config.Http)
+}
+
+// load newline-delimited records from request body, response with counts and errors of each failed line
+func (srv *Server) handleImport(w http.ResponseWriter, r *http.Request, ns *Namespace.Namespace) {
+	mode := r.URL.Query().Get(Common.QueryImportMode)
+	srv.log.Printf("import records, %s=[%s]", Common.QueryImportMode, mode)
+	result, err := ns.Data.Import(r.Body, mode, Http.GetIdentity(r))
+	if err != nil {
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	status := http.StatusOK
+	if result.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	Http.ResponseJson(w, result, status, srv.config.Http)
+}
+
+// list ids or records of dataType filtered and sorted by query,
+// return paged result {items, nextPageToken} when pageSize or pageToken is in query
+func (srv *Server) handleList(w http.ResponseWriter, r *http.Request, ns *Namespace.Namespace, dataType string) {
+	query := r.URL.Query()
+	if len(query) == 0 {
+		srv.log.Printf("list id of [%s]", dataType)
+		idList, err := ns.Data.List(dataType)
+		if err != nil {
+			Http.ResponseJson(w, err, err.Status, srv.config.Http)
+			return
+		}
+		Http.ResponseJson(w, idList, http.StatusOK, srv.config.Http)
+		return
+	}
+	listQuery, err := ns.Data.ParseListQuery(dataType, query)
+	if err != nil {
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	srv.log.Printf("list of [%s], query=[%s]", dataType, r.URL.RawQuery)
+	itemList, nextToken, err := ns.Data.Query(dataType, listQuery)
+	if err != nil {
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	if !listQuery.Paged {
+		Http.ResponseJson(w, itemList, http.StatusOK, srv.config.Http)
+		return
+	}
+	result := map[string]interface{}{
+		Common.KeyItems:         itemList,
+		Common.KeyNextPageToken: nextToken,
+	}
+	Http.ResponseJson(w, result, http.StatusOK, srv.config.Http)
+}
+
+func (srv *Server) BuildRecord(ns *Namespace.Namespace, payload map[string]interface{}, dataType string, dataId string) (*Record.Record, *Http.HttpError) {
 	if dataType == "" {
 		return nil, Http.NewHttpError(fmt.Sprintf("empty data type in path. [%s/%s]=''", Record.DataType, Record.DataId), http.StatusBadRequest)
 	}
 	if dataId == "" {
 		return nil, Http.NewHttpError(fmt.Sprintf("empty data id in path. [%s/%s]=''", Record.DataType, Record.DataId), http.StatusBadRequest)
 	}
-	schema, err := srv.data.LocalSchema(dataType, "")
+	schema, err := ns.Data.LocalSchema(dataType, "")
 	if err != nil {
 		return nil, err
 	}
@@ -261,7 +604,7 @@ func (srv *Server) BuildRecord(payload map[string]interface{}, dataType string,
 	return record, nil
 }
 
-func (srv *Server) handlePost(w http.ResponseWriter, r *http.Request, dataType string, dataId string) {
+func (srv *Server) handlePost(w http.ResponseWriter, r *http.Request, ns *Namespace.Namespace, dataType string, dataId string) {
 	reqBody, err := Http.LoadRequest(r)
 	if err != nil {
 		Http.ResponseJson(w, err, err.Status, srv.config.Http)
@@ -289,21 +632,26 @@ func (srv *Server) handlePost(w http.ResponseWriter, r *http.Request, dataType s
 			return
 		}
 	} else {
-		record, err = srv.BuildRecord(payload, dataType, dataId)
+		record, err = srv.BuildRecord(ns, payload, dataType, dataId)
 		if err != nil {
 			Http.ResponseJson(w, err, err.Status, srv.config.Http)
 			return
 		}
 	}
-	err = srv.data.Add(record)
+	if !srv.allow(w, r, Rbac.VerbPost, record.Type, "") {
+		return
+	}
+	err = ns.Data.Add(record)
 	if err != nil {
 		Http.ResponseJson(w, err, err.Status, srv.config.Http)
 		return
 	}
+	w.Header().Set(Common.HeaderRevision, strconv.FormatInt(record.Revision, 10))
+	w.Header().Set(Common.HeaderETag, record.ETag())
 	Http.ResponseText(w, []byte(record.Id), http.StatusCreated, srv.config.Http)
 }
 
-func (srv *Server) handlePut(w http.ResponseWriter, r *http.Request, dataType string, dataId string) {
+func (srv *Server) handlePut(w http.ResponseWriter, r *http.Request, ns *Namespace.Namespace, dataType string, dataId string) {
 	reqBody, err := Http.LoadRequest(r)
 	if err != nil {
 		Http.ResponseJson(w, err, err.Status, srv.config.Http)
@@ -322,24 +670,33 @@ func (srv *Server) handlePut(w http.ResponseWriter, r *http.Request, dataType st
 			return
 		}
 	} else {
-		record, err = srv.BuildRecord(payload, dataType, dataId)
+		record, err = srv.BuildRecord(ns, payload, dataType, dataId)
 		if err != nil {
 			Http.ResponseJson(w, err, err.Status, srv.config.Http)
 			return
 		}
 	}
-	err = srv.data.Set(dataType, dataId, record)
+	if !srv.allow(w, r, Rbac.VerbPut, record.Type, "") {
+		return
+	}
+	if dataType != "" && dataType != record.Type && !srv.allow(w, r, Rbac.VerbPut, dataType, "") {
+		return
+	}
+	err = ns.Data.SetIfMatch(dataType, dataId, record, r.Header.Get(Common.HeaderIfMatch))
 	if err != nil {
 		Http.ResponseJson(w, err, err.Status, srv.config.Http)
 		return
 	}
+	w.Header().Set(Common.HeaderRevision, strconv.FormatInt(record.Revision, 10))
+	w.Header().Set(Common.HeaderETag, record.ETag())
 	Http.ResponseText(w, []byte(record.Id), http.StatusCreated, srv.config.Http)
 }
 
-func (srv *Server) handleDelete(w http.ResponseWriter, dataType string, dataId string) {
-	err := srv.data.Delete(dataType, dataId)
+func (srv *Server) handleDelete(w http.ResponseWriter, r *http.Request, ns *Namespace.Namespace, dataType string, dataId string) {
+	err := ns.Data.DeleteIfMatch(dataType, dataId, r.Header.Get(Common.HeaderIfMatch))
 	if err != nil {
 		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
 	}
 	result := map[string]string{
 		"result": fmt.Sprintf("item [type/id]=[%s/%s] deleted", dataType, dataId),
@@ -347,7 +704,25 @@ func (srv *Server) handleDelete(w http.ResponseWriter, dataType string, dataId s
 	Http.ResponseJson(w, result, http.StatusAccepted, srv.config.Http)
 }
 
-func (srv *Server) handlePatch(w http.ResponseWriter, r *http.Request, dataType string, idPath string) {
+func (srv *Server) handleUndelete(w http.ResponseWriter, ns *Namespace.Namespace, idPath string) {
+	dataType, dataId := Util.ParsePath(idPath)
+	if dataType == "" || dataId == "" {
+		err := Http.NewHttpError(fmt.Sprintf("invalid path [%s/%s], expect format=[%s/{dataType}/{dataId}]", Common.KeyUndelete, idPath, Common.KeyUndelete), http.StatusBadRequest)
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	srv.log.Printf("undelete [%s/%s]", dataType, dataId)
+	record, err := ns.Data.Undelete(dataType, dataId)
+	if err != nil {
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	w.Header().Set(Common.HeaderRevision, strconv.FormatInt(record.Revision, 10))
+	w.Header().Set(Common.HeaderETag, record.ETag())
+	Http.ResponseJson(w, record.Map(), http.StatusOK, srv.config.Http)
+}
+
+func (srv *Server) handlePatch(w http.ResponseWriter, r *http.Request, ns *Namespace.Namespace, dataType string, idPath string) {
 	payload, e := Http.LoadRequest(r)
 	if e != nil {
 		srv.log.Printf("PATCH: [%s/%s] failed to load request, Error: %s", dataType, idPath, e)
@@ -355,11 +730,61 @@ func (srv *Server) handlePatch(w http.ResponseWriter, r *http.Request, dataType
 		return
 	}
 	headers := Http.ParseHeaders(r)
+	// paths changed by patch are authorized in handler
+	headers[Common.KeyIdentity] = Http.GetIdentity(r)
 	srv.log.Printf("PATCH [%s/%s]: call handler Patch", dataType, idPath)
-	response, e := srv.data.Patch(dataType, idPath, headers, payload)
+	response, e := ns.Data.Patch(dataType, idPath, headers, payload)
+	if e != nil {
+		Http.ResponseJson(w, e, e.Status, srv.config.Http)
+		return
+	}
+	etag, e := DataHandler.ETag(response)
 	if e != nil {
 		Http.ResponseJson(w, e, e.Status, srv.config.Http)
 		return
 	}
+	w.Header().Set(Common.HeaderETag, etag)
 	Http.ResponseJson(w, response, http.StatusAccepted, srv.config.Http)
 }
+
+// admin api of namespaces: GET /namespace to list, POST|DELETE /namespace/{name} to create or delete
+func (srv *Server) handleNamespace(w http.ResponseWriter, r *http.Request, idPath string) {
+	name, nextPath := Util.ParsePath(idPath)
+	if nextPath != "" {
+		err := Http.NewHttpError(fmt.Sprintf("invalid path [%s/%s], expect format=[%s/{name}]", Common.KeyNamespace, idPath, Common.KeyNamespace), http.StatusBadRequest)
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	var err *Http.HttpError
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		Http.ResponseJson(w, srv.namespaces.List(), http.StatusOK, srv.config.Http)
+		return
+	case r.Method == http.MethodGet:
+		_, err = srv.namespaces.Get(name)
+		if err == nil {
+			Http.ResponseText(w, []byte(name), http.StatusOK, srv.config.Http)
+			return
+		}
+	case r.Method == http.MethodPost && name != "":
+		srv.log.Printf("create namespace [%s]", name)
+		err = srv.namespaces.Create(name)
+		if err == nil {
+			Http.ResponseText(w, []byte(name), http.StatusCreated, srv.config.Http)
+			return
+		}
+	case r.Method == http.MethodDelete && name != "":
+		srv.log.Printf("delete namespace [%s]", name)
+		err = srv.namespaces.Delete(name)
+		if err == nil {
+			result := map[string]string{
+				"result": fmt.Sprintf("namespace [%s] deleted", name),
+			}
+			Http.ResponseJson(w, result, http.StatusAccepted, srv.config.Http)
+			return
+		}
+	default:
+		err = Http.NewHttpError(fmt.Sprintf("method [%s] on [%s/%s] not supported", r.Method, Common.KeyNamespace, idPath), http.StatusMethodNotAllowed)
+	}
+	Http.ResponseJson(w, err, err.Status, srv.config.Http)
+}
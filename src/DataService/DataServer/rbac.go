@@ -0,0 +1,149 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataServer
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"DataService/Common"
+	"DataService/Rbac"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Util"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+// authorize request on target type and path of the url, response StatusForbidden when denied.
+// POST and PUT of records are checked on type of record in body, PATCH on each changed path in Handler.Patch,
+// import on type of each imported record in Handler.Import
+func (srv *Server) authorize(w http.ResponseWriter, r *http.Request, dataType string, idPath string) bool {
+	verb := strings.ToLower(r.Method)
+	idPath = strings.SplitN(idPath, "?", 2)[0]
+	target := dataType
+	dataPath := ""
+	switch dataType {
+	case Common.KeyGraphQl:
+		// query on any type
+		verb = Rbac.VerbGet
+		target = Rbac.Wildcard
+	case Common.KeyExport:
+		// types of export all are filtered by handleExport
+		if idPath == "" {
+			target = Rbac.Wildcard
+		} else {
+			target = idPath
+		}
+	case Common.KeyStream:
+		// events of all types are filtered by handleStream
+		var nextPath string
+		target, nextPath = Util.ParsePath(idPath)
+		_, dataPath = Util.ParsePath(nextPath)
+		if target == "" {
+			target = Rbac.Wildcard
+		}
+	case Common.KeyUndelete:
+		target, _ = Util.ParsePath(idPath)
+	case Common.KeyRole, Common.KeyRoleBinding, Common.KeyNamespace, Common.KeyImport, Common.KeyJournal, Common.KeyWebhook:
+	default:
+		if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+			return true
+		}
+		if r.Method == http.MethodGet {
+			_, dataPath = Util.ParsePath(idPath)
+		}
+	}
+	return srv.allow(w, r, verb, target, dataPath)
+}
+
+func (srv *Server) allow(w http.ResponseWriter, r *http.Request, verb string, dataType string, dataPath string) bool {
+	err := srv.rbac.Check(Http.GetIdentity(r), verb, dataType, dataPath)
+	if err != nil {
+		srv.log.Printf("access denied. %s", err.Message[0])
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return false
+	}
+	return true
+}
+
+// admin api of role based access control: GET /{role|roleBinding}[/{name}] to list or get,
+// PUT|DELETE /{role|roleBinding}/{name} to set or delete
+func (srv *Server) handleRbac(w http.ResponseWriter, r *http.Request, dataType string, idPath string) {
+	name, nextPath := Util.ParsePath(idPath)
+	if nextPath != "" {
+		err := Http.NewHttpError(fmt.Sprintf("invalid path [%s/%s], expect format=[%s/{name}]", dataType, idPath, dataType), http.StatusBadRequest)
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	var err *Http.HttpError
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		var nameList []string
+		nameList, err = srv.rbac.List(dataType)
+		if err == nil {
+			Http.ResponseJson(w, nameList, http.StatusOK, srv.config.Http)
+			return
+		}
+	case r.Method == http.MethodGet:
+		var record *Record.Record
+		record, err = srv.rbac.Get(dataType, name)
+		if err == nil {
+			Http.ResponseJson(w, record.Map(), http.StatusOK, srv.config.Http)
+			return
+		}
+	case r.Method == http.MethodPut && name != "":
+		var reqBody interface{}
+		reqBody, err = Http.LoadRequest(r)
+		if err != nil {
+			break
+		}
+		payload, ok := reqBody.(map[string]interface{})
+		if !ok {
+			err = Http.NewHttpError("failed to parse request into JSON object", http.StatusBadRequest)
+			break
+		}
+		srv.log.Printf("set %s [%s]", dataType, name)
+		_, err = srv.rbac.Set(dataType, name, payload)
+		if err == nil {
+			Http.ResponseText(w, []byte(name), http.StatusCreated, srv.config.Http)
+			return
+		}
+	case r.Method == http.MethodDelete && name != "":
+		srv.log.Printf("delete %s [%s]", dataType, name)
+		err = srv.rbac.Delete(dataType, name)
+		if err == nil {
+			result := map[string]string{
+				"result": fmt.Sprintf("%s [%s] deleted", dataType, name),
+			}
+			Http.ResponseJson(w, result, http.StatusAccepted, srv.config.Http)
+			return
+		}
+	default:
+		err = Http.NewHttpError(fmt.Sprintf("method [%s] on [%s/%s] not supported", r.Method, dataType, idPath), http.StatusMethodNotAllowed)
+	}
+	Http.ResponseJson(w, err, err.Status, srv.config.Http)
+}
@@ -0,0 +1,196 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataServer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"DataService/Common"
+	"DataService/DataJournal"
+	"DataService/Namespace"
+	"DataService/Rbac"
+
+	"github.com/salesforce/UniTAO/lib/Util"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+const (
+	EventChange = "change"
+	EventError  = "error"
+	// keep idle stream alive through proxies
+	StreamHeartbeat = 15 * time.Second
+)
+
+// stream journal events of all records, a type, a record, or changes covered by SchemaPath of a record.
+// Server-Sent Events by default, websocket text messages when request asks to upgrade
+func (srv *Server) handleStream(w http.ResponseWriter, r *http.Request, ns *Namespace.Namespace, idPath string) {
+	idPath = strings.SplitN(idPath, "?", 2)[0]
+	filter := DataJournal.ChangeFilter{}
+	var nextPath, dataPath string
+	filter.DataType, nextPath = Util.ParsePath(idPath)
+	filter.DataId, dataPath = Util.ParsePath(nextPath)
+	if dataPath != "" {
+		targets, err := ns.Data.PathTargets(filter.DataType, filter.DataId, dataPath)
+		if err != nil {
+			Http.ResponseJson(w, err, err.Status, srv.config.Http)
+			return
+		}
+		filter.Targets = targets
+	}
+	// type of path is authorized on request, events of other types are checked one by one
+	if visible := srv.rbac.Visible(Http.GetIdentity(r), Rbac.VerbGet); visible != nil {
+		filter.Allow = func(dataType string) bool {
+			return dataType == filter.DataType || visible(dataType)
+		}
+	}
+	since := r.Header.Get(Common.HeaderLastEventId)
+	if query := r.URL.Query(); query.Has(Common.QuerySince) {
+		since = query.Get(Common.QuerySince)
+	}
+	sub, replay, err := ns.Journal.Subscribe(&filter, since)
+	if err != nil {
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	defer sub.Close()
+	srv.log.Printf("stream journal events of [%s], since=[%s], replay [%d] events", idPath, since, len(replay))
+	if Http.IsWebSocket(r) {
+		srv.streamWebSocket(w, r, sub, replay)
+		return
+	}
+	srv.streamEvents(w, r, sub, replay)
+}
+
+func (srv *Server) streamEvents(w http.ResponseWriter, r *http.Request, sub *DataJournal.Subscription, replay []*DataJournal.ChangeEvent) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		err := Http.NewHttpError("connection does not support streaming", http.StatusInternalServerError)
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	Http.ResponseStream(w, Common.ContentTypeEventStream, http.StatusOK, srv.config.Http)
+	flusher.Flush()
+	write := func(event *DataJournal.ChangeEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.Position, EventChange, data)
+		flusher.Flush()
+		return err
+	}
+	for _, event := range replay {
+		if write(event) != nil {
+			return
+		}
+	}
+	heartbeat := time.NewTicker(StreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event, ok := <-sub.Events:
+			if !ok {
+				if err := sub.Err(); err != nil {
+					srv.log.Printf("stream closed. Error: %s", err.Message)
+					data, _ := json.Marshal(err)
+					fmt.Fprintf(w, "event: %s\ndata: %s\n\n", EventError, data)
+					flusher.Flush()
+				}
+				return
+			}
+			if write(event) != nil {
+				return
+			}
+		}
+	}
+}
+
+func (srv *Server) streamWebSocket(w http.ResponseWriter, r *http.Request, sub *DataJournal.Subscription, replay []*DataJournal.ChangeEvent) {
+	ws, err := Http.UpgradeWebSocket(w, r, srv.config.Http.AllowOrigins)
+	if err != nil {
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	defer ws.Close()
+	// messages from client are ignored, read to answer ping and to know when it is closed
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			_, _, ex := ws.ReadMessage()
+			if ex != nil {
+				return
+			}
+		}
+	}()
+	write := func(event *DataJournal.ChangeEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return ws.WriteText(data)
+	}
+	for _, event := range replay {
+		if write(event) != nil {
+			return
+		}
+	}
+	heartbeat := time.NewTicker(StreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-heartbeat.C:
+			if ws.WriteMessage(Http.OpPing, nil) != nil {
+				return
+			}
+		case event, ok := <-sub.Events:
+			if !ok {
+				if err := sub.Err(); err != nil {
+					srv.log.Printf("stream closed. Error: %s", err.Message)
+					ws.WriteClose(Http.CloseTryAgainLater, err.Message[0])
+				} else {
+					ws.WriteClose(Http.CloseGoingAway, "")
+				}
+				return
+			}
+			if write(event) != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataServer
+
+import (
+	"fmt"
+	"net/http"
+
+	"DataService/Common"
+	"DataService/DataJournal/Process"
+	"DataService/Namespace"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Util"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+// admin api of outbound webhooks in namespace: GET /webhook[/{name}] to list or get with secret redacted,
+// PUT|DELETE /webhook/{name} to set or delete
+func (srv *Server) handleWebhook(w http.ResponseWriter, r *http.Request, ns *Namespace.Namespace, idPath string) {
+	name, nextPath := Util.ParsePath(idPath)
+	if nextPath != "" {
+		err := Http.NewHttpError(fmt.Sprintf("invalid path [%s/%s], expect format=[%s/{name}]", Common.KeyWebhook, idPath, Common.KeyWebhook), http.StatusBadRequest)
+		Http.ResponseJson(w, err, err.Status, srv.config.Http)
+		return
+	}
+	var err *Http.HttpError
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		var nameList []string
+		nameList, err = Process.ListWebhooks(ns.Data)
+		if err == nil {
+			Http.ResponseJson(w, nameList, http.StatusOK, srv.config.Http)
+			return
+		}
+	case r.Method == http.MethodGet:
+		var record *Record.Record
+		record, err = Process.GetWebhook(ns.Data, name)
+		if err == nil {
+			Http.ResponseJson(w, record.Map(), http.StatusOK, srv.config.Http)
+			return
+		}
+	case r.Method == http.MethodPut && name != "":
+		var reqBody interface{}
+		reqBody, err = Http.LoadRequest(r)
+		if err != nil {
+			break
+		}
+		payload, ok := reqBody.(map[string]interface{})
+		if !ok {
+			err = Http.NewHttpError("failed to parse request into JSON object", http.StatusBadRequest)
+			break
+		}
+		srv.log.Printf("set %s [%s]", Common.KeyWebhook, name)
+		_, err = Process.SetWebhook(ns.Data, name, payload)
+		if err == nil {
+			Http.ResponseText(w, []byte(name), http.StatusCreated, srv.config.Http)
+			return
+		}
+	case r.Method == http.MethodDelete && name != "":
+		srv.log.Printf("delete %s [%s]", Common.KeyWebhook, name)
+		err = Process.DeleteWebhook(ns.Data, name)
+		if err == nil {
+			result := map[string]string{
+				"result": fmt.Sprintf("%s [%s] deleted", Common.KeyWebhook, name),
+			}
+			Http.ResponseJson(w, result, http.StatusAccepted, srv.config.Http)
+			return
+		}
+	default:
+		err = Http.NewHttpError(fmt.Sprintf("method [%s] on [%s/%s] not supported", r.Method, Common.KeyWebhook, idPath), http.StatusMethodNotAllowed)
+	}
+	Http.ResponseJson(w, err, err.Status, srv.config.Http)
+}
@@ -0,0 +1,148 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package GraphQl
+
+// Document of a GraphQL request, operations and fragments are kept in order of request
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+type Operation struct {
+	Type         string
+	Name         string
+	Variables    []*VariableDef
+	Directives   []*Directive
+	SelectionSet []Selection
+	Loc          Location
+}
+
+type VariableDef struct {
+	Name    string
+	Type    *TypeRef
+	Default interface{}
+	Loc     Location
+}
+
+// TypeRef is a named type, or list of Elem when Name is empty
+type TypeRef struct {
+	Name    string
+	Elem    *TypeRef
+	NonNull bool
+}
+
+// Selection is one of *Field, *FragmentSpread and *InlineFragment
+type Selection interface{}
+
+type Field struct {
+	Alias        string
+	Name         string
+	Arguments    []*Argument
+	Directives   []*Directive
+	SelectionSet []Selection
+	Loc          Location
+}
+
+type Argument struct {
+	Name  string
+	Value interface{}
+	Loc   Location
+}
+
+type Directive struct {
+	Name      string
+	Arguments []*Argument
+	Loc       Location
+}
+
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+	Loc        Location
+}
+
+type InlineFragment struct {
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+	Loc           Location
+}
+
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+	Loc           Location
+}
+
+// values of argument are string, int64, float64, bool, nil, []interface{}, map[string]interface{},
+// or Variable and Enum
+type Variable string
+
+type Enum string
+
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (t *TypeRef) String() string {
+	result := t.Name
+	if t.Elem != nil {
+		result = "[" + t.Elem.String() + "]"
+	}
+	if t.NonNull {
+		result += "!"
+	}
+	return result
+}
+
+// Nullable return same type without non-null
+func (t *TypeRef) Nullable() *TypeRef {
+	if !t.NonNull {
+		return t
+	}
+	return &TypeRef{
+		Name: t.Name,
+		Elem: t.Elem,
+	}
+}
+
+// NamedType return name of type, or of list item type
+func (t *TypeRef) NamedType() string {
+	if t.Elem != nil {
+		return t.Elem.NamedType()
+	}
+	return t.Name
+}
+
+func (f *Field) Key() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
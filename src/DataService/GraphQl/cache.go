@@ -0,0 +1,91 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package GraphQl
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"DataService/DataHandler"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+// Cache keep GraphQL schema built for table of each data handler,
+// schema is built again only when a schema record is added, changed or removed
+type Cache struct {
+	lock    sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	handler *DataHandler.Handler
+	key     string
+	schema  *Schema
+}
+
+func NewCache() *Cache {
+	return &Cache{
+		entries: map[string]*cacheEntry{},
+	}
+}
+
+// Get return schema of data handler, schema records are compared on revision with the ones cached schema is built from
+func (c *Cache) Get(data *DataHandler.Handler) (*Schema, *Http.HttpError) {
+	schemaList, err := querySchemas(data)
+	if err != nil {
+		return nil, err
+	}
+	key := schemaKey(schemaList)
+	table := data.Config.DataTable.Data
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// handler of table is changed when namespace is created again
+	if entry, ok := c.entries[table]; ok && entry.handler == data && entry.key == key {
+		return entry.schema, nil
+	}
+	schema, err := build(data, schemaList)
+	if err != nil {
+		return nil, err
+	}
+	c.entries[table] = &cacheEntry{
+		handler: data,
+		key:     key,
+		schema:  schema,
+	}
+	return schema, nil
+}
+
+// schemaKey is id and revision of each schema record in order of id
+func schemaKey(schemaList []map[string]interface{}) string {
+	keyList := make([]string, 0, len(schemaList))
+	for _, schemaData := range schemaList {
+		keyList = append(keyList, fmt.Sprintf("%v@%v:%t", schemaData[Record.DataId], schemaData[Record.Revision], DataHandler.IsDeleted(schemaData)))
+	}
+	return strings.Join(keyList, ",")
+}
@@ -0,0 +1,592 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package GraphQl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+const (
+	DirectiveSkip    = "skip"
+	DirectiveInclude = "include"
+	ArgIf            = "if"
+)
+
+// Request body of POST /graphql
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response of request, Data is nil when request failed before execution
+type Response struct {
+	Data   *Object  `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Location    `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+	// httpStatus of failed request to data handler
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Object is result of selection set, keys are in order of selection
+type Object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+type execution struct {
+	schema    *Schema
+	doc       *Document
+	variables map[string]interface{}
+	errors    []*Error
+	// records loaded in this request by {type}/{id}
+	records map[string]map[string]interface{}
+}
+
+type fieldGroup struct {
+	key    string
+	fields []*Field
+}
+
+func newError(message string, loc Location) *Error {
+	return &Error{
+		Message:   message,
+		Locations: []Location{loc},
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newObject() *Object {
+	return &Object{
+		values: map[string]interface{}{},
+	}
+}
+
+func (o *Object) Set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *Object) Get(key string) interface{} {
+	return o.values[key]
+}
+
+func (o *Object) Keys() []string {
+	return o.keys
+}
+
+func (o *Object) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteString("{")
+	for idx, key := range o.keys {
+		if idx > 0 {
+			buf.WriteString(",")
+		}
+		keyBytes, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		valueBytes, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(keyBytes)
+		buf.WriteString(":")
+		buf.Write(valueBytes)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// Execute query operation of request. Data is nil when the request is invalid,
+// otherwise errors of fields are returned with partial data
+func (s *Schema) Execute(req *Request) *Response {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{err}}
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{err}}
+	}
+	if op.Type != OpQuery {
+		return &Response{Errors: []*Error{newError(fmt.Sprintf("operation [%s] is not supported", op.Type), op.Loc)}}
+	}
+	errList := s.validate(doc, op)
+	if len(errList) > 0 {
+		return &Response{Errors: errList}
+	}
+	ex := execution{
+		schema:  s,
+		doc:     doc,
+		records: map[string]map[string]interface{}{},
+	}
+	ex.variables, errList = coerceVariables(op, req.Variables)
+	if len(errList) > 0 {
+		return &Response{Errors: errList}
+	}
+	data, _ := ex.executeSelectionSet(s.Query, nil, op.SelectionSet, nil)
+	return &Response{
+		Data:   data,
+		Errors: ex.errors,
+	}
+}
+
+func selectOperation(doc *Document, name string) (*Operation, *Error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, newError("operationName is required when document has more than one operation", doc.Operations[1].Loc)
+		}
+		return doc.Operations[0], nil
+	}
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("operation [%s] is not found in document", name)}
+}
+
+func (ex *execution) addError(err error, loc Location, path []interface{}) {
+	fieldErr := Error{
+		Message:   err.Error(),
+		Locations: []Location{loc},
+		Path:      path,
+	}
+	if httpErr, ok := err.(*Http.HttpError); ok {
+		fieldErr.Message = strings.Join(httpErr.Message, "\n")
+		fieldErr.Extensions = map[string]interface{}{
+			"httpStatus": httpErr.Status,
+		}
+	}
+	ex.errors = append(ex.errors, &fieldErr)
+}
+
+func subPath(path []interface{}, key interface{}) []interface{} {
+	result := make([]interface{}, 0, len(path)+1)
+	result = append(result, path...)
+	return append(result, key)
+}
+
+// executeSelectionSet return false when a non-null field is null, the error is already added
+func (ex *execution) executeSelectionSet(objType *ObjectType, source map[string]interface{}, selectionSet []Selection, path []interface{}) (*Object, bool) {
+	groups := []*fieldGroup{}
+	ex.collectFields(objType, selectionSet, &groups, map[string]*fieldGroup{}, map[string]bool{})
+	result := newObject()
+	for _, group := range groups {
+		field := group.fields[0]
+		fieldPath := subPath(path, group.key)
+		if field.Name == KeyTypeName {
+			result.Set(group.key, objType.Name)
+			continue
+		}
+		fieldDef := objType.Field(field.Name)
+		args, err := ex.coerceArgs(fieldDef, field)
+		var value interface{}
+		if err == nil {
+			value, err = fieldDef.resolve(source, args)
+		}
+		if err != nil {
+			ex.addError(err, field.Loc, fieldPath)
+			if fieldDef.Type.NonNull {
+				return nil, false
+			}
+			result.Set(group.key, nil)
+			continue
+		}
+		selectionSet := []Selection{}
+		for _, f := range group.fields {
+			selectionSet = append(selectionSet, f.SelectionSet...)
+		}
+		completed, ok := ex.complete(fieldDef.Type, value, selectionSet, fieldPath, field.Loc)
+		if !ok {
+			return nil, false
+		}
+		result.Set(group.key, completed)
+	}
+	return result, true
+}
+
+func (ex *execution) collectFields(objType *ObjectType, selectionSet []Selection, groups *[]*fieldGroup, groupMap map[string]*fieldGroup, visited map[string]bool) {
+	for _, selection := range selectionSet {
+		switch s := selection.(type) {
+		case *Field:
+			if !ex.include(s.Directives) {
+				continue
+			}
+			group, ok := groupMap[s.Key()]
+			if !ok {
+				group = &fieldGroup{key: s.Key()}
+				groupMap[s.Key()] = group
+				*groups = append(*groups, group)
+			}
+			group.fields = append(group.fields, s)
+		case *FragmentSpread:
+			if visited[s.Name] || !ex.include(s.Directives) {
+				continue
+			}
+			visited[s.Name] = true
+			fragment := ex.doc.Fragments[s.Name]
+			if fragment.TypeCondition != objType.Name {
+				continue
+			}
+			ex.collectFields(objType, fragment.SelectionSet, groups, groupMap, visited)
+		case *InlineFragment:
+			if !ex.include(s.Directives) {
+				continue
+			}
+			if s.TypeCondition != "" && s.TypeCondition != objType.Name {
+				continue
+			}
+			ex.collectFields(objType, s.SelectionSet, groups, groupMap, visited)
+		}
+	}
+}
+
+// include check directives @skip(if:) and @include(if:)
+func (ex *execution) include(directives []*Directive) bool {
+	for _, directive := range directives {
+		var value interface{}
+		for _, arg := range directive.Arguments {
+			if arg.Name == ArgIf {
+				value = substitute(arg.Value, ex.variables)
+			}
+		}
+		condition, _ := value.(bool)
+		if directive.Name == DirectiveSkip && condition {
+			return false
+		}
+		if directive.Name == DirectiveInclude && !condition {
+			return false
+		}
+	}
+	return true
+}
+
+func (ex *execution) coerceArgs(fieldDef *FieldDef, field *Field) (map[string]interface{}, *Http.HttpError) {
+	result := map[string]interface{}{}
+	for _, argDef := range fieldDef.Args {
+		var arg *Argument
+		for _, a := range field.Arguments {
+			if a.Name == argDef.Name {
+				arg = a
+			}
+		}
+		if arg != nil {
+			if name, isVar := arg.Value.(Variable); isVar {
+				if _, ok := ex.variables[string(name)]; !ok {
+					arg = nil
+				}
+			}
+		}
+		if arg == nil {
+			if argDef.Type.NonNull {
+				return nil, Http.NewHttpError(fmt.Sprintf("argument [%s] of field [%s] is required", argDef.Name, field.Name), http.StatusBadRequest)
+			}
+			continue
+		}
+		value, err := coerceInput(argDef.Type, substitute(arg.Value, ex.variables))
+		if err != nil {
+			return nil, Http.NewHttpError(fmt.Sprintf("invalid argument [%s] of field [%s], %s", argDef.Name, field.Name, err), http.StatusBadRequest)
+		}
+		result[argDef.Name] = value
+	}
+	return result, nil
+}
+
+// complete value of field by its type, return false when null of non-null type is propagated to parent
+func (ex *execution) complete(fieldType *TypeRef, value interface{}, selectionSet []Selection, path []interface{}, loc Location) (interface{}, bool) {
+	if !fieldType.NonNull {
+		result, ok := ex.completeValue(fieldType, value, selectionSet, path, loc)
+		if !ok {
+			return nil, true
+		}
+		return result, true
+	}
+	result, ok := ex.completeValue(fieldType.Nullable(), value, selectionSet, path, loc)
+	if !ok {
+		return nil, false
+	}
+	if result == nil {
+		ex.addError(fmt.Errorf("null value of non-null type [%s]", fieldType), loc, path)
+		return nil, false
+	}
+	return result, true
+}
+
+func (ex *execution) completeValue(fieldType *TypeRef, value interface{}, selectionSet []Selection, path []interface{}, loc Location) (interface{}, bool) {
+	if value == nil {
+		return nil, true
+	}
+	if fieldType.Elem != nil {
+		list, ok := value.([]interface{})
+		if !ok {
+			ex.addError(fmt.Errorf("expect list value of type [%s], got [%T]", fieldType, value), loc, path)
+			return nil, false
+		}
+		result := make([]interface{}, 0, len(list))
+		for idx, item := range list {
+			itemResult, ok := ex.complete(fieldType.Elem, item, selectionSet, subPath(path, idx), loc)
+			if !ok {
+				return nil, false
+			}
+			result = append(result, itemResult)
+		}
+		return result, true
+	}
+	if objType, ok := ex.schema.Types[fieldType.Name]; ok {
+		source, err := ex.source(value)
+		if err != nil {
+			ex.addError(err, loc, path)
+			return nil, false
+		}
+		result, ok := ex.executeSelectionSet(objType, source, selectionSet, path)
+		if !ok {
+			return nil, false
+		}
+		return result, true
+	}
+	result, err := serialize(fieldType.Name, value)
+	if err != nil {
+		ex.addError(err, loc, path)
+		return nil, false
+	}
+	return result, true
+}
+
+// source object of value, reference is loaded as record through inventory
+func (ex *execution) source(value interface{}) (map[string]interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, nil
+	case recordRef:
+		data, err := ex.record(v.dataType, v.dataId)
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("expect object value, got [%T]", value)
+}
+
+func (ex *execution) record(dataType string, dataId string) (map[string]interface{}, *Http.HttpError) {
+	key := fmt.Sprintf("%s/%s", dataType, dataId)
+	if data, ok := ex.records[key]; ok {
+		return data, nil
+	}
+	record, err := ex.schema.handler.Inventory.Get(dataType, dataId)
+	if err != nil {
+		return nil, err
+	}
+	data := record.Map()
+	ex.records[key] = data
+	return data, nil
+}
+
+func coerceVariables(op *Operation, values map[string]interface{}) (map[string]interface{}, []*Error) {
+	result := map[string]interface{}{}
+	errList := []*Error{}
+	for _, varDef := range op.Variables {
+		value, ok := values[varDef.Name]
+		if !ok && varDef.Default != nil {
+			value, ok = varDef.Default, true
+		}
+		if !ok {
+			if varDef.Type.NonNull {
+				errList = append(errList, newError(fmt.Sprintf("variable [$%s] of type [%s] is required", varDef.Name, varDef.Type), varDef.Loc))
+			}
+			continue
+		}
+		coerced, err := coerceInput(varDef.Type, value)
+		if err != nil {
+			errList = append(errList, newError(fmt.Sprintf("invalid variable [$%s], %s", varDef.Name, err), varDef.Loc))
+			continue
+		}
+		result[varDef.Name] = coerced
+	}
+	return result, errList
+}
+
+// substitute variables in argument value
+func substitute(value interface{}, variables map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case Variable:
+		return variables[string(v)]
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			result = append(result, substitute(item, variables))
+		}
+		return result
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, item := range v {
+			result[key] = substitute(item, variables)
+		}
+		return result
+	}
+	return value
+}
+
+// coerceInput check value of argument or variable against input type
+func coerceInput(inputType *TypeRef, value interface{}) (interface{}, error) {
+	if value == nil {
+		if inputType.NonNull {
+			return nil, fmt.Errorf("null value of non-null type [%s]", inputType)
+		}
+		return nil, nil
+	}
+	if inputType.Elem != nil {
+		list, ok := value.([]interface{})
+		if !ok {
+			list = []interface{}{value}
+		}
+		result := make([]interface{}, 0, len(list))
+		for _, item := range list {
+			itemValue, err := coerceInput(inputType.Elem, item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, itemValue)
+		}
+		return result, nil
+	}
+	switch inputType.Name {
+	case TypeString:
+		if str, ok := value.(string); ok {
+			return str, nil
+		}
+	case TypeId:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case int64:
+			return fmt.Sprint(v), nil
+		case float64:
+			if v == math.Trunc(v) {
+				return fmt.Sprint(int64(v)), nil
+			}
+		}
+	case TypeInt:
+		if num, ok := toFloat(value); ok && num == math.Trunc(num) {
+			return int64(num), nil
+		}
+	case TypeFloat:
+		if num, ok := toFloat(value); ok {
+			return num, nil
+		}
+	case TypeBoolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case TypeJson:
+		return jsonValue(value), nil
+	default:
+		return nil, fmt.Errorf("type [%s] is not an input type", inputType.Name)
+	}
+	return nil, fmt.Errorf("expect value of type [%s], got [%v]", inputType.Name, value)
+}
+
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case Enum:
+		return string(v)
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			result = append(result, jsonValue(item))
+		}
+		return result
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, item := range v {
+			result[key] = jsonValue(item)
+		}
+		return result
+	}
+	return value
+}
+
+// serialize scalar value of record, Int is not limited to 32 bits as integer attribute of schema
+func serialize(typeName string, value interface{}) (interface{}, error) {
+	switch typeName {
+	case TypeString, TypeId:
+		if str, ok := value.(string); ok {
+			return str, nil
+		}
+	case TypeInt:
+		if num, ok := toFloat(value); ok && num == math.Trunc(num) {
+			return int64(num), nil
+		}
+	case TypeFloat:
+		if num, ok := toFloat(value); ok {
+			return num, nil
+		}
+	case TypeBoolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case TypeJson:
+		return value, nil
+	}
+	return nil, fmt.Errorf("expect value of type [%s], got [%v]", typeName, value)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		num, err := v.Float64()
+		return num, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,796 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package GraphQl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	tokenEOF = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+const (
+	OpQuery        = "query"
+	OpMutation     = "mutation"
+	OpSubscription = "subscription"
+	KeyFragment    = "fragment"
+	KeyOn          = "on"
+	Spread         = "..."
+)
+
+type token struct {
+	kind  int
+	value string
+	loc   Location
+}
+
+type lexer struct {
+	src  []rune
+	pos  int
+	line int
+	col  int
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// Parse GraphQL query document
+func Parse(query string) (*Document, *Error) {
+	p := parser{
+		lex: &lexer{
+			src:  []rune(query),
+			line: 1,
+			col:  1,
+		},
+	}
+	err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	doc := Document{
+		Fragments: map[string]*Fragment{},
+	}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunct, "{"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.peek(tokenName, OpQuery), p.peek(tokenName, OpMutation), p.peek(tokenName, OpSubscription):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.peek(tokenName, KeyFragment):
+			fragment, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.Fragments[fragment.Name]; ok {
+				return nil, newError(fmt.Sprintf("fragment [%s] is defined more than once", fragment.Name), fragment.Loc)
+			}
+			doc.Fragments[fragment.Name] = fragment
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, newError("no operation in document", p.tok.loc)
+	}
+	return &doc, nil
+}
+
+func (l *lexer) char(offset int) rune {
+	if l.pos+offset >= len(l.src) {
+		return utf8.RuneError
+	}
+	return l.src[l.pos+offset]
+}
+
+func (l *lexer) advance() {
+	if l.src[l.pos] == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	l.pos++
+}
+
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; c {
+		case ' ', '\t', '\n', '\r', ',', '\ufeff':
+			l.advance()
+		case '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, *Error) {
+	l.skipIgnored()
+	loc := Location{Line: l.line, Column: l.col}
+	if l.pos >= len(l.src) {
+		return token{kind: tokenEOF, loc: loc}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case strings.ContainsRune("!$&()[]{}:=@|", c):
+		l.advance()
+		return token{kind: tokenPunct, value: string(c), loc: loc}, nil
+	case c == '.':
+		if l.char(1) != '.' || l.char(2) != '.' {
+			return token{}, newError("unexpected character [.]", loc)
+		}
+		l.advance()
+		l.advance()
+		l.advance()
+		return token{kind: tokenPunct, value: Spread, loc: loc}, nil
+	case isNameStart(c):
+		start := l.pos
+		for l.pos < len(l.src) && isNameChar(l.src[l.pos]) {
+			l.advance()
+		}
+		return token{kind: tokenName, value: string(l.src[start:l.pos]), loc: loc}, nil
+	case c == '-' || isDigit(c):
+		return l.number(loc)
+	case c == '"':
+		if l.char(1) == '"' && l.char(2) == '"' {
+			return l.blockString(loc)
+		}
+		return l.string(loc)
+	}
+	return token{}, newError(fmt.Sprintf("unexpected character [%c]", c), loc)
+}
+
+func (l *lexer) digits() int {
+	count := 0
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.advance()
+		count++
+	}
+	return count
+}
+
+func (l *lexer) number(loc Location) (token, *Error) {
+	start := l.pos
+	kind := tokenInt
+	if l.src[l.pos] == '-' {
+		l.advance()
+	}
+	if l.digits() == 0 {
+		return token{}, newError("invalid number, expect digit", loc)
+	}
+	if l.char(0) == '.' {
+		kind = tokenFloat
+		l.advance()
+		if l.digits() == 0 {
+			return token{}, newError("invalid number, expect digit after [.]", loc)
+		}
+	}
+	if c := l.char(0); c == 'e' || c == 'E' {
+		kind = tokenFloat
+		l.advance()
+		if c := l.char(0); c == '+' || c == '-' {
+			l.advance()
+		}
+		if l.digits() == 0 {
+			return token{}, newError("invalid number, expect digit of exponent", loc)
+		}
+	}
+	if l.pos < len(l.src) && (isNameStart(l.src[l.pos]) || l.src[l.pos] == '.') {
+		return token{}, newError(fmt.Sprintf("invalid number, unexpected character [%c]", l.src[l.pos]), loc)
+	}
+	return token{kind: kind, value: string(l.src[start:l.pos]), loc: loc}, nil
+}
+
+func (l *lexer) string(loc Location) (token, *Error) {
+	l.advance()
+	value := strings.Builder{}
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' || l.src[l.pos] == '\r' {
+			return token{}, newError("unterminated string", loc)
+		}
+		c := l.src[l.pos]
+		l.advance()
+		if c == '"' {
+			return token{kind: tokenString, value: value.String(), loc: loc}, nil
+		}
+		if c != '\\' {
+			value.WriteRune(c)
+			continue
+		}
+		if l.pos >= len(l.src) {
+			return token{}, newError("unterminated string", loc)
+		}
+		escaped := l.src[l.pos]
+		l.advance()
+		switch escaped {
+		case '"', '\\', '/':
+			value.WriteRune(escaped)
+		case 'b':
+			value.WriteRune('\b')
+		case 'f':
+			value.WriteRune('\f')
+		case 'n':
+			value.WriteRune('\n')
+		case 'r':
+			value.WriteRune('\r')
+		case 't':
+			value.WriteRune('\t')
+		case 'u':
+			if l.pos+4 > len(l.src) {
+				return token{}, newError("invalid unicode escape in string", loc)
+			}
+			code, err := strconv.ParseUint(string(l.src[l.pos:l.pos+4]), 16, 32)
+			if err != nil {
+				return token{}, newError("invalid unicode escape in string", loc)
+			}
+			for i := 0; i < 4; i++ {
+				l.advance()
+			}
+			value.WriteRune(rune(code))
+		default:
+			return token{}, newError(fmt.Sprintf("invalid escape [\\%c] in string", escaped), loc)
+		}
+	}
+}
+
+func (l *lexer) blockString(loc Location) (token, *Error) {
+	for i := 0; i < 3; i++ {
+		l.advance()
+	}
+	raw := strings.Builder{}
+	for {
+		if l.pos >= len(l.src) {
+			return token{}, newError("unterminated block string", loc)
+		}
+		if l.char(0) == '"' && l.char(1) == '"' && l.char(2) == '"' {
+			for i := 0; i < 3; i++ {
+				l.advance()
+			}
+			return token{kind: tokenString, value: blockStringValue(raw.String()), loc: loc}, nil
+		}
+		if l.char(0) == '\\' && l.char(1) == '"' && l.char(2) == '"' && l.char(3) == '"' {
+			for i := 0; i < 4; i++ {
+				l.advance()
+			}
+			raw.WriteString(`"""`)
+			continue
+		}
+		raw.WriteRune(l.src[l.pos])
+		l.advance()
+	}
+}
+
+// remove common indentation and blank leading/trailing lines of block string
+func blockStringValue(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(strings.ReplaceAll(raw, "\r\n", "\n"), "\r", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if size := len(line) - len(trimmed); indent < 0 || size < indent {
+			indent = size
+		}
+	}
+	if indent > 0 {
+		for idx := 1; idx < len(lines); idx++ {
+			if len(lines[idx]) >= indent {
+				lines[idx] = lines[idx][indent:]
+			} else {
+				lines[idx] = strings.TrimLeft(lines[idx], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isNameStart(c rune) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c rune) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *parser) next() *Error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) peek(kind int, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+func (p *parser) unexpected() *Error {
+	if p.tok.kind == tokenEOF {
+		return newError("unexpected end of document", p.tok.loc)
+	}
+	return newError(fmt.Sprintf("unexpected [%s]", p.tok.value), p.tok.loc)
+}
+
+// skip token if it is expected punctuator
+func (p *parser) skip(punct string) (bool, *Error) {
+	if !p.peek(tokenPunct, punct) {
+		return false, nil
+	}
+	return true, p.next()
+}
+
+func (p *parser) expect(punct string) *Error {
+	if !p.peek(tokenPunct, punct) {
+		if p.tok.kind == tokenEOF {
+			return newError(fmt.Sprintf("expect [%s], got end of document", punct), p.tok.loc)
+		}
+		return newError(fmt.Sprintf("expect [%s], got [%s]", punct, p.tok.value), p.tok.loc)
+	}
+	return p.next()
+}
+
+func (p *parser) name() (string, *Error) {
+	if p.tok.kind != tokenName {
+		if p.tok.kind == tokenEOF {
+			return "", newError("expect name, got end of document", p.tok.loc)
+		}
+		return "", newError(fmt.Sprintf("expect name, got [%s]", p.tok.value), p.tok.loc)
+	}
+	name := p.tok.value
+	return name, p.next()
+}
+
+func (p *parser) parseOperation() (*Operation, *Error) {
+	op := Operation{
+		Type: OpQuery,
+		Loc:  p.tok.loc,
+	}
+	if p.peek(tokenPunct, "{") {
+		selectionSet, err := p.parseSelectionSet()
+		if err != nil {
+			return nil, err
+		}
+		op.SelectionSet = selectionSet
+		return &op, nil
+	}
+	op.Type = p.tok.value
+	err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenName {
+		op.Name = p.tok.value
+		err = p.next()
+		if err != nil {
+			return nil, err
+		}
+	}
+	op.Variables, err = p.parseVariableDefs()
+	if err != nil {
+		return nil, err
+	}
+	op.Directives, err = p.parseDirectives()
+	if err != nil {
+		return nil, err
+	}
+	op.SelectionSet, err = p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+func (p *parser) parseVariableDefs() ([]*VariableDef, *Error) {
+	ok, err := p.skip("(")
+	if !ok || err != nil {
+		return nil, err
+	}
+	result := []*VariableDef{}
+	for !p.peek(tokenPunct, ")") {
+		varDef := VariableDef{
+			Loc: p.tok.loc,
+		}
+		err = p.expect("$")
+		if err != nil {
+			return nil, err
+		}
+		varDef.Name, err = p.name()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expect(":")
+		if err != nil {
+			return nil, err
+		}
+		varDef.Type, err = p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		ok, err = p.skip("=")
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			varDef.Default, err = p.parseValue(true)
+			if err != nil {
+				return nil, err
+			}
+		}
+		// directives of variable definition are not used
+		_, err = p.parseDirectives()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &varDef)
+	}
+	if len(result) == 0 {
+		return nil, p.unexpected()
+	}
+	return result, p.next()
+}
+
+func (p *parser) parseType() (*TypeRef, *Error) {
+	result := TypeRef{}
+	ok, err := p.skip("[")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		result.Elem, err = p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expect("]")
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		result.Name, err = p.name()
+		if err != nil {
+			return nil, err
+		}
+	}
+	result.NonNull, err = p.skip("!")
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (p *parser) parseDirectives() ([]*Directive, *Error) {
+	var result []*Directive
+	for p.peek(tokenPunct, "@") {
+		directive := Directive{
+			Loc: p.tok.loc,
+		}
+		err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		directive.Name, err = p.name()
+		if err != nil {
+			return nil, err
+		}
+		directive.Arguments, err = p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &directive)
+	}
+	return result, nil
+}
+
+func (p *parser) parseArguments() ([]*Argument, *Error) {
+	ok, err := p.skip("(")
+	if !ok || err != nil {
+		return nil, err
+	}
+	result := []*Argument{}
+	for !p.peek(tokenPunct, ")") {
+		arg := Argument{
+			Loc: p.tok.loc,
+		}
+		arg.Name, err = p.name()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expect(":")
+		if err != nil {
+			return nil, err
+		}
+		arg.Value, err = p.parseValue(false)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &arg)
+	}
+	if len(result) == 0 {
+		return nil, p.unexpected()
+	}
+	return result, p.next()
+}
+
+func (p *parser) parseValue(isConst bool) (interface{}, *Error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenInt:
+		value, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, newError(fmt.Sprintf("invalid integer [%s]", tok.value), tok.loc)
+		}
+		return value, p.next()
+	case tokenFloat:
+		value, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, newError(fmt.Sprintf("invalid float [%s]", tok.value), tok.loc)
+		}
+		return value, p.next()
+	case tokenString:
+		return tok.value, p.next()
+	case tokenName:
+		var value interface{}
+		switch tok.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			value = Enum(tok.value)
+		}
+		return value, p.next()
+	case tokenPunct:
+		switch tok.value {
+		case "$":
+			if isConst {
+				return nil, newError("variable is not allowed in constant value", tok.loc)
+			}
+			err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			return Variable(name), nil
+		case "[":
+			err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			result := []interface{}{}
+			for !p.peek(tokenPunct, "]") {
+				item, err := p.parseValue(isConst)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, item)
+			}
+			return result, p.next()
+		case "{":
+			err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			result := map[string]interface{}{}
+			for !p.peek(tokenPunct, "}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				err = p.expect(":")
+				if err != nil {
+					return nil, err
+				}
+				result[name], err = p.parseValue(isConst)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return result, p.next()
+		}
+	}
+	return nil, p.unexpected()
+}
+
+func (p *parser) parseSelectionSet() ([]Selection, *Error) {
+	err := p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	result := []Selection{}
+	for !p.peek(tokenPunct, "}") {
+		var selection Selection
+		if p.peek(tokenPunct, Spread) {
+			selection, err = p.parseFragmentSelection()
+		} else {
+			selection, err = p.parseField()
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, selection)
+	}
+	if len(result) == 0 {
+		return nil, p.unexpected()
+	}
+	return result, p.next()
+}
+
+func (p *parser) parseField() (*Field, *Error) {
+	field := Field{
+		Loc: p.tok.loc,
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := p.skip(":")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		field.Alias = name
+		name, err = p.name()
+		if err != nil {
+			return nil, err
+		}
+	}
+	field.Name = name
+	field.Arguments, err = p.parseArguments()
+	if err != nil {
+		return nil, err
+	}
+	field.Directives, err = p.parseDirectives()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek(tokenPunct, "{") {
+		field.SelectionSet, err = p.parseSelectionSet()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &field, nil
+}
+
+func (p *parser) parseFragmentSelection() (Selection, *Error) {
+	loc := p.tok.loc
+	err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenName && p.tok.value != KeyOn {
+		spread := FragmentSpread{
+			Name: p.tok.value,
+			Loc:  loc,
+		}
+		err = p.next()
+		if err != nil {
+			return nil, err
+		}
+		spread.Directives, err = p.parseDirectives()
+		if err != nil {
+			return nil, err
+		}
+		return &spread, nil
+	}
+	inline := InlineFragment{
+		Loc: loc,
+	}
+	if p.peek(tokenName, KeyOn) {
+		err = p.next()
+		if err != nil {
+			return nil, err
+		}
+		inline.TypeCondition, err = p.name()
+		if err != nil {
+			return nil, err
+		}
+	}
+	inline.Directives, err = p.parseDirectives()
+	if err != nil {
+		return nil, err
+	}
+	inline.SelectionSet, err = p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &inline, nil
+}
+
+func (p *parser) parseFragment() (*Fragment, *Error) {
+	fragment := Fragment{
+		Loc: p.tok.loc,
+	}
+	err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek(tokenName, KeyOn) {
+		return nil, p.unexpected()
+	}
+	fragment.Name, err = p.name()
+	if err != nil {
+		return nil, err
+	}
+	if !p.peek(tokenName, KeyOn) {
+		return nil, newError(fmt.Sprintf("expect [%s] of fragment [%s]", KeyOn, fragment.Name), p.tok.loc)
+	}
+	err = p.next()
+	if err != nil {
+		return nil, err
+	}
+	fragment.TypeCondition, err = p.name()
+	if err != nil {
+		return nil, err
+	}
+	fragment.Directives, err = p.parseDirectives()
+	if err != nil {
+		return nil, err
+	}
+	fragment.SelectionSet, err = p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &fragment, nil
+}
@@ -0,0 +1,529 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package GraphQl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"DataService/Common"
+	"DataService/DataHandler"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Schema/SchemaDoc"
+	"github.com/salesforce/UniTAO/lib/Util"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+const (
+	TypeQuery   = "Query"
+	TypeString  = "String"
+	TypeInt     = "Int"
+	TypeFloat   = "Float"
+	TypeBoolean = "Boolean"
+	TypeId      = "ID"
+	// map and object without definition, value as it is in record
+	TypeJson = "JSON"
+	// object type of definition is {type}_{definition}
+	TypeDiv      = "_"
+	KeyTypeName  = "__typename"
+	FieldId      = "_id"
+	FieldType    = "_type"
+	FieldVersion = "_version"
+	FieldRev     = "_revision"
+	// root field of record list is list_{type}, of single record is {type}
+	ListPrefix = "list_"
+	ArgId      = "id"
+	ArgFilter  = "filter"
+	ArgSort    = "sort"
+	ArgLimit   = "limit"
+)
+
+var builtinScalars = map[string]string{
+	TypeString:  "",
+	TypeInt:     "",
+	TypeFloat:   "",
+	TypeBoolean: "",
+	TypeId:      "",
+}
+
+// Schema of GraphQL generated from current schema records of data handler.
+// every data type is an object type with record fields and data attributes,
+// attribute with contentMediaType inventory/{type} is resolved as object of referenced type
+type Schema struct {
+	Query   *ObjectType
+	Types   map[string]*ObjectType
+	Scalars map[string]string
+	handler *DataHandler.Handler
+}
+
+type ObjectType struct {
+	Name        string
+	Description string
+	Fields      []*FieldDef
+	fieldMap    map[string]*FieldDef
+}
+
+type FieldDef struct {
+	Name        string
+	Description string
+	Type        *TypeRef
+	Args        []*ArgDef
+	resolve     resolveFunc
+}
+
+type ArgDef struct {
+	Name        string
+	Description string
+	Type        *TypeRef
+}
+
+// resolve value of field from source object, reference of record is returned as recordRef,
+// and it is loaded when completing object value
+type resolveFunc func(source map[string]interface{}, args map[string]interface{}) (interface{}, *Http.HttpError)
+
+type recordRef struct {
+	dataType string
+	dataId   string
+}
+
+type builder struct {
+	handler *DataHandler.Handler
+	schema  *Schema
+	// object type name of data type, empty when schema of the type is not available
+	recordTypes map[string]string
+	pending     []pendingType
+}
+
+type pendingType struct {
+	name string
+	doc  *SchemaDoc.SchemaDoc
+}
+
+// Build GraphQL schema from current schema records of data handler,
+// referenced types of other DataService are loaded through inventory
+func Build(data *DataHandler.Handler) (*Schema, *Http.HttpError) {
+	schemaList, err := querySchemas(data)
+	if err != nil {
+		return nil, err
+	}
+	return build(data, schemaList)
+}
+
+// schema records of data handler in order of id
+func querySchemas(data *DataHandler.Handler) ([]map[string]interface{}, *Http.HttpError) {
+	schemaList, err := data.QueryDb(JsonKey.Schema, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(schemaList, func(i, j int) bool {
+		return fmt.Sprint(schemaList[i][Record.DataId]) < fmt.Sprint(schemaList[j][Record.DataId])
+	})
+	return schemaList, nil
+}
+
+func build(data *DataHandler.Handler, schemaList []map[string]interface{}) (*Schema, *Http.HttpError) {
+	b := builder{
+		handler: data,
+		schema: &Schema{
+			Types: map[string]*ObjectType{},
+			Scalars: map[string]string{
+				TypeJson: "value of map or object attribute as it is in record",
+			},
+			handler: data,
+		},
+		recordTypes: map[string]string{},
+	}
+	b.schema.Query = b.newType(TypeQuery, "records of the DataService")
+	localTypes := []string{}
+	for _, schemaData := range schemaList {
+		if DataHandler.IsDeleted(schemaData) {
+			continue
+		}
+		schemaId, _ := schemaData[Record.DataId].(string)
+		dataType, ver := Util.ParseCustomPath(schemaId, JsonKey.ArchivedSchemaIdDiv)
+		if _, ok := Common.InternalTypes[dataType]; ok || ver != "" {
+			continue
+		}
+		record, ex := Record.LoadMap(schemaData)
+		if ex != nil {
+			return nil, Http.WrapError(ex, fmt.Sprintf("failed to load schema [%s] as record", schemaId), http.StatusInternalServerError)
+		}
+		doc, ex := SchemaDoc.New(record.Data)
+		if ex != nil {
+			return nil, Http.WrapError(ex, fmt.Sprintf("failed to load schema [%s]", schemaId), http.StatusInternalServerError)
+		}
+		b.addRecordType(dataType, doc)
+		localTypes = append(localTypes, dataType)
+	}
+	for _, dataType := range localTypes {
+		b.addRootFields(dataType)
+	}
+	for len(b.pending) > 0 {
+		next := b.pending[0]
+		b.pending = b.pending[1:]
+		b.buildRecordType(next)
+	}
+	return b.schema, nil
+}
+
+// GraphQL name from data type or attribute name, invalid characters are replaced with _
+func Name(name string) string {
+	result := strings.Builder{}
+	for idx, c := range name {
+		switch {
+		case isNameChar(c) && (idx > 0 || !isDigit(c)):
+			result.WriteRune(c)
+		case idx == 0 && isDigit(c):
+			result.WriteString(TypeDiv)
+			result.WriteRune(c)
+		default:
+			result.WriteString(TypeDiv)
+		}
+	}
+	return result.String()
+}
+
+func (b *builder) newType(name string, description string) *ObjectType {
+	name = Name(name)
+	for {
+		_, isType := b.schema.Types[name]
+		_, isBuiltin := builtinScalars[name]
+		_, isScalar := b.schema.Scalars[name]
+		if !isType && !isBuiltin && !isScalar {
+			break
+		}
+		name += TypeDiv
+	}
+	objType := ObjectType{
+		Name:        name,
+		Description: description,
+		fieldMap:    map[string]*FieldDef{},
+	}
+	b.schema.Types[name] = &objType
+	return &objType
+}
+
+func (t *ObjectType) addField(field *FieldDef) {
+	if _, ok := t.fieldMap[field.Name]; ok {
+		return
+	}
+	t.Fields = append(t.Fields, field)
+	t.fieldMap[field.Name] = field
+}
+
+func (t *ObjectType) Field(name string) *FieldDef {
+	return t.fieldMap[name]
+}
+
+func (b *builder) addRecordType(dataType string, doc *SchemaDoc.SchemaDoc) string {
+	description, _ := doc.Data["description"].(string)
+	if description == "" {
+		description = fmt.Sprintf("record of [%s]", dataType)
+	}
+	objType := b.newType(dataType, description)
+	b.recordTypes[dataType] = objType.Name
+	b.pending = append(b.pending, pendingType{
+		name: objType.Name,
+		doc:  doc,
+	})
+	return objType.Name
+}
+
+// object type of referenced data type, schema of type is loaded through inventory when it is not known yet
+func (b *builder) refType(dataType string) string {
+	if name, ok := b.recordTypes[dataType]; ok {
+		return name
+	}
+	b.recordTypes[dataType] = ""
+	record, err := b.handler.Inventory.Get(JsonKey.Schema, dataType)
+	if err != nil {
+		b.handler.Log(fmt.Sprintf("GraphQL: schema of referenced type [%s] is not available, Error: %s", dataType, err))
+		return ""
+	}
+	doc, ex := SchemaDoc.New(record.Data)
+	if ex != nil {
+		b.handler.Log(fmt.Sprintf("GraphQL: failed to load schema of referenced type [%s], Error: %s", dataType, ex))
+		return ""
+	}
+	return b.addRecordType(dataType, doc)
+}
+
+func (b *builder) addRootFields(dataType string) {
+	name := b.recordTypes[dataType]
+	b.schema.Query.addField(&FieldDef{
+		Name:        name,
+		Description: fmt.Sprintf("record of [%s] by id", dataType),
+		Type:        &TypeRef{Name: name},
+		Args: []*ArgDef{
+			{Name: ArgId, Type: &TypeRef{Name: TypeString, NonNull: true}},
+		},
+		resolve: func(source map[string]interface{}, args map[string]interface{}) (interface{}, *Http.HttpError) {
+			return recordRef{
+				dataType: dataType,
+				dataId:   args[ArgId].(string),
+			}, nil
+		},
+	})
+	b.schema.Query.addField(&FieldDef{
+		Name:        ListPrefix + name,
+		Description: fmt.Sprintf("records of [%s], same as GET /%s?view=%s", dataType, dataType, Common.ViewRecords),
+		Type:        &TypeRef{Elem: &TypeRef{Name: name, NonNull: true}},
+		Args: []*ArgDef{
+			{
+				Name:        ArgFilter,
+				Description: "attribute filter of {path}:{op}:{value}",
+				Type:        &TypeRef{Elem: &TypeRef{Name: TypeString, NonNull: true}},
+			},
+			{
+				Name:        ArgSort,
+				Description: "comma separated attribute paths, descending with prefix -",
+				Type:        &TypeRef{Name: TypeString},
+			},
+			{
+				Name: ArgLimit,
+				Type: &TypeRef{Name: TypeInt},
+			},
+		},
+		resolve: func(source map[string]interface{}, args map[string]interface{}) (interface{}, *Http.HttpError) {
+			return listRecords(b.handler, dataType, args)
+		},
+	})
+}
+
+func listRecords(data *DataHandler.Handler, dataType string, args map[string]interface{}) (interface{}, *Http.HttpError) {
+	query := url.Values{}
+	query.Set(Common.QueryView, Common.ViewRecords)
+	if filterList, ok := args[ArgFilter].([]interface{}); ok {
+		for _, filter := range filterList {
+			query.Add(Common.QueryFilter, filter.(string))
+		}
+	}
+	if sortStr, ok := args[ArgSort].(string); ok {
+		query.Set(Common.QuerySort, sortStr)
+	}
+	if limit, ok := args[ArgLimit].(int64); ok {
+		query.Set(Common.QueryLimit, strconv.FormatInt(limit, 10))
+	}
+	listQuery, err := data.ParseListQuery(dataType, query)
+	if err != nil {
+		return nil, err
+	}
+	recordList, _, err := data.Query(dataType, listQuery)
+	if err != nil {
+		return nil, err
+	}
+	return recordList, nil
+}
+
+func (b *builder) buildRecordType(p pendingType) {
+	objType := b.schema.Types[p.name]
+	for _, field := range []struct {
+		name     string
+		key      string
+		typeName string
+		nonNull  bool
+	}{
+		{FieldId, Record.DataId, TypeString, true},
+		{FieldType, Record.DataType, TypeString, true},
+		{FieldVersion, Record.Version, TypeString, true},
+		{FieldRev, Record.Revision, TypeInt, false},
+	} {
+		key := field.key
+		objType.addField(&FieldDef{
+			Name: field.name,
+			Type: &TypeRef{Name: field.typeName, NonNull: field.nonNull},
+			resolve: func(source map[string]interface{}, args map[string]interface{}) (interface{}, *Http.HttpError) {
+				return source[key], nil
+			},
+		})
+	}
+	// definitions of schema in order of name, so type names are stable
+	docNames := map[*SchemaDoc.SchemaDoc]string{}
+	docList := []*SchemaDoc.SchemaDoc{}
+	var collect func(doc *SchemaDoc.SchemaDoc, name string)
+	collect = func(doc *SchemaDoc.SchemaDoc, name string) {
+		defNames := make([]string, 0, len(doc.Definitions))
+		for defName := range doc.Definitions {
+			defNames = append(defNames, defName)
+		}
+		sort.Strings(defNames)
+		for _, defName := range defNames {
+			defDoc := doc.Definitions[defName]
+			description, _ := defDoc.Data["description"].(string)
+			defType := b.newType(name+TypeDiv+defName, description)
+			docNames[defDoc] = defType.Name
+			docList = append(docList, defDoc)
+			collect(defDoc, defType.Name)
+		}
+	}
+	collect(p.doc, p.name)
+	b.addAttrFields(objType, p.doc, docNames, true)
+	for _, defDoc := range docList {
+		b.addAttrFields(b.schema.Types[docNames[defDoc]], defDoc, docNames, false)
+	}
+}
+
+// fields of data attributes, source of record type is the record and attributes are in its data
+func (b *builder) addAttrFields(objType *ObjectType, doc *SchemaDoc.SchemaDoc, docNames map[*SchemaDoc.SchemaDoc]string, isRecord bool) {
+	properties := doc.Properties()
+	attrList := make([]string, 0, len(properties))
+	for attrName := range properties {
+		attrList = append(attrList, attrName)
+	}
+	sort.Strings(attrList)
+	for _, attrName := range attrList {
+		attrDef, ok := properties[attrName].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		attrType, refType := b.attrType(doc, attrName, attrDef, docNames)
+		description, _ := attrDef["description"].(string)
+		name := attrName
+		objType.addField(&FieldDef{
+			Name:        Name(attrName),
+			Description: description,
+			Type:        attrType,
+			resolve: func(source map[string]interface{}, args map[string]interface{}) (interface{}, *Http.HttpError) {
+				data := source
+				if isRecord {
+					data, _ = source[Record.Data].(map[string]interface{})
+				}
+				return toRef(refType, data[name]), nil
+			},
+		})
+	}
+}
+
+// GraphQL type of attribute, and data type when the attribute is a reference
+func (b *builder) attrType(doc *SchemaDoc.SchemaDoc, attrName string, attrDef map[string]interface{}, docNames map[*SchemaDoc.SchemaDoc]string) (*TypeRef, string) {
+	switch attrDef[JsonKey.Type] {
+	case JsonKey.String:
+		cmtRef, ok := doc.CmtRefs[attrName]
+		if ok && cmtRef.CmtType == JsonKey.Inventory {
+			if name := b.refType(cmtRef.ContentType); name != "" {
+				return &TypeRef{Name: name}, cmtRef.ContentType
+			}
+		}
+		return &TypeRef{Name: TypeString}, ""
+	case JsonKey.Integer:
+		return &TypeRef{Name: TypeInt}, ""
+	case JsonKey.Number:
+		return &TypeRef{Name: TypeFloat}, ""
+	case JsonKey.Boolean:
+		return &TypeRef{Name: TypeBoolean}, ""
+	case JsonKey.Array:
+		itemDef, ok := attrDef[JsonKey.Items].(map[string]interface{})
+		if !ok {
+			return &TypeRef{Name: TypeJson}, ""
+		}
+		itemType, refType := b.attrType(doc, attrName, itemDef, docNames)
+		return &TypeRef{Elem: itemType}, refType
+	case JsonKey.Object:
+		if SchemaDoc.IsMap(attrDef) {
+			return &TypeRef{Name: TypeJson}, ""
+		}
+		if name, ok := docNames[doc.SubDocs[attrName]]; ok {
+			return &TypeRef{Name: name}, ""
+		}
+	}
+	return &TypeRef{Name: TypeJson}, ""
+}
+
+// id of reference, or list of them, as recordRef
+func toRef(refType string, value interface{}) interface{} {
+	if refType == "" || value == nil {
+		return value
+	}
+	switch refValue := value.(type) {
+	case string:
+		return recordRef{
+			dataType: refType,
+			dataId:   refValue,
+		}
+	case []interface{}:
+		result := make([]interface{}, 0, len(refValue))
+		for _, item := range refValue {
+			result = append(result, toRef(refType, item))
+		}
+		return result
+	}
+	return value
+}
+
+// SDL of schema, Query type first then other types in order of name
+func (s *Schema) SDL() string {
+	buf := strings.Builder{}
+	scalarList := make([]string, 0, len(s.Scalars))
+	for name := range s.Scalars {
+		scalarList = append(scalarList, name)
+	}
+	sort.Strings(scalarList)
+	for _, name := range scalarList {
+		writeDescription(&buf, "", s.Scalars[name])
+		buf.WriteString(fmt.Sprintf("scalar %s\n\n", name))
+	}
+	typeList := make([]string, 0, len(s.Types))
+	for name := range s.Types {
+		if name != s.Query.Name {
+			typeList = append(typeList, name)
+		}
+	}
+	sort.Strings(typeList)
+	for _, name := range append([]string{s.Query.Name}, typeList...) {
+		objType := s.Types[name]
+		writeDescription(&buf, "", objType.Description)
+		buf.WriteString(fmt.Sprintf("type %s {\n", name))
+		for _, field := range objType.Fields {
+			writeDescription(&buf, "  ", field.Description)
+			buf.WriteString("  " + field.Name)
+			if len(field.Args) > 0 {
+				argList := make([]string, 0, len(field.Args))
+				for _, arg := range field.Args {
+					argList = append(argList, fmt.Sprintf("%s: %s", arg.Name, arg.Type))
+				}
+				buf.WriteString(fmt.Sprintf("(%s)", strings.Join(argList, ", ")))
+			}
+			buf.WriteString(fmt.Sprintf(": %s\n", field.Type))
+		}
+		buf.WriteString("}\n\n")
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func writeDescription(buf *strings.Builder, indent string, description string) {
+	if description == "" {
+		return
+	}
+	quoted, _ := json.Marshal(description)
+	buf.WriteString(fmt.Sprintf("%s%s\n", indent, quoted))
+}
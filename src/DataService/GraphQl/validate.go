@@ -0,0 +1,235 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package GraphQl
+
+import (
+	"fmt"
+)
+
+type validator struct {
+	schema   *Schema
+	doc      *Document
+	varDefs  map[string]*VariableDef
+	visiting map[string]bool
+	errors   []*Error
+}
+
+// validate selections of operation and fragments it spreads against schema
+func (s *Schema) validate(doc *Document, op *Operation) []*Error {
+	v := validator{
+		schema:   s,
+		doc:      doc,
+		varDefs:  map[string]*VariableDef{},
+		visiting: map[string]bool{},
+	}
+	for _, varDef := range op.Variables {
+		if _, ok := v.varDefs[varDef.Name]; ok {
+			v.addError(fmt.Sprintf("variable [$%s] is defined more than once", varDef.Name), varDef.Loc)
+		}
+		v.varDefs[varDef.Name] = varDef
+		if !isInputType(varDef.Type.NamedType()) {
+			v.addError(fmt.Sprintf("type [%s] of variable [$%s] is not an input type", varDef.Type, varDef.Name), varDef.Loc)
+		}
+	}
+	v.directives(op.Directives)
+	v.selectionSet(s.Query, op.SelectionSet)
+	return v.errors
+}
+
+func isInputType(name string) bool {
+	if _, ok := builtinScalars[name]; ok {
+		return true
+	}
+	return name == TypeJson
+}
+
+func (v *validator) addError(message string, loc Location) {
+	v.errors = append(v.errors, newError(message, loc))
+}
+
+func (v *validator) selectionSet(objType *ObjectType, selectionSet []Selection) {
+	for _, selection := range selectionSet {
+		switch s := selection.(type) {
+		case *Field:
+			v.field(objType, s)
+		case *FragmentSpread:
+			v.directives(s.Directives)
+			fragment, ok := v.doc.Fragments[s.Name]
+			if !ok {
+				v.addError(fmt.Sprintf("fragment [%s] is not defined", s.Name), s.Loc)
+				continue
+			}
+			if v.visiting[s.Name] {
+				v.addError(fmt.Sprintf("fragment [%s] spreads itself", s.Name), s.Loc)
+				continue
+			}
+			v.visiting[s.Name] = true
+			v.directives(fragment.Directives)
+			v.fragment(objType, fragment.TypeCondition, fragment.SelectionSet, fragment.Loc)
+			delete(v.visiting, s.Name)
+		case *InlineFragment:
+			v.directives(s.Directives)
+			typeCondition := s.TypeCondition
+			if typeCondition == "" {
+				typeCondition = objType.Name
+			}
+			v.fragment(objType, typeCondition, s.SelectionSet, s.Loc)
+		}
+	}
+}
+
+// there is no interface or union, fragment only applies on the object type of its condition
+func (v *validator) fragment(objType *ObjectType, typeCondition string, selectionSet []Selection, loc Location) {
+	fragmentType, ok := v.schema.Types[typeCondition]
+	if !ok {
+		v.addError(fmt.Sprintf("type [%s] of fragment is not defined", typeCondition), loc)
+		return
+	}
+	if fragmentType != objType {
+		v.addError(fmt.Sprintf("fragment on type [%s] cannot be spread on type [%s]", typeCondition, objType.Name), loc)
+		return
+	}
+	v.selectionSet(fragmentType, selectionSet)
+}
+
+func (v *validator) field(objType *ObjectType, field *Field) {
+	v.directives(field.Directives)
+	if field.Name == KeyTypeName {
+		if len(field.Arguments) > 0 || len(field.SelectionSet) > 0 {
+			v.addError(fmt.Sprintf("field [%s] has no arguments or selections", KeyTypeName), field.Loc)
+		}
+		return
+	}
+	fieldDef := objType.Field(field.Name)
+	if fieldDef == nil {
+		v.addError(fmt.Sprintf("field [%s] is not defined on type [%s]", field.Name, objType.Name), field.Loc)
+		return
+	}
+	v.arguments(fieldDef.Args, field.Arguments, fmt.Sprintf("field [%s]", field.Name), field.Loc)
+	typeName := fieldDef.Type.NamedType()
+	subType, isObject := v.schema.Types[typeName]
+	switch {
+	case isObject && len(field.SelectionSet) == 0:
+		v.addError(fmt.Sprintf("field [%s] of type [%s] must have selections", field.Name, fieldDef.Type), field.Loc)
+	case isObject:
+		v.selectionSet(subType, field.SelectionSet)
+	case len(field.SelectionSet) > 0:
+		v.addError(fmt.Sprintf("field [%s] of scalar type [%s] cannot have selections", field.Name, fieldDef.Type), field.Loc)
+	}
+}
+
+func (v *validator) directives(directives []*Directive) {
+	for _, directive := range directives {
+		if directive.Name != DirectiveSkip && directive.Name != DirectiveInclude {
+			v.addError(fmt.Sprintf("directive [@%s] is not supported", directive.Name), directive.Loc)
+			continue
+		}
+		argDefs := []*ArgDef{
+			{Name: ArgIf, Type: &TypeRef{Name: TypeBoolean, NonNull: true}},
+		}
+		v.arguments(argDefs, directive.Arguments, fmt.Sprintf("directive [@%s]", directive.Name), directive.Loc)
+	}
+}
+
+func (v *validator) arguments(argDefs []*ArgDef, args []*Argument, owner string, loc Location) {
+	argMap := map[string]*Argument{}
+	for _, arg := range args {
+		if _, ok := argMap[arg.Name]; ok {
+			v.addError(fmt.Sprintf("argument [%s] of %s is given more than once", arg.Name, owner), arg.Loc)
+			continue
+		}
+		argMap[arg.Name] = arg
+		var argDef *ArgDef
+		for _, def := range argDefs {
+			if def.Name == arg.Name {
+				argDef = def
+			}
+		}
+		if argDef == nil {
+			v.addError(fmt.Sprintf("argument [%s] is not defined on %s", arg.Name, owner), arg.Loc)
+			continue
+		}
+		if !v.variablesDefined(arg.Value, arg.Loc) {
+			continue
+		}
+		if hasVariable(arg.Value) {
+			// value of variable is checked when it is coerced
+			continue
+		}
+		_, err := coerceInput(argDef.Type, arg.Value)
+		if err != nil {
+			v.addError(fmt.Sprintf("invalid argument [%s] of %s, %s", arg.Name, owner, err), arg.Loc)
+		}
+	}
+	for _, argDef := range argDefs {
+		if _, ok := argMap[argDef.Name]; !ok && argDef.Type.NonNull {
+			v.addError(fmt.Sprintf("argument [%s] of %s is required", argDef.Name, owner), loc)
+		}
+	}
+}
+
+func (v *validator) variablesDefined(value interface{}, loc Location) bool {
+	switch val := value.(type) {
+	case Variable:
+		if _, ok := v.varDefs[string(val)]; !ok {
+			v.addError(fmt.Sprintf("variable [$%s] is not defined", val), loc)
+			return false
+		}
+	case []interface{}:
+		result := true
+		for _, item := range val {
+			result = v.variablesDefined(item, loc) && result
+		}
+		return result
+	case map[string]interface{}:
+		result := true
+		for _, item := range val {
+			result = v.variablesDefined(item, loc) && result
+		}
+		return result
+	}
+	return true
+}
+
+func hasVariable(value interface{}) bool {
+	switch val := value.(type) {
+	case Variable:
+		return true
+	case []interface{}:
+		for _, item := range val {
+			if hasVariable(item) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range val {
+			if hasVariable(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,352 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+// copy all records of a Data Service from one database to another
+package Migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"Data/DbIface"
+	"DataService/Common"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Util"
+)
+
+const (
+	DefaultBatchSize = 25
+	// internal id field added by mongodb, not part of record
+	MongoId = "_id"
+)
+
+// TypeState is progress of copying one type in a table
+type TypeState struct {
+	PageToken string `json:"pageToken,omitempty"`
+	Count     int    `json:"count"`
+	Done      bool   `json:"done"`
+}
+
+// State is saved after every batch, so an interrupted migration resume from last committed batch
+type State struct {
+	Tables map[string]map[string]*TypeState `json:"tables"`
+}
+
+// Summary is count and checksum of records of one type, checksum does not depend on record order
+type Summary struct {
+	Count    int
+	Checksum string
+}
+
+type Migration struct {
+	Source    DbIface.Database
+	Target    DbIface.Database
+	Tables    map[string]string
+	BatchSize int
+	StatePath string
+	logger    *log.Logger
+	state     State
+}
+
+// New create migration of tables [source table]=>[target table], load state from statePath to resume if exists
+func New(source DbIface.Database, target DbIface.Database, tables map[string]string, batchSize int, statePath string, logger *log.Logger) (*Migration, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	m := Migration{
+		Source:    source,
+		Target:    target,
+		Tables:    tables,
+		BatchSize: batchSize,
+		StatePath: statePath,
+		logger:    logger,
+		state: State{
+			Tables: map[string]map[string]*TypeState{},
+		},
+	}
+	err := m.loadState()
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (m *Migration) loadState() error {
+	if m.StatePath == "" {
+		return nil
+	}
+	raw, err := ioutil.ReadFile(m.StatePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read migration state [%s], Error:%s", m.StatePath, err)
+	}
+	err = json.Unmarshal(raw, &m.state)
+	if err != nil {
+		return fmt.Errorf("failed to parse migration state [%s], Error:%s", m.StatePath, err)
+	}
+	if m.state.Tables == nil {
+		m.state.Tables = map[string]map[string]*TypeState{}
+	}
+	m.logger.Printf("resume migration from state [%s]", m.StatePath)
+	return nil
+}
+
+func (m *Migration) saveState() error {
+	if m.StatePath == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(m.state, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal migration state, Error:%s", err)
+	}
+	tmpPath := m.StatePath + ".tmp"
+	err = ioutil.WriteFile(tmpPath, raw, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write migration state [%s], Error:%s", tmpPath, err)
+	}
+	return os.Rename(tmpPath, m.StatePath)
+}
+
+func (m *Migration) typeState(table string, dataType string) *TypeState {
+	typeMap, ok := m.state.Tables[table]
+	if !ok {
+		typeMap = map[string]*TypeState{}
+		m.state.Tables[table] = typeMap
+	}
+	state, ok := typeMap[dataType]
+	if !ok {
+		state = &TypeState{}
+		typeMap[dataType] = state
+	}
+	return state
+}
+
+func (m *Migration) tableList() []string {
+	tableList := make([]string, 0, len(m.Tables))
+	for table := range m.Tables {
+		tableList = append(tableList, table)
+	}
+	sort.Strings(tableList)
+	return tableList
+}
+
+// ListTypes return all types stored in table: schema, internal types and types defined by current and archived schemas.
+// databases like dynamodb and mongodb can only be queried by type
+func (m *Migration) ListTypes(table string) ([]string, error) {
+	typeMap := map[string]bool{
+		JsonKey.Schema: true,
+	}
+	for dataType := range Common.InternalTypes {
+		typeMap[dataType] = true
+	}
+	pageToken := ""
+	for {
+		schemaList, nextToken, err := m.Source.GetPage(map[string]interface{}{
+			DbIface.Table:   table,
+			Record.DataType: JsonKey.Schema,
+		}, m.BatchSize, pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list schema from table [%s], Error:%s", table, err)
+		}
+		for _, schema := range schemaList {
+			schemaId, ok := schema[Record.DataId].(string)
+			if !ok {
+				continue
+			}
+			// data of archived schema is saved with the type name and old version
+			dataType, _ := Util.ParseCustomPath(schemaId, JsonKey.ArchivedSchemaIdDiv)
+			typeMap[dataType] = true
+		}
+		if nextToken == "" {
+			break
+		}
+		pageToken = nextToken
+	}
+	typeList := make([]string, 0, len(typeMap))
+	for dataType := range typeMap {
+		typeList = append(typeList, dataType)
+	}
+	sort.Strings(typeList)
+	return typeList, nil
+}
+
+func (m *Migration) prepareTarget(table string) error {
+	tableList, err := m.Target.ListTable()
+	if err != nil {
+		return fmt.Errorf("failed to list target tables, Error:%s", err)
+	}
+	for _, name := range tableList {
+		if name == table {
+			return nil
+		}
+	}
+	m.logger.Printf("create target table [%s]", table)
+	err = m.Target.CreateTable(table, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create target table [%s], create it with [table] command first. Error:%s", table, err)
+	}
+	return nil
+}
+
+// Run copy all tables, records are written with Replace so a replayed batch is harmless
+func (m *Migration) Run() error {
+	for _, srcTable := range m.tableList() {
+		tgtTable := m.Tables[srcTable]
+		m.logger.Printf("migrate table [%s]=>[%s]", srcTable, tgtTable)
+		err := m.prepareTarget(tgtTable)
+		if err != nil {
+			return err
+		}
+		typeList, err := m.ListTypes(srcTable)
+		if err != nil {
+			return err
+		}
+		for _, dataType := range typeList {
+			err = m.copyType(srcTable, tgtTable, dataType)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Migration) copyType(srcTable string, tgtTable string, dataType string) error {
+	state := m.typeState(srcTable, dataType)
+	if state.Done {
+		m.logger.Printf("skip [%s/%s], %d records migrated", srcTable, dataType, state.Count)
+		return nil
+	}
+	for !state.Done {
+		recordList, nextToken, err := m.Source.GetPage(map[string]interface{}{
+			DbIface.Table:   srcTable,
+			Record.DataType: dataType,
+		}, m.BatchSize, state.PageToken)
+		if err != nil {
+			return fmt.Errorf("failed to read [%s/%s] from source, Error:%s", srcTable, dataType, err)
+		}
+		batch := DbIface.NewBatch()
+		for _, record := range recordList {
+			delete(record, MongoId)
+			batch.Replace(tgtTable, map[string]interface{}{
+				Record.DataType: record[Record.DataType],
+				Record.DataId:   record[Record.DataId],
+			}, record)
+		}
+		err = m.Target.Commit(batch)
+		if err != nil {
+			return fmt.Errorf("failed to write [%s/%s] to target after %d records, Error:%s", tgtTable, dataType, state.Count, err)
+		}
+		state.Count += len(recordList)
+		state.PageToken = nextToken
+		state.Done = nextToken == ""
+		err = m.saveState()
+		if err != nil {
+			return err
+		}
+	}
+	m.logger.Printf("migrated [%s/%s], %d records", srcTable, dataType, state.Count)
+	return nil
+}
+
+func summarize(db DbIface.Database, table string, dataType string, pageSize int) (*Summary, error) {
+	checksum := make([]byte, sha256.Size)
+	count := 0
+	pageToken := ""
+	for {
+		recordList, nextToken, err := db.GetPage(map[string]interface{}{
+			DbIface.Table:   table,
+			Record.DataType: dataType,
+		}, pageSize, pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read [%s/%s], Error:%s", table, dataType, err)
+		}
+		for _, record := range recordList {
+			delete(record, MongoId)
+			// json marshal sort map keys, so same record has same bytes from any database
+			raw, err := json.Marshal(record)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal [%s/%v], Error:%s", dataType, record[Record.DataId], err)
+			}
+			sum := sha256.Sum256(raw)
+			for idx := range checksum {
+				checksum[idx] ^= sum[idx]
+			}
+			count++
+		}
+		if nextToken == "" {
+			break
+		}
+		pageToken = nextToken
+	}
+	return &Summary{
+		Count:    count,
+		Checksum: hex.EncodeToString(checksum),
+	}, nil
+}
+
+// Verify compare count and checksum of every type between source and target
+func (m *Migration) Verify() error {
+	mismatch := []string{}
+	for _, srcTable := range m.tableList() {
+		tgtTable := m.Tables[srcTable]
+		typeList, err := m.ListTypes(srcTable)
+		if err != nil {
+			return err
+		}
+		for _, dataType := range typeList {
+			srcSum, err := summarize(m.Source, srcTable, dataType, m.BatchSize)
+			if err != nil {
+				return err
+			}
+			tgtSum, err := summarize(m.Target, tgtTable, dataType, m.BatchSize)
+			if err != nil {
+				return err
+			}
+			m.logger.Printf("verify [%s/%s]: source count=[%d] checksum=[%s], target count=[%d] checksum=[%s]", srcTable, dataType, srcSum.Count, srcSum.Checksum, tgtSum.Count, tgtSum.Checksum)
+			if *srcSum != *tgtSum {
+				mismatch = append(mismatch, fmt.Sprintf("%s/%s", srcTable, dataType))
+			}
+		}
+	}
+	if len(mismatch) > 0 {
+		return fmt.Errorf("source and target do not match on [%s]", strings.Join(mismatch, ", "))
+	}
+	return nil
+}
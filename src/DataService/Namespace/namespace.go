@@ -0,0 +1,275 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+// namespaces of DataService, each namespace hold schemas, records and journals in its own data table
+package Namespace
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+
+	"Data/DbIface"
+	"DataService/Common"
+	"DataService/DataHandler"
+	"DataService/DataJournal"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+	"github.com/salesforce/UniTAO/lib/Util/Json"
+	"github.com/salesforce/UniTAO/lib/Util/Thread"
+)
+
+const (
+	// name of namespace on default data table
+	Default    = ""
+	CurrentVer = "0.0.1"
+	KeyTable   = "table"
+)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+type Namespace struct {
+	Name    string
+	Data    *DataHandler.Handler
+	Journal *DataJournal.JournalLib
+	worker  *Thread.Worker
+}
+
+type Manager struct {
+	root       *Namespace
+	backendCtl *Thread.ThreadCtrl
+	lock       sync.RWMutex
+	spaces     map[string]*Namespace
+	log        *log.Logger
+	jLog       *log.Logger
+}
+
+// NewManager open default namespace on data and all namespaces registered in default data table.
+// journal handler of each namespace run as worker of backendCtl, backendCtl could be nil to not process journals
+func NewManager(data *DataHandler.Handler, backendCtl *Thread.ThreadCtrl, logger *log.Logger, jLogger *log.Logger) (*Manager, *Http.HttpError) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if jLogger == nil {
+		jLogger = logger
+	}
+	m := Manager{
+		backendCtl: backendCtl,
+		spaces:     map[string]*Namespace{},
+		log:        logger,
+		jLog:       jLogger,
+	}
+	root, err := m.open(Default, data)
+	if err != nil {
+		return nil, err
+	}
+	m.root = root
+	recordList, err := root.Data.QueryDb(Common.KeyNamespace, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range recordList {
+		record, ex := Record.LoadMap(data)
+		if ex != nil {
+			return nil, Http.WrapError(ex, fmt.Sprintf("failed to load namespace record [%s]", data[Record.DataId]), http.StatusInternalServerError)
+		}
+		table, _ := record.Data[KeyTable].(string)
+		ns, err := m.open(record.Id, root.Data.ForTable(table))
+		if err != nil {
+			return nil, err
+		}
+		m.spaces[record.Id] = ns
+	}
+	return &m, nil
+}
+
+func (m *Manager) Log(message string) {
+	m.log.Printf("Namespace: %s", message)
+}
+
+// open journal of namespace and start its journal handler
+func (m *Manager) open(name string, data *DataHandler.Handler) (*Namespace, *Http.HttpError) {
+	journal, err := DataJournal.NewJournalLib(data.DB, data.Config.DataTable.Data, m.jLog)
+	if err != nil {
+		return nil, err
+	}
+	data.AddJournal = journal.AddJournal
+	ns := Namespace{
+		Name:    name,
+		Data:    data,
+		Journal: journal,
+	}
+	if m.backendCtl == nil {
+		return &ns, nil
+	}
+	handler, ex := DataJournal.NewJournalHandler(data, journal, m.jLog)
+	if ex != nil {
+		return nil, Http.WrapError(ex, fmt.Sprintf("failed to load Journal Handler of namespace [%s]", name), http.StatusInternalServerError)
+	}
+	workerId := "journalHandler"
+	if name != Default {
+		workerId = fmt.Sprintf("%s_%s", workerId, name)
+	}
+	worker, ex := m.backendCtl.AddWorker(workerId, handler.Run)
+	if ex != nil {
+		return nil, Http.WrapError(ex, fmt.Sprintf("failed to create Journal Handler of namespace [%s] as backend process", name), http.StatusInternalServerError)
+	}
+	journal.HandlerNotify = worker.Notify
+	ns.worker = worker
+	worker.Run()
+	return &ns, nil
+}
+
+// Get return namespace by name, default namespace when name is empty
+func (m *Manager) Get(name string) (*Namespace, *Http.HttpError) {
+	if name == Default {
+		return m.root, nil
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ns, ok := m.spaces[name]
+	if !ok {
+		return nil, Http.NewHttpError(fmt.Sprintf("namespace [%s] does not exists", name), http.StatusNotFound)
+	}
+	return ns, nil
+}
+
+// List return sorted names of namespaces, default namespace is not included
+func (m *Manager) List() []string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	nameList := make([]string, 0, len(m.spaces))
+	for name := range m.spaces {
+		nameList = append(nameList, name)
+	}
+	sort.Strings(nameList)
+	return nameList
+}
+
+// All return default namespace and all namespaces
+func (m *Manager) All() []*Namespace {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	nsList := []*Namespace{m.root}
+	for _, ns := range m.spaces {
+		nsList = append(nsList, ns)
+	}
+	return nsList
+}
+
+// Create namespace with new data table, schemas of internal types are copied from default namespace
+func (m *Manager) Create(name string) *Http.HttpError {
+	if !namePattern.MatchString(name) {
+		return Http.NewHttpError(fmt.Sprintf("invalid namespace name [%s], expect pattern [%s]", name, namePattern), http.StatusBadRequest)
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.spaces[name]; ok {
+		return Http.NewHttpError(fmt.Sprintf("namespace [%s] already exists", name), http.StatusConflict)
+	}
+	config := m.root.Data.Config
+	table := config.DataTable.NamespaceTable(name)
+	meta := map[string]interface{}{}
+	if config.Namespace.TableMeta != nil {
+		meta, _ = Json.CopyToMap(config.Namespace.TableMeta)
+	}
+	m.Log(fmt.Sprintf("create table [%s] for namespace [%s]", table, name))
+	ex := m.root.Data.DB.CreateTable(table, meta)
+	if ex != nil {
+		return Http.WrapError(ex, fmt.Sprintf("failed to create table [%s] of namespace [%s]", table, name), http.StatusInternalServerError)
+	}
+	schemaList, err := m.root.Data.QueryDb(JsonKey.Schema, "", nil)
+	if err != nil {
+		return err
+	}
+	batch := DbIface.NewBatch()
+	for _, schema := range schemaList {
+		schemaId := schema[Record.DataId].(string)
+		if _, ok := Common.InternalTypes[schemaId]; ok {
+			batch.Create(table, schema)
+		}
+	}
+	record := Record.NewRecord(Common.KeyNamespace, CurrentVer, name, map[string]interface{}{
+		KeyTable: table,
+	})
+	batch.Create(config.DataTable.Data, record.Map())
+	ex = m.root.Data.DB.Commit(batch)
+	if ex != nil {
+		m.root.Data.DB.DeleteTable(table)
+		return Http.WrapError(ex, fmt.Sprintf("failed to initialize namespace [%s]", name), Common.CommitStatus(ex))
+	}
+	ns, err := m.open(name, m.root.Data.ForTable(table))
+	if err != nil {
+		m.Log(fmt.Sprintf("failed to open namespace [%s], remove its table and record", name))
+		m.root.Data.DB.DeleteTable(table)
+		m.root.Data.DB.Delete(config.DataTable.Data, map[string]interface{}{
+			Record.DataType: Common.KeyNamespace,
+			Record.DataId:   name,
+		})
+		return err
+	}
+	m.spaces[name] = ns
+	m.Log(fmt.Sprintf("namespace [%s] created", name))
+	return nil
+}
+
+// Delete namespace with all its data, journals are dropped without being processed
+func (m *Manager) Delete(name string) *Http.HttpError {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	ns, ok := m.spaces[name]
+	if !ok {
+		return Http.NewHttpError(fmt.Sprintf("namespace [%s] does not exists", name), http.StatusNotFound)
+	}
+	// subscribers of the namespace are ended before its table is dropped
+	ns.Journal.Feed.Close(Http.NewHttpError(fmt.Sprintf("namespace [%s] is deleted", name), http.StatusGone))
+	if ns.worker != nil {
+		// journal handler could still write to the table, and its worker id is reused when namespace is created again.
+		// handler stop go routines of its processes, such as webhook dispatchers, before it exits
+		ns.worker.Stop()
+		ns.worker.Wait()
+	}
+	delete(m.spaces, name)
+	table := ns.Data.Config.DataTable.Data
+	m.Log(fmt.Sprintf("delete table [%s] of namespace [%s]", table, name))
+	ex := m.root.Data.DB.DeleteTable(table)
+	if ex != nil {
+		return Http.WrapError(ex, fmt.Sprintf("failed to delete table [%s] of namespace [%s]", table, name), http.StatusInternalServerError)
+	}
+	ex = m.root.Data.DB.Delete(m.root.Data.Config.DataTable.Data, map[string]interface{}{
+		Record.DataType: Common.KeyNamespace,
+		Record.DataId:   name,
+	})
+	if ex != nil {
+		return Http.WrapError(ex, fmt.Sprintf("failed to delete record of namespace [%s]", name), http.StatusInternalServerError)
+	}
+	m.Log(fmt.Sprintf("namespace [%s] deleted", name))
+	return nil
+}
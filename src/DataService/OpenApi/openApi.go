@@ -0,0 +1,537 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package OpenApi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"DataService/Common"
+	"DataService/DataHandler"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Schema/SchemaDoc"
+	"github.com/salesforce/UniTAO/lib/Util"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+const (
+	Version = "3.0.3"
+
+	ComponentPrefix = "#/components/schemas/"
+	ParameterPrefix = "#/components/parameters/"
+	// component of definition is {type}.{definition}, record of type is {type}.__record
+	DefinitionDiv = "."
+	RecordSuffix  = ".__record"
+	// shared components
+	KeyError         = "__HttpError"
+	KeyRecord        = "__Record"
+	KeyJsonPatch     = "__JsonPatchOperation"
+	ParamId          = "id"
+	ParamPath        = "path"
+	ParamNamespace   = "namespace"
+	ParamIfMatch     = "ifMatch"
+	ParamIfNoneMatch = "ifNoneMatch"
+	ExtCmt           = "x-contentMediaType"
+	ContentTypeJson  = "application/json"
+	ContentTypeText  = "text/plain"
+)
+
+// keywords of attribute definition that are the same in OpenAPI schema object
+var schemaKeys = []string{
+	"description", "enum", "default", "format", "pattern",
+	"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum",
+	"minLength", "maxLength", "minItems", "maxItems", "uniqueItems", "readOnly",
+}
+
+// Build OpenAPI document from current schema records of data handler,
+// every current and archived schema has a component, paths are generated for current schemas
+func Build(data *DataHandler.Handler, title string) (map[string]interface{}, *Http.HttpError) {
+	schemaList, err := data.QueryDb(JsonKey.Schema, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(schemaList, func(i, j int) bool {
+		return fmt.Sprint(schemaList[i][Record.DataId]) < fmt.Sprint(schemaList[j][Record.DataId])
+	})
+	schemas := sharedSchemas()
+	paths := map[string]interface{}{}
+	recordRefs := []interface{}{}
+	for _, schemaData := range schemaList {
+		if DataHandler.IsDeleted(schemaData) {
+			continue
+		}
+		schemaId, _ := schemaData[Record.DataId].(string)
+		dataType, ver := Util.ParseCustomPath(schemaId, JsonKey.ArchivedSchemaIdDiv)
+		if _, ok := Common.InternalTypes[dataType]; ok && dataType != JsonKey.Schema {
+			continue
+		}
+		record, ex := Record.LoadMap(schemaData)
+		if ex != nil {
+			return nil, Http.WrapError(ex, fmt.Sprintf("failed to load schema [%s] as record", schemaId), http.StatusInternalServerError)
+		}
+		doc, ex := SchemaDoc.New(record.Data)
+		if ex != nil {
+			return nil, Http.WrapError(ex, fmt.Sprintf("failed to load schema [%s]", schemaId), http.StatusInternalServerError)
+		}
+		addComponents(doc, schemaId, schemas, ver != "")
+		if ver != "" {
+			continue
+		}
+		recordRefs = append(recordRefs, ref(schemaId+RecordSuffix))
+		addPaths(paths, schemaId)
+	}
+	if len(recordRefs) > 0 {
+		paths["/"] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"operationId": "add",
+				"summary":     "add record of any type",
+				"parameters":  []interface{}{paramRef(ParamNamespace)},
+				"requestBody": jsonBody(map[string]interface{}{"oneOf": recordRefs}),
+				"responses": responses(map[string]interface{}{
+					"201": textResponse("id of new record"),
+				}, http.StatusBadRequest, http.StatusConflict),
+			},
+		}
+	}
+	doc := map[string]interface{}{
+		"openapi": Version,
+		"info": map[string]interface{}{
+			"title":       title,
+			"description": "generated from schema records of the DataService",
+			"version":     "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas":    schemas,
+			"parameters": sharedParameters(),
+		},
+	}
+	return doc, nil
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{
+		JsonKey.Ref: ComponentPrefix + name,
+	}
+}
+
+func paramRef(name string) map[string]interface{} {
+	return map[string]interface{}{
+		JsonKey.Ref: ParameterPrefix + name,
+	}
+}
+
+// components of root doc and all of its definitions, record envelope of data type
+func addComponents(doc *SchemaDoc.SchemaDoc, name string, schemas map[string]interface{}, deprecated bool) {
+	names := map[*SchemaDoc.SchemaDoc]string{}
+	var collect func(d *SchemaDoc.SchemaDoc, dName string)
+	collect = func(d *SchemaDoc.SchemaDoc, dName string) {
+		names[d] = dName
+		for defName, defDoc := range d.Definitions {
+			collect(defDoc, dName+DefinitionDiv+defName)
+		}
+	}
+	collect(doc, name)
+	for d, dName := range names {
+		component := objectSchema(d, names)
+		if deprecated {
+			component["deprecated"] = true
+		}
+		schemas[dName] = component
+	}
+	envelope := map[string]interface{}{
+		"allOf": []interface{}{
+			ref(KeyRecord),
+			map[string]interface{}{
+				"type": JsonKey.Object,
+				"properties": map[string]interface{}{
+					Record.Data: ref(name),
+				},
+			},
+		},
+	}
+	if deprecated {
+		envelope["deprecated"] = true
+	}
+	schemas[name+RecordSuffix] = envelope
+}
+
+func objectSchema(doc *SchemaDoc.SchemaDoc, names map[*SchemaDoc.SchemaDoc]string) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for attrName, attrDef := range doc.Properties() {
+		properties[attrName] = attrSchema(doc, attrName, attrDef.(map[string]interface{}), names)
+	}
+	result := map[string]interface{}{
+		"type":       JsonKey.Object,
+		"properties": properties,
+	}
+	if description, ok := doc.Data["description"]; ok {
+		result["description"] = description
+	}
+	if required, ok := doc.Data[JsonKey.Required].([]interface{}); ok {
+		requiredList := make([]string, 0, len(required))
+		for _, attrName := range required {
+			requiredList = append(requiredList, attrName.(string))
+		}
+		sort.Strings(requiredList)
+		result[JsonKey.Required] = requiredList
+	}
+	if additional, ok := doc.Data[JsonKey.AdditionalProperties].(bool); ok {
+		result[JsonKey.AdditionalProperties] = additional
+	}
+	return result
+}
+
+func attrSchema(doc *SchemaDoc.SchemaDoc, attrName string, attrDef map[string]interface{}, names map[*SchemaDoc.SchemaDoc]string) map[string]interface{} {
+	subDoc := doc.SubDocs[attrName]
+	switch attrDef[JsonKey.Type] {
+	case JsonKey.Array:
+		result := simpleSchema(attrDef)
+		itemDef, _ := attrDef[JsonKey.Items].(map[string]interface{})
+		result[JsonKey.Items] = itemSchema(subDoc, itemDef, names)
+		return result
+	case JsonKey.Object:
+		if SchemaDoc.IsMap(attrDef) {
+			result := simpleSchema(attrDef)
+			itemDef, _ := attrDef[JsonKey.AdditionalProperties].(map[string]interface{})
+			result[JsonKey.AdditionalProperties] = itemSchema(subDoc, itemDef, names)
+			return result
+		}
+		if subDoc != nil {
+			return refSchema(subDoc, attrDef, names)
+		}
+		result := simpleSchema(attrDef)
+		if additional, ok := attrDef[JsonKey.AdditionalProperties].(bool); ok {
+			result[JsonKey.AdditionalProperties] = additional
+		}
+		return result
+	}
+	return simpleSchema(attrDef)
+}
+
+func itemSchema(subDoc *SchemaDoc.SchemaDoc, itemDef map[string]interface{}, names map[*SchemaDoc.SchemaDoc]string) map[string]interface{} {
+	if itemDef == nil {
+		return map[string]interface{}{}
+	}
+	if itemDef[JsonKey.Type] == JsonKey.Object && subDoc != nil {
+		return refSchema(subDoc, itemDef, names)
+	}
+	return simpleSchema(itemDef)
+}
+
+// $ref cannot have siblings in OpenAPI 3.0, description is kept with allOf
+func refSchema(subDoc *SchemaDoc.SchemaDoc, attrDef map[string]interface{}, names map[*SchemaDoc.SchemaDoc]string) map[string]interface{} {
+	result := ref(names[subDoc])
+	description, ok := attrDef["description"]
+	if !ok {
+		return result
+	}
+	return map[string]interface{}{
+		"description": description,
+		"allOf":       []interface{}{result},
+	}
+}
+
+func simpleSchema(attrDef map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	if attrType, ok := attrDef[JsonKey.Type]; ok {
+		result["type"] = attrType
+	}
+	for _, key := range schemaKeys {
+		if value, ok := attrDef[key]; ok {
+			result[key] = value
+		}
+	}
+	if SchemaDoc.IsCmtRef(attrDef) {
+		cmt := attrDef[JsonKey.ContentMediaType].(string)
+		result[ExtCmt] = cmt
+		if _, ok := result["description"]; !ok {
+			_, refType := Util.ParsePath(cmt)
+			result["description"] = fmt.Sprintf("id of [%s]", refType)
+		}
+	}
+	return result
+}
+
+func addPaths(paths map[string]interface{}, dataType string) {
+	recordRef := ref(dataType + RecordSuffix)
+	paths["/"+dataType] = map[string]interface{}{
+		"parameters": []interface{}{paramRef(ParamNamespace)},
+		"get": map[string]interface{}{
+			"operationId": "list_" + dataType,
+			"summary":     fmt.Sprintf("list ids or records of [%s]", dataType),
+			"parameters":  listParameters(),
+			"responses": responses(map[string]interface{}{
+				"200": jsonResponse("ids, records, or a page of them when paged", map[string]interface{}{
+					"oneOf": []interface{}{
+						arraySchema(map[string]interface{}{"type": JsonKey.String}),
+						arraySchema(recordRef),
+						map[string]interface{}{
+							"type": JsonKey.Object,
+							"properties": map[string]interface{}{
+								Common.KeyItems:         map[string]interface{}{"type": JsonKey.Array},
+								Common.KeyNextPageToken: map[string]interface{}{"type": JsonKey.String},
+							},
+						},
+					},
+				}),
+			}, http.StatusBadRequest, http.StatusNotFound),
+		},
+	}
+	paths[fmt.Sprintf("/%s/{%s}", dataType, ParamId)] = map[string]interface{}{
+		"parameters": []interface{}{paramRef(ParamNamespace), paramRef(ParamId)},
+		"get": map[string]interface{}{
+			"operationId": "get_" + dataType,
+			"summary":     fmt.Sprintf("get record of [%s]", dataType),
+			"parameters":  []interface{}{paramRef(ParamIfNoneMatch)},
+			"responses": responses(map[string]interface{}{
+				"200": withETag(jsonResponse("record", recordRef)),
+				"304": map[string]interface{}{"description": "record matches If-None-Match"},
+			}, http.StatusNotFound),
+		},
+		"put": map[string]interface{}{
+			"operationId": "set_" + dataType,
+			"summary":     fmt.Sprintf("create or replace record of [%s]", dataType),
+			"parameters":  []interface{}{paramRef(ParamIfMatch)},
+			"requestBody": jsonBody(recordRef),
+			"responses": responses(map[string]interface{}{
+				"201": withETag(textResponse("id of record")),
+			}, http.StatusBadRequest, http.StatusConflict, http.StatusPreconditionFailed),
+		},
+		"patch": map[string]interface{}{
+			"operationId": "patch_" + dataType,
+			"summary":     fmt.Sprintf("patch record of [%s] with JSON Patch or JSON Merge Patch", dataType),
+			"parameters":  []interface{}{paramRef(ParamIfMatch)},
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					Common.ContentTypeJsonPatch: map[string]interface{}{
+						"schema": arraySchema(ref(KeyJsonPatch)),
+					},
+					Common.ContentTypeMergePatch: map[string]interface{}{
+						"schema": map[string]interface{}{"type": JsonKey.Object},
+					},
+				},
+			},
+			"responses": responses(map[string]interface{}{
+				"202": withETag(jsonResponse("patched record", recordRef)),
+			}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed),
+		},
+		"delete": map[string]interface{}{
+			"operationId": "delete_" + dataType,
+			"summary":     fmt.Sprintf("delete record of [%s]", dataType),
+			"parameters":  []interface{}{paramRef(ParamIfMatch)},
+			"responses": responses(map[string]interface{}{
+				"202": jsonResponse("record deleted", map[string]interface{}{"type": JsonKey.Object}),
+			}, http.StatusBadRequest, http.StatusPreconditionFailed),
+		},
+	}
+	paths[fmt.Sprintf("/%s/{%s}/{%s}", dataType, ParamId, ParamPath)] = map[string]interface{}{
+		"parameters": []interface{}{paramRef(ParamNamespace), paramRef(ParamId), paramRef(ParamPath)},
+		"get": map[string]interface{}{
+			"operationId": "query_" + dataType,
+			"summary":     fmt.Sprintf("SchemaPath query from record of [%s]", dataType),
+			"responses": responses(map[string]interface{}{
+				"200": jsonResponse("value on path", map[string]interface{}{}),
+			}, http.StatusBadRequest, http.StatusNotFound),
+		},
+		"patch": map[string]interface{}{
+			"operationId": "patchPath_" + dataType,
+			"summary":     fmt.Sprintf("set or remove value on path of record of [%s], null value remove it", dataType),
+			"parameters":  []interface{}{paramRef(ParamIfMatch)},
+			"requestBody": jsonBody(map[string]interface{}{"nullable": true}),
+			"responses": responses(map[string]interface{}{
+				"202": withETag(jsonResponse("patched record", recordRef)),
+			}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed),
+		},
+	}
+}
+
+func listParameters() []interface{} {
+	query := func(name string, description string, schema map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"name":        name,
+			"in":          "query",
+			"description": description,
+			"schema":      schema,
+		}
+	}
+	stringSchema := map[string]interface{}{"type": JsonKey.String}
+	intSchema := map[string]interface{}{"type": JsonKey.Integer, "minimum": 0}
+	filter := query(Common.QueryFilter, "filter on attribute path, format={path}:{op}:{value}, op is one of eq, ne, gt, gte, lt, lte, in", arraySchema(stringSchema))
+	filter["explode"] = true
+	return []interface{}{
+		filter,
+		query(Common.QuerySort, "comma separated attribute paths, prefix - for descending order", stringSchema),
+		query(Common.QueryLimit, "max number of items", intSchema),
+		query(Common.QueryView, "return ids or full records", map[string]interface{}{
+			"type": JsonKey.String,
+			"enum": []interface{}{Common.ViewIds, Common.ViewRecords},
+		}),
+		query(Common.QueryPageSize, "page size of paged list", intSchema),
+		query(Common.QueryPageToken, "token of next page from previous page", stringSchema),
+	}
+}
+
+func sharedParameters() map[string]interface{} {
+	stringSchema := map[string]interface{}{"type": JsonKey.String}
+	return map[string]interface{}{
+		ParamId: map[string]interface{}{
+			"name":     ParamId,
+			"in":       "path",
+			"required": true,
+			"schema":   stringSchema,
+		},
+		// path parameter of OpenAPI is one segment, server decode request uri before parsing path
+		ParamPath: map[string]interface{}{
+			"name":        ParamPath,
+			"in":          "path",
+			"required":    true,
+			"description": "attribute path in record data, could have multiple levels, ex: attr1/attr2[key]/attr3. send [/] between levels encoded as %2F",
+			"example":     "attr1%2Fattr2[key]%2Fattr3",
+			"schema":      stringSchema,
+		},
+		ParamNamespace: map[string]interface{}{
+			"name":        Common.HeaderNamespace,
+			"in":          "header",
+			"description": "namespace of request, default namespace when missing",
+			"schema":      stringSchema,
+		},
+		ParamIfMatch: map[string]interface{}{
+			"name":        Common.HeaderIfMatch,
+			"in":          "header",
+			"description": "ETag of current record, request fails with 412 when it does not match",
+			"schema":      stringSchema,
+		},
+		ParamIfNoneMatch: map[string]interface{}{
+			"name":        Common.HeaderIfNoneMatch,
+			"in":          "header",
+			"description": "ETag of cached record, 304 when it still matches",
+			"schema":      stringSchema,
+		},
+	}
+}
+
+func sharedSchemas() map[string]interface{} {
+	stringSchema := map[string]interface{}{"type": JsonKey.String}
+	return map[string]interface{}{
+		KeyError: map[string]interface{}{
+			"type": JsonKey.Object,
+			"properties": map[string]interface{}{
+				"httpStatus": map[string]interface{}{"type": JsonKey.Integer},
+				"message":    arraySchema(stringSchema),
+				"code":       map[string]interface{}{"type": JsonKey.Integer},
+				"context":    arraySchema(stringSchema),
+				"payload":    map[string]interface{}{},
+			},
+		},
+		KeyRecord: map[string]interface{}{
+			"type": JsonKey.Object,
+			"properties": map[string]interface{}{
+				Record.DataId:   stringSchema,
+				Record.DataType: stringSchema,
+				Record.Version:  stringSchema,
+				Record.Revision: map[string]interface{}{"type": JsonKey.Integer, "readOnly": true},
+				Record.Deleted:  map[string]interface{}{"type": JsonKey.String, "readOnly": true},
+				Record.Expire:   stringSchema,
+				Record.Data:     map[string]interface{}{"type": JsonKey.Object},
+			},
+			JsonKey.Required: []string{Record.DataId, Record.DataType, Record.Version, Record.Data},
+		},
+		KeyJsonPatch: map[string]interface{}{
+			"type": JsonKey.Object,
+			"properties": map[string]interface{}{
+				"op": map[string]interface{}{
+					"type": JsonKey.String,
+					"enum": []interface{}{"add", "remove", "replace", "move", "copy", "test"},
+				},
+				"path":  stringSchema,
+				"from":  stringSchema,
+				"value": map[string]interface{}{},
+			},
+			JsonKey.Required: []string{"op", "path"},
+		},
+	}
+}
+
+func arraySchema(items map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        JsonKey.Array,
+		JsonKey.Items: items,
+	}
+}
+
+func jsonBody(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			ContentTypeJson: map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+func jsonResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			ContentTypeJson: map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+func textResponse(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			ContentTypeText: map[string]interface{}{
+				"schema": map[string]interface{}{"type": JsonKey.String},
+			},
+		},
+	}
+}
+
+func withETag(response map[string]interface{}) map[string]interface{} {
+	response["headers"] = map[string]interface{}{
+		Common.HeaderETag: map[string]interface{}{
+			"description": "ETag of record",
+			"schema":      map[string]interface{}{"type": JsonKey.String},
+		},
+	}
+	return response
+}
+
+// add error responses of status codes
+func responses(result map[string]interface{}, errStatusList ...int) map[string]interface{} {
+	for _, status := range errStatusList {
+		result[strconv.Itoa(status)] = jsonResponse(strings.ToLower(http.StatusText(status)), ref(KeyError))
+	}
+	return result
+}
@@ -0,0 +1,327 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+// role based access control on types, records and attribute paths of records
+package Rbac
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"DataService/Common"
+	"DataService/DataHandler"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+	"github.com/salesforce/UniTAO/lib/Util/Json"
+)
+
+const (
+	CurrentVer = "0.0.1"
+	// role of callers allowed to do everything, no record needed
+	RoleAdmin = "admin"
+	// match all types except explicit types, all verbs, or everyone in subjects of binding
+	Wildcard   = "*"
+	VerbGet    = "get"
+	VerbPost   = "post"
+	VerbPut    = "put"
+	VerbPatch  = "patch"
+	VerbDelete = "delete"
+)
+
+// ExplicitTypes are not covered by Wildcard same as internal types, rules need to name them
+var ExplicitTypes = map[string]bool{
+	Common.KeyImport: true,
+}
+
+type Role struct {
+	Name  string `json:"name"`
+	Rules []Rule `json:"rules"`
+}
+
+type Rule struct {
+	Name      string   `json:"name"`
+	DataTypes []string `json:"dataTypes"`
+	Verbs     []string `json:"verbs"`
+	Paths     []string `json:"paths,omitempty"`
+}
+
+type RoleBinding struct {
+	Name     string   `json:"name"`
+	Role     string   `json:"role"`
+	Subjects []string `json:"subjects"`
+}
+
+// Allow verb on path of data of dataType, empty path is the whole record.
+// rule with paths only allow the attributes under them
+func (r *Rule) Allow(verb string, dataType string, path string) bool {
+	if !r.matchType(dataType) || !contains(r.Verbs, verb) {
+		return false
+	}
+	if len(r.Paths) == 0 {
+		return true
+	}
+	for _, prefix := range r.Paths {
+		if MatchPath(prefix, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchType(dataType string) bool {
+	for _, ruleType := range r.DataTypes {
+		if ruleType == dataType {
+			return true
+		}
+		if ruleType == Wildcard && !isExplicit(dataType) {
+			return true
+		}
+	}
+	return false
+}
+
+func isExplicit(dataType string) bool {
+	if _, ok := Common.InternalTypes[dataType]; ok {
+		return true
+	}
+	return ExplicitTypes[dataType]
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value || item == Wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPath return true when path is prefix or under it, ex: prefix [rack] match [rack/name] and [rack[0]]
+func MatchPath(prefix string, path string) bool {
+	prefix = strings.Trim(prefix, "/")
+	path = strings.Trim(path, "/")
+	if prefix == "" || path == "" {
+		return false
+	}
+	if path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, prefix+"/") || strings.HasPrefix(path, prefix+"[")
+}
+
+// Manager check access with roles and bindings in table of data handler. nothing is checked when disabled
+type Manager struct {
+	Data    *DataHandler.Handler
+	Enabled bool
+}
+
+func NewManager(data *DataHandler.Handler, enabled bool) *Manager {
+	return &Manager{
+		Data:    data,
+		Enabled: enabled,
+	}
+}
+
+// Check return StatusForbidden when identity is not allowed, nil identity is anonymous caller
+func (m *Manager) Check(identity *Http.Identity, verb string, dataType string, path string) *Http.HttpError {
+	if !m.Enabled {
+		return nil
+	}
+	roleNames, err := m.rolesOf(identity)
+	if err != nil {
+		return err
+	}
+	if roleNames[RoleAdmin] {
+		return nil
+	}
+	for name := range roleNames {
+		role, err := m.GetRole(name)
+		if err != nil {
+			if err.Status == http.StatusNotFound {
+				continue
+			}
+			return err
+		}
+		for _, rule := range role.Rules {
+			if rule.Allow(verb, dataType, path) {
+				return nil
+			}
+		}
+	}
+	caller := "anonymous"
+	if identity != nil {
+		caller = identity.Name
+	}
+	target := dataType
+	if path != "" {
+		target = fmt.Sprintf("%s/%s", dataType, path)
+	}
+	return Http.NewHttpError(fmt.Sprintf("[%s] is not allowed to [%s] on [%s]", caller, verb, target), http.StatusForbidden)
+}
+
+// Visible return filter of types identity is allowed to verb on whole records, nil when nothing is checked.
+// result of each type is kept, so it is for one request or subscription
+func (m *Manager) Visible(identity *Http.Identity, verb string) func(dataType string) bool {
+	if !m.Enabled {
+		return nil
+	}
+	lock := sync.Mutex{}
+	allowed := map[string]bool{}
+	return func(dataType string) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		if result, ok := allowed[dataType]; ok {
+			return result
+		}
+		allowed[dataType] = m.Check(identity, verb, dataType, "") == nil
+		return allowed[dataType]
+	}
+}
+
+// rolesOf return roles of identity itself and from bindings with subject of identity name, identity role or Wildcard
+func (m *Manager) rolesOf(identity *Http.Identity) (map[string]bool, *Http.HttpError) {
+	subjects := map[string]bool{
+		Wildcard: true,
+	}
+	roleNames := map[string]bool{}
+	if identity != nil {
+		subjects[identity.Name] = true
+		for _, role := range identity.Roles {
+			subjects[role] = true
+			roleNames[role] = true
+		}
+	}
+	bindingList, err := m.ListBindings()
+	if err != nil {
+		return nil, err
+	}
+	for _, binding := range bindingList {
+		for _, subject := range binding.Subjects {
+			if subjects[subject] {
+				roleNames[binding.Role] = true
+				break
+			}
+		}
+	}
+	return roleNames, nil
+}
+
+func (m *Manager) GetRole(name string) (*Role, *Http.HttpError) {
+	record, err := m.Get(Common.KeyRole, name)
+	if err != nil {
+		return nil, err
+	}
+	role := Role{}
+	ex := Json.CopyTo(record.Data, &role)
+	if ex != nil {
+		return nil, Http.WrapError(ex, fmt.Sprintf("invalid record of %s [%s]", Common.KeyRole, name), http.StatusInternalServerError)
+	}
+	return &role, nil
+}
+
+func (m *Manager) ListBindings() ([]*RoleBinding, *Http.HttpError) {
+	recordList, err := m.Data.QueryDb(Common.KeyRoleBinding, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	bindingList := make([]*RoleBinding, 0, len(recordList))
+	for _, data := range recordList {
+		record, ex := Record.LoadMap(data)
+		if ex != nil {
+			return nil, Http.WrapError(ex, fmt.Sprintf("invalid record of %s", Common.KeyRoleBinding), http.StatusInternalServerError)
+		}
+		binding := RoleBinding{}
+		ex = Json.CopyTo(record.Data, &binding)
+		if ex != nil {
+			return nil, Http.WrapError(ex, fmt.Sprintf("invalid record of %s [%s]", Common.KeyRoleBinding, record.Id), http.StatusInternalServerError)
+		}
+		bindingList = append(bindingList, &binding)
+	}
+	return bindingList, nil
+}
+
+// List names of roles or bindings
+func (m *Manager) List(dataType string) ([]string, *Http.HttpError) {
+	recordList, err := m.Data.QueryDb(dataType, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	nameList := make([]string, 0, len(recordList))
+	for _, data := range recordList {
+		if name, ok := data[Record.DataId].(string); ok {
+			nameList = append(nameList, name)
+		}
+	}
+	return nameList, nil
+}
+
+func (m *Manager) Get(dataType string, name string) (*Record.Record, *Http.HttpError) {
+	recordList, err := m.Data.QueryDb(dataType, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(recordList) == 0 {
+		return nil, Http.NewHttpError(fmt.Sprintf("%s [%s] does not exists", dataType, name), http.StatusNotFound)
+	}
+	record, ex := Record.LoadMap(recordList[0])
+	if ex != nil {
+		return nil, Http.WrapError(ex, fmt.Sprintf("invalid record of %s [%s]", dataType, name), http.StatusInternalServerError)
+	}
+	return record, nil
+}
+
+// Set create or replace role or binding with name, name in data is set to the name of record
+func (m *Manager) Set(dataType string, name string, data map[string]interface{}) (*Record.Record, *Http.HttpError) {
+	if dataType != Common.KeyRole && dataType != Common.KeyRoleBinding {
+		return nil, Http.NewHttpError(fmt.Sprintf("invalid type [%s], expect [%s] or [%s]", dataType, Common.KeyRole, Common.KeyRoleBinding), http.StatusBadRequest)
+	}
+	if name == "" || name == RoleAdmin {
+		return nil, Http.NewHttpError(fmt.Sprintf("invalid %s name [%s]", dataType, name), http.StatusBadRequest)
+	}
+	data["name"] = name
+	record := Record.NewRecord(dataType, CurrentVer, name, data)
+	err := m.Data.SetInternal(record)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (m *Manager) Delete(dataType string, name string) *Http.HttpError {
+	if dataType != Common.KeyRole && dataType != Common.KeyRoleBinding {
+		return Http.NewHttpError(fmt.Sprintf("invalid type [%s], expect [%s] or [%s]", dataType, Common.KeyRole, Common.KeyRoleBinding), http.StatusBadRequest)
+	}
+	if name == "" {
+		return Http.NewHttpError(fmt.Sprintf("invalid %s name [%s]", dataType, name), http.StatusBadRequest)
+	}
+	_, err := m.Get(dataType, name)
+	if err != nil {
+		return err
+	}
+	return m.Data.Delete(dataType, name)
+}
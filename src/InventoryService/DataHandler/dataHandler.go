@@ -27,12 +27,14 @@ package DataHandler
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 
 	"Data"
 	"Data/DbConfig"
 	"Data/DbIface"
+	"Data/DbMetrics"
 	"InventoryService/InvRecord"
 	"InventoryService/RefRecord"
 
@@ -47,8 +49,11 @@ import (
 )
 
 type Handler struct {
-	log *log.Logger
-	Db  DbIface.Database
+	log     *log.Logger
+	Db      DbIface.Database
+	metrics *DbMetrics.Database
+	// credential of inventory when calling data services
+	Credential *Http.Credential
 }
 
 var InvTypes = map[string]bool{
@@ -69,11 +74,15 @@ func New(config DbConfig.DatabaseConfig, logger *log.Logger) (*Handler, error) {
 	if err != nil {
 		return nil, err
 	}
+	metrics, err := DbMetrics.New(db, logger)
+	if err != nil {
+		return nil, err
+	}
 	handler := Handler{
-		log: logger,
-		Db:  db,
+		log:     logger,
+		Db:      metrics,
+		metrics: metrics,
 	}
-	handler.Db = db
 	err = handler.init()
 	if err != nil {
 		return nil, err
@@ -81,6 +90,11 @@ func New(config DbConfig.DatabaseConfig, logger *log.Logger) (*Handler, error) {
 	return &handler, nil
 }
 
+// WriteMetrics write Database metrics in Prometheus text exposition format
+func (h *Handler) WriteMetrics(w io.Writer) {
+	h.metrics.WriteText(w)
+}
+
 func (h *Handler) Log(msg string) {
 	h.log.Printf(fmt.Sprintf("InvSrvHandler: %s", msg))
 }
@@ -147,7 +161,7 @@ func (h *Handler) List(dataType string) ([]interface{}, *Http.HttpError) {
 	if e != nil {
 		return nil, Http.WrapError(e, fmt.Sprintf("failed to parse url from data service [%s]=[%s], url=[%s]", Record.DataId, referral.DsInfo.Id, dsUrl), http.StatusInternalServerError)
 	}
-	data, code, e := Http.GetRestData(*urlPath)
+	data, code, e := Http.GetRestDataAuth(*urlPath, h.Credential.For(referral.AuthType))
 	if e != nil {
 		return nil, Http.WrapError(e, fmt.Sprintf("failed to get data from REST URL=[%s]", *urlPath), code)
 
@@ -190,7 +204,7 @@ func (h *Handler) GetSchemaRecord(dataType string) (*Record.Record, *Http.HttpEr
 	if err != nil {
 		return nil, err
 	}
-	return referral.GetSchema(dataType, h.log)
+	return referral.GetSchema(dataType, h.Credential, h.log)
 }
 
 func (h *Handler) GetRecord(dataType string, dataId string) (*Record.Record, *Http.HttpError) {
@@ -207,7 +221,7 @@ func (h *Handler) GetRecord(dataType string, dataId string) (*Record.Record, *Ht
 			return nil, err
 		}
 		// when query referral, we also want to display schema of the dataType
-		record, err := referral.GetSchema(dataId, h.log)
+		record, err := referral.GetSchema(dataId, h.Credential, h.log)
 		if err != nil {
 			return nil, err
 		}
@@ -280,7 +294,7 @@ func (h *Handler) GetDataServiceData(dataType string, dataId string) (interface{
 	if e != nil {
 		return nil, Http.WrapError(e, fmt.Sprintf("failed to parse url from data service [%s]=[%s], url=[%s]", Record.DataId, referral.DsId, dsUrl), http.StatusInternalServerError)
 	}
-	data, code, e := Http.GetRestData(*idPath)
+	data, code, e := Http.GetRestDataAuth(*idPath, h.Credential.For(referral.AuthType))
 	if e != nil {
 		if code == http.StatusNotFound {
 			return nil, Http.NewHttpError(e.Error(), code)
@@ -26,6 +26,7 @@ This copyright notice and license applies to all files in this directory or sub-
 package InventoryServer
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"log"
@@ -57,6 +58,8 @@ const (
 	CONFIG       = "config"
 	PORT         = "port"
 	PORT_DEFAULT = "8003"
+	// Database metrics in Prometheus text format on GET /metrics
+	METRICS = "metrics"
 )
 
 func argHandler() ServerArgs {
@@ -113,10 +116,15 @@ func (srv *Server) Run() {
 	if err != nil {
 		srv.log.Fatalf("failed to initialize data layer, Err:%s", err)
 	}
+	handler.Credential = srv.config.Http.Auth.Peer
 	srv.data = handler
-	http.HandleFunc("/", srv.handler)
+	auth, ex := Http.NewAuthHandler(srv.config.Http.Auth)
+	if ex != nil {
+		srv.log.Fatalf("failed to load auth config, Err:%s", ex)
+	}
+	http.HandleFunc("/", auth.Middleware(srv.handler, srv.config.Http))
 	srv.log.Printf("Data Server Listen @%s://%s:%s", srv.config.Http.HttpType, srv.config.Http.DnsName, srv.Port)
-	srv.log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", srv.Port), nil))
+	srv.log.Fatal(Http.ListenAndServe(srv.Port, nil, srv.config.Http))
 }
 
 func (srv *Server) handler(w http.ResponseWriter, r *http.Request) {
@@ -144,6 +152,12 @@ func (srv *Server) handleGet(w http.ResponseWriter, r *http.Request) {
 		Http.ResponseJson(w, err, err.Status, srv.config.Http)
 		return
 	}
+	if dataType == METRICS && dataPath == "" {
+		buf := bytes.Buffer{}
+		srv.data.WriteMetrics(&buf)
+		Http.ResponseText(w, buf.Bytes(), http.StatusOK, srv.config.Http)
+		return
+	}
 	if dataPath == "" {
 		idList, err := srv.data.List(dataType)
 		if err != nil {
@@ -90,7 +90,8 @@ func LoadMap(data map[string]interface{}) (*ReferralData, error) {
 	return &record, nil
 }
 
-func (r *ReferralData) GetSchema(dataType string, logger *log.Logger) (*Record.Record, *Http.HttpError) {
+// GetSchema from data service with credential of inventory, limited to AuthType of the data service
+func (r *ReferralData) GetSchema(dataType string, cred *Http.Credential, logger *log.Logger) (*Record.Record, *Http.HttpError) {
 	if logger == nil {
 		logger = log.Default()
 	}
@@ -107,7 +108,7 @@ func (r *ReferralData) GetSchema(dataType string, logger *log.Logger) (*Record.R
 		return nil, Http.NewHttpError(msg, http.StatusInternalServerError)
 	}
 	schemaUrl := fmt.Sprintf("%s/%s/%s", dsUrl, JsonKey.Schema, dataType)
-	schemaData, code, err := Http.GetRestData(schemaUrl)
+	schemaData, code, err := Http.GetRestDataAuth(schemaUrl, cred.For(r.AuthType))
 	if err != nil {
 		logger.Print(err.Error())
 		return nil, Http.NewHttpError(err.Error(), code)
@@ -363,3 +363,37 @@ func TestAddCmtSchema(t *testing.T) {
 	addLayer1DataAndLeafDataAndDelete(env)
 	processJournal(env, "categoryLayer1", "Layer1Cat01")
 }
+
+// version mismatch of PATCH is StatusPreconditionFailed, index on parent of other version is skipped
+func TestCmtIndexSkipOtherVersion(t *testing.T) {
+	env := prepEnv(t)
+	addLeafSchemaAndData(env)
+	addLayer1SchemaDataAndUpgradSchema(env)
+	_, err := env.Handler.Patch("categoryLayer1", "Layer1Cat01/testAry", map[string]interface{}{
+		JsonKey.Version: "0.0.2",
+	}, []interface{}{"test01"})
+	if err == nil || err.Status != http.StatusPreconditionFailed {
+		t.Fatalf("expect status [%d] on version mismatch, got %v", http.StatusPreconditionFailed, err)
+	}
+	test03Str := `{
+		"__id": "test03",
+		"__type": "test",
+		"__ver": "0.0.1",
+		"data": {
+			"attr1": "test",
+			"categoryLayer1": "Layer1Cat01"
+		}
+	}`
+	addData(env, test03Str)
+	data, err := env.Handler.LocalData("categoryLayer1", "Layer1Cat01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	catLayer1, ex := Record.LoadMap(data)
+	if ex != nil {
+		t.Fatal(ex)
+	}
+	if catLayer1.Version != "0.0.1" || len(catLayer1.Data["testAry"].([]interface{})) != 0 {
+		t.Fatalf("record of version [%s] should not be indexed, testAry=%v", catLayer1.Version, catLayer1.Data["testAry"])
+	}
+}
@@ -0,0 +1,302 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataServiceTest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"DataService/DataJournal"
+	"DataService/DataJournal/ProcessIface"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+func receiveEvents(t *testing.T, sub *DataJournal.Subscription, count int) []*DataJournal.ChangeEvent {
+	result := []*DataJournal.ChangeEvent{}
+	for len(result) < count {
+		select {
+		case event, ok := <-sub.Events:
+			if !ok {
+				t.Fatalf("subscription closed after [%d] events, expect [%d]", len(result), count)
+			}
+			result = append(result, event)
+		default:
+			t.Fatalf("got [%d] events, expect [%d]", len(result), count)
+		}
+	}
+	select {
+	case event := <-sub.Events:
+		t.Fatalf("unexpected event of [%s/%s]", event.DataType, event.DataId)
+	default:
+	}
+	return result
+}
+
+func TestChangeFeed(t *testing.T) {
+	handler := newMachineHandler(t)
+	journal, e := DataJournal.NewJournalLib(handler.DB, handler.Config.DataTable.Data, nil)
+	if e != nil {
+		t.Fatalf("failed to create journal. Error:%s", e)
+	}
+	handler.AddJournal = journal.AddJournal
+	targets, e := handler.PathTargets("machine", "m1", "rack/name")
+	if e != nil {
+		t.Fatalf("failed to resolve SchemaPath. Error:%s", e)
+	}
+	start := journal.Position()
+	all, _, e := journal.Subscribe(&DataJournal.ChangeFilter{}, "")
+	if e != nil {
+		t.Fatalf("failed to subscribe. Error:%s", e)
+	}
+	rack, _, e := journal.Subscribe(&DataJournal.ChangeFilter{Targets: targets}, "")
+	if e != nil {
+		t.Fatalf("failed to subscribe path. Error:%s", e)
+	}
+	other, _, e := journal.Subscribe(&DataJournal.ChangeFilter{DataType: "machine", DataId: "m2"}, "")
+	if e != nil {
+		t.Fatalf("failed to subscribe record. Error:%s", e)
+	}
+	_, e = handler.Patch("machine", "m1/state", nil, "busy")
+	if e != nil {
+		t.Fatalf("failed to patch state. Error:%s", e)
+	}
+	_, e = handler.Patch("machine", "m1/rack/name", nil, "r13")
+	if e != nil {
+		t.Fatalf("failed to patch rack. Error:%s", e)
+	}
+	eventList := receiveEvents(t, all, 2)
+	if eventList[0].DataId != "m1" || eventList[0].Position == start {
+		t.Fatalf("invalid first event %v", eventList[0])
+	}
+	rackEvent := receiveEvents(t, rack, 1)[0]
+	if rackEvent.Position != eventList[1].Position {
+		t.Fatalf("expect rack change at [%s], got [%s]", eventList[1].Position, rackEvent.Position)
+	}
+	after := rackEvent.After[Record.Data].(map[string]interface{})["rack"].(map[string]interface{})
+	if after["name"] != "r13" {
+		t.Fatalf("invalid rack change %v", rackEvent.After)
+	}
+	receiveEvents(t, other, 0)
+	// resume after reconnect
+	_, replay, e := journal.Subscribe(&DataJournal.ChangeFilter{DataType: "machine"}, start)
+	if e != nil || len(replay) != 2 {
+		t.Fatalf("expect 2 events to replay from start, got %d, Error:%v", len(replay), e)
+	}
+	_, replay, e = journal.Subscribe(&DataJournal.ChangeFilter{Targets: targets}, start)
+	if e != nil || len(replay) != 1 || replay[0].Position != rackEvent.Position {
+		t.Fatalf("expect rack change to replay, got %v, Error:%v", replay, e)
+	}
+	// position is kept in journal, resume after restart
+	restarted, e := DataJournal.NewJournalLib(handler.DB, handler.Config.DataTable.Data, nil)
+	if e != nil {
+		t.Fatalf("failed to reload journal. Error:%s", e)
+	}
+	if restarted.Position() != eventList[1].Position {
+		t.Fatalf("expect position [%s] after restart, got [%s]", eventList[1].Position, restarted.Position())
+	}
+	_, replay, e = restarted.Subscribe(&DataJournal.ChangeFilter{DataType: "machine"}, eventList[0].Position)
+	if e != nil || len(replay) != 1 || replay[0].Position != eventList[1].Position {
+		t.Fatalf("expect last event to replay, got %v, Error:%v", replay, e)
+	}
+	badPositions := map[string]int{
+		"abc":                       http.StatusBadRequest,
+		"-1":                        http.StatusBadRequest,
+		eventList[1].Position + "0": http.StatusBadRequest,
+	}
+	for position, status := range badPositions {
+		_, _, e = journal.Subscribe(&DataJournal.ChangeFilter{}, position)
+		if e == nil || e.Status != status {
+			t.Fatalf("expect status [%d] of position [%s], got %v", status, position, e)
+		}
+	}
+	all.Close()
+	rack.Close()
+	other.Close()
+	// slow subscriber is dropped instead of blocking journal
+	slow := journal.Feed.Subscribe(&DataJournal.ChangeFilter{DataType: "test"})
+	entry := ProcessIface.JournalEntry{Page: 1, Idx: 1}
+	for i := 0; i < DataJournal.SubscriberBufferSize+1; i++ {
+		journal.Feed.Publish("test", "t1", &entry)
+	}
+	count := 0
+	for range slow.Events {
+		count++
+	}
+	if count != DataJournal.SubscriberBufferSize || slow.Err() == nil || slow.Err().Status != http.StatusTooManyRequests {
+		t.Fatalf("expect slow subscriber dropped after [%d] events, got [%d], Error:%v", DataJournal.SubscriberBufferSize, count, slow.Err())
+	}
+}
+
+func TestChangeFeedGone(t *testing.T) {
+	handler := newMachineHandler(t)
+	journal, e := DataJournal.NewJournalLib(handler.DB, handler.Config.DataTable.Data, nil)
+	if e != nil {
+		t.Fatalf("failed to create journal. Error:%s", e)
+	}
+	handler.AddJournal = journal.AddJournal
+	start := journal.Position()
+	for i := 0; i <= DataJournal.MaxEntryPerPage; i++ {
+		_, e = handler.Patch("machine", "m2/cpu", nil, i)
+		if e != nil {
+			t.Fatalf("failed to patch cpu. Error:%s", e)
+		}
+	}
+	_, e = handler.Patch("machine", "m1/cpu", nil, 1)
+	if e != nil {
+		t.Fatalf("failed to patch cpu. Error:%s", e)
+	}
+	// first page of m2 is removed once all of its entries are archived
+	for i := 0; i < DataJournal.MaxEntryPerPage; i++ {
+		entry := journal.NextJournalEntry("machine", "m2")
+		e = journal.ArchiveJournalEntry("machine", "m2", entry)
+		if e != nil {
+			t.Fatalf("failed to archive entry. Error:%s", e)
+		}
+	}
+	_, _, e = journal.Subscribe(&DataJournal.ChangeFilter{DataType: "machine"}, start)
+	if e == nil || e.Status != http.StatusGone {
+		t.Fatalf("expect position [%s] no longer kept, got %v", start, e)
+	}
+	_, replay, e := journal.Subscribe(&DataJournal.ChangeFilter{DataType: "machine", DataId: "m1"}, start)
+	if e != nil || len(replay) != 1 {
+		t.Fatalf("expect change of m1 to replay, got %v, Error:%v", replay, e)
+	}
+	_, replay, e = journal.Subscribe(&DataJournal.ChangeFilter{DataType: "machine"}, strconv.Itoa(DataJournal.MaxEntryPerPage))
+	if e != nil || len(replay) != 2 {
+		t.Fatalf("expect last page of m2 and change of m1 to replay, got %v, Error:%v", replay, e)
+	}
+}
+
+func TestChangeFeedReference(t *testing.T) {
+	handler := newServerHandler(t)
+	journal, e := DataJournal.NewJournalLib(handler.DB, handler.Config.DataTable.Data, nil)
+	if e != nil {
+		t.Fatalf("failed to create journal. Error:%s", e)
+	}
+	handler.AddJournal = journal.AddJournal
+	e = handler.Add(Record.NewRecord("rack", "0.0.1", "r13", map[string]interface{}{"row": 3, "data_center": "SEA1"}))
+	if e != nil {
+		t.Fatalf("failed to add rack. Error:%s", e)
+	}
+	// rack of s2 and rack record it refers to
+	targets, e := handler.PathTargets("server", "s2", "rack")
+	if e != nil {
+		t.Fatalf("failed to resolve SchemaPath. Error:%s", e)
+	}
+	rack, _, e := journal.Subscribe(&DataJournal.ChangeFilter{Targets: targets}, "")
+	if e != nil {
+		t.Fatalf("failed to subscribe. Error:%s", e)
+	}
+	targets, e = handler.PathTargets("server", "s2", "rack/row")
+	if e != nil {
+		t.Fatalf("failed to resolve SchemaPath. Error:%s", e)
+	}
+	row, _, e := journal.Subscribe(&DataJournal.ChangeFilter{Targets: targets}, "")
+	if e != nil {
+		t.Fatalf("failed to subscribe. Error:%s", e)
+	}
+	patchList := []struct {
+		dataType string
+		path     string
+		value    interface{}
+	}{
+		{"server", "s2/cpu", 32},
+		{"server", "s1/cpu", 4},
+		{"rack", "r13/row", 4},
+		{"rack", "r12/row", 2},
+		{"server", "s2/rack", "r13"},
+	}
+	for _, patch := range patchList {
+		_, e = handler.Patch(patch.dataType, patch.path, nil, patch.value)
+		if e != nil {
+			t.Fatalf("failed to patch [%s/%s]. Error:%s", patch.dataType, patch.path, e)
+		}
+	}
+	eventList := receiveEvents(t, rack, 2)
+	if eventList[0].DataId != "r12" || eventList[1].DataId != "s2" {
+		t.Fatalf("expect changes of rack r12 and rack of s2, got %v", eventList)
+	}
+	// change of reference walked through is also on the path
+	eventList = receiveEvents(t, row, 2)
+	if eventList[0].DataId != "r12" || eventList[1].DataId != "s2" {
+		t.Fatalf("expect row change of r12 and rack of s2, got %v", eventList)
+	}
+}
+
+func TestChangeFeedSeq(t *testing.T) {
+	handler := newMachineHandler(t)
+	journal, e := DataJournal.NewJournalLib(handler.DB, handler.Config.DataTable.Data, nil)
+	if e != nil {
+		t.Fatalf("failed to create journal. Error:%s", e)
+	}
+	// other service instance on the same table reserve its own block of seq
+	other, e := DataJournal.NewJournalLib(handler.DB, handler.Config.DataTable.Data, nil)
+	if e != nil {
+		t.Fatalf("failed to create other journal. Error:%s", e)
+	}
+	sub, _, e := journal.Subscribe(&DataJournal.ChangeFilter{}, "")
+	if e != nil {
+		t.Fatalf("failed to subscribe. Error:%s", e)
+	}
+	defer sub.Close()
+	e = other.AddJournal(nil, "machine", "m2", nil, map[string]interface{}{"cpu": 1}, nil)
+	if e != nil {
+		t.Fatalf("failed to add journal. Error:%s", e)
+	}
+	count := 20
+	wg := sync.WaitGroup{}
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			err := journal.AddJournal(nil, "machine", fmt.Sprintf("c%d", idx), nil, map[string]interface{}{"cpu": idx}, nil)
+			if err != nil {
+				t.Errorf("failed to add journal. Error:%s", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	// entries committed at the same time are published in order of seq
+	eventList := receiveEvents(t, sub, count)
+	var last uint64
+	for _, event := range eventList {
+		seq, err := strconv.ParseUint(event.Position, 10, 64)
+		if err != nil || seq <= last {
+			t.Fatalf("expect events in order of seq, got [%s] after [%d]", event.Position, last)
+		}
+		last = seq
+	}
+	if other.Position() != "1" {
+		t.Fatalf("expect position [1] of other instance, got [%s]", other.Position())
+	}
+	if journal.Position() != strconv.Itoa(DataJournal.SeqBlockSize+count) {
+		t.Fatalf("expect seq from block after the one of other instance, got [%s]", journal.Position())
+	}
+}
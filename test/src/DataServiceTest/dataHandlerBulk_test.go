@@ -0,0 +1,119 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataServiceTest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"DataService/DataHandler"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+func TestDataHandlerExport(t *testing.T) {
+	handler := newDataCenterHandler(t)
+	typeList, e := handler.ExportTypes("")
+	if e != nil {
+		t.Fatalf("failed to get export types. Error:%s", e)
+	}
+	if len(typeList) != 2 || typeList[0] != JsonKey.Schema || typeList[1] != "data_center" {
+		t.Fatalf("invalid export types %v", typeList)
+	}
+	_, e = handler.ExportTypes("unknown")
+	if e == nil || e.Status != http.StatusNotFound {
+		t.Fatalf("failed to reject export of unknown type")
+	}
+	e = handler.Delete("data_center", "LAX2")
+	if e != nil {
+		t.Fatalf("failed to delete record. Error:%s", e)
+	}
+	buf := bytes.Buffer{}
+	e = handler.Export(typeList, &buf)
+	if e != nil {
+		t.Fatalf("failed to export. Error:%s", e)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expect 3 lines in export, got %d\n%s", len(lines), buf.String())
+	}
+	first := map[string]interface{}{}
+	err := json.Unmarshal([]byte(lines[0]), &first)
+	if err != nil || first[Record.DataType] != JsonKey.Schema {
+		t.Fatalf("schema is not exported first, %s", lines[0])
+	}
+}
+
+func TestDataHandlerImport(t *testing.T) {
+	handler := newDataCenterHandler(t)
+	importStr := strings.Join([]string{
+		`{"__id": "SEA1", "__type": "data_center", "__ver": "0.0.1", "__rev": 5, "data": {"name": "Seattle Downtown"}}`,
+		`{"__id": "DFW4", "__type": "data_center", "__ver": "0.0.1", "data": {"name": "Dallas"}}`,
+		``,
+		`{"__id": "SJC1", "__type": "data_center", "__ver": "0.0.1", "data": {"name": "San Jose"}}`,
+		`not a record`,
+	}, "\n")
+	_, e := handler.Import(strings.NewReader(importStr), "merge", nil)
+	if e == nil || e.Status != http.StatusBadRequest {
+		t.Fatalf("failed to reject invalid import mode")
+	}
+	result, e := handler.Import(strings.NewReader(importStr), DataHandler.ImportCreateOnly, nil)
+	if e != nil {
+		t.Fatalf("failed to import. Error:%s", e)
+	}
+	if result.Created != 1 || result.Failed != 3 {
+		t.Fatalf("invalid create-only result %v", result)
+	}
+	if result.Errors[0].Line != 1 || result.Errors[0].Error.Status != http.StatusConflict || result.Errors[2].Line != 5 {
+		t.Fatalf("invalid create-only errors %v", result.Errors)
+	}
+	result, e = handler.Import(strings.NewReader(importStr), DataHandler.ImportUpsert, nil)
+	if e != nil {
+		t.Fatalf("failed to import. Error:%s", e)
+	}
+	if result.Replaced != 1 || result.Unchanged != 2 || result.Failed != 1 {
+		t.Fatalf("invalid upsert result %v", result)
+	}
+	data, e := handler.Get("data_center", "SEA1")
+	if e != nil {
+		t.Fatalf("failed to get replaced record. Error:%s", e)
+	}
+	record, _ := Record.LoadMap(data.(map[string]interface{}))
+	if record.Data["name"] != "Seattle Downtown" || record.Revision != 1 {
+		t.Fatalf("invalid replaced record %v", record.Map())
+	}
+	result, e = handler.Import(strings.NewReader(`{"__id": "BOS1", "__type": "data_center", "__ver": "0.0.1", "data": {"name": "Boston"}}`), DataHandler.ImportReplace, nil)
+	if e != nil {
+		t.Fatalf("failed to import. Error:%s", e)
+	}
+	if result.Failed != 1 || result.Errors[0].Error.Status != http.StatusNotFound {
+		t.Fatalf("replace should not create record %v", result)
+	}
+}
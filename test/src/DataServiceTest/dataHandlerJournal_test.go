@@ -118,7 +118,7 @@ func TestAddJournal(t *testing.T) {
 	if e != nil {
 		t.Fatalf("failed to create Journal Library. Error: %s", err)
 	}
-	e = journal.AddJournal("test", "testid_123", nil, map[string]interface{}{"attr": "test"})
+	e = journal.AddJournal(nil, "test", "testid_123", nil, map[string]interface{}{"attr": "test"}, nil)
 	if e != nil {
 		t.Fatalf(e.Error())
 	}
@@ -135,7 +135,7 @@ func TestAddJournal(t *testing.T) {
 	if len(record.Data["active"].([]interface{})) != 1 {
 		t.Fatal("failed add the first entry")
 	}
-	journal.AddJournal("test", "testid_123", nil, map[string]interface{}{"attr": "test"})
+	journal.AddJournal(nil, "test", "testid_123", nil, map[string]interface{}{"attr": "test"}, nil)
 	if len(mockDb.Data[Common.KeyJournal].(map[string]interface{})) != 1 {
 		t.Fatalf("invalid add Journal Entry.")
 	}
@@ -147,7 +147,7 @@ func TestAddJournal(t *testing.T) {
 		t.Fatal("failed add the first entry")
 	}
 	for i := 0; i < 8; i++ {
-		journal.AddJournal("test", "testid_123", nil, map[string]interface{}{"attr": fmt.Sprintf("test_%d", i)})
+		journal.AddJournal(nil, "test", "testid_123", nil, map[string]interface{}{"attr": fmt.Sprintf("test_%d", i)}, nil)
 		if len(mockDb.Data[Common.KeyJournal].(map[string]interface{})) != 1 {
 			t.Fatalf("invalid add Journal Entry.")
 		}
@@ -159,7 +159,7 @@ func TestAddJournal(t *testing.T) {
 			t.Fatal("failed add the first entry")
 		}
 	}
-	journal.AddJournal("test", "testid_123", nil, map[string]interface{}{"attr": fmt.Sprintf("test_%d", 0)})
+	journal.AddJournal(nil, "test", "testid_123", nil, map[string]interface{}{"attr": fmt.Sprintf("test_%d", 0)}, nil)
 	if len(mockDb.Data[Common.KeyJournal].(map[string]interface{})) != 2 {
 		t.Fatalf("invalid add Journal Entry.")
 	}
@@ -193,7 +193,7 @@ func TestArchiveJournal(t *testing.T) {
 		t.Fatalf("failed to create Journal Library. Error: %s", err)
 	}
 	for i := 0; i < 16; i++ {
-		e = journal.AddJournal("test", "testid_123", nil, map[string]interface{}{"attr": fmt.Sprintf("test_%d", i)})
+		e = journal.AddJournal(nil, "test", "testid_123", nil, map[string]interface{}{"attr": fmt.Sprintf("test_%d", i)}, nil)
 		if e != nil {
 			t.Fatalf("failed to add hournal. Error: %s", e)
 		}
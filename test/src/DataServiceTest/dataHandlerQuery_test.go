@@ -0,0 +1,203 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataServiceTest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"DataService/DataHandler"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+func newMachineHandler(t *testing.T) *DataHandler.Handler {
+	handler := newNamespaceHandler(t)
+	schema := Record.Record{}
+	err := json.Unmarshal([]byte(`{
+		"__id": "machine",
+		"__type": "schema",
+		"__ver": "0.0.1",
+		"data": {
+			"name": "machine",
+			"version": "0.0.1",
+			"properties": {
+				"state": {
+					"type": "string"
+				},
+				"cpu": {
+					"type": "integer"
+				},
+				"ready": {
+					"type": "boolean"
+				},
+				"rack": {
+					"type": "object",
+					"$ref": "#/definitions/rack"
+				},
+				"tags": {
+					"type": "array",
+					"items": {
+						"type": "string"
+					}
+				}
+			},
+			"definitions": {
+				"rack": {
+					"name": "rack",
+					"properties": {
+						"name": {
+							"type": "string"
+						},
+						"row": {
+							"type": "integer"
+						}
+					}
+				}
+			}
+		}
+	}`), &schema)
+	if err != nil {
+		t.Fatalf("failed to load machine schema. Error:%s", err)
+	}
+	e := handler.Add(&schema)
+	if e != nil {
+		t.Fatalf("failed to add machine schema. Error:%s", e)
+	}
+	machineList := []struct {
+		id    string
+		state string
+		cpu   int
+		ready bool
+		rack  string
+		row   int
+	}{
+		{"m1", "ready", 8, true, "r12", 1},
+		{"m2", "busy", 16, false, "r12", 1},
+		{"m3", "ready", 32, true, "r12", 2},
+		{"m4", "ready", 4, true, "r13", 2},
+	}
+	for _, m := range machineList {
+		e = handler.Add(Record.NewRecord("machine", "0.0.1", m.id, map[string]interface{}{
+			"state": m.state,
+			"cpu":   m.cpu,
+			"ready": m.ready,
+			"rack": map[string]interface{}{
+				"name": m.rack,
+				"row":  m.row,
+			},
+			"tags": []interface{}{},
+		}))
+		if e != nil {
+			t.Fatalf("failed to add machine [%s]. Error:%s", m.id, e)
+		}
+	}
+	return handler
+}
+
+func queryIds(t *testing.T, handler *DataHandler.Handler, rawQuery string) []interface{} {
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatalf("invalid query [%s]. Error:%s", rawQuery, err)
+	}
+	listQuery, e := handler.ParseListQuery("machine", query)
+	if e != nil {
+		t.Fatalf("failed to parse query [%s]. Error:%s", rawQuery, e)
+	}
+	result, _, e := handler.Query("machine", listQuery)
+	if e != nil {
+		t.Fatalf("failed to query [%s]. Error:%s", rawQuery, e)
+	}
+	return result
+}
+
+func TestDataHandlerQuery(t *testing.T) {
+	handler := newMachineHandler(t)
+	queryMap := map[string]string{
+		"filter=rack/name:eq:r12&filter=state:eq:ready&sort=cpu": `["m1","m3"]`,
+		"filter=cpu:gte:8&sort=-cpu":                             `["m3","m2","m1"]`,
+		"filter=state:in:busy,idle":                              `["m2"]`,
+		"filter=ready:eq:true&sort=rack/row,-cpu&limit=2":        `["m1","m3"]`,
+		"filter=ready:ne:true":                                   `["m2"]`,
+		"sort=-rack/name,cpu&limit=1":                            `["m4"]`,
+	}
+	for rawQuery, expected := range queryMap {
+		result := queryIds(t, handler, rawQuery)
+		resultStr, _ := json.Marshal(result)
+		if string(resultStr) != expected {
+			t.Fatalf("query [%s] expect %s, got %s", rawQuery, expected, resultStr)
+		}
+	}
+	query, _ := url.ParseQuery("filter=cpu:lt:10&view=records&sort=cpu")
+	listQuery, e := handler.ParseListQuery("machine", query)
+	if e != nil {
+		t.Fatalf("failed to parse query. Error:%s", e)
+	}
+	result, _, e := handler.Query("machine", listQuery)
+	if e != nil || len(result) != 2 {
+		t.Fatalf("failed to query records, got %v, Error:%v", result, e)
+	}
+	if result[0].(map[string]interface{})[Record.DataId] != "m4" {
+		t.Fatalf("expect full record of m4, got %v", result[0])
+	}
+	query, _ = url.ParseQuery("filter=state:eq:ready&pageSize=2")
+	listQuery, e = handler.ParseListQuery("machine", query)
+	if e != nil {
+		t.Fatalf("failed to parse paged query. Error:%s", e)
+	}
+	result, nextToken, e := handler.Query("machine", listQuery)
+	if e != nil || len(result) != 2 || nextToken == "" {
+		t.Fatalf("failed to query first page, got %v, token=[%s], Error:%v", result, nextToken, e)
+	}
+	listQuery.PageToken = nextToken
+	result, nextToken, e = handler.Query("machine", listQuery)
+	if e != nil || len(result) != 1 || nextToken != "" {
+		t.Fatalf("failed to query last page, got %v, token=[%s], Error:%v", result, nextToken, e)
+	}
+	badQueryList := []string{
+		"filter=unknown:eq:x",
+		"filter=rack:eq:x",
+		"filter=tags:eq:x",
+		"filter=cpu:eq:abc",
+		"filter=ready:gt:true",
+		"filter=state:like:r",
+		"filter=state",
+		"sort=rack",
+		"view=all",
+		"limit=-1",
+		"sort=cpu&pageSize=2",
+		"limit=2&pageToken=abc",
+	}
+	for _, rawQuery := range badQueryList {
+		query, _ := url.ParseQuery(rawQuery)
+		_, e := handler.ParseListQuery("machine", query)
+		if e == nil || e.Status != http.StatusBadRequest {
+			t.Fatalf("failed to reject query [%s]", rawQuery)
+		}
+	}
+}
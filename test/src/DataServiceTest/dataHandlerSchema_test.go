@@ -137,6 +137,34 @@ func TestAddSchema(t *testing.T) {
 	}
 }
 
+func TestAddSchemaRouteName(t *testing.T) {
+	handler, ex := MockHandler()
+	if ex != nil {
+		t.Fatalf(ex.Error())
+	}
+	cacheSchema := `{
+		"__id": "cache",
+		"__type": "schema",
+		"__ver": "0.0.1",
+		"data": {
+			"name": "cache",
+			"version": "0.0.1",
+			"properties": {
+				"testAttr1": {
+					"type": "string"
+				}
+			}
+		}
+	}`
+	err := AddData(handler, cacheSchema)
+	if err == nil {
+		t.Fatalf("failed to reject schema [cache] shadowed by api route")
+	}
+	if err.Status != http.StatusBadRequest {
+		t.Fatalf("expect status [%d] on schema [cache], got [%d]", http.StatusBadRequest, err.Status)
+	}
+}
+
 func TestAddData(t *testing.T) {
 	handler, ex := MockHandler()
 	if ex != nil {
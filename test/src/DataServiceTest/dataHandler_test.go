@@ -29,13 +29,16 @@ import (
 	"Data/DbConfig"
 	"Data/DbIface"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"testing"
+	"time"
 
 	"DataService/Config"
 	"DataService/DataHandler"
 
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
 	"github.com/salesforce/UniTAO/lib/Schema/Record"
 )
 
@@ -1192,3 +1195,580 @@ func TestDataHandlerPatchArrayCmt(t *testing.T) {
 		t.Fatalf("failed to catch duplicate error")
 	}
 }
+
+// handler on mock database with 3 data_center records
+func newDataCenterHandler(t *testing.T) *DataHandler.Handler {
+	return newDataCenterHandlerWith(t, "")
+}
+
+// schemaOption is extra attributes on root of data_center schema
+func newDataCenterHandlerWith(t *testing.T, schemaOption string) *DataHandler.Handler {
+	configStr := `
+	{
+		"database": {
+			"type": "dynamodb"
+		},
+		"table": {
+			"data": "DataService01"
+		},
+		"http": {
+			"type": "http",
+			"dns": "localhost",
+			"port": "8002",
+			"id": "DataService01"
+		},
+		"inventory": {
+			"url": "http://localhost:8004"
+		}
+	}
+	`
+	config := Config.Confuguration{}
+	err := json.Unmarshal([]byte(configStr), &config)
+	if err != nil {
+		t.Fatalf("faild to load config str. invalid format. Error:%s", err)
+	}
+	schemaStr := fmt.Sprintf(`
+	{
+		"schema": {
+			"data_center": {
+				"__id": "data_center",
+				"__type": "schema",
+				"__ver": "0.0.1",
+				"data": {
+					"name": "data_center",
+					"version": "0.0.1",
+					"description": "data center Schema",%s
+					"properties": {
+						"name": {
+							"type": "string"
+						}
+					}
+				}
+			}
+		},
+		"data_center": {
+			"SEA1": {
+				"__id": "SEA1",
+				"__type": "data_center",
+				"__ver": "0.0.1",
+				"data": {
+					"name": "Seattle"
+				}
+			},
+			"DFW4": {
+				"__id": "DFW4",
+				"__type": "data_center",
+				"__ver": "0.0.1",
+				"data": {
+					"name": "Dallas"
+				}
+			},
+			"LAX2": {
+				"__id": "LAX2",
+				"__type": "data_center",
+				"__ver": "0.0.1",
+				"data": {
+					"name": "Los Angeles"
+				}
+			}
+		}
+	}
+	`, schemaOption)
+	connectDb := func(config DbConfig.DatabaseConfig, logger *log.Logger) (DbIface.Database, error) {
+		mockDb, err := NewMockDb(config, schemaStr, logger)
+		if err != nil {
+			return nil, err
+		}
+		return mockDb, nil
+	}
+	handler, ex := DataHandler.New(config, nil, connectDb)
+	if ex != nil {
+		t.Fatalf("failed to create handler")
+	}
+	return handler
+}
+
+func TestDataHandlerListPage(t *testing.T) {
+	handler := newDataCenterHandler(t)
+	idList, nextToken, e := handler.ListPage("data_center", 2, "")
+	if e != nil {
+		t.Fatalf("failed to list first page. Error:%s", e)
+	}
+	if len(idList) != 2 || idList[0] != "DFW4" || idList[1] != "LAX2" || nextToken == "" {
+		t.Fatalf("invalid first page %v, token=[%s]", idList, nextToken)
+	}
+	idList, nextToken, e = handler.ListPage("data_center", 2, nextToken)
+	if e != nil {
+		t.Fatalf("failed to list second page. Error:%s", e)
+	}
+	if len(idList) != 1 || idList[0] != "SEA1" || nextToken != "" {
+		t.Fatalf("invalid second page %v, token=[%s]", idList, nextToken)
+	}
+	_, _, e = handler.ListPage("data_center", 2, "bad token")
+	if e == nil || e.Status != http.StatusBadRequest {
+		t.Fatalf("failed to reject invalid page token")
+	}
+	idList, e = handler.List("data_center")
+	if e != nil || len(idList) != 3 {
+		t.Fatalf("failed to list all ids %v", idList)
+	}
+}
+
+func TestDataHandlerRevision(t *testing.T) {
+	handler := newDataCenterHandler(t)
+	record := Record.NewRecord("data_center", "0.0.1", "SEA1", map[string]interface{}{
+		"name": "Seattle 1",
+	})
+	e := handler.Set("data_center", "SEA1", record)
+	if e != nil {
+		t.Fatalf("failed to set record. Error:%s", e)
+	}
+	if record.Revision != 1 {
+		t.Fatalf("expect revision 1 after set, got %d", record.Revision)
+	}
+	stale := Record.NewRecord("data_center", "0.0.1", "SEA1", map[string]interface{}{
+		"name": "Seattle 2",
+	})
+	stale.Revision = 5
+	e = handler.Set("data_center", "SEA1", stale)
+	if e == nil || e.Status != http.StatusConflict {
+		t.Fatalf("failed to reject set on stale revision")
+	}
+	_, e = handler.Patch("data_center", "SEA1/name", map[string]interface{}{
+		"revision": "0",
+	}, "Seattle 3")
+	if e == nil || e.Status != http.StatusConflict {
+		t.Fatalf("failed to reject patch on stale revision")
+	}
+	patched, e := handler.Patch("data_center", "SEA1/name", map[string]interface{}{
+		"revision": "1",
+	}, "Seattle 3")
+	if e != nil {
+		t.Fatalf("failed to patch on current revision. Error:%s", e)
+	}
+	if patched[Record.Revision] != float64(2) {
+		t.Fatalf("expect revision 2 after patch, got %v", patched[Record.Revision])
+	}
+}
+
+func TestDataHandlerSoftDelete(t *testing.T) {
+	handler := newDataCenterHandlerWith(t, `
+					"softDelete": {
+						"retention": "1h"
+					},`)
+	e := handler.Delete("data_center", "SEA1")
+	if e != nil {
+		t.Fatalf("failed to delete record. Error:%s", e)
+	}
+	_, e = handler.Get("data_center", "SEA1")
+	if e == nil || e.Status != http.StatusNotFound {
+		t.Fatalf("deleted record still found")
+	}
+	idList, e := handler.List("data_center")
+	if e != nil || len(idList) != 2 {
+		t.Fatalf("deleted record still listed %v", idList)
+	}
+	e = handler.Add(Record.NewRecord("data_center", "0.0.1", "SEA1", map[string]interface{}{
+		"name": "Seattle",
+	}))
+	if e == nil || e.Status != http.StatusConflict {
+		t.Fatalf("failed to reject add on deleted record")
+	}
+	record, e := handler.Undelete("data_center", "SEA1")
+	if e != nil {
+		t.Fatalf("failed to undelete record. Error:%s", e)
+	}
+	if record.Deleted != "" || record.Revision != 2 {
+		t.Fatalf("invalid record after undelete %v", record.Map())
+	}
+	_, e = handler.Undelete("data_center", "SEA1")
+	if e == nil || e.Status != http.StatusNotModified {
+		t.Fatalf("undelete on live record should not modify it")
+	}
+	_, e = handler.Get("data_center", "SEA1")
+	if e != nil {
+		t.Fatalf("failed to get undeleted record. Error:%s", e)
+	}
+	e = handler.Delete("data_center", "SEA1")
+	if e != nil {
+		t.Fatalf("failed to delete record again. Error:%s", e)
+	}
+	purged, e := handler.Purge(time.Now())
+	if e != nil || purged != 0 {
+		t.Fatalf("record purged before retention, purged=[%d], Error:%v", purged, e)
+	}
+	purged, e = handler.Purge(time.Now().Add(2 * time.Hour))
+	if e != nil || purged != 1 {
+		t.Fatalf("failed to purge record after retention, purged=[%d], Error:%v", purged, e)
+	}
+	_, e = handler.Undelete("data_center", "SEA1")
+	if e == nil || e.Status != http.StatusNotFound {
+		t.Fatalf("purged record still restorable")
+	}
+}
+
+func TestDataHandlerHardDelete(t *testing.T) {
+	handler := newDataCenterHandler(t)
+	e := handler.Delete("data_center", "SEA1")
+	if e != nil {
+		t.Fatalf("failed to delete record. Error:%s", e)
+	}
+	_, e = handler.Undelete("data_center", "SEA1")
+	if e == nil || e.Status != http.StatusNotFound {
+		t.Fatalf("record without soft delete should be removed")
+	}
+}
+
+func TestDataHandlerTtl(t *testing.T) {
+	handler := newDataCenterHandlerWith(t, `
+					"ttl": {
+						"default": "1h"
+					},`)
+	e := handler.Add(Record.NewRecord("data_center", "0.0.1", "SJC1", map[string]interface{}{
+		"name": "San Jose",
+	}))
+	if e != nil {
+		t.Fatalf("failed to add record. Error:%s", e)
+	}
+	expired, e := handler.Expire(time.Now())
+	if e != nil || expired != 0 {
+		t.Fatalf("record expired before ttl, expired=[%d], Error:%v", expired, e)
+	}
+	expired, e = handler.Expire(time.Now().Add(2 * time.Hour))
+	if e != nil || expired != 1 {
+		t.Fatalf("failed to expire record after ttl, expired=[%d], Error:%v", expired, e)
+	}
+	_, e = handler.Get("data_center", "SJC1")
+	if e == nil || e.Status != http.StatusNotFound {
+		t.Fatalf("expired record still found")
+	}
+	e = handler.Set("data_center", "SEA1", Record.NewRecord("data_center", "0.0.1", "SEA1", map[string]interface{}{
+		"name": "Seattle Downtown",
+	}))
+	if e != nil {
+		t.Fatalf("failed to set record. Error:%s", e)
+	}
+	expired, e = handler.Expire(time.Now().Add(2 * time.Hour))
+	if e != nil || expired != 1 {
+		t.Fatalf("failed to expire updated record, expired=[%d], Error:%v", expired, e)
+	}
+	idList, e := handler.List("data_center")
+	if e != nil || len(idList) != 2 {
+		t.Fatalf("record without expiry time should not expire %v", idList)
+	}
+}
+
+func TestDataHandlerTtlAttribute(t *testing.T) {
+	handler := newDataCenterHandlerWith(t, `
+					"ttl": {
+						"attribute": "name"
+					},`)
+	expireAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	e := handler.Add(Record.NewRecord("data_center", "0.0.1", "SJC1", map[string]interface{}{
+		"name": expireAt,
+	}))
+	if e != nil {
+		t.Fatalf("failed to add record. Error:%s", e)
+	}
+	e = handler.Add(Record.NewRecord("data_center", "0.0.1", "SJC2", map[string]interface{}{
+		"name": "San Jose",
+	}))
+	if e == nil || e.Status != http.StatusBadRequest {
+		t.Fatalf("failed to reject invalid expiry time")
+	}
+	expired, e := handler.Expire(time.Now())
+	if e != nil || expired != 0 {
+		t.Fatalf("record expired before expiry time, expired=[%d], Error:%v", expired, e)
+	}
+	expired, e = handler.Expire(time.Now().Add(2 * time.Hour))
+	if e != nil || expired != 1 {
+		t.Fatalf("failed to expire record, expired=[%d], Error:%v", expired, e)
+	}
+}
+
+func TestDataHandlerPatchDoc(t *testing.T) {
+	configStr := `
+	{
+		"database": {
+			"type": "dynamodb",
+			"dynamodb": {
+				"region": "us-west-2",
+				"endpoint": "http://localhost:8000"
+			}
+		},
+		"table": {
+			"data": "DataService01"
+		},
+		"http": {
+			"type": "http",
+			"dns": "localhost",
+			"port": "8002",
+			"id": "DataService01"
+		},
+		"inventory": {
+			"url": "http://localhost:8004"
+		}
+	}
+	`
+	config := Config.Confuguration{}
+	err := json.Unmarshal([]byte(configStr), &config)
+	if err != nil {
+		t.Fatalf("faild to load config str. invalid format. Error:%s", err)
+	}
+	dataStr := `{
+		"schema": {
+			"test": {
+				"__id": "test",
+				"__type": "schema",
+				"__ver": "0.0.1",
+				"data": {
+					"name": "test",
+					"version": "0.0.1",
+					"properties": {
+						"attr1": {
+							"type": "string"
+						},
+						"attr2": {
+							"type": "integer",
+							"required": false
+						},
+						"attr3": {
+							"type": "array",
+							"items": {
+								"type": "object",
+								"$ref": "#/definitions/subTest"
+							}
+						}
+					},
+					"definitions": {
+						"subTest": {
+							"name": "subTest",
+							"key": "{subKey}",
+							"properties": {
+								"subKey": {
+									"type": "string"
+								},
+								"subAttr1": {
+									"type": "string"
+								}
+							}
+						}
+					}
+				}
+			}
+		},
+		"test": {
+			"test01": {
+				"__id": "test01",
+				"__type": "test",
+				"__ver": "0.0.1",
+				"data": {
+					"attr1": "test",
+					"attr2": 1,
+					"attr3": [
+						{
+							"subKey": "k1",
+							"subAttr1": "test"
+						},
+						{
+							"subKey": "k2",
+							"subAttr1": "test"
+						}
+					]
+				}
+			}
+		}
+	}`
+	connectDb := func(config DbConfig.DatabaseConfig, logger *log.Logger) (DbIface.Database, error) {
+		mockDb, err := NewMockDb(config, dataStr, logger)
+		if err != nil {
+			return nil, err
+		}
+		return mockDb, nil
+	}
+	handler, e := DataHandler.New(config, nil, connectDb)
+	if e != nil {
+		t.Fatalf("failed to create handler, Error:%s", e)
+	}
+	jsonPatch := map[string]interface{}{
+		"content-type": "application/json-patch+json",
+	}
+	_, e = handler.Patch("test", "test01/attr1", jsonPatch, "[]")
+	if e == nil || e.Status != http.StatusBadRequest {
+		t.Fatalf("failed to reject document patch on path")
+	}
+	ops := `[
+		{"op": "replace", "path": "/attr1", "value": "ok"},
+		{"op": "remove", "path": "/attr2"},
+		{"op": "replace", "path": "/attr3/k1/subAttr1", "value": "ok"},
+		{"op": "remove", "path": "/attr3/k2"}
+	]`
+	_, e = handler.Patch("test", "test01", jsonPatch, ops)
+	if e != nil {
+		t.Fatalf("failed to apply json patch. Error:%s", e)
+	}
+	data, e := handler.LocalData("test", "test01")
+	if e != nil {
+		t.Fatalf("failed to get patched data")
+	}
+	record, err := Record.LoadMap(data)
+	if err != nil {
+		t.Fatalf("failed to load patched data as record. Error:%s", err)
+	}
+	if record.Data["attr1"].(string) != "ok" {
+		t.Fatalf("failed to replace attr1")
+	}
+	if _, ok := record.Data["attr2"]; ok {
+		t.Fatalf("failed to remove attr2")
+	}
+	attr3 := record.Data["attr3"].([]interface{})
+	if len(attr3) != 1 || attr3[0].(map[string]interface{})["subAttr1"].(string) != "ok" {
+		t.Fatalf("failed to patch keyed array attr3, got %v", attr3)
+	}
+	if record.Revision != 1 {
+		t.Fatalf("json patch should write once, revision=[%d]", record.Revision)
+	}
+	ops = `[
+		{"op": "replace", "path": "/attr1", "value": "bad"},
+		{"op": "test", "path": "/attr3/k1/subAttr1", "value": "test"}
+	]`
+	_, e = handler.Patch("test", "test01", jsonPatch, ops)
+	if e == nil || e.Status != http.StatusConflict {
+		t.Fatalf("failed to fail json patch on test op, Error:%v", e)
+	}
+	data, _ = handler.LocalData("test", "test01")
+	if data[Record.Data].(map[string]interface{})["attr1"].(string) != "ok" {
+		t.Fatalf("failed json patch should not change data")
+	}
+	mergePatch := map[string]interface{}{
+		"content-type": "application/merge-patch+json; charset=utf-8",
+	}
+	patch := map[string]interface{}{
+		"attr2": 3,
+		"attr3": map[string]interface{}{
+			"k1": map[string]interface{}{
+				"subAttr1": "merged",
+			},
+			"k3": map[string]interface{}{
+				"subKey":   "k3",
+				"subAttr1": "new",
+			},
+		},
+	}
+	_, e = handler.Patch("test", "test01", mergePatch, patch)
+	if e != nil {
+		t.Fatalf("failed to apply merge patch. Error:%s", e)
+	}
+	data, _ = handler.LocalData("test", "test01")
+	record, _ = Record.LoadMap(data)
+	if record.Data["attr2"].(float64) != 3 {
+		t.Fatalf("failed to merge attr2")
+	}
+	attr3 = record.Data["attr3"].([]interface{})
+	if len(attr3) != 2 || attr3[0].(map[string]interface{})["subAttr1"].(string) != "merged" {
+		t.Fatalf("failed to merge keyed array attr3, got %v", attr3)
+	}
+	if record.Revision != 2 {
+		t.Fatalf("merge patch should write once, revision=[%d]", record.Revision)
+	}
+	_, e = handler.Patch("test", "test01", mergePatch, map[string]interface{}{"attr2": "bad"})
+	if e == nil {
+		t.Fatalf("failed to validate merged record")
+	}
+}
+
+func TestDataHandlerETag(t *testing.T) {
+	handler := newDataCenterHandler(t)
+	data, e := handler.LocalData("data_center", "SEA1")
+	if e != nil {
+		t.Fatalf("failed to get record. Error:%s", e)
+	}
+	etag, e := DataHandler.ETag(data)
+	if e != nil {
+		t.Fatalf("failed to get ETag. Error:%s", e)
+	}
+	sameTag, _ := DataHandler.ETag(data)
+	if etag == "" || etag != sameTag {
+		t.Fatalf("ETag of same record should be stable, [%s] != [%s]", etag, sameTag)
+	}
+	if !DataHandler.MatchETag(fmt.Sprintf(`"bad", W/%s`, etag), etag) || !DataHandler.MatchETag("*", etag) || DataHandler.MatchETag(`"bad"`, etag) {
+		t.Fatalf("failed to match ETag [%s]", etag)
+	}
+	record := Record.NewRecord("data_center", "0.0.1", "SEA1", map[string]interface{}{
+		"name": "Seattle 2",
+	})
+	e = handler.SetIfMatch("data_center", "SEA1", record, `"bad"`)
+	if e == nil || e.Status != http.StatusPreconditionFailed {
+		t.Fatalf("failed to reject set on ETag mismatch")
+	}
+	e = handler.SetIfMatch("data_center", "SEA1", record, etag)
+	if e != nil {
+		t.Fatalf("failed to set on matched ETag. Error:%s", e)
+	}
+	data, _ = handler.LocalData("data_center", "SEA1")
+	newTag, _ := DataHandler.ETag(data)
+	if newTag == etag || newTag != record.ETag() {
+		t.Fatalf("ETag should change with record, before=[%s], after=[%s], record=[%s]", etag, newTag, record.ETag())
+	}
+	_, e = handler.Patch("data_center", "SEA1/name", map[string]interface{}{
+		"if-match": etag,
+	}, "Seattle 3")
+	if e == nil || e.Status != http.StatusPreconditionFailed {
+		t.Fatalf("failed to reject patch on stale ETag")
+	}
+	_, e = handler.Patch("data_center", "SEA1/name", map[string]interface{}{
+		"version": "0.0.2",
+	}, "Seattle 3")
+	if e == nil || e.Status != http.StatusPreconditionFailed {
+		t.Fatalf("failed to reject patch on version mismatch")
+	}
+	patched, e := handler.Patch("data_center", "SEA1/name", map[string]interface{}{
+		"if-match": newTag,
+	}, "Seattle 3")
+	if e != nil {
+		t.Fatalf("failed to patch on matched ETag. Error:%s", e)
+	}
+	e = handler.DeleteIfMatch("data_center", "SEA1", newTag)
+	if e == nil || e.Status != http.StatusPreconditionFailed {
+		t.Fatalf("failed to reject delete on stale ETag")
+	}
+	patchedTag, _ := DataHandler.ETag(patched)
+	e = handler.DeleteIfMatch("data_center", "SEA1", patchedTag)
+	if e != nil {
+		t.Fatalf("failed to delete on matched ETag. Error:%s", e)
+	}
+	e = handler.DeleteIfMatch("data_center", "SEA1", "*")
+	if e == nil || e.Status != http.StatusPreconditionFailed {
+		t.Fatalf("failed to reject delete of missing record with If-Match")
+	}
+}
+
+// ETag of schema is compared under lock of schema record same as other records
+func TestDataHandlerSchemaETag(t *testing.T) {
+	handler := newMachineHandler(t)
+	schema := Record.NewRecord(JsonKey.Schema, "0.0.1", "tag", map[string]interface{}{
+		"name":       "tag",
+		"version":    "0.0.1",
+		"properties": map[string]interface{}{},
+	})
+	e := handler.Add(schema)
+	if e != nil {
+		t.Fatalf("failed to add schema. Error:%s", e)
+	}
+	e = handler.DeleteIfMatch(JsonKey.Schema, "tag", `"bad"`)
+	if e == nil || e.Status != http.StatusPreconditionFailed {
+		t.Fatalf("failed to reject delete of schema on ETag mismatch")
+	}
+	_, e = handler.LocalSchema("tag", "")
+	if e != nil {
+		t.Fatalf("schema should not be deleted on ETag mismatch. Error:%s", e)
+	}
+	e = handler.DeleteIfMatch(JsonKey.Schema, "tag", schema.ETag())
+	if e != nil {
+		t.Fatalf("failed to delete schema on matched ETag. Error:%s", e)
+	}
+	_, e = handler.LocalSchema("tag", "")
+	if e == nil || e.Status != http.StatusNotFound {
+		t.Fatalf("schema should be deleted on matched ETag")
+	}
+}
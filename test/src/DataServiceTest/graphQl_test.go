@@ -0,0 +1,244 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataServiceTest
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"DataService/DataHandler"
+	"DataService/GraphQl"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+func newServerHandler(t *testing.T) *DataHandler.Handler {
+	handler := newNamespaceHandler(t)
+	schemaList := []string{
+		`{
+			"name": "data_center",
+			"version": "0.0.1",
+			"properties": {
+				"region": {
+					"type": "string"
+				}
+			}
+		}`,
+		`{
+			"name": "rack",
+			"version": "0.0.1",
+			"properties": {
+				"row": {
+					"type": "integer"
+				},
+				"data_center": {
+					"type": "string",
+					"contentMediaType": "inventory/data_center"
+				}
+			}
+		}`,
+		`{
+			"name": "server",
+			"version": "0.0.1",
+			"properties": {
+				"cpu": {
+					"type": "integer"
+				},
+				"rack": {
+					"type": "string",
+					"contentMediaType": "inventory/rack"
+				},
+				"ports": {
+					"type": "array",
+					"items": {
+						"type": "object",
+						"$ref": "#/definitions/port"
+					}
+				},
+				"peers": {
+					"type": "array",
+					"required": false,
+					"items": {
+						"type": "string",
+						"contentMediaType": "inventory/server"
+					}
+				},
+				"labels": {
+					"type": "map",
+					"required": false,
+					"items": {
+						"type": "string"
+					}
+				}
+			},
+			"definitions": {
+				"port": {
+					"name": "port",
+					"key": "{name}",
+					"properties": {
+						"name": {
+							"type": "string"
+						},
+						"speed": {
+							"type": "number"
+						}
+					}
+				}
+			}
+		}`,
+	}
+	for _, schemaStr := range schemaList {
+		data := map[string]interface{}{}
+		err := json.Unmarshal([]byte(schemaStr), &data)
+		if err != nil {
+			t.Fatalf("failed to load schema. Error:%s", err)
+		}
+		e := handler.Add(Record.NewRecord("schema", "0.0.1", data["name"].(string), data))
+		if e != nil {
+			t.Fatalf("failed to add schema [%s]. Error:%s", data["name"], e)
+		}
+	}
+	recordList := []*Record.Record{
+		Record.NewRecord("data_center", "0.0.1", "SEA1", map[string]interface{}{"region": "us-west"}),
+		Record.NewRecord("rack", "0.0.1", "r12", map[string]interface{}{"row": 1, "data_center": "SEA1"}),
+		Record.NewRecord("server", "0.0.1", "s1", map[string]interface{}{
+			"cpu":   8,
+			"rack":  "r12",
+			"ports": []interface{}{},
+		}),
+		Record.NewRecord("server", "0.0.1", "s2", map[string]interface{}{
+			"cpu":  16,
+			"rack": "r12",
+			"ports": []interface{}{
+				map[string]interface{}{"name": "eth0", "speed": 2.5},
+			},
+			"peers":  []interface{}{"s1"},
+			"labels": map[string]interface{}{"env": "prod"},
+		}),
+	}
+	for _, record := range recordList {
+		e := handler.Add(record)
+		if e != nil {
+			t.Fatalf("failed to add record [%s/%s]. Error:%s", record.Type, record.Id, e)
+		}
+	}
+	return handler
+}
+
+func TestGraphQl(t *testing.T) {
+	handler := newServerHandler(t)
+	schema, e := GraphQl.Build(handler)
+	if e != nil {
+		t.Fatalf("failed to build GraphQL schema. Error:%s", e)
+	}
+	sdl := schema.SDL()
+	for _, line := range []string{"type server {", "  rack: rack\n", "  ports: [server_port]\n", "  peers: [server]\n", "  labels: JSON\n", "  list_server(filter: [String!], sort: String, limit: Int): [server!]\n"} {
+		if !strings.Contains(sdl, line) {
+			t.Fatalf("missing [%s] in SDL:\n%s", line, sdl)
+		}
+	}
+	queryMap := map[string]string{
+		`query Server($id: String!) {
+			server(id: $id) {
+				_id
+				cpu
+				rack { _id row data_center { _id region } }
+				ports { name speed }
+				peers { _id cpu }
+				labels
+			}
+		}`: `{"data":{"server":{"_id":"s2","cpu":16,"rack":{"_id":"r12","row":1,"data_center":{"_id":"SEA1","region":"us-west"}},"ports":[{"name":"eth0","speed":2.5}],"peers":[{"_id":"s1","cpu":8}],"labels":{"env":"prod"}}}}`,
+		`{
+			big: list_server(filter: ["cpu:gte:8"], sort: "-cpu", limit: 1) { ...id }
+			all: list_server(sort: "cpu") { __typename ... on server { _id } peers @skip(if: true) { _id } }
+		}
+		fragment id on server { _id _revision }`: `{"data":{"big":[{"_id":"s2","_revision":1}],"all":[{"__typename":"server","_id":"s1"},{"__typename":"server","_id":"s2"}]}}`,
+	}
+	for query, expected := range queryMap {
+		result := schema.Execute(&GraphQl.Request{
+			Query:     query,
+			Variables: map[string]interface{}{"id": "s2"},
+		})
+		resultStr, _ := json.Marshal(result)
+		if string(resultStr) != expected {
+			t.Fatalf("query [%s] expect %s, got %s", query, expected, resultStr)
+		}
+	}
+	result := schema.Execute(&GraphQl.Request{Query: `{ server(id: "none") { _id } rack(id: "r12") { _id } }`})
+	if result.Data == nil || result.Data.Get("server") != nil || result.Data.Get("rack") == nil {
+		t.Fatalf("expect partial data, got %v", result.Data)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Extensions["httpStatus"] != 404 {
+		t.Fatalf("expect not found error of server, got %v", result.Errors)
+	}
+	badQueryList := []string{
+		`{ server(id: "s1") { unknown } }`,
+		`{ server(id: "s1") }`,
+		`{ server(id: "s1") { cpu { name } } }`,
+		`{ server { _id } }`,
+		`{ server(id: 1) { _id } }`,
+		`{ list_server(limit: "1") { _id } }`,
+		`query ($id: String) { server(id: $other) { _id } }`,
+		`query ($id: String!) { server(id: $id) { _id } }`,
+		`{ server(id: "s1") { ...f } } fragment f on rack { _id }`,
+		`{ server(id: "s1") { ...f } } fragment f on server { ...f }`,
+		`mutation { server(id: "s1") { _id } }`,
+		`{ server(id: "s1") { _id }`,
+	}
+	for _, query := range badQueryList {
+		result := schema.Execute(&GraphQl.Request{Query: query})
+		if result.Data != nil || len(result.Errors) == 0 {
+			t.Fatalf("failed to reject query [%s]", query)
+		}
+	}
+}
+
+func TestGraphQlCache(t *testing.T) {
+	handler := newServerHandler(t)
+	cache := GraphQl.NewCache()
+	schema, e := cache.Get(handler)
+	if e != nil {
+		t.Fatalf("failed to get GraphQL schema. Error:%s", e)
+	}
+	cached, e := cache.Get(handler)
+	if e != nil || cached != schema {
+		t.Fatalf("schema should not be built again when schema records are not changed")
+	}
+	e = handler.Add(Record.NewRecord(JsonKey.Schema, "0.0.1", "tag", map[string]interface{}{
+		"name":       "tag",
+		"version":    "0.0.1",
+		"properties": map[string]interface{}{},
+	}))
+	if e != nil {
+		t.Fatalf("failed to add schema. Error:%s", e)
+	}
+	rebuilt, e := cache.Get(handler)
+	if e != nil || rebuilt == schema || rebuilt.Types["tag"] == nil {
+		t.Fatalf("schema should be built again with new type [tag]")
+	}
+}
@@ -0,0 +1,103 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataServiceTest
+
+import (
+	"net/http"
+	"testing"
+
+	"DataService/DataJournal"
+	"DataService/DataJournal/ProcessIface"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+func TestJournalChangeMeta(t *testing.T) {
+	handler := newMachineHandler(t)
+	journal, e := DataJournal.NewJournalLib(handler.DB, handler.Config.DataTable.Data, nil)
+	if e != nil {
+		t.Fatalf("failed to create journal. Error:%s", e)
+	}
+	handler.AddJournal = journal.AddJournal
+	_, e = handler.WithChange(&ProcessIface.ChangeMeta{Actor: "alice", Reason: "Rack Move", RequestId: "req-1"}).Patch("machine", "m1/rack/name", nil, "r13")
+	if e != nil {
+		t.Fatalf("failed to patch rack. Error:%s", e)
+	}
+	_, e = handler.WithChange(&ProcessIface.ChangeMeta{Actor: "bob", Reason: "maintenance", RequestId: "req-2"}).Patch("machine", "m2/state", nil, "idle")
+	if e != nil {
+		t.Fatalf("failed to patch state. Error:%s", e)
+	}
+	_, e = handler.Patch("machine", "m3/state", nil, "busy")
+	if e != nil {
+		t.Fatalf("failed to patch state. Error:%s", e)
+	}
+	page, e := journal.QueryJournal(ProcessIface.PageId("machine", "m1", 1))
+	if e != nil {
+		t.Fatalf("failed to get journal of m1. Error:%s", e)
+	}
+	entry := page.(*Record.Record).Data["active"].([]interface{})[0].(map[string]interface{})
+	if entry["actor"] != "alice" || entry["reason"] != "Rack Move" || entry["requestId"] != "req-1" {
+		t.Fatalf("invalid change meta in journal entry %v", entry)
+	}
+	page, e = journal.QueryJournal(ProcessIface.PageId("machine", "m3", 1))
+	if e != nil {
+		t.Fatalf("failed to get journal of m3. Error:%s", e)
+	}
+	entry = page.(*Record.Record).Data["active"].([]interface{})[0].(map[string]interface{})
+	if _, ok := entry["actor"]; ok {
+		t.Fatalf("unexpected actor in journal entry %v", entry)
+	}
+	filters := []struct {
+		journalId string
+		filter    ProcessIface.ChangeMeta
+		dataIds   []string
+	}{
+		{"", ProcessIface.ChangeMeta{Actor: "alice"}, []string{"m1"}},
+		{"", ProcessIface.ChangeMeta{Reason: "rack"}, []string{"m1"}},
+		{"", ProcessIface.ChangeMeta{Actor: "bob", RequestId: "req-1"}, []string{}},
+		{"dataType:machine", ProcessIface.ChangeMeta{RequestId: "req-2"}, []string{"m2"}},
+		{"dataType:machine_dataId:m2", ProcessIface.ChangeMeta{Actor: "alice"}, []string{}},
+		{"dataType:machine_dataId:m1_page:1", ProcessIface.ChangeMeta{Actor: "alice"}, []string{"m1"}},
+	}
+	for _, f := range filters {
+		pages, e := journal.FilterJournal(f.journalId, &f.filter)
+		if e != nil {
+			t.Fatalf("failed to filter journal [%s] by %v. Error:%s", f.journalId, f.filter, e)
+		}
+		if len(pages) != len(f.dataIds) {
+			t.Fatalf("expect %d pages of [%s] by %v, got %d", len(f.dataIds), f.journalId, f.filter, len(pages))
+		}
+		for idx, page := range pages {
+			if page.DataId != f.dataIds[idx] || len(page.Active) != 1 {
+				t.Fatalf("invalid page [%s] of [%s] by %v", page.Id(), f.journalId, f.filter)
+			}
+		}
+	}
+	_, e = journal.FilterJournal("machine", &ProcessIface.ChangeMeta{Actor: "alice"})
+	if e == nil || e.Status != http.StatusBadRequest {
+		t.Fatalf("expect bad request on invalid journal id, got %v", e)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataServiceTest
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"Data"
+	"Data/DbConfig"
+	"Data/DbIface"
+	"DataService/Common"
+	"DataService/Migrate"
+
+	"github.com/salesforce/UniTAO/lib/Schema/CmtIndex"
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+func connectMigrateDb(t *testing.T, configStr string) DbIface.Database {
+	config := DbConfig.DatabaseConfig{}
+	err := json.Unmarshal([]byte(configStr), &config)
+	if err != nil {
+		t.Fatalf("failed to load config, Error:%s", err)
+	}
+	db, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to connect [%s], Error:%s", config.DbType, err)
+	}
+	return db
+}
+
+func migrateRecord(dataType string, dataId string) map[string]interface{} {
+	return Record.NewRecord(dataType, "0.0.1", dataId, map[string]interface{}{
+		"name": dataId,
+	}).Map()
+}
+
+func TestMigrate(t *testing.T) {
+	source := connectMigrateDb(t, `{"type": "inmemory"}`)
+	err := source.CreateTable("DataService01", nil)
+	if err != nil {
+		t.Fatalf("failed to create source table, Error:%s", err)
+	}
+	batch := DbIface.NewBatch()
+	for _, record := range []map[string]interface{}{
+		migrateRecord(JsonKey.Schema, "rack"),
+		migrateRecord(JsonKey.Schema, "server"),
+		migrateRecord(JsonKey.Schema, "server__0.0.1"),
+		migrateRecord("rack", "r01"),
+		migrateRecord("rack", "r02"),
+		migrateRecord("rack", "r03"),
+		migrateRecord("server", "s01"),
+		migrateRecord(CmtIndex.KeyCmtIdx, "rack"),
+		migrateRecord(Common.KeyJournal, "rack_r01_1"),
+	} {
+		batch.Create("DataService01", record)
+	}
+	err = source.Commit(batch)
+	if err != nil {
+		t.Fatalf("failed to create source records, Error:%s", err)
+	}
+	target := connectMigrateDb(t, `{"type": "boltdb", "boltdb": {"path": "`+filepath.Join(t.TempDir(), "target.db")+`"}}`)
+	statePath := filepath.Join(t.TempDir(), "migrate.json")
+	tables := map[string]string{
+		"DataService01": "DataService02",
+	}
+	migration, err := Migrate.New(source, target, tables, 2, statePath, nil)
+	if err != nil {
+		t.Fatalf("failed to create migration, Error:%s", err)
+	}
+	typeList, err := migration.ListTypes("DataService01")
+	if err != nil {
+		t.Fatalf("failed to list types, Error:%s", err)
+	}
+	typeMap := map[string]bool{}
+	for _, dataType := range typeList {
+		typeMap[dataType] = true
+	}
+	for _, dataType := range []string{JsonKey.Schema, "rack", "server", CmtIndex.KeyCmtIdx, CmtIndex.KeyCmtSubscriber, Common.KeyJournal} {
+		if !typeMap[dataType] {
+			t.Fatalf("type [%s] missing from %v", dataType, typeList)
+		}
+	}
+	err = migration.Run()
+	if err != nil {
+		t.Fatalf("failed to migrate, Error:%s", err)
+	}
+	err = migration.Verify()
+	if err != nil {
+		t.Fatalf("failed to verify migration, Error:%s", err)
+	}
+	// resume skip finished types, so a record added to source after that is not copied
+	err = source.Create("DataService01", migrateRecord("rack", "r04"))
+	if err != nil {
+		t.Fatalf("failed to create source record, Error:%s", err)
+	}
+	resumed, err := Migrate.New(source, target, tables, 2, statePath, nil)
+	if err != nil {
+		t.Fatalf("failed to resume migration, Error:%s", err)
+	}
+	err = resumed.Run()
+	if err != nil {
+		t.Fatalf("failed to run resumed migration, Error:%s", err)
+	}
+	err = resumed.Verify()
+	if err == nil {
+		t.Fatalf("failed to detect missing record on verify")
+	}
+	fresh, err := Migrate.New(source, target, tables, 2, "", nil)
+	if err != nil {
+		t.Fatalf("failed to create migration, Error:%s", err)
+	}
+	err = fresh.Run()
+	if err != nil {
+		t.Fatalf("failed to migrate again, Error:%s", err)
+	}
+	err = fresh.Verify()
+	if err != nil {
+		t.Fatalf("failed to verify migration, Error:%s", err)
+	}
+}
+
+func TestMigrateSysDirFile(t *testing.T) {
+	source := connectMigrateDb(t, `{"type": "sysdirfile", "sysdirfile": {"path": "`+t.TempDir()+`"}}`)
+	err := source.CreateTable("DataService01", nil)
+	if err != nil {
+		t.Fatalf("failed to create source table, Error:%s", err)
+	}
+	// sysdirfile name file by id, so keep id unique across types
+	for _, record := range []map[string]interface{}{
+		migrateRecord(JsonKey.Schema, "rack"),
+		migrateRecord(JsonKey.Schema, "server"),
+		migrateRecord("rack", "r01"),
+		migrateRecord("rack", "r02"),
+		migrateRecord("server", "s01"),
+		migrateRecord(Common.KeyJournal, "rack_r01_1"),
+	} {
+		err = source.Create("DataService01", record)
+		if err != nil {
+			t.Fatalf("failed to create source record, Error:%s", err)
+		}
+	}
+	target := connectMigrateDb(t, `{"type": "inmemory"}`)
+	tables := map[string]string{
+		"DataService01": "DataService02",
+	}
+	migration, err := Migrate.New(source, target, tables, 2, "", nil)
+	if err != nil {
+		t.Fatalf("failed to create migration, Error:%s", err)
+	}
+	err = migration.Run()
+	if err != nil {
+		t.Fatalf("failed to migrate, Error:%s", err)
+	}
+	err = migration.Verify()
+	if err != nil {
+		t.Fatalf("failed to verify migration, Error:%s", err)
+	}
+	for dataType, count := range map[string]int{
+		JsonKey.Schema:    2,
+		"rack":            2,
+		"server":          1,
+		Common.KeyJournal: 1,
+	} {
+		recordList, err := target.Get(map[string]interface{}{
+			DbIface.Table:   "DataService02",
+			Record.DataType: dataType,
+		})
+		if err != nil {
+			t.Fatalf("failed to get [%s] from target, Error:%s", dataType, err)
+		}
+		if len(recordList) != count {
+			t.Fatalf("expect [%d] record of type [%s] in target, got [%d]", count, dataType, len(recordList))
+		}
+	}
+}
@@ -27,6 +27,7 @@ package DataServiceTest
 
 import (
 	"Data/DbConfig"
+	"Data/DbIface"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -113,7 +114,19 @@ func (db MockDatabase) Get(queryArgs map[string]interface{}) ([]map[string]inter
 			result = append(result, dataMap)
 		}
 	}
-	return result, nil
+	filter, err := DbIface.GetFilter(queryArgs)
+	if err != nil {
+		return nil, err
+	}
+	return DbIface.FilterRecords(result, filter), nil
+}
+
+func (db MockDatabase) GetPage(queryArgs map[string]interface{}, pageSize int, pageToken string) ([]map[string]interface{}, string, error) {
+	recordList, err := db.Get(queryArgs)
+	if err != nil {
+		return nil, "", err
+	}
+	return DbIface.PageRecords(recordList, pageSize, pageToken)
 }
 
 func (db MockDatabase) Update(table string, keys map[string]interface{}, data interface{}) (map[string]interface{}, error) {
@@ -136,6 +149,14 @@ func (db MockDatabase) Replace(table string, keys map[string]interface{}, data i
 	if err != nil {
 		return fmt.Errorf("invalid data format, failed to convert to record")
 	}
+	var current map[string]interface{}
+	if typeMap, ok := db.Data[dataType].(map[string]interface{}); ok {
+		current, _ = typeMap[dataId].(map[string]interface{})
+	}
+	err = DbIface.CheckRevision(current, keys)
+	if err != nil {
+		return err
+	}
 	if dataType != record.Type || dataId != record.Id {
 		db.Delete(table, keys)
 	}
@@ -164,3 +185,26 @@ func (db MockDatabase) Delete(table string, keys map[string]interface{}) error {
 	delete(typeMap, dataId)
 	return nil
 }
+
+func (db MockDatabase) Commit(batch *DbIface.Batch) error {
+	if batch.Empty() {
+		return nil
+	}
+	for idx, op := range batch.Operations {
+		var err error
+		switch op.Action {
+		case DbIface.OpCreate:
+			err = db.Create(op.Table, op.Data)
+		case DbIface.OpReplace:
+			err = db.Replace(op.Table, op.Keys, op.Data)
+		case DbIface.OpDelete:
+			err = db.Delete(op.Table, op.Keys)
+		default:
+			err = fmt.Errorf("unknown action [%s]", op.Action)
+		}
+		if err != nil {
+			return fmt.Errorf("batch operation [%d] failed. Error:%w", idx, err)
+		}
+	}
+	return nil
+}
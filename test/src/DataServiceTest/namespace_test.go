@@ -0,0 +1,241 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataServiceTest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"Data"
+	"Data/DbIface"
+	"DataService/Config"
+	"DataService/DataHandler"
+	"DataService/DataJournal"
+	"DataService/Namespace"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Util/Thread"
+)
+
+func newNamespaceHandler(t *testing.T) *DataHandler.Handler {
+	config := Config.Confuguration{}
+	err := json.Unmarshal([]byte(`
+	{
+		"database": {
+			"type": "inmemory"
+		},
+		"table": {
+			"data": "DataService01"
+		}
+	}`), &config)
+	if err != nil {
+		t.Fatalf("failed to load config. Error:%s", err)
+	}
+	handler, e := DataHandler.New(config, nil, Data.ConnectDb)
+	if e != nil {
+		t.Fatalf("failed to create handler. Error:%s", e)
+	}
+	err = handler.DB.CreateTable("DataService01", nil)
+	if err != nil {
+		t.Fatalf("failed to create table. Error:%s", err)
+	}
+	schemaStr, err := GetSchemaOfSchema()
+	if err != nil {
+		t.Fatalf("failed to load schema of schema. Error:%s", err)
+	}
+	schemaData := map[string]map[string]map[string]interface{}{}
+	err = json.Unmarshal([]byte(schemaStr), &schemaData)
+	if err != nil {
+		t.Fatalf("failed to parse schema of schema. Error:%s", err)
+	}
+	batch := DbIface.NewBatch()
+	for _, typeData := range schemaData {
+		for _, record := range typeData {
+			batch.Create("DataService01", record)
+		}
+	}
+	err = handler.DB.Commit(batch)
+	if err != nil {
+		t.Fatalf("failed to import schema of schema. Error:%s", err)
+	}
+	return handler
+}
+
+func TestNamespace(t *testing.T) {
+	handler := newNamespaceHandler(t)
+	manager, e := Namespace.NewManager(handler, nil, nil, nil)
+	if e != nil {
+		t.Fatalf("failed to create namespace manager. Error:%s", e)
+	}
+	e = manager.Create("team_a")
+	if e == nil || e.Status != http.StatusBadRequest {
+		t.Fatalf("failed to reject invalid namespace name")
+	}
+	e = manager.Create("team-a")
+	if e != nil {
+		t.Fatalf("failed to create namespace. Error:%s", e)
+	}
+	e = manager.Create("team-a")
+	if e == nil || e.Status != http.StatusConflict {
+		t.Fatalf("failed to reject existing namespace")
+	}
+	ns, e := manager.Get("team-a")
+	if e != nil {
+		t.Fatalf("failed to get namespace. Error:%s", e)
+	}
+	e = ns.Data.Add(Record.NewRecord(JsonKey.Schema, "0.0.1", "data_center", map[string]interface{}{
+		"name":        "data_center",
+		"version":     "0.0.1",
+		"description": "data center Schema",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{
+				"type": "string",
+			},
+		},
+	}))
+	if e != nil {
+		t.Fatalf("failed to add schema in namespace. Error:%s", e)
+	}
+	e = ns.Data.Add(Record.NewRecord("data_center", "0.0.1", "SEA1", map[string]interface{}{
+		"name": "Seattle",
+	}))
+	if e != nil {
+		t.Fatalf("failed to add record in namespace. Error:%s", e)
+	}
+	journalTypes := ns.Journal.ListJournalTypes()
+	if len(journalTypes) != 2 {
+		t.Fatalf("journal of namespace not recorded, %v", journalTypes)
+	}
+	_, e = handler.Get("data_center", "SEA1")
+	if e == nil || e.Status != http.StatusNotFound {
+		t.Fatalf("record of namespace is visible in default namespace")
+	}
+	root, _ := manager.Get(Namespace.Default)
+	if len(root.Journal.ListJournalTypes()) != 0 {
+		t.Fatalf("journal of namespace is visible in default namespace")
+	}
+	// namespaces are loaded again from default data table
+	reloaded, e := Namespace.NewManager(handler, nil, nil, nil)
+	if e != nil {
+		t.Fatalf("failed to reload namespaces. Error:%s", e)
+	}
+	nameList := reloaded.List()
+	if len(nameList) != 1 || nameList[0] != "team-a" {
+		t.Fatalf("invalid namespace list %v", nameList)
+	}
+	ns, _ = reloaded.Get("team-a")
+	_, e = ns.Data.Get("data_center", "SEA1")
+	if e != nil {
+		t.Fatalf("failed to get record of reloaded namespace. Error:%s", e)
+	}
+	e = reloaded.Delete("team-a")
+	if e != nil {
+		t.Fatalf("failed to delete namespace. Error:%s", e)
+	}
+	_, e = reloaded.Get("team-a")
+	if e == nil || e.Status != http.StatusNotFound {
+		t.Fatalf("deleted namespace still exists")
+	}
+	tableList, _ := handler.DB.ListTable()
+	if len(tableList) != 1 {
+		t.Fatalf("table of deleted namespace still exists %v", tableList)
+	}
+}
+
+// journal handlers run as workers of backend controller
+func TestNamespaceWorker(t *testing.T) {
+	handler := newNamespaceHandler(t)
+	backendCtl := Thread.NewThreadController(nil)
+	manager, e := Namespace.NewManager(handler, backendCtl, nil, nil)
+	if e != nil {
+		t.Fatalf("failed to create namespace manager. Error:%s", e)
+	}
+	e = manager.Create("team-a")
+	if e != nil {
+		t.Fatalf("failed to create namespace. Error:%s", e)
+	}
+	ns, _ := manager.Get("team-a")
+	e = ns.Data.Add(Record.NewRecord(JsonKey.Schema, "0.0.1", "data_center", map[string]interface{}{
+		"name":       "data_center",
+		"version":    "0.0.1",
+		"properties": map[string]interface{}{},
+	}))
+	if e != nil {
+		t.Fatalf("failed to add schema in namespace. Error:%s", e)
+	}
+	workerId := "journalHandler_team-a"
+	if backendCtl.GetWorker(workerId) == nil {
+		t.Fatalf("journal handler of namespace is not started")
+	}
+	feed := ns.Journal.Feed
+	sub := feed.Subscribe(&DataJournal.ChangeFilter{})
+	e = manager.Delete("team-a")
+	if e != nil {
+		t.Fatalf("failed to delete namespace. Error:%s", e)
+	}
+	if _, ok := <-sub.Events; ok || sub.Err() == nil || sub.Err().Status != http.StatusGone {
+		t.Fatalf("subscription should be ended when namespace is deleted")
+	}
+	sub = feed.Subscribe(&DataJournal.ChangeFilter{})
+	if _, ok := <-sub.Events; ok || sub.Err() == nil {
+		t.Fatalf("subscribe to deleted namespace should be ended right away")
+	}
+	if backendCtl.GetWorker(workerId) != nil {
+		t.Fatalf("journal handler of namespace should exit before Delete return")
+	}
+	e = manager.Create("team-a")
+	if e != nil {
+		t.Fatalf("failed to create namespace again. Error:%s", e)
+	}
+	ns, _ = manager.Get("team-a")
+	_, e = ns.Data.LocalSchema("data_center", "")
+	if e == nil || e.Status != http.StatusNotFound {
+		t.Fatalf("schema of deleted namespace should not exist in namespace created again")
+	}
+	// namespace is rolled back when its journal handler failed to start
+	_, ex := backendCtl.AddWorker("journalHandler_team-b", func(chan interface{}) error { return nil })
+	if ex != nil {
+		t.Fatalf("failed to add worker. Error:%s", ex)
+	}
+	e = manager.Create("team-b")
+	if e == nil {
+		t.Fatalf("namespace should not be created when journal handler failed to start")
+	}
+	tableList, _ := handler.DB.ListTable()
+	if len(tableList) != 2 {
+		t.Fatalf("table of failed namespace should be removed, got %v", tableList)
+	}
+	reloaded, e := Namespace.NewManager(handler, nil, nil, nil)
+	if e != nil {
+		t.Fatalf("failed to reload namespaces. Error:%s", e)
+	}
+	nameList := reloaded.List()
+	if len(nameList) != 1 || nameList[0] != "team-a" {
+		t.Fatalf("record of failed namespace should be removed, got %v", nameList)
+	}
+}
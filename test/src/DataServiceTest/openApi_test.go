@@ -0,0 +1,122 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataServiceTest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"DataService/OpenApi"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Util"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+func TestOpenApi(t *testing.T) {
+	handler := newMachineHandler(t)
+	doc, e := OpenApi.Build(handler, "test")
+	if e != nil {
+		t.Fatalf("failed to build OpenAPI document. Error:%s", e)
+	}
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, name := range []string{"machine", "machine.rack", "machine.__record", JsonKey.Schema, OpenApi.KeyError, OpenApi.KeyJsonPatch} {
+		if _, ok := schemas[name]; !ok {
+			t.Fatalf("missing component [%s]", name)
+		}
+	}
+	machine := schemas["machine"].(map[string]interface{})
+	rack := machine["properties"].(map[string]interface{})["rack"].(map[string]interface{})
+	if rack[JsonKey.Ref] != OpenApi.ComponentPrefix+"machine.rack" {
+		t.Fatalf("invalid ref of rack %v", rack)
+	}
+	if len(machine[JsonKey.Required].([]string)) != 5 {
+		t.Fatalf("invalid required attributes %v", machine[JsonKey.Required])
+	}
+	paths := doc["paths"].(map[string]interface{})
+	for _, path := range []string{"/", "/machine", "/machine/{id}", "/machine/{id}/{path}"} {
+		if _, ok := paths[path]; !ok {
+			t.Fatalf("missing path [%s]", path)
+		}
+	}
+	if _, ok := paths["/machine/{id}"].(map[string]interface{})["patch"]; !ok {
+		t.Fatalf("missing patch on record")
+	}
+	// multi level path is one encoded path parameter, server decode it back to levels
+	pathParam := doc["components"].(map[string]interface{})["parameters"].(map[string]interface{})[OpenApi.ParamPath].(map[string]interface{})
+	request := httptest.NewRequest(http.MethodGet, "/machine/m01/"+pathParam["example"].(string), nil)
+	requestUrl, err := Http.GetUrl(request)
+	if err != nil {
+		t.Fatalf("failed to decode url of example path. Error:%s", err)
+	}
+	dataType, idPath := Util.ParsePath(requestUrl)
+	if dataType != "machine" || idPath != "m01/attr1/attr2[key]/attr3" {
+		t.Fatalf("invalid decoded path of example [%s/%s]", dataType, idPath)
+	}
+	schema := Record.Record{}
+	json.Unmarshal([]byte(`{
+		"__id": "machine",
+		"__type": "schema",
+		"__ver": "0.0.1",
+		"data": {
+			"name": "machine",
+			"version": "0.0.2",
+			"properties": {
+				"state": {
+					"type": "string"
+				},
+				"owner": {
+					"type": "string",
+					"contentMediaType": "inventory/team",
+					"required": false
+				}
+			}
+		}
+	}`), &schema)
+	e = handler.Add(&schema)
+	if e != nil {
+		t.Fatalf("failed to upgrade machine schema. Error:%s", e)
+	}
+	doc, e = OpenApi.Build(handler, "test")
+	if e != nil {
+		t.Fatalf("failed to rebuild OpenAPI document. Error:%s", e)
+	}
+	schemas = doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	archived, ok := schemas["machine__0.0.1"].(map[string]interface{})
+	if !ok || archived["deprecated"] != true {
+		t.Fatalf("missing deprecated component of archived schema")
+	}
+	owner := schemas["machine"].(map[string]interface{})["properties"].(map[string]interface{})["owner"].(map[string]interface{})
+	if owner[OpenApi.ExtCmt] != "inventory/team" {
+		t.Fatalf("invalid component of new schema %v", owner)
+	}
+	if _, ok := doc["paths"].(map[string]interface{})["/machine__0.0.1"]; ok {
+		t.Fatalf("archived schema should not have paths")
+	}
+}
@@ -0,0 +1,246 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataServiceTest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"DataService/Common"
+	"DataService/DataHandler"
+	"DataService/DataJournal"
+	"DataService/Rbac"
+
+	"github.com/salesforce/UniTAO/lib/Schema/JsonKey"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+func TestRbac(t *testing.T) {
+	handler := newMachineHandler(t)
+	manager := Rbac.NewManager(handler, true)
+	_, e := manager.Set(Common.KeyRole, "machine-ops", map[string]interface{}{
+		"rules": []interface{}{
+			map[string]interface{}{
+				"name":      "read",
+				"dataTypes": []interface{}{"*"},
+				"verbs":     []interface{}{"get"},
+			},
+			map[string]interface{}{
+				"name":      "state",
+				"dataTypes": []interface{}{"machine"},
+				"verbs":     []interface{}{"patch"},
+				"paths":     []interface{}{"state", "rack/name"},
+			},
+			map[string]interface{}{
+				"name":      "create",
+				"dataTypes": []interface{}{"machine"},
+				"verbs":     []interface{}{"post"},
+			},
+		},
+	})
+	if e != nil {
+		t.Fatalf("failed to set role. Error:%s", e)
+	}
+	_, e = manager.Set(Common.KeyRoleBinding, "ops", map[string]interface{}{
+		"role":     "machine-ops",
+		"subjects": []interface{}{"ops-team"},
+	})
+	if e != nil {
+		t.Fatalf("failed to set role binding. Error:%s", e)
+	}
+	_, e = manager.Set(Common.KeyRole, "bad", map[string]interface{}{
+		"rules": "all",
+	})
+	if e == nil || e.Status != http.StatusBadRequest {
+		t.Fatalf("failed to reject invalid role")
+	}
+	_, e = manager.Set(Common.KeyRole, Rbac.RoleAdmin, map[string]interface{}{
+		"rules": []interface{}{},
+	})
+	if e == nil || e.Status != http.StatusBadRequest {
+		t.Fatalf("failed to reject role named [%s]", Rbac.RoleAdmin)
+	}
+	alice := &Http.Identity{Name: "alice", Roles: []string{"ops-team"}}
+	admin := &Http.Identity{Name: "root", Roles: []string{Rbac.RoleAdmin}}
+	cases := []struct {
+		identity *Http.Identity
+		verb     string
+		dataType string
+		path     string
+		allowed  bool
+	}{
+		{alice, Rbac.VerbGet, "machine", "", true},
+		{alice, Rbac.VerbGet, JsonKey.Schema, "", false},
+		{alice, Rbac.VerbPut, "machine", "", false},
+		{alice, Rbac.VerbPatch, "machine", "state", true},
+		{alice, Rbac.VerbPatch, "machine", "rack/name", true},
+		{alice, Rbac.VerbPatch, "machine", "rack/row", false},
+		{alice, Rbac.VerbPatch, "machine", "cpu", false},
+		{alice, Rbac.VerbPatch, "machine", "", false},
+		{alice, Rbac.VerbPatch, "server", "state", false},
+		{nil, Rbac.VerbGet, "machine", "", false},
+		{admin, Rbac.VerbPut, JsonKey.Schema, "", true},
+	}
+	for _, c := range cases {
+		name := "anonymous"
+		if c.identity != nil {
+			name = c.identity.Name
+		}
+		e = manager.Check(c.identity, c.verb, c.dataType, c.path)
+		if c.allowed && e != nil {
+			t.Fatalf("[%s] should be allowed to [%s] on [%s/%s]. Error:%s", name, c.verb, c.dataType, c.path, e)
+		}
+		if !c.allowed && (e == nil || e.Status != http.StatusForbidden) {
+			t.Fatalf("[%s] should be forbidden to [%s] on [%s/%s]", name, c.verb, c.dataType, c.path)
+		}
+	}
+	handler.Authorize = manager.Check
+	headers := map[string]interface{}{
+		Common.KeyIdentity: alice,
+	}
+	_, e = handler.Patch("machine", "m1/state", headers, "busy")
+	if e != nil {
+		t.Fatalf("failed to patch allowed path. Error:%s", e)
+	}
+	_, e = handler.Patch("machine", "m1/cpu", headers, 4)
+	if e == nil || e.Status != http.StatusForbidden {
+		t.Fatalf("failed to deny patch on path [cpu]")
+	}
+	headers["content-type"] = Common.ContentTypeMergePatch
+	_, e = handler.Patch("machine", "m1", headers, map[string]interface{}{"state": "ready", "cpu": 4})
+	if e == nil || e.Status != http.StatusForbidden {
+		t.Fatalf("failed to deny merge patch with attribute [cpu]")
+	}
+	_, e = handler.Patch("machine", "m1", headers, map[string]interface{}{"rack": map[string]interface{}{"name": "r14"}})
+	if e != nil {
+		t.Fatalf("failed to merge patch allowed nested attribute [rack/name]. Error:%s", e)
+	}
+	_, e = handler.Patch("machine", "m1", headers, map[string]interface{}{"rack": map[string]interface{}{"row": 3}})
+	if e == nil || e.Status != http.StatusForbidden {
+		t.Fatalf("failed to deny merge patch with nested attribute [rack/row]")
+	}
+	headers["content-type"] = Common.ContentTypeJsonPatch
+	_, e = handler.Patch("machine", "m1", headers, []interface{}{
+		map[string]interface{}{"op": "replace", "path": "/rack/name", "value": "r15"},
+	})
+	if e != nil {
+		t.Fatalf("failed to JSON patch allowed pointer [/rack/name]. Error:%s", e)
+	}
+	_, e = handler.Patch("machine", "m1", headers, []interface{}{
+		map[string]interface{}{"op": "replace", "path": "/state", "value": "busy"},
+		map[string]interface{}{"op": "copy", "from": "/state", "path": "/rack/name"},
+		map[string]interface{}{"op": "replace", "path": "/rack/row", "value": 3},
+	})
+	if e == nil || e.Status != http.StatusForbidden {
+		t.Fatalf("failed to deny JSON patch with pointer [/rack/row]")
+	}
+	_, e = handler.Patch("machine", "m1/cpu", nil, 4)
+	if e != nil {
+		t.Fatalf("internal patch without identity should not be checked. Error:%s", e)
+	}
+	// each imported record is checked on its own type
+	importStr := strings.Join([]string{
+		`{"__id": "m9", "__type": "machine", "__ver": "0.0.1", "data": {"state": "new", "cpu": 2, "ready": false, "rack": {"name": "r1", "row": 1}, "tags": []}}`,
+		`{"__id": "m1", "__type": "machine", "__ver": "0.0.1", "data": {"state": "replaced"}}`,
+		`{"__id": "rogue", "__type": "schema", "__ver": "0.0.1", "data": {"name": "rogue", "version": "0.0.1", "properties": {}}}`,
+	}, "\n")
+	result, e := handler.Import(strings.NewReader(importStr), DataHandler.ImportUpsert, alice)
+	if e != nil {
+		t.Fatalf("failed to import. Error:%s", e)
+	}
+	if result.Created != 1 || result.Failed != 2 {
+		t.Fatalf("invalid import result %v", result)
+	}
+	for _, err := range result.Errors {
+		if err.Error.Status != http.StatusForbidden {
+			t.Fatalf("import of line [%d] should be forbidden, got %s", err.Line, err.Error)
+		}
+	}
+	// events of types not granted explicitly are not visible in stream of all types
+	visible := manager.Visible(alice, Rbac.VerbGet)
+	if !visible("machine") || visible(JsonKey.Schema) || visible(Common.KeyRole) {
+		t.Fatalf("wildcard rule should cover user types only")
+	}
+	if Rbac.NewManager(handler, false).Visible(alice, Rbac.VerbGet) != nil {
+		t.Fatalf("nothing should be filtered when access control is disabled")
+	}
+	journal, e := DataJournal.NewJournalLib(handler.DB, handler.Config.DataTable.Data, nil)
+	if e != nil {
+		t.Fatalf("failed to create journal. Error:%s", e)
+	}
+	handler.AddJournal = journal.AddJournal
+	sub, _, e := journal.Subscribe(&DataJournal.ChangeFilter{Allow: visible}, "")
+	if e != nil {
+		t.Fatalf("failed to subscribe. Error:%s", e)
+	}
+	defer sub.Close()
+	schema := Record.NewRecord(JsonKey.Schema, "0.0.1", "tag", map[string]interface{}{
+		"name":       "tag",
+		"version":    "0.0.1",
+		"properties": map[string]interface{}{},
+	})
+	e = handler.Add(schema)
+	if e != nil {
+		t.Fatalf("failed to add schema. Error:%s", e)
+	}
+	_, e = handler.Patch("machine", "m2/cpu", nil, 2)
+	if e != nil {
+		t.Fatalf("failed to patch cpu. Error:%s", e)
+	}
+	event := receiveEvents(t, sub, 1)[0]
+	if event.DataType != "machine" {
+		t.Fatalf("expect only change of machine, got [%s/%s]", event.DataType, event.DataId)
+	}
+	// roles and bindings are saved with journal and revision like other records
+	_, e = manager.Set(Common.KeyRoleBinding, "ops", map[string]interface{}{
+		"role":     "machine-ops",
+		"subjects": []interface{}{"ops-team", "sre"},
+	})
+	if e != nil {
+		t.Fatalf("failed to replace role binding. Error:%s", e)
+	}
+	binding, e := manager.Get(Common.KeyRoleBinding, "ops")
+	if e != nil || binding.Revision != 2 {
+		t.Fatalf("role binding should be replaced on revision [2], got %v", binding)
+	}
+	if len(journal.ListJournalIds(Common.KeyRoleBinding)) != 1 {
+		t.Fatalf("change of role binding is not journaled")
+	}
+	e = manager.Delete("machine", "m1")
+	if e == nil || e.Status != http.StatusBadRequest {
+		t.Fatalf("failed to reject delete of type [machine]")
+	}
+	e = manager.Delete(Common.KeyRoleBinding, "ops")
+	if e != nil {
+		t.Fatalf("failed to delete role binding. Error:%s", e)
+	}
+	e = manager.Check(alice, Rbac.VerbGet, "machine", "")
+	if e == nil || e.Status != http.StatusForbidden {
+		t.Fatalf("role should be revoked with binding")
+	}
+}
@@ -0,0 +1,322 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataServiceTest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"DataService/Common"
+	"DataService/DataHandler"
+	"DataService/DataJournal/Process"
+	"DataService/DataJournal/ProcessIface"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+type webhookCall struct {
+	path      string
+	signature string
+	delivery  string
+	payload   Process.WebhookPayload
+	body      []byte
+}
+
+func machineData(id string, state string, cpu int) map[string]interface{} {
+	return Record.NewRecord("machine", "0.0.1", id, map[string]interface{}{
+		"state": state,
+		"cpu":   cpu,
+		"ready": true,
+		"rack": map[string]interface{}{
+			"name": "r12",
+			"row":  1,
+		},
+		"tags": []interface{}{},
+	}).Map()
+}
+
+func TestWebhookProcess(t *testing.T) {
+	handler := newMachineHandler(t)
+	lock := sync.Mutex{}
+	attempts := map[string]int{}
+	calls := make(chan webhookCall, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		attempts[r.URL.Path]++
+		count := attempts[r.URL.Path]
+		lock.Unlock()
+		switch {
+		case r.URL.Path == "/bad":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		case r.URL.Path == "/all" && count <= 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		call := webhookCall{
+			path:      r.URL.Path,
+			signature: r.Header.Get(Common.HeaderWebhookSignature),
+			delivery:  r.Header.Get(Common.HeaderWebhookDelivery),
+		}
+		call.body, _ = io.ReadAll(r.Body)
+		json.Unmarshal(call.body, &call.payload)
+		calls <- call
+	}))
+	defer srv.Close()
+	hooks := map[string]map[string]interface{}{
+		"all":   {"url": srv.URL + "/all", "dataType": "machine", "secret": "s3cret"},
+		"state": {"url": srv.URL + "/state", "path": "state"},
+		"bad":   {"url": srv.URL + "/bad", "dataType": "machine"},
+		"rack":  {"url": srv.URL + "/rack", "dataType": "rack"},
+	}
+	for name, data := range hooks {
+		_, e := Process.SetWebhook(handler, name, data)
+		if e != nil {
+			t.Fatalf("failed to add webhook [%s]. Error:%s", name, e)
+		}
+	}
+	// secret is redacted on read, and kept when set back
+	record, e := Process.GetWebhook(handler, "all")
+	if e != nil || record.Data["secret"] != Process.SecretRedacted {
+		t.Fatalf("secret of webhook should be redacted, got %v. Error:%v", record, e)
+	}
+	_, e = Process.SetWebhook(handler, "all", record.Data)
+	if e != nil {
+		t.Fatalf("failed to set webhook with redacted secret. Error:%s", e)
+	}
+	_, e = Process.SetWebhook(handler, "state", map[string]interface{}{"url": srv.URL + "/state", "path": "state", "secret": Process.SecretRedacted})
+	if e == nil || e.Status != http.StatusBadRequest {
+		t.Fatalf("redacted secret should not be set on webhook without secret")
+	}
+	process, err := Process.NewWebhookProcess(handler, nil)
+	if err != nil {
+		t.Fatalf("failed to create webhook process. Error:%s", err)
+	}
+	webhook := process.(*Process.WebhookChanges)
+	defer webhook.Stop()
+	webhook.RetryInterval = 10 * time.Millisecond
+	webhook.MaxInterval = 20 * time.Millisecond
+	for dataType, expect := range map[string]bool{
+		"machine":         true,
+		"server":          true,
+		Common.KeyWebhook: false,
+		Common.KeyJournal: false,
+	} {
+		can, err := process.HandleType(dataType, "0.0.1")
+		if err != nil || can != expect {
+			t.Fatalf("HandleType of [%s]=[%t], expect [%t]. Error:%v", dataType, can, expect, err)
+		}
+	}
+	entries := []*ProcessIface.JournalEntry{
+		{Page: 1, Idx: 1, Time: "t1", Before: machineData("m1", "ready", 8), After: machineData("m1", "ready", 16)},
+		{Page: 1, Idx: 2, Time: "t2", Before: machineData("m1", "ready", 16), After: machineData("m1", "busy", 16)},
+	}
+	for _, entry := range entries {
+		e := process.ProcessEntry("machine", "m1", entry)
+		if e != nil {
+			t.Fatalf("failed to queue entry [%d]. Error:%s", entry.Idx, e)
+		}
+	}
+	received := map[string][]webhookCall{}
+	for i := 0; i < 3; i++ {
+		select {
+		case call := <-calls:
+			received[call.path] = append(received[call.path], call)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got [%d] deliveries, expect 3", i)
+		}
+	}
+	if len(received["/all"]) != 2 || received["/all"][0].payload.Idx != 1 || received["/all"][1].payload.Idx != 2 {
+		t.Fatalf("webhook [all] should receive both entries in order after retry")
+	}
+	for _, call := range received["/all"] {
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(call.body)
+		if call.signature != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Fatalf("invalid signature [%s] of entry [%s]", call.signature, call.delivery)
+		}
+		if call.payload.Webhook != "all" || call.payload.DataId != "m1" || call.payload.Before == nil || call.payload.After == nil {
+			t.Fatalf("invalid payload of entry [%s]", call.delivery)
+		}
+	}
+	stateCalls := received["/state"]
+	if len(stateCalls) != 1 || stateCalls[0].delivery != "machine/m1/1-2" || stateCalls[0].signature != "" {
+		t.Fatalf("webhook [state] should only receive unsigned entry of state change")
+	}
+	// failed deliveries of webhook [bad] are not retried, wait for both entries
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		lock.Lock()
+		count := attempts["/bad"]
+		lock.Unlock()
+		if count >= 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	lock.Lock()
+	defer lock.Unlock()
+	if attempts["/all"] != 4 {
+		t.Fatalf("webhook [all] attempts=[%d], expect 4", attempts["/all"])
+	}
+	if attempts["/bad"] != 2 {
+		t.Fatalf("webhook [bad] attempts=[%d], expect no retry on status 400", attempts["/bad"])
+	}
+	if attempts["/rack"] != 0 {
+		t.Fatalf("webhook [rack] should not receive entry of machine")
+	}
+	e = process.ProcessEntry("server", "s1", &ProcessIface.JournalEntry{Page: 1, Idx: 1})
+	if e == nil || e.Status != http.StatusNotModified {
+		t.Fatalf("entry without subscribed webhook should return [%d]", http.StatusNotModified)
+	}
+}
+
+// deliveries stay in outbox after max attempts, and are delivered in order after restart
+func TestWebhookOutbox(t *testing.T) {
+	handler := newMachineHandler(t)
+	lock := sync.Mutex{}
+	up := false
+	attempts := 0
+	calls := make(chan webhookCall, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts++
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		call := webhookCall{delivery: r.Header.Get(Common.HeaderWebhookDelivery)}
+		call.body, _ = io.ReadAll(r.Body)
+		json.Unmarshal(call.body, &call.payload)
+		calls <- call
+	}))
+	defer srv.Close()
+	_, e := Process.SetWebhook(handler, "down", map[string]interface{}{"url": srv.URL, "dataType": "machine"})
+	if e != nil {
+		t.Fatalf("failed to add webhook. Error:%s", e)
+	}
+	process, err := Process.NewWebhookProcess(handler, nil)
+	if err != nil {
+		t.Fatalf("failed to create webhook process. Error:%s", err)
+	}
+	webhook := process.(*Process.WebhookChanges)
+	webhook.MaxAttempts = 1
+	webhook.MaxInterval = time.Minute
+	entries := []*ProcessIface.JournalEntry{
+		{Page: 1, Idx: 1, Seq: 1, Time: "t1", Before: machineData("m1", "ready", 8), After: machineData("m1", "ready", 16)},
+		{Page: 1, Idx: 2, Seq: 2, Time: "t2", Before: machineData("m1", "ready", 16), After: machineData("m1", "busy", 16)},
+	}
+	for _, entry := range entries {
+		e = process.ProcessEntry("machine", "m1", entry)
+		if e != nil {
+			t.Fatalf("failed to save delivery of entry [%d]. Error:%s", entry.Idx, e)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		lock.Lock()
+		count := attempts
+		lock.Unlock()
+		if count > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	pending, e := handler.QueryDb(Common.KeyWebhookDelivery, "", nil)
+	if e != nil || len(pending) != 2 {
+		t.Fatalf("expect 2 deliveries kept in outbox, got %d. Error:%v", len(pending), e)
+	}
+	// dispatcher waiting to retry exit on stop
+	webhook.Stop()
+	lock.Lock()
+	up = true
+	lock.Unlock()
+	// process created on restart resume deliveries in outbox
+	process, err = Process.NewWebhookProcess(handler, nil)
+	if err != nil {
+		t.Fatalf("failed to create webhook process. Error:%s", err)
+	}
+	webhook = process.(*Process.WebhookChanges)
+	defer webhook.Stop()
+	for _, entry := range entries {
+		select {
+		case call := <-calls:
+			if call.payload.Idx != entry.Idx || call.delivery != fmt.Sprintf("machine/m1/1-%d", entry.Idx) {
+				t.Fatalf("expect entry [%d] delivered in order, got [%s]", entry.Idx, call.delivery)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("entry [%d] is not delivered after restart", entry.Idx)
+		}
+	}
+	if !waitOutboxEmpty(handler) {
+		t.Fatalf("deliveries should be removed from outbox after delivered")
+	}
+	// deliveries left of deleted webhook are dropped by its dispatcher
+	lock.Lock()
+	up = false
+	lock.Unlock()
+	webhook.MaxAttempts = 1
+	webhook.MaxInterval = time.Minute
+	e = process.ProcessEntry("machine", "m1", &ProcessIface.JournalEntry{Page: 1, Idx: 3, Seq: 3, Time: "t3", After: machineData("m1", "ready", 16)})
+	if e != nil {
+		t.Fatalf("failed to save delivery of entry [3]. Error:%s", e)
+	}
+	e = Process.DeleteWebhook(handler, "down")
+	if e != nil {
+		t.Fatalf("failed to delete webhook. Error:%s", e)
+	}
+	if !waitOutboxEmpty(handler) {
+		t.Fatalf("deliveries of deleted webhook should be dropped")
+	}
+	can, _ := process.HandleType("machine", "0.0.1")
+	if can {
+		t.Fatalf("deleted webhook should be removed from webhooks of process")
+	}
+}
+
+func waitOutboxEmpty(handler *DataHandler.Handler) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		pending, e := handler.QueryDb(Common.KeyWebhookDelivery, "", nil)
+		if e == nil && len(pending) == 0 {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
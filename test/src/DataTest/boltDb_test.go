@@ -0,0 +1,80 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataTest
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"Data"
+	"Data/BoltDb"
+	"Data/DbConfig"
+	"Data/DbIface"
+)
+
+func connectBoltDb(t *testing.T) DbIface.Database {
+	configStr := `{
+		"type": "boltdb",
+		"boltdb": {}
+	}`
+	config := DbConfig.DatabaseConfig{}
+	err := json.Unmarshal([]byte(configStr), &config)
+	if err != nil {
+		t.Fatalf("failed to load config, Error:%s", err)
+	}
+	err = config.SetSection(BoltDb.Name, DbConfig.BoltDbConfig{
+		Path: filepath.Join(t.TempDir(), "test.db"),
+	})
+	if err != nil {
+		t.Fatalf("failed to set config, Error:%s", err)
+	}
+	db, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to connect boltdb, Error:%s", err)
+	}
+	return db
+}
+
+func TestBoltDbOps(t *testing.T) {
+	testDatabaseOps(t, connectBoltDb(t))
+}
+
+func TestBoltDbCommit(t *testing.T) {
+	testDatabaseCommit(t, connectBoltDb(t))
+}
+
+func TestBoltDbFilter(t *testing.T) {
+	testDatabaseFilter(t, connectBoltDb(t))
+}
+
+func TestBoltDbPage(t *testing.T) {
+	testDatabasePage(t, connectBoltDb(t))
+}
+
+func TestBoltDbRevision(t *testing.T) {
+	testDatabaseRevision(t, connectBoltDb(t))
+}
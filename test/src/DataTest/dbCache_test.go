@@ -0,0 +1,119 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataTest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"Data"
+	"Data/DbCache"
+	"Data/DbConfig"
+	"Data/DbIface"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+func connectCache(t *testing.T, size int) *DbCache.Database {
+	config := DbConfig.DatabaseConfig{}
+	err := json.Unmarshal([]byte(`{"type": "inmemory"}`), &config)
+	if err != nil {
+		t.Fatalf("failed to load config, Error:%s", err)
+	}
+	db, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to connect inmemory, Error:%s", err)
+	}
+	cache, err := DbCache.New(db, DbConfig.CacheConfig{Size: size}, nil)
+	if err != nil {
+		t.Fatalf("failed to create cache, Error:%s", err)
+	}
+	return cache
+}
+
+func TestDbCacheOps(t *testing.T) {
+	testDatabaseOps(t, connectCache(t, 10))
+}
+
+func TestDbCacheCommit(t *testing.T) {
+	testDatabaseCommit(t, connectCache(t, 10))
+}
+
+func TestDbCacheRevision(t *testing.T) {
+	testDatabaseRevision(t, connectCache(t, 10))
+}
+
+func TestDbCacheStats(t *testing.T) {
+	db := connectCache(t, 2)
+	err := db.CreateTable(testTable, nil)
+	if err != nil {
+		t.Fatalf("failed to create table, Error:%s", err)
+	}
+	for _, dataId := range []string{"test01", "test02", "test03"} {
+		err = db.Create(testTable, newTestRecord(dataId, "v1"))
+		if err != nil {
+			t.Fatalf("failed to create record [%s], Error:%s", dataId, err)
+		}
+	}
+	record := getTestRecord(t, db, "test01")
+	// change on returned record should not leak into cache
+	record[Record.Data].(map[string]interface{})["attr01"] = "changed"
+	record = getTestRecord(t, db, "test01")
+	if record[Record.Data].(map[string]interface{})["attr01"] != "v1" {
+		t.Fatalf("cached record changed by caller, %v", record)
+	}
+	stats := db.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Size != 1 {
+		t.Fatalf("invalid stats after first lookup, %v", stats)
+	}
+	keys := map[string]interface{}{
+		Record.DataType: testType,
+		Record.DataId:   "test01",
+	}
+	err = db.Replace(testTable, keys, newTestRecord("test01", "v2"))
+	if err != nil {
+		t.Fatalf("failed to replace record, Error:%s", err)
+	}
+	record = getTestRecord(t, db, "test01")
+	if record[Record.Data].(map[string]interface{})["attr01"] != "v2" {
+		t.Fatalf("stale record after replace, %v", record)
+	}
+	batch := DbIface.NewBatch()
+	batch.Delete(testTable, keys)
+	err = db.Commit(batch)
+	if err != nil {
+		t.Fatalf("failed to commit batch, Error:%s", err)
+	}
+	if getTestRecord(t, db, "test01") != nil {
+		t.Fatalf("stale record after delete in batch")
+	}
+	getTestRecord(t, db, "test02")
+	getTestRecord(t, db, "test03")
+	stats = db.Stats()
+	if stats.Size != 2 || stats.Evictions != 1 || stats.Hits != 1 || stats.Misses != 5 {
+		t.Fatalf("invalid stats after eviction, %v", stats)
+	}
+}
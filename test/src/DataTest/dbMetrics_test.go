@@ -0,0 +1,108 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataTest
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"Data"
+	"Data/DbConfig"
+	"Data/DbIface"
+	"Data/DbMetrics"
+)
+
+func connectMetrics(t *testing.T) *DbMetrics.Database {
+	config := DbConfig.DatabaseConfig{}
+	err := json.Unmarshal([]byte(`{"type": "inmemory"}`), &config)
+	if err != nil {
+		t.Fatalf("failed to load config, Error:%s", err)
+	}
+	db, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to connect inmemory, Error:%s", err)
+	}
+	metrics, err := DbMetrics.New(db, nil)
+	if err != nil {
+		t.Fatalf("failed to instrument database, Error:%s", err)
+	}
+	return metrics
+}
+
+func TestDbMetricsOps(t *testing.T) {
+	testDatabaseOps(t, connectMetrics(t))
+}
+
+func TestDbMetricsStats(t *testing.T) {
+	db := connectMetrics(t)
+	err := db.CreateTable(testTable, nil)
+	if err != nil {
+		t.Fatalf("failed to create table, Error:%s", err)
+	}
+	batch := DbIface.NewBatch()
+	batch.Create(testTable, newTestRecord("test01", "v1"))
+	batch.Create(testTable, newTestRecord("test02", "v1"))
+	err = db.Commit(batch)
+	if err != nil {
+		t.Fatalf("failed to commit batch, Error:%s", err)
+	}
+	getTestRecord(t, db, "test01")
+	getTestRecord(t, db, "test03")
+	_, err = db.Get(map[string]interface{}{})
+	if err == nil {
+		t.Fatalf("get without table should fail")
+	}
+	statsMap := map[string]DbMetrics.Stats{}
+	for _, stats := range db.Stats() {
+		statsMap[stats.Method+"/"+stats.Table] = stats
+	}
+	stats := statsMap[DbMetrics.MethodGet+"/"+testTable]
+	if stats.Count != 2 || stats.Errors != 0 || stats.Records != 1 {
+		t.Fatalf("invalid stats of Get, %v", stats)
+	}
+	stats = statsMap[DbMetrics.MethodGet+"/"]
+	if stats.Count != 1 || stats.Errors != 1 {
+		t.Fatalf("invalid stats of failed Get, %v", stats)
+	}
+	stats = statsMap[DbMetrics.MethodCommit+"/"+testTable]
+	if stats.Count != 1 || stats.Records != 2 {
+		t.Fatalf("invalid stats of Commit, %v", stats)
+	}
+	buf := bytes.Buffer{}
+	db.WriteText(&buf)
+	text := buf.String()
+	for _, line := range []string{
+		`unitao_db_call_duration_seconds_count{method="Get",table="data"} 2`,
+		`unitao_db_call_errors_total{method="Get",table=""} 1`,
+		`unitao_db_result_records_bucket{method="Commit",table="data",le="10"} 1`,
+	} {
+		if !strings.Contains(text, line) {
+			t.Fatalf("missing line [%s] in metrics:\n%s", line, text)
+		}
+	}
+}
@@ -0,0 +1,417 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataTest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"Data/DbIface"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+const (
+	testTable = "data"
+	testType  = "testData"
+)
+
+func newTestRecord(dataId string, value string) map[string]interface{} {
+	return map[string]interface{}{
+		Record.DataId:   dataId,
+		Record.DataType: testType,
+		Record.Version:  "0.0.1",
+		Record.Data: map[string]interface{}{
+			"attr01": value,
+			"map01":  map[string]interface{}{},
+		},
+	}
+}
+
+func getTestRecord(t *testing.T, db DbIface.Database, dataId string) map[string]interface{} {
+	recordList, err := db.Get(map[string]interface{}{
+		DbIface.Table:   testTable,
+		Record.DataType: testType,
+		Record.DataId:   dataId,
+	})
+	if err != nil {
+		t.Fatalf("failed to get record [%s], Error:%s", dataId, err)
+	}
+	if len(recordList) == 0 {
+		return nil
+	}
+	return recordList[0]
+}
+
+func testDatabaseOps(t *testing.T, db DbIface.Database) {
+	err := db.CreateTable(testTable, nil)
+	if err != nil {
+		t.Fatalf("failed to create table, Error:%s", err)
+	}
+	tableList, err := db.ListTable()
+	if err != nil {
+		t.Fatalf("failed to list table, Error:%s", err)
+	}
+	if len(tableList) != 1 || tableList[0] != testTable {
+		t.Fatalf("invalid table list %v", tableList)
+	}
+	err = db.Create(testTable, newTestRecord("test01", "v1"))
+	if err != nil {
+		t.Fatalf("failed to create record, Error:%s", err)
+	}
+	err = db.Create(testTable, newTestRecord("test01", "v1"))
+	if err == nil {
+		t.Fatalf("failed to block duplicate create")
+	}
+	err = db.Create(testTable, newTestRecord("test02", "v2"))
+	if err != nil {
+		t.Fatalf("failed to create record, Error:%s", err)
+	}
+	recordList, err := db.Get(map[string]interface{}{
+		DbIface.Table:   testTable,
+		Record.DataType: testType,
+	})
+	if err != nil {
+		t.Fatalf("failed to list records, Error:%s", err)
+	}
+	if len(recordList) != 2 {
+		t.Fatalf("expect 2 records, got %d", len(recordList))
+	}
+	// query without type, the way InventoryService does
+	recordList, err = db.Get(map[string]interface{}{
+		DbIface.Table: testTable,
+		Record.DataId: "test02",
+	})
+	if err != nil || len(recordList) != 1 {
+		t.Fatalf("failed to get record by id only, Error:%v", err)
+	}
+	patched, err := db.Update(testTable, map[string]interface{}{
+		Record.DataType:   testType,
+		Record.DataId:     "test01",
+		DbIface.PatchPath: "data/map01[key01]",
+	}, "value01")
+	if err != nil {
+		t.Fatalf("failed to update record, Error:%s", err)
+	}
+	if patched[Record.Data].(map[string]interface{})["map01"].(map[string]interface{})["key01"] != "value01" {
+		t.Fatalf("patched record not returned")
+	}
+	if DbIface.RecordRevision(patched) != 1 {
+		t.Fatalf("expect revision 1 after update, got %d", DbIface.RecordRevision(patched))
+	}
+	_, err = db.Update(testTable, map[string]interface{}{
+		Record.DataType:   testType,
+		Record.DataId:     "test01",
+		Record.Revision:   0,
+		DbIface.PatchPath: "data/map01[key01]",
+	}, "value02")
+	if !errors.Is(err, DbIface.ErrRevisionConflict) {
+		t.Fatalf("failed to reject update on stale revision, Error:%v", err)
+	}
+	record := getTestRecord(t, db, "test01")
+	if record[Record.Data].(map[string]interface{})["map01"].(map[string]interface{})["key01"] != "value01" {
+		t.Fatalf("failed to persist patched record")
+	}
+	// replace with a different id, the way schema archive works
+	err = db.Replace(testTable, map[string]interface{}{
+		Record.DataType: testType,
+		Record.DataId:   "test02",
+	}, newTestRecord("test02_archived", "v2"))
+	if err != nil {
+		t.Fatalf("failed to replace record, Error:%s", err)
+	}
+	if getTestRecord(t, db, "test02") != nil {
+		t.Fatalf("replaced record [test02] still exists")
+	}
+	if getTestRecord(t, db, "test02_archived") == nil {
+		t.Fatalf("failed to get replaced record [test02_archived]")
+	}
+	err = db.Delete(testTable, map[string]interface{}{
+		Record.DataType: testType,
+		Record.DataId:   "test01",
+	})
+	if err != nil {
+		t.Fatalf("failed to delete record, Error:%s", err)
+	}
+	if getTestRecord(t, db, "test01") != nil {
+		t.Fatalf("deleted record [test01] still exists")
+	}
+	err = db.DeleteTable(testTable)
+	if err != nil {
+		t.Fatalf("failed to delete table, Error:%s", err)
+	}
+	tableList, err = db.ListTable()
+	if err != nil || len(tableList) != 0 {
+		t.Fatalf("failed to delete table, tables=%v, Error:%v", tableList, err)
+	}
+}
+
+func testDatabaseCommit(t *testing.T, db DbIface.Database) {
+	err := db.CreateTable(testTable, nil)
+	if err != nil {
+		t.Fatalf("failed to create table, Error:%s", err)
+	}
+	err = db.Create(testTable, newTestRecord("test01", "v1"))
+	if err != nil {
+		t.Fatalf("failed to create record, Error:%s", err)
+	}
+	keys01 := map[string]interface{}{
+		Record.DataType: testType,
+		Record.DataId:   "test01",
+	}
+	batch := DbIface.NewBatch()
+	batch.Create(testTable, newTestRecord("test02", "v2"))
+	batch.Replace(testTable, keys01, newTestRecord("test01", "v1.1"))
+	err = db.Commit(batch)
+	if err != nil {
+		t.Fatalf("failed to commit batch, Error:%s", err)
+	}
+	if getTestRecord(t, db, "test02") == nil {
+		t.Fatalf("record [test02] not created by batch")
+	}
+	if getTestRecord(t, db, "test01")[Record.Data].(map[string]interface{})["attr01"] != "v1.1" {
+		t.Fatalf("record [test01] not replaced by batch")
+	}
+	// second create of test02 fails, all other operations should be rolled back
+	batch = DbIface.NewBatch()
+	batch.Delete(testTable, keys01)
+	batch.Create(testTable, newTestRecord("test03", "v3"))
+	batch.Create(testTable, newTestRecord("test02", "v2"))
+	err = db.Commit(batch)
+	if err == nil {
+		t.Fatalf("failed to reject batch with duplicate create")
+	}
+	if getTestRecord(t, db, "test01") == nil {
+		t.Fatalf("delete of [test01] not rolled back")
+	}
+	if getTestRecord(t, db, "test03") != nil {
+		t.Fatalf("create of [test03] not rolled back")
+	}
+	batch = DbIface.NewBatch()
+	batch.Delete(testTable, keys01)
+	batch.Replace(testTable, map[string]interface{}{
+		Record.DataType: testType,
+		Record.DataId:   "test02",
+	}, newTestRecord("test02_archived", "v2"))
+	err = db.Commit(batch)
+	if err != nil {
+		t.Fatalf("failed to commit batch, Error:%s", err)
+	}
+	recordList, err := db.Get(map[string]interface{}{
+		DbIface.Table:   testTable,
+		Record.DataType: testType,
+	})
+	if err != nil {
+		t.Fatalf("failed to list records, Error:%s", err)
+	}
+	if len(recordList) != 1 || recordList[0][Record.DataId] != "test02_archived" {
+		t.Fatalf("invalid records after batch, %v", recordList)
+	}
+}
+
+func newFilterRecord(dataId string, rack string, cpu float64) map[string]interface{} {
+	return map[string]interface{}{
+		Record.DataId:   dataId,
+		Record.DataType: testType,
+		Record.Version:  "0.0.1",
+		Record.Data: map[string]interface{}{
+			"cpu": cpu,
+			"location": map[string]interface{}{
+				"rack": rack,
+			},
+		},
+	}
+}
+
+func testDatabaseFilter(t *testing.T, db DbIface.Database) {
+	err := db.CreateTable(testTable, nil)
+	if err != nil {
+		t.Fatalf("failed to create table, Error:%s", err)
+	}
+	batch := DbIface.NewBatch()
+	batch.Create(testTable, newFilterRecord("srv01", "r01", 4))
+	batch.Create(testTable, newFilterRecord("srv02", "r01", 8))
+	batch.Create(testTable, newFilterRecord("srv03", "r02", 16))
+	batch.Create(testTable, newFilterRecord("srv04", "r03", 32))
+	err = db.Commit(batch)
+	if err != nil {
+		t.Fatalf("failed to create records, Error:%s", err)
+	}
+	testCases := map[string]struct {
+		filter *DbIface.FilterExpr
+		count  int
+	}{
+		"eq":     {DbIface.Eq("location/rack", "r01"), 2},
+		"ne":     {DbIface.Ne("location/rack", "r01"), 2},
+		"gte":    {DbIface.Gte("cpu", 8), 3},
+		"range":  {DbIface.And(DbIface.Gt("cpu", 4), DbIface.Lte("cpu", 16)), 2},
+		"in":     {DbIface.In("location/rack", "r02", "r03"), 2},
+		"and":    {DbIface.And(DbIface.Eq("location/rack", "r01"), DbIface.Lt("cpu", 8)), 1},
+		"noAttr": {DbIface.Eq("location/row", "r01"), 0},
+	}
+	for name, testCase := range testCases {
+		recordList, err := db.Get(map[string]interface{}{
+			DbIface.Table:   testTable,
+			Record.DataType: testType,
+			DbIface.Filter:  testCase.filter,
+		})
+		if err != nil {
+			t.Fatalf("[%s]: failed to query with filter, Error:%s", name, err)
+		}
+		if len(recordList) != testCase.count {
+			t.Fatalf("[%s]: expect %d records, got %d", name, testCase.count, len(recordList))
+		}
+	}
+	_, err = db.Get(map[string]interface{}{
+		DbIface.Table:   testTable,
+		Record.DataType: testType,
+		DbIface.Filter:  &DbIface.FilterExpr{Op: "like", Path: "cpu"},
+	})
+	if err == nil {
+		t.Fatalf("failed to reject unknown filter op")
+	}
+}
+
+func testDatabasePage(t *testing.T, db DbIface.Database) {
+	err := db.CreateTable(testTable, nil)
+	if err != nil {
+		t.Fatalf("failed to create table, Error:%s", err)
+	}
+	batch := DbIface.NewBatch()
+	for idx := 1; idx <= 7; idx++ {
+		batch.Create(testTable, newFilterRecord(fmt.Sprintf("srv%02d", idx), "r01", float64(idx)))
+	}
+	err = db.Commit(batch)
+	if err != nil {
+		t.Fatalf("failed to create records, Error:%s", err)
+	}
+	queryArgs := map[string]interface{}{
+		DbIface.Table:   testTable,
+		Record.DataType: testType,
+	}
+	idList := []string{}
+	pageToken := ""
+	for pageCount := 1; ; pageCount++ {
+		if pageCount > 4 {
+			t.Fatalf("too many pages, token=[%s]", pageToken)
+		}
+		recordList, nextToken, err := db.GetPage(queryArgs, 3, pageToken)
+		if err != nil {
+			t.Fatalf("failed to get page %d, Error:%s", pageCount, err)
+		}
+		if len(recordList) > 3 {
+			t.Fatalf("page %d has %d records, expect at most 3", pageCount, len(recordList))
+		}
+		for _, record := range recordList {
+			idList = append(idList, record[Record.DataId].(string))
+		}
+		if nextToken == "" {
+			break
+		}
+		pageToken = nextToken
+	}
+	if len(idList) != 7 || idList[0] != "srv01" || idList[6] != "srv07" {
+		t.Fatalf("invalid paged ids %v", idList)
+	}
+	queryArgs[DbIface.Filter] = DbIface.Gt("cpu", 2)
+	recordList, nextToken, err := db.GetPage(queryArgs, 3, "")
+	if err != nil {
+		t.Fatalf("failed to get filtered page, Error:%s", err)
+	}
+	if len(recordList) != 3 || recordList[0][Record.DataId] != "srv03" || nextToken == "" {
+		t.Fatalf("invalid filtered page, count=%d, token=[%s]", len(recordList), nextToken)
+	}
+	recordList, nextToken, err = db.GetPage(queryArgs, 3, nextToken)
+	if err != nil {
+		t.Fatalf("failed to get filtered page 2, Error:%s", err)
+	}
+	if len(recordList) != 2 || recordList[1][Record.DataId] != "srv07" || nextToken != "" {
+		t.Fatalf("invalid filtered page 2, count=%d, token=[%s]", len(recordList), nextToken)
+	}
+	_, _, err = db.GetPage(queryArgs, 5, "not a token")
+	if !errors.Is(err, DbIface.ErrInvalidPageToken) {
+		t.Fatalf("failed to reject invalid page token, Error:%v", err)
+	}
+}
+
+func testDatabaseRevision(t *testing.T, db DbIface.Database) {
+	err := db.CreateTable(testTable, nil)
+	if err != nil {
+		t.Fatalf("failed to create table, Error:%s", err)
+	}
+	record := newTestRecord("test01", "v1")
+	record[Record.Revision] = 1
+	err = db.Create(testTable, record)
+	if err != nil {
+		t.Fatalf("failed to create record, Error:%s", err)
+	}
+	keys := map[string]interface{}{
+		Record.DataType: testType,
+		Record.DataId:   "test01",
+		Record.Revision: 1,
+	}
+	record = newTestRecord("test01", "v2")
+	record[Record.Revision] = 2
+	err = db.Replace(testTable, keys, record)
+	if err != nil {
+		t.Fatalf("failed to replace record on current revision, Error:%s", err)
+	}
+	// second writer read revision 1 too
+	record = newTestRecord("test01", "v3")
+	record[Record.Revision] = 2
+	err = db.Replace(testTable, keys, record)
+	if !errors.Is(err, DbIface.ErrRevisionConflict) {
+		t.Fatalf("failed to reject replace on stale revision, Error:%v", err)
+	}
+	batch := DbIface.NewBatch()
+	batch.Create(testTable, newTestRecord("test02", "v1"))
+	batch.Replace(testTable, keys, record)
+	err = db.Commit(batch)
+	if !errors.Is(err, DbIface.ErrRevisionConflict) {
+		t.Fatalf("failed to reject batch on stale revision, Error:%v", err)
+	}
+	if getTestRecord(t, db, "test02") != nil {
+		t.Fatalf("record [test02] created by failed batch")
+	}
+	current := getTestRecord(t, db, "test01")
+	if DbIface.RecordRevision(current) != 2 || current[Record.Data].(map[string]interface{})["attr01"] != "v2" {
+		t.Fatalf("invalid record after conflict %v", current)
+	}
+	err = db.Delete(testTable, keys)
+	if !errors.Is(err, DbIface.ErrRevisionConflict) {
+		t.Fatalf("failed to reject delete on stale revision, Error:%v", err)
+	}
+	keys[Record.Revision] = 2
+	err = db.Delete(testTable, keys)
+	if err != nil {
+		t.Fatalf("failed to delete record on current revision, Error:%s", err)
+	}
+	err = db.Replace(testTable, keys, record)
+	if !errors.Is(err, DbIface.ErrRevisionConflict) {
+		t.Fatalf("failed to reject replace of deleted record, Error:%v", err)
+	}
+}
@@ -0,0 +1,250 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataTest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Data"
+	"Data/DbConfig"
+	"Data/DbIface"
+	"Data/InMemory"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+func TestInMemoryOps(t *testing.T) {
+	config := DbConfig.DatabaseConfig{}
+	err := json.Unmarshal([]byte(`{"type": "inmemory"}`), &config)
+	if err != nil {
+		t.Fatalf("failed to load config, Error:%s", err)
+	}
+	db, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to connect inmemory, Error:%s", err)
+	}
+	testDatabaseOps(t, db)
+}
+
+func TestInMemoryCommit(t *testing.T) {
+	config := DbConfig.DatabaseConfig{}
+	err := json.Unmarshal([]byte(`{"type": "inmemory"}`), &config)
+	if err != nil {
+		t.Fatalf("failed to load config, Error:%s", err)
+	}
+	db, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to connect inmemory, Error:%s", err)
+	}
+	testDatabaseCommit(t, db)
+}
+
+func TestInMemoryFilter(t *testing.T) {
+	config := DbConfig.DatabaseConfig{}
+	err := json.Unmarshal([]byte(`{"type": "inmemory"}`), &config)
+	if err != nil {
+		t.Fatalf("failed to load config, Error:%s", err)
+	}
+	db, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to connect inmemory, Error:%s", err)
+	}
+	testDatabaseFilter(t, db)
+}
+
+func TestInMemoryPage(t *testing.T) {
+	config := DbConfig.DatabaseConfig{}
+	err := json.Unmarshal([]byte(`{"type": "inmemory"}`), &config)
+	if err != nil {
+		t.Fatalf("failed to load config, Error:%s", err)
+	}
+	db, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to connect inmemory, Error:%s", err)
+	}
+	testDatabasePage(t, db)
+}
+
+func TestInMemoryRevision(t *testing.T) {
+	config := DbConfig.DatabaseConfig{}
+	err := json.Unmarshal([]byte(`{"type": "inmemory"}`), &config)
+	if err != nil {
+		t.Fatalf("failed to load config, Error:%s", err)
+	}
+	db, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to connect inmemory, Error:%s", err)
+	}
+	testDatabaseRevision(t, db)
+}
+
+func TestInMemorySnapshot(t *testing.T) {
+	config := DbConfig.DatabaseConfig{}
+	err := json.Unmarshal([]byte(`{"type": "inmemory"}`), &config)
+	if err != nil {
+		t.Fatalf("failed to load config, Error:%s", err)
+	}
+	err = config.SetSection(InMemory.Name, DbConfig.InMemoryConfig{
+		Snapshot: filepath.Join(t.TempDir(), "snapshot.json"),
+	})
+	if err != nil {
+		t.Fatalf("failed to set config, Error:%s", err)
+	}
+	db, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to connect inmemory, Error:%s", err)
+	}
+	err = db.CreateTable(testTable, nil)
+	if err != nil {
+		t.Fatalf("failed to create table, Error:%s", err)
+	}
+	err = db.Create(testTable, newTestRecord("test01", "v1"))
+	if err != nil {
+		t.Fatalf("failed to create record, Error:%s", err)
+	}
+	reloaded, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to reload inmemory from snapshot, Error:%s", err)
+	}
+	record := getTestRecord(t, reloaded, "test01")
+	if record == nil {
+		t.Fatalf("record [test01] not loaded from snapshot")
+	}
+	if record["data"].(map[string]interface{})["attr01"] != "v1" {
+		t.Fatalf("invalid record loaded from snapshot, %v", record)
+	}
+}
+
+func TestInMemoryLog(t *testing.T) {
+	config := DbConfig.DatabaseConfig{}
+	err := json.Unmarshal([]byte(`{"type": "inmemory"}`), &config)
+	if err != nil {
+		t.Fatalf("failed to load config, Error:%s", err)
+	}
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+	logPath := snapshotPath + ".log"
+	err = config.SetSection(InMemory.Name, DbConfig.InMemoryConfig{
+		Snapshot: snapshotPath,
+		LogSize:  4,
+	})
+	if err != nil {
+		t.Fatalf("failed to set config, Error:%s", err)
+	}
+	db, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to connect inmemory, Error:%s", err)
+	}
+	err = db.CreateTable(testTable, nil)
+	if err != nil {
+		t.Fatalf("failed to create table, Error:%s", err)
+	}
+	for _, dataId := range []string{"test01", "test02"} {
+		err = db.Create(testTable, newTestRecord(dataId, "v1"))
+		if err != nil {
+			t.Fatalf("failed to create record [%s], Error:%s", dataId, err)
+		}
+	}
+	// writes under LogSize only go to log
+	_, err = os.Stat(snapshotPath)
+	if !os.IsNotExist(err) {
+		t.Fatalf("snapshot should not be written before log is full, Error:%v", err)
+	}
+	err = db.Create(testTable, newTestRecord("test01", "v2"))
+	if err == nil {
+		t.Fatalf("failed to reject duplicate record")
+	}
+	_, err = db.Update(testTable, map[string]interface{}{
+		Record.DataType:   testType,
+		Record.DataId:     "test02",
+		DbIface.PatchPath: "data/map01[key01]",
+	}, "value01")
+	if err != nil {
+		t.Fatalf("failed to update record, Error:%s", err)
+	}
+	// rejected create is not logged, update is 4th write and compact log into snapshot
+	logInfo, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatalf("failed to stat log, Error:%s", err)
+	}
+	if logInfo.Size() != 0 {
+		t.Fatalf("log should be empty after compact, size=[%d]", logInfo.Size())
+	}
+	err = db.Delete(testTable, map[string]interface{}{
+		Record.DataType: testType,
+		Record.DataId:   "test01",
+	})
+	if err != nil {
+		t.Fatalf("failed to delete record, Error:%s", err)
+	}
+	batch := DbIface.NewBatch()
+	batch.Create(testTable, newTestRecord("test03", "v1"))
+	batch.Replace(testTable, map[string]interface{}{
+		Record.DataType: testType,
+		Record.DataId:   "test02",
+		Record.Revision: 1,
+	}, newTestRecord("test02", "v2"))
+	err = db.Commit(batch)
+	if err != nil {
+		t.Fatalf("failed to commit batch, Error:%s", err)
+	}
+	// write interrupted before it returned leave an incomplete line
+	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open log, Error:%s", err)
+	}
+	_, err = logFile.WriteString(`[{"Action":"delete","Table":"data"`)
+	logFile.Close()
+	if err != nil {
+		t.Fatalf("failed to write log, Error:%s", err)
+	}
+	reloaded, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to reload inmemory from log, Error:%s", err)
+	}
+	if getTestRecord(t, reloaded, "test01") != nil {
+		t.Fatalf("deleted record [test01] loaded from log")
+	}
+	for dataId, value := range map[string]string{"test02": "v2", "test03": "v1"} {
+		record := getTestRecord(t, reloaded, dataId)
+		if record == nil || record[Record.Data].(map[string]interface{})["attr01"] != value {
+			t.Fatalf("invalid record [%s] loaded from log, %v", dataId, record)
+		}
+	}
+	err = reloaded.Create(testTable, newTestRecord("test04", "v1"))
+	if err != nil {
+		t.Fatalf("failed to create record after reload, Error:%s", err)
+	}
+	reloaded, err = Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to reload inmemory again, Error:%s", err)
+	}
+	if getTestRecord(t, reloaded, "test04") == nil {
+		t.Fatalf("record [test04] written after incomplete line not loaded")
+	}
+}
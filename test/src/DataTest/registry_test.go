@@ -0,0 +1,89 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataTest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"testing"
+
+	"Data"
+	"Data/DbConfig"
+	"Data/DbIface"
+	"Data/InMemory"
+)
+
+type testBackendConfig struct {
+	Snapshot string `json:"snapshot"`
+	Tag      string `json:"tag"`
+}
+
+func TestRegisterBackend(t *testing.T) {
+	var decoded testBackendConfig
+	err := Data.Register("testBackend", func(raw json.RawMessage) (interface{}, error) {
+		config := testBackendConfig{}
+		err := json.Unmarshal(raw, &config)
+		return config, err
+	}, func(config interface{}, logger *log.Logger) (DbIface.Database, error) {
+		decoded = config.(testBackendConfig)
+		if decoded.Tag == "" {
+			return nil, fmt.Errorf("missing tag")
+		}
+		return InMemory.Connect(DbConfig.InMemoryConfig{Snapshot: decoded.Snapshot}, logger)
+	})
+	if err != nil {
+		t.Fatalf("failed to register backend, Error:%s", err)
+	}
+	err = Data.Register("testBackend", nil, nil)
+	if err == nil {
+		t.Fatalf("failed to block duplicate registration")
+	}
+	configStr := `{
+		"type": "testBackend",
+		"testBackend": {
+			"tag": "test01"
+		}
+	}`
+	config := DbConfig.DatabaseConfig{}
+	err = json.Unmarshal([]byte(configStr), &config)
+	if err != nil {
+		t.Fatalf("failed to load config, Error:%s", err)
+	}
+	db, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to connect to registered backend, Error:%s", err)
+	}
+	if decoded.Tag != "test01" {
+		t.Fatalf("raw config not passed to backend, tag=[%s]", decoded.Tag)
+	}
+	testDatabaseOps(t, db)
+	config.DbType = "unknownBackend"
+	_, err = Data.ConnectDb(config, nil)
+	if err == nil {
+		t.Fatalf("failed to reject unknown backend")
+	}
+}
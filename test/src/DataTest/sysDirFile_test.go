@@ -0,0 +1,235 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package DataTest
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"Data"
+	"Data/DbConfig"
+	"Data/DbIface"
+	"Data/SysDirFile"
+
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+func connectSysDirFile(t *testing.T, path string) DbIface.Database {
+	config := DbConfig.DatabaseConfig{}
+	err := json.Unmarshal([]byte(`{"type": "sysdirfile"}`), &config)
+	if err != nil {
+		t.Fatalf("failed to load config, Error:%s", err)
+	}
+	err = config.SetSection(SysDirFile.Name, DbConfig.SysDirFileConfig{
+		Path: path,
+	})
+	if err != nil {
+		t.Fatalf("failed to set config, Error:%s", err)
+	}
+	db, err := Data.ConnectDb(config, nil)
+	if err != nil {
+		t.Fatalf("failed to connect sysdirfile, Error:%s", err)
+	}
+	return db
+}
+
+func TestSysDirFileCommit(t *testing.T) {
+	testDatabaseCommit(t, connectSysDirFile(t, t.TempDir()))
+}
+
+func TestSysDirFileFilter(t *testing.T) {
+	testDatabaseFilter(t, connectSysDirFile(t, t.TempDir()))
+}
+
+func TestSysDirFilePage(t *testing.T) {
+	testDatabasePage(t, connectSysDirFile(t, t.TempDir()))
+}
+
+func TestSysDirFileRevision(t *testing.T) {
+	testDatabaseRevision(t, connectSysDirFile(t, t.TempDir()))
+}
+
+func TestSysDirFileReplayTxn(t *testing.T) {
+	rootPath := t.TempDir()
+	db := connectSysDirFile(t, rootPath)
+	err := db.CreateTable(testTable, nil)
+	if err != nil {
+		t.Fatalf("failed to create table, Error:%s", err)
+	}
+	// simulate crash after transaction log written, before steps applied
+	tablePath := filepath.Join(rootPath, testTable)
+	raw, _ := json.Marshal(newTestRecord("test01", "v1"))
+	stagedPath := filepath.Join(tablePath, ".test01.1.txn")
+	err = os.WriteFile(stagedPath, raw, 0644)
+	if err != nil {
+		t.Fatalf("failed to write staged file, Error:%s", err)
+	}
+	txnLog := []map[string]string{
+		{
+			"action": "rename",
+			"source": stagedPath,
+			"target": filepath.Join(tablePath, "test01"),
+		},
+	}
+	raw, _ = json.Marshal(txnLog)
+	err = os.WriteFile(filepath.Join(rootPath, SysDirFile.TxnLog), raw, 0644)
+	if err != nil {
+		t.Fatalf("failed to write transaction log, Error:%s", err)
+	}
+	// staged file of uncommitted transaction
+	err = os.WriteFile(filepath.Join(tablePath, ".test02.1.txn"), raw, 0644)
+	if err != nil {
+		t.Fatalf("failed to write staged file, Error:%s", err)
+	}
+	db = connectSysDirFile(t, rootPath)
+	if getTestRecord(t, db, "test01") == nil {
+		t.Fatalf("committed transaction not replayed")
+	}
+	if _, err := os.Stat(filepath.Join(rootPath, SysDirFile.TxnLog)); !os.IsNotExist(err) {
+		t.Fatalf("transaction log not removed after replay")
+	}
+	if _, err := os.Stat(filepath.Join(tablePath, ".test02.1.txn")); !os.IsNotExist(err) {
+		t.Fatalf("staged file of uncommitted transaction not cleaned")
+	}
+}
+
+func TestSysDirFileShared(t *testing.T) {
+	rootPath := t.TempDir()
+	writer := connectSysDirFile(t, rootPath)
+	reader := connectSysDirFile(t, rootPath)
+	err := writer.CreateTable(testTable, nil)
+	if err != nil {
+		t.Fatalf("failed to create table, Error:%s", err)
+	}
+	err = writer.Create(testTable, newTestRecord("test01", "v1"))
+	if err != nil {
+		t.Fatalf("failed to create record, Error:%s", err)
+	}
+	record := getTestRecord(t, reader, "test01")
+	if record == nil || record[Record.Data].(map[string]interface{})["attr01"] != "v1" {
+		t.Fatalf("record created by other connection not found, %v", record)
+	}
+	keys := map[string]interface{}{
+		Record.DataType: testType,
+		Record.DataId:   "test01",
+	}
+	// same size on purpose, cache should be invalidated by file change not by size
+	err = writer.Replace(testTable, keys, newTestRecord("test01", "v2"))
+	if err != nil {
+		t.Fatalf("failed to replace record, Error:%s", err)
+	}
+	record = getTestRecord(t, reader, "test01")
+	if record[Record.Data].(map[string]interface{})["attr01"] != "v2" {
+		t.Fatalf("stale record from cache, %v", record)
+	}
+	fileList, err := os.ReadDir(filepath.Join(rootPath, testTable))
+	if err != nil {
+		t.Fatalf("failed to list table, Error:%s", err)
+	}
+	if len(fileList) != 1 {
+		t.Fatalf("temp files left in table, count=[%d]", len(fileList))
+	}
+	err = writer.Delete(testTable, keys)
+	if err != nil {
+		t.Fatalf("failed to delete record, Error:%s", err)
+	}
+	if getTestRecord(t, reader, "test01") != nil {
+		t.Fatalf("record deleted by other connection still found")
+	}
+	tableList, err := reader.ListTable()
+	if err != nil {
+		t.Fatalf("failed to list table, Error:%s", err)
+	}
+	if len(tableList) != 1 {
+		t.Fatalf("invalid table list %v", tableList)
+	}
+}
+
+func TestSysDirFileConcurrentWrite(t *testing.T) {
+	rootPath := t.TempDir()
+	dbList := []DbIface.Database{
+		connectSysDirFile(t, rootPath),
+		connectSysDirFile(t, rootPath),
+	}
+	err := dbList[0].CreateTable(testTable, nil)
+	if err != nil {
+		t.Fatalf("failed to create table, Error:%s", err)
+	}
+	record := newTestRecord("test01", "v1")
+	record[Record.Revision] = 1
+	err = dbList[0].Create(testTable, record)
+	if err != nil {
+		t.Fatalf("failed to create record, Error:%s", err)
+	}
+	// every writer bumps the revision with compare-and-swap, no bump should be lost
+	writers := 10
+	errList := make(chan error, writers)
+	wg := sync.WaitGroup{}
+	for idx := 0; idx < writers; idx++ {
+		wg.Add(1)
+		go func(db DbIface.Database) {
+			defer wg.Done()
+			for {
+				recordList, err := db.Get(map[string]interface{}{
+					DbIface.Table: testTable,
+					Record.DataId: "test01",
+				})
+				if err != nil {
+					errList <- err
+					return
+				}
+				revision := DbIface.RecordRevision(recordList[0])
+				record := newTestRecord("test01", "v1")
+				record[Record.Revision] = revision + 1
+				err = db.Replace(testTable, map[string]interface{}{
+					Record.DataType: testType,
+					Record.DataId:   "test01",
+					Record.Revision: revision,
+				}, record)
+				if errors.Is(err, DbIface.ErrRevisionConflict) {
+					continue
+				}
+				if err != nil {
+					errList <- err
+				}
+				return
+			}
+		}(dbList[idx%len(dbList)])
+	}
+	wg.Wait()
+	close(errList)
+	for err := range errList {
+		t.Fatalf("failed to write record, Error:%s", err)
+	}
+	record = getTestRecord(t, dbList[1], "test01")
+	if DbIface.RecordRevision(record) != int64(1+writers) {
+		t.Fatalf("lost update, [revision]=[%d]", DbIface.RecordRevision(record))
+	}
+}
@@ -0,0 +1,262 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package SchemaTest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/salesforce/UniTAO/lib/Schema"
+	"github.com/salesforce/UniTAO/lib/Schema/Record"
+)
+
+const docPatchSchema = `{
+	"name": "testRoot",
+	"version": "0.0.1",
+	"properties": {
+		"attr1": {
+			"type": "string"
+		},
+		"attr2": {
+			"type": "array",
+			"items": {
+				"type": "string"
+			}
+		},
+		"attr3": {
+			"type": "map",
+			"items": {
+				"type": "object",
+				"$ref": "#/definitions/item"
+			}
+		},
+		"attr4": {
+			"type": "object",
+			"$ref": "#/definitions/item",
+			"required": false
+		}
+	},
+	"definitions": {
+		"item": {
+			"name": "item",
+			"key": "{name}",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"value": {
+					"type": "string",
+					"required": false
+				}
+			}
+		}
+	}
+}`
+
+const docPatchRecord = `{
+	"__id": "test01",
+	"__type": "testRoot",
+	"__ver": "0.0.1",
+	"data": {
+		"attr1": "test",
+		"attr2": ["a", "b"],
+		"attr3": {
+			"k1": {
+				"name": "k1",
+				"value": "test"
+			},
+			"k2": {
+				"name": "k2",
+				"value": "test"
+			}
+		}
+	}
+}`
+
+func TestPointerToPath(t *testing.T) {
+	schema, err := LoadSchema(docPatchSchema)
+	if err != nil {
+		t.Fatalf("failed to load schema, Error: %s", err)
+	}
+	pathMap := map[string]string{
+		"/attr1":            "attr1",
+		"/attr2/-":          "attr2",
+		"/attr2/1":          "attr2[1]",
+		"/attr3/k~01/value": "attr3[k~1]/value",
+		"/attr4/value":      "attr4/value",
+	}
+	for pointer, expected := range pathMap {
+		dataPath, e := Schema.PointerToPath(schema.Schema, pointer)
+		if e != nil {
+			t.Fatalf("failed to convert pointer [%s], Error: %s", pointer, e)
+		}
+		if dataPath != expected {
+			t.Fatalf("pointer [%s] expect path [%s], got [%s]", pointer, expected, dataPath)
+		}
+	}
+	for _, pointer := range []string{"", "attr1", "/attrX", "/attr1/sub", "/attr2/-/sub"} {
+		_, e := Schema.PointerToPath(schema.Schema, pointer)
+		if e == nil || e.Status != http.StatusBadRequest {
+			t.Fatalf("failed to reject invalid pointer [%s]", pointer)
+		}
+	}
+}
+
+func TestPatchPaths(t *testing.T) {
+	schema, err := LoadSchema(docPatchSchema)
+	if err != nil {
+		t.Fatalf("failed to load schema, Error: %s", err)
+	}
+	var jsonPatch interface{}
+	err = json.Unmarshal([]byte(`[
+		{"op": "replace", "path": "/attr3/k1/value", "value": "new"},
+		{"op": "move", "from": "/attr2/0", "path": "/attr1"}
+	]`), &jsonPatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pathList, e := Schema.JsonPatchPaths(schema.Schema, jsonPatch)
+	if e != nil {
+		t.Fatalf("failed to get paths of JSON patch, Error: %s", e)
+	}
+	pathBytes, _ := json.Marshal(pathList)
+	if string(pathBytes) != `["attr3[k1]/value","attr1","attr2[0]"]` {
+		t.Fatalf("invalid paths of JSON patch %s", pathBytes)
+	}
+	var mergePatch interface{}
+	err = json.Unmarshal([]byte(`{
+		"attr1": null,
+		"attr2": ["c"],
+		"attr3": {"k1": {"value": "new"}, "k2": null, "k3": {}},
+		"attr4": {"name": "a4", "value": "new"}
+	}`), &mergePatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pathList, e = Schema.MergePatchPaths(schema.Schema, mergePatch)
+	if e != nil {
+		t.Fatalf("failed to get paths of merge patch, Error: %s", e)
+	}
+	pathBytes, _ = json.Marshal(pathList)
+	if string(pathBytes) != `["attr1","attr2","attr3[k1]/value","attr3[k2]","attr3[k3]","attr4/name","attr4/value"]` {
+		t.Fatalf("invalid paths of merge patch %s", pathBytes)
+	}
+	_, e = Schema.MergePatchPaths(schema.Schema, map[string]interface{}{"attr4": map[string]interface{}{"attrX": 1}})
+	if e == nil || e.Status != http.StatusBadRequest {
+		t.Fatal("failed to reject merge patch of undefined attribute")
+	}
+}
+
+func TestApplyJsonPatch(t *testing.T) {
+	schema, err := LoadSchema(docPatchSchema)
+	if err != nil {
+		t.Fatalf("failed to load schema, Error: %s", err)
+	}
+	record, err := Record.LoadStr(docPatchRecord)
+	if err != nil {
+		t.Fatalf("failed to load record, Error: %s", err)
+	}
+	ops := []interface{}{}
+	json.Unmarshal([]byte(`[
+		{"op": "test", "path": "/attr3/k1/value", "value": "test"},
+		{"op": "add", "path": "/attr2/-", "value": "c"},
+		{"op": "remove", "path": "/attr2/a"},
+		{"op": "copy", "from": "/attr3/k2", "path": "/attr4"},
+		{"op": "move", "from": "/attr3/k2/value", "path": "/attr1"},
+		{"op": "add", "path": "/attr3/k3", "value": {"name": "k3"}}
+	]`), &ops)
+	e := Schema.ApplyJsonPatch(schema.Schema, record.Data, ops, "testRoot/test01")
+	if e != nil {
+		t.Fatalf("failed to apply json patch, Error: %s", e)
+	}
+	resultStr, _ := json.Marshal(record.Data)
+	expected := `{"attr1":"test","attr2":["b","c"],"attr3":{"k1":{"name":"k1","value":"test"},"k2":{"name":"k2"},"k3":{"name":"k3"}},"attr4":{"name":"k2","value":"test"}}`
+	if string(resultStr) != expected {
+		t.Fatalf("unexpected result of json patch, got %s", resultStr)
+	}
+	failOps := map[string]int{
+		`[{"op": "replace", "path": "/attr3/k4/value", "value": "x"}]`: http.StatusConflict,
+		`[{"op": "remove", "path": "/attr3/k2/value"}]`:                http.StatusConflict,
+		`[{"op": "test", "path": "/attr1", "value": "x"}]`:             http.StatusConflict,
+		`[{"op": "remove", "path": "/attr2/-"}]`:                       http.StatusBadRequest,
+		`[{"op": "add", "path": "/attr1", "value": null}]`:             http.StatusBadRequest,
+		`[{"op": "unknown", "path": "/attr1"}]`:                        http.StatusBadRequest,
+		`{"op": "remove", "path": "/attr1"}`:                           http.StatusBadRequest,
+	}
+	for opStr, status := range failOps {
+		var patch interface{}
+		json.Unmarshal([]byte(opStr), &patch)
+		e = Schema.ApplyJsonPatch(schema.Schema, record.Data, patch, "testRoot/test01")
+		if e == nil || e.Status != status {
+			t.Fatalf("expect status [%d] of patch %s, got %v", status, opStr, e)
+		}
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	schema, err := LoadSchema(docPatchSchema)
+	if err != nil {
+		t.Fatalf("failed to load schema, Error: %s", err)
+	}
+	record, err := Record.LoadStr(docPatchRecord)
+	if err != nil {
+		t.Fatalf("failed to load record, Error: %s", err)
+	}
+	var patch interface{}
+	json.Unmarshal([]byte(`{
+		"attr1": "ok",
+		"attr2": ["x"],
+		"attr3": {
+			"k1": null,
+			"k2": {"value": null},
+			"k3": {"name": "k3", "value": null}
+		},
+		"attr4": {"name": "k4", "value": "ok"}
+	}`), &patch)
+	e := Schema.ApplyMergePatch(schema.Schema, record.Data, patch, "testRoot/test01")
+	if e != nil {
+		t.Fatalf("failed to apply merge patch, Error: %s", e)
+	}
+	resultStr, _ := json.Marshal(record.Data)
+	expected := `{"attr1":"ok","attr2":["x"],"attr3":{"k2":{"name":"k2"},"k3":{"name":"k3"}},"attr4":{"name":"k4","value":"ok"}}`
+	if string(resultStr) != expected {
+		t.Fatalf("unexpected result of merge patch, got %s", resultStr)
+	}
+	json.Unmarshal([]byte(`{"attr4": {"value": null}, "attrX": null}`), &patch)
+	e = Schema.ApplyMergePatch(schema.Schema, record.Data, patch, "testRoot/test01")
+	if e == nil || e.Status != http.StatusBadRequest {
+		t.Fatalf("failed to reject merge patch on undefined attr")
+	}
+	json.Unmarshal([]byte(`{"attr4": {"value": null}}`), &patch)
+	e = Schema.ApplyMergePatch(schema.Schema, record.Data, patch, "testRoot/test01")
+	if e != nil {
+		t.Fatalf("failed to apply merge patch, Error: %s", e)
+	}
+	if _, ok := record.Data["attr4"].(map[string]interface{})["value"]; ok {
+		t.Fatalf("failed to remove attr4/value")
+	}
+}
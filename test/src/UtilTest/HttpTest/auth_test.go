@@ -0,0 +1,233 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package HttpErrorTest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+func TestAuthHandler(t *testing.T) {
+	auth, err := Http.NewAuthHandler(Http.AuthConfig{
+		Tokens: []Http.TokenConfig{
+			{Name: "admin", Token: "static-token", Roles: []string{"admin"}},
+		},
+		Hmac: &Http.HmacConfig{
+			Keys: map[string]string{"k1": "secret1"},
+		},
+		Mtls: &Http.MtlsConfig{
+			Clients: map[string][]string{"DataService01": {"peer"}},
+		},
+		Anonymous: []string{http.MethodGet},
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth handler. Error:%s", err)
+	}
+	var identity *Http.Identity
+	handler := auth.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		identity = Http.GetIdentity(r)
+		w.WriteHeader(http.StatusOK)
+	}, Http.Config{})
+	verifiedTls := func(name string) *tls.ConnectionState {
+		return &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: name}}}},
+		}
+	}
+	cases := []struct {
+		name   string
+		method string
+		token  string
+		tls    *tls.ConnectionState
+		status int
+		expect string
+	}{
+		{"anonymous get", http.MethodGet, "", nil, http.StatusOK, ""},
+		{"anonymous post", http.MethodPost, "", nil, http.StatusUnauthorized, ""},
+		{"static token", http.MethodPost, "static-token", nil, http.StatusOK, "admin"},
+		{"bad token on get", http.MethodGet, "wrong-token", nil, http.StatusUnauthorized, ""},
+		{"hmac token", http.MethodPost, Http.NewHmacToken("k1", "secret1", "bot", []string{"reader"}, time.Minute), nil, http.StatusOK, "bot"},
+		{"hmac unknown key", http.MethodPost, Http.NewHmacToken("k2", "secret1", "bot", nil, 0), nil, http.StatusUnauthorized, ""},
+		{"hmac bad secret", http.MethodPost, Http.NewHmacToken("k1", "secret2", "bot", nil, 0), nil, http.StatusUnauthorized, ""},
+		{"hmac expired", http.MethodPost, Http.NewHmacToken("k1", "secret1", "bot", nil, -time.Minute), nil, http.StatusUnauthorized, ""},
+		{"mtls", http.MethodPost, "", verifiedTls("DataService01"), http.StatusOK, "DataService01"},
+		{"mtls unknown client", http.MethodPost, "", verifiedTls("DataService02"), http.StatusUnauthorized, ""},
+	}
+	for _, c := range cases {
+		identity = nil
+		req := httptest.NewRequest(c.method, "/data_center", nil)
+		if c.token != "" {
+			req.Header.Set(Http.HeaderAuthorization, "Bearer "+c.token)
+		}
+		req.TLS = c.tls
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != c.status {
+			t.Fatalf("[%s] status=[%d], expect [%d]", c.name, w.Code, c.status)
+		}
+		if c.status == http.StatusUnauthorized && w.Header().Get(Http.HeaderWwwAuthenticate) == "" {
+			t.Fatalf("[%s] missing header [%s]", c.name, Http.HeaderWwwAuthenticate)
+		}
+		name := ""
+		if identity != nil {
+			name = identity.Name
+		}
+		if name != c.expect {
+			t.Fatalf("[%s] identity=[%s], expect [%s]", c.name, name, c.expect)
+		}
+	}
+	if identity != nil {
+		t.Fatalf("identity should be nil on rejected request")
+	}
+}
+
+func TestCredential(t *testing.T) {
+	auth, err := Http.NewAuthHandler(Http.AuthConfig{
+		Tokens: []Http.TokenConfig{
+			{Name: "inventory", Token: "inv-token"},
+		},
+		Hmac: &Http.HmacConfig{
+			Keys: map[string]string{"k1": "secret1"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth handler. Error:%s", err)
+	}
+	server := httptest.NewServer(auth.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		Http.ResponseJson(w, Http.GetIdentity(r), http.StatusOK, Http.Config{})
+	}, Http.Config{}))
+	defer server.Close()
+	_, code, ex := Http.GetRestData(server.URL)
+	if ex == nil || code != http.StatusUnauthorized {
+		t.Fatalf("failed to reject request without credential, code=[%d]", code)
+	}
+	cred := &Http.Credential{
+		Token: "inv-token",
+		Hmac: &Http.HmacIssuer{
+			KeyId:   "k1",
+			Secret:  "secret1",
+			Subject: "DataService01",
+			Ttl:     "1m",
+		},
+	}
+	for authType, expect := range map[string]string{
+		"":             "DataService01",
+		Http.AuthHmac:  "DataService01",
+		Http.AuthToken: "inventory",
+	} {
+		data, code, ex := Http.GetRestDataAuth(server.URL, cred.For(authType))
+		if ex != nil {
+			t.Fatalf("failed to call with credential of [%s], code=[%d]. Error:%s", authType, code, ex)
+		}
+		name, _ := data.(map[string]interface{})["name"].(string)
+		if name != expect {
+			t.Fatalf("identity of credential [%s] is [%s], expect [%s]", authType, name, expect)
+		}
+	}
+	_, code, ex = Http.SubmitPayloadAuth(server.URL, http.MethodPost, nil, map[string]interface{}{}, cred.For(Http.AuthToken))
+	if ex != nil || code != http.StatusOK {
+		t.Fatalf("failed to submit with credential, code=[%d]. Error:%v", code, ex)
+	}
+	_, code, _ = Http.SubmitPayloadAuth(server.URL, http.MethodPost, nil, map[string]interface{}{}, cred.For(Http.AuthMtls))
+	if code != http.StatusUnauthorized {
+		t.Fatalf("request without header credential should be rejected, code=[%d]", code)
+	}
+}
+
+func TestCredentialClient(t *testing.T) {
+	client, ex := (*Http.Credential)(nil).Client()
+	if ex != nil {
+		t.Fatalf("failed to get default client. Error:%s", ex)
+	}
+	other, _ := (&Http.Credential{Token: "t1"}).Client()
+	if client != other {
+		t.Fatalf("default client should be shared by credentials without certificate")
+	}
+	dir := t.TempDir()
+	cred := &Http.Credential{
+		CertFile: filepath.Join(dir, "client.crt"),
+		KeyFile:  filepath.Join(dir, "client.key"),
+	}
+	writeClientCert(t, cred.CertFile, cred.KeyFile)
+	client, ex = cred.For(Http.AuthMtls).Client()
+	if ex != nil {
+		t.Fatalf("failed to get client with certificate. Error:%s", ex)
+	}
+	other, ex = cred.For(Http.AuthMtls).Client()
+	if ex != nil || other != client {
+		t.Fatalf("client should be reused when certificate files are not modified")
+	}
+	modTime := time.Now().Add(time.Minute)
+	err := os.Chtimes(cred.CertFile, modTime, modTime)
+	if err != nil {
+		t.Fatalf("failed to touch certificate file. Error:%s", err)
+	}
+	other, ex = cred.Client()
+	if ex != nil || other == client {
+		t.Fatalf("client should be built again when certificate file is modified")
+	}
+}
+
+func writeClientCert(t *testing.T, certFile string, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key. Error:%s", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "DataService01"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate. Error:%s", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key. Error:%s", err)
+	}
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err == nil {
+		err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	}
+	if err != nil {
+		t.Fatalf("failed to write certificate files. Error:%s", err)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+************************************************************************************************************
+Copyright (c) 2022 Salesforce, Inc.
+All rights reserved.
+
+UniTAO was originally created in 2022 by Shai Herzog & Yi Huo as an
+Universal No-Coding Heterogeneous Infrastructure Maintenance & Inventory system that is holistically driven by open/community-developed semantic models/schemas.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>
+
+This copyright notice and license applies to all files in this directory or sub-directories, except when stated otherwise explicitly.
+************************************************************************************************************
+*/
+
+package HttpErrorTest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/salesforce/UniTAO/lib/Util/Http"
+)
+
+func TestWebSocket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := Http.UpgradeWebSocket(w, r, []string{"https://portal.example.com"})
+		if err != nil {
+			http.Error(w, err.Message[0], err.Status)
+			return
+		}
+		defer ws.Close()
+		for {
+			opcode, message, ex := ws.ReadMessage()
+			if ex != nil {
+				return
+			}
+			ws.WriteMessage(opcode, message)
+		}
+	}))
+	defer server.Close()
+	resp, ex := http.Get(server.URL)
+	if ex != nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("failed to reject request without upgrade, Error:%v", ex)
+	}
+	ws, err := Http.DialWebSocket(strings.Replace(server.URL, "http://", "ws://", 1), nil)
+	if err != nil {
+		t.Fatalf("failed to dial websocket. Error:%s", err)
+	}
+	defer ws.Close()
+	for _, size := range []int{0, 10, 125, 126, 65535, 65536} {
+		message := strings.Repeat("a", size)
+		if ex := ws.WriteText([]byte(message)); ex != nil {
+			t.Fatalf("failed to write message of size [%d]. Error:%s", size, ex)
+		}
+		opcode, echo, ex := ws.ReadMessage()
+		if ex != nil || opcode != Http.OpText || string(echo) != message {
+			t.Fatalf("invalid echo of size [%d], opcode=[%d], size=[%d], Error:%v", size, opcode, len(echo), ex)
+		}
+	}
+	if ex := ws.WriteMessage(Http.OpPing, []byte("ping")); ex != nil {
+		t.Fatalf("failed to ping. Error:%s", ex)
+	}
+	if ex := ws.WriteClose(Http.CloseNormal, "done"); ex != nil {
+		t.Fatalf("failed to close. Error:%s", ex)
+	}
+	_, _, ex = ws.ReadMessage()
+	if ex != io.EOF {
+		t.Fatalf("expect close from server, got %v", ex)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := Http.UpgradeWebSocket(w, r, []string{"https://portal.example.com"})
+		if err != nil {
+			http.Error(w, err.Message[0], err.Status)
+			return
+		}
+		ws.Close()
+	}))
+	defer server.Close()
+	wsUrl := strings.Replace(server.URL, "http://", "ws://", 1)
+	for origin, status := range map[string]int{
+		"":                           http.StatusSwitchingProtocols,
+		server.URL:                   http.StatusSwitchingProtocols,
+		"https://portal.example.com": http.StatusSwitchingProtocols,
+		"https://evil.example.com":   http.StatusForbidden,
+	} {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		ws, err := Http.DialWebSocket(wsUrl, header)
+		if status == http.StatusSwitchingProtocols {
+			if err != nil {
+				t.Fatalf("failed to dial websocket from origin=[%s]. Error:%s", origin, err)
+			}
+			ws.Close()
+			continue
+		}
+		if err == nil || err.Status != status {
+			t.Fatalf("expect status [%d] from origin=[%s], got %v", status, origin, err)
+		}
+	}
+}
@@ -34,6 +34,7 @@ import (
 	"Data"
 	"Data/DbIface"
 	"DataService/Config"
+	"DataService/Migrate"
 
 	"github.com/salesforce/UniTAO/lib/Util/CustomLogger"
 	"github.com/salesforce/UniTAO/lib/Util/Json"
@@ -45,6 +46,7 @@ type AdminArgs struct {
 	srvConfig Config.Confuguration
 	table     TableArgs
 	data      DataArgs
+	migrate   MigrateArgs
 	logPath   string
 }
 
@@ -58,9 +60,17 @@ type DataArgs struct {
 	file  string
 }
 
+type MigrateArgs struct {
+	target    string
+	tgtConfig Config.Confuguration
+	batch     int
+	state     string
+}
+
 const (
-	TABLE = "table"
-	DATA  = "data"
+	TABLE   = "table"
+	DATA    = "data"
+	MIGRATE = "migrate"
 )
 
 func ArgHandler() AdminArgs {
@@ -76,8 +86,15 @@ func ArgHandler() AdminArgs {
 	dataFile := dataCmd.String(DATA, "", "data file to be import into database")
 	dataLogPath := dataCmd.String("log", "", "path that hold log")
 
+	migrateCmd := flag.NewFlagSet(MIGRATE, flag.ExitOnError)
+	migrateDbConfig := migrateCmd.String("config", "", "source Data Service config")
+	migrateTarget := migrateCmd.String("target", "", "target Data Service config, records are copied into its database and tables")
+	migrateBatch := migrateCmd.Int("batch", Migrate.DefaultBatchSize, "number of records copied in one batch")
+	migrateState := migrateCmd.String("state", "", "file to save progress, rerun with the same file to resume")
+	migrateLogPath := migrateCmd.String("log", "", "path that hold log")
+
 	if len(os.Args) < 2 {
-		log.Fatal("expected [table, data]] subcommands")
+		log.Fatal("expected [table, data, migrate] subcommands")
 	}
 	args := AdminArgs{
 		cmd: os.Args[1],
@@ -111,6 +128,21 @@ func ArgHandler() AdminArgs {
 			tableCmd.Usage()
 			log.Fatalf("missing data file for %s", DATA)
 		}
+	case MIGRATE:
+		migrateCmd.Parse(os.Args[2:])
+		args.config = *migrateDbConfig
+		args.migrate.target = *migrateTarget
+		args.migrate.batch = *migrateBatch
+		args.migrate.state = *migrateState
+		args.logPath = *migrateLogPath
+		if args.config == "" {
+			migrateCmd.Usage()
+			log.Fatalf("missing configuration for %s", MIGRATE)
+		}
+		if args.migrate.target == "" {
+			migrateCmd.Usage()
+			log.Fatalf("missing target configuration for %s", MIGRATE)
+		}
 	default:
 		log.Fatalf("Unknown cmd=%s", args.cmd)
 	}
@@ -241,6 +273,32 @@ func ImportTables(db DbIface.Database, tableList []interface{}, args AdminArgs,
 	}
 }
 
+func MigrateData(db DbIface.Database, args AdminArgs, logger *log.Logger) {
+	target, err := Data.ConnectDb(args.migrate.tgtConfig.Database, logger)
+	if err != nil {
+		logger.Fatalf("failed to connect to target database, err:%s", err)
+	}
+	tables := map[string]string{}
+	tgtTables := args.migrate.tgtConfig.DataTable.Map()
+	for key, table := range args.srvConfig.DataTable.Map() {
+		tables[table.(string)] = tgtTables[key].(string)
+	}
+	migration, err := Migrate.New(db, target, tables, args.migrate.batch, args.migrate.state, logger)
+	if err != nil {
+		logger.Fatalf("failed to start migration, err:%s", err)
+	}
+	logger.Printf("migrate [%s]=>[%s]", db.Name(), target.Name())
+	err = migration.Run()
+	if err != nil {
+		logger.Fatalf("migration failed, rerun with the same state file to resume. err:%s", err)
+	}
+	err = migration.Verify()
+	if err != nil {
+		logger.Fatalf("migration verify failed, err:%s", err)
+	}
+	logger.Print("migration verified")
+}
+
 func main() {
 	args := ArgHandler()
 	log.Print("Admin tool for Data Service")
@@ -250,6 +308,12 @@ func main() {
 		log.Fatalf("failed to read configuration file=[%s], Err:%s", args.config, err)
 	}
 	args.srvConfig = config
+	if args.cmd == MIGRATE {
+		err = Config.Read(args.migrate.target, &args.migrate.tgtConfig)
+		if err != nil {
+			log.Fatalf("failed to read target configuration file=[%s], Err:%s", args.migrate.target, err)
+		}
+	}
 	logFile, logger, ex := CustomLogger.FileLoger(args.logPath, fmt.Sprintf("%s_admin", config.Http.Id))
 	if ex != nil {
 		log.Fatalf("failed to create log file @[%s]", args.logPath)
@@ -265,6 +329,8 @@ func main() {
 		CreateTables(database, args, logger)
 	case "data":
 		ImportData(database, args, logger)
+	case MIGRATE:
+		MigrateData(database, args, logger)
 	}
 	logger.Printf("Admin Operation %s completed", args.cmd)
 }